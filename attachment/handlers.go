@@ -0,0 +1,338 @@
+// attachment/handlers.go
+package attachment
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/imadbg01/go-todo/auth"
+	"github.com/imadbg01/go-todo/blob"
+	"github.com/imadbg01/go-todo/config"
+	"github.com/imadbg01/go-todo/openapi"
+	"github.com/imadbg01/go-todo/todo"
+	"github.com/jinzhu/gorm"
+)
+
+const defaultTypes = "image/*,text/plain,application/pdf,application/json,application/zip,application/x-gzip"
+
+type AttachmentHandler struct {
+	repository *AttachmentRepository
+	todos      *todo.TodoRepository
+	maxSize    int64
+	types      []string
+}
+
+func (handler *AttachmentHandler) GetAll(c *fiber.Ctx) error {
+	item, err := handler.todo(c)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"message": "Item not found",
+		})
+	}
+
+	return c.JSON(handler.repository.FindByTodo(item.ID))
+}
+
+func (handler *AttachmentHandler) Create(c *fiber.Ctx) error {
+	item, err := handler.todo(c)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"message": "Item not found",
+		})
+	}
+
+	form, err := c.MultipartForm()
+	if err != nil || len(form.File["file"]) == 0 {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  400,
+			"message": "Upload files in the multipart field \"file\"",
+		})
+	}
+
+	// Every file is checked before any is stored, so a rejected upload
+	// leaves nothing behind.
+	files := form.File["file"]
+	contentTypes := make([]string, len(files))
+	for i, file := range files {
+		if file.Size > handler.maxSize {
+			return c.Status(413).JSON(fiber.Map{
+				"status":  413,
+				"message": fmt.Sprintf("%s is larger than %d bytes", file.Filename, handler.maxSize),
+			})
+		}
+
+		contentType, err := detectType(file)
+		if err != nil || !handler.allowed(contentType) {
+			return c.Status(415).JSON(fiber.Map{
+				"status":  415,
+				"message": fmt.Sprintf("%s has unsupported type %s", file.Filename, contentType),
+			})
+		}
+		contentTypes[i] = contentType
+	}
+
+	created := []Attachment{}
+	for i, file := range files {
+		attachment, err := handler.repository.Create(c.Context(), Attachment{
+			TodoID:      item.ID,
+			Filename:    file.Filename,
+			ContentType: contentTypes[i],
+			Uploader:    auth.Actor(c),
+		}, file)
+
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"status":  500,
+				"message": "Failed storing attachment",
+				"error":   err.Error(),
+			})
+		}
+		created = append(created, attachment)
+	}
+
+	return c.Status(201).JSON(created)
+}
+
+// Download streams the attachment, honouring a single byte range.
+func (handler *AttachmentHandler) Download(c *fiber.Ctx) error {
+	attachment, err := handler.attachment(c)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"message": "Attachment not found",
+		})
+	}
+
+	offset, length, partial, ok := parseRange(c.Get(fiber.HeaderRange), attachment.Size)
+	if !ok {
+		c.Set(fiber.HeaderContentRange, fmt.Sprintf("bytes */%d", attachment.Size))
+		return c.SendStatus(416)
+	}
+
+	body, err := handler.repository.Open(c.Context(), attachment, offset, length)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  500,
+			"message": "Failed reading attachment",
+			"error":   err.Error(),
+		})
+	}
+
+	c.Set(fiber.HeaderContentType, attachment.ContentType)
+	c.Set(fiber.HeaderContentDisposition, mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename}))
+	c.Set(fiber.HeaderAcceptRanges, "bytes")
+	c.Set(fiber.HeaderETag, strconv.Quote(attachment.Hash))
+	if partial {
+		c.Set(fiber.HeaderContentRange, fmt.Sprintf("bytes %d-%d/%d", offset, offset+length-1, attachment.Size))
+		c.Status(206)
+	}
+	return c.SendStream(body, int(length))
+}
+
+func (handler *AttachmentHandler) Delete(c *fiber.Ctx) error {
+	attachment, err := handler.attachment(c)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"message": "Attachment not found",
+		})
+	}
+
+	if err := handler.repository.Delete(c.Context(), attachment); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  500,
+			"message": "Failed deleting attachment",
+			"error":   err.Error(),
+		})
+	}
+	return c.SendStatus(204)
+}
+
+func (handler *AttachmentHandler) todo(c *fiber.Ctx) (todo.Todo, error) {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return todo.Todo{}, err
+	}
+	return handler.todos.Find(id)
+}
+
+func (handler *AttachmentHandler) attachment(c *fiber.Ctx) (Attachment, error) {
+	item, err := handler.todo(c)
+	if err != nil {
+		return Attachment{}, err
+	}
+	id, err := strconv.Atoi(c.Params("attachmentId"))
+	if err != nil {
+		return Attachment{}, err
+	}
+	return handler.repository.Find(item.ID, id)
+}
+
+func (handler *AttachmentHandler) allowed(contentType string) bool {
+	for _, allowed := range handler.types {
+		if allowed == contentType || allowed == "*/*" {
+			return true
+		}
+		if strings.HasSuffix(allowed, "/*") && strings.HasPrefix(contentType, strings.TrimSuffix(allowed, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+// detectType sniffs the content type from the start of the file rather than
+// trusting the type the client declared.
+func detectType(file *multipart.FileHeader) (string, error) {
+	body, err := file.Open()
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+
+	head := make([]byte, 512)
+	n, _ := body.Read(head)
+	contentType, _, err := mime.ParseMediaType(http.DetectContentType(head[:n]))
+	return contentType, err
+}
+
+// parseRange resolves a Range header against a blob of the given size. A
+// missing or multi-range header selects the whole blob.
+func parseRange(header string, size int64) (offset, length int64, partial, ok bool) {
+	if !strings.HasPrefix(header, "bytes=") || strings.Contains(header, ",") {
+		return 0, size, false, true
+	}
+	bounds := strings.SplitN(strings.TrimPrefix(header, "bytes="), "-", 2)
+	if len(bounds) != 2 {
+		return 0, 0, false, false
+	}
+
+	start, end := strings.TrimSpace(bounds[0]), strings.TrimSpace(bounds[1])
+	switch {
+	case start == "":
+		n, err := strconv.ParseInt(end, 10, 64)
+		if err != nil || n <= 0 {
+			return 0, 0, false, false
+		}
+		if n > size {
+			n = size
+		}
+		offset = size - n
+	default:
+		n, err := strconv.ParseInt(start, 10, 64)
+		if err != nil || n >= size {
+			return 0, 0, false, false
+		}
+		offset = n
+	}
+
+	last := size - 1
+	if start != "" && end != "" {
+		n, err := strconv.ParseInt(end, 10, 64)
+		if err != nil || n < offset {
+			return 0, 0, false, false
+		}
+		if n < last {
+			last = n
+		}
+	}
+	return offset, last - offset + 1, true, true
+}
+
+// MaxSize is the largest accepted upload in bytes, from ATTACHMENT_MAX_SIZE.
+func MaxSize() int64 {
+	size, err := strconv.ParseInt(config.ConfigOr("ATTACHMENT_MAX_SIZE", "10485760"), 10, 64)
+	if err != nil {
+		return 10 << 20
+	}
+	return size
+}
+
+// Retention is how long the attachments of a deleted todo are kept, from
+// ATTACHMENT_RETENTION, so that restoring the todo brings them back.
+func Retention() time.Duration {
+	retention, err := time.ParseDuration(config.ConfigOr("ATTACHMENT_RETENTION", "720h"))
+	if err != nil {
+		return 720 * time.Hour
+	}
+	return retention
+}
+
+func NewAttachmentHandler(repository *AttachmentRepository, todos *todo.TodoRepository) *AttachmentHandler {
+	return &AttachmentHandler{
+		repository: repository,
+		todos:      todos,
+		maxSize:    MaxSize(),
+		types:      strings.Split(config.ConfigOr("ATTACHMENT_TYPES", defaultTypes), ","),
+	}
+}
+
+// Start purges the attachments of long deleted todos and the blobs no
+// longer used every hour, until the context is done.
+func Start(ctx context.Context, database *gorm.DB) {
+	store, err := blob.FromConfig()
+	if err != nil {
+		panic("failed to open blob store: " + err.Error())
+	}
+
+	database.AutoMigrate(&Attachment{}, &Blob{})
+	attachmentRepository := NewAttachmentRepository(database, store)
+
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := attachmentRepository.Purge(ctx, time.Now().Add(-Retention())); err != nil {
+					log.Printf("attachment: purge failed: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// describe documents the routes of the handler in the OpenAPI document.
+func describe(handler *AttachmentHandler) {
+	openapi.Describe(handler.GetAll, openapi.Doc{Summary: "List the attachments of a todo", Result: []Attachment{}, Errors: []int{404}})
+	openapi.Describe(handler.Create, openapi.Doc{
+		Summary:     "Upload files to a todo",
+		Description: "The files are sent in the multipart field \"file\".",
+		BodyTypes:   []string{fiber.MIMEMultipartForm},
+		Result:      []Attachment{},
+		Status:      201,
+		Errors:      []int{400, 404, 413, 415, 500},
+	})
+	openapi.Describe(handler.Download, openapi.Doc{
+		Summary:     "Download an attachment",
+		Headers:     []*openapi.Parameter{{Name: "Range", Description: "A single byte range", Schema: openapi.Type("string")}},
+		ResultTypes: []string{"application/octet-stream"},
+		Errors:      []int{404, 416, 500},
+	})
+	openapi.Describe(handler.Delete, openapi.Doc{Summary: "Delete an attachment", Status: 204, Errors: []int{404, 500}})
+}
+
+func Register(router fiber.Router, database *gorm.DB) {
+	store, err := blob.FromConfig()
+	if err != nil {
+		panic("failed to open blob store: " + err.Error())
+	}
+
+	database.AutoMigrate(&Attachment{}, &Blob{})
+	attachmentRepository := NewAttachmentRepository(database, store)
+	attachmentHandler := NewAttachmentHandler(attachmentRepository, todo.NewTodoRepository(database))
+	describe(attachmentHandler)
+
+	attachmentRouter := router.Group("/todo/:id/attachments")
+	attachmentRouter.Get("/", attachmentHandler.GetAll)
+	attachmentRouter.Post("/", attachmentHandler.Create)
+	attachmentRouter.Get("/:attachmentId", attachmentHandler.Download)
+	attachmentRouter.Delete("/:attachmentId", attachmentHandler.Delete)
+}
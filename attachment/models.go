@@ -0,0 +1,31 @@
+// attachment/models.go
+package attachment
+
+import "time"
+
+type Attachment struct {
+	ID          uint      `gorm:"primary_key" json:"id"`
+	TodoID      uint      `gorm:"Not Null;index" json:"todo_id"`
+	Filename    string    `gorm:"Not Null" json:"filename"`
+	ContentType string    `gorm:"Not Null" json:"content_type"`
+	Size        int64     `json:"size"`
+	Hash        string    `gorm:"Not Null;index" json:"hash"`
+	Uploader    string    `json:"uploader"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// BlobKey is where the content of the attachment is stored. Attachments with
+// the same content share one blob.
+func (attachment Attachment) BlobKey() string {
+	return "sha256/" + attachment.Hash[:2] + "/" + attachment.Hash
+}
+
+// Blob is a row per stored content. Attaching and collecting a blob lock
+// its row, so a blob is never deleted while an upload is referring to it.
+// OrphanedAt is set once the last attachment using the blob is deleted, and
+// the purge removes the content and then the row.
+type Blob struct {
+	Hash       string     `gorm:"primary_key" json:"hash"`
+	OrphanedAt *time.Time `gorm:"index" json:"orphaned_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
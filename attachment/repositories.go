@@ -0,0 +1,194 @@
+// attachment/repositories.go
+package attachment
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"mime/multipart"
+	"time"
+
+	"github.com/imadbg01/go-todo/blob"
+	"github.com/jinzhu/gorm"
+)
+
+type AttachmentRepository struct {
+	database *gorm.DB
+	store    blob.Store
+}
+
+func (repository *AttachmentRepository) FindByTodo(todoID uint) []Attachment {
+	attachments := []Attachment{}
+	repository.database.Where("todo_id = ?", todoID).Order("id asc").Find(&attachments)
+	return attachments
+}
+
+func (repository *AttachmentRepository) Find(todoID uint, id int) (Attachment, error) {
+	var attachment Attachment
+	err := repository.database.Where("todo_id = ?", todoID).First(&attachment, id).Error
+	if gorm.IsRecordNotFoundError(err) {
+		err = errors.New("Attachment not found")
+	}
+	return attachment, err
+}
+
+// Create stores the uploaded file, reusing the blob of an earlier upload
+// with the same content.
+func (repository *AttachmentRepository) Create(ctx context.Context, attachment Attachment, file *multipart.FileHeader) (Attachment, error) {
+	hash, err := hashFile(file)
+	if err != nil {
+		return attachment, err
+	}
+	attachment.Hash = hash
+	attachment.Size = file.Size
+
+	err = repository.database.Transaction(func(tx *gorm.DB) error {
+		if err := lockBlob(tx, attachment.Hash); err != nil {
+			return err
+		}
+		if err := tx.Model(&Blob{}).Where("hash = ?", attachment.Hash).UpdateColumn("orphaned_at", gorm.Expr("NULL")).Error; err != nil {
+			return err
+		}
+		exists, err := repository.store.Exists(ctx, attachment.BlobKey())
+		if err != nil {
+			return err
+		}
+		if !exists {
+			body, err := file.Open()
+			if err != nil {
+				return err
+			}
+			defer body.Close()
+			if err := repository.store.Put(ctx, attachment.BlobKey(), body, file.Size); err != nil {
+				return err
+			}
+		}
+		return tx.Create(&attachment).Error
+	})
+	return attachment, err
+}
+
+func (repository *AttachmentRepository) Open(ctx context.Context, attachment Attachment, offset, length int64) (io.ReadCloser, error) {
+	return repository.store.Get(ctx, attachment.BlobKey(), offset, length)
+}
+
+// Delete removes the attachment. A blob no other attachment refers to is
+// marked orphaned and left for the purge to remove, so the content is never
+// gone while a row that was not committed away still refers to it.
+func (repository *AttachmentRepository) Delete(ctx context.Context, attachment Attachment) error {
+	return repository.database.Transaction(func(tx *gorm.DB) error {
+		if err := lockBlob(tx, attachment.Hash); err != nil {
+			return err
+		}
+		if err := tx.Delete(&attachment).Error; err != nil {
+			return err
+		}
+		var count int
+		if err := tx.Model(&Attachment{}).Where("hash = ?", attachment.Hash).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return nil
+		}
+		return tx.Model(&Blob{}).Where("hash = ?", attachment.Hash).UpdateColumn("orphaned_at", time.Now()).Error
+	})
+}
+
+// Purge removes the attachments of todos deleted before the given time, or
+// no longer stored at all, and then the orphaned blobs. Attachments of
+// recently deleted todos are kept so that restoring the todo brings them
+// back.
+func (repository *AttachmentRepository) Purge(ctx context.Context, deletedBefore time.Time) error {
+	var attachments []Attachment
+	err := repository.database.
+		Joins("LEFT JOIN todos ON todos.id = attachments.todo_id").
+		Where("todos.id IS NULL OR todos.deleted_at < ?", deletedBefore).
+		Order("attachments.id asc").
+		Find(&attachments).Error
+	if err != nil {
+		return err
+	}
+	for _, attachment := range attachments {
+		if err := repository.Delete(ctx, attachment); err != nil {
+			return err
+		}
+	}
+
+	var hashes []string
+	if err := repository.database.Model(&Blob{}).Where("orphaned_at IS NOT NULL").Pluck("hash", &hashes).Error; err != nil {
+		return err
+	}
+	for _, hash := range hashes {
+		if err := repository.collect(ctx, hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// collect removes an orphaned blob from the store, then its row. Its row is
+// locked throughout, so an upload of the same content waits and stores it
+// again. Should the transaction fail after the content is gone, the row is
+// still marked orphaned and no attachment refers to it, so the next purge
+// finishes the job and an upload meanwhile stores the content again.
+func (repository *AttachmentRepository) collect(ctx context.Context, hash string) error {
+	return repository.database.Transaction(func(tx *gorm.DB) error {
+		if err := lockBlob(tx, hash); err != nil {
+			return err
+		}
+		var blob Blob
+		if err := tx.First(&blob, "hash = ?", hash).Error; err != nil {
+			return err
+		}
+		if blob.OrphanedAt == nil {
+			return nil
+		}
+		var count int
+		if err := tx.Model(&Attachment{}).Where("hash = ?", hash).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return tx.Model(&Blob{}).Where("hash = ?", hash).UpdateColumn("orphaned_at", gorm.Expr("NULL")).Error
+		}
+		if err := repository.store.Delete(ctx, Attachment{Hash: hash}.BlobKey()); err != nil {
+			return err
+		}
+		return tx.Delete(&Blob{Hash: hash}).Error
+	})
+}
+
+// lockBlob locks the row of a blob for the rest of the transaction,
+// creating it for blobs stored before rows were kept.
+func lockBlob(tx *gorm.DB, hash string) error {
+	err := tx.Set("gorm:insert_option", "ON CONFLICT DO NOTHING").Create(&Blob{Hash: hash}).Error
+	if err != nil {
+		return err
+	}
+	if tx.Dialect().GetName() == "postgres" {
+		tx = tx.Set("gorm:query_option", "FOR UPDATE")
+	}
+	return tx.First(&Blob{}, "hash = ?", hash).Error
+}
+
+func hashFile(file *multipart.FileHeader) (string, error) {
+	body, err := file.Open()
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, body); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func NewAttachmentRepository(database *gorm.DB, store blob.Store) *AttachmentRepository {
+	return &AttachmentRepository{
+		database: database,
+		store:    store,
+	}
+}
@@ -0,0 +1,167 @@
+// attachment/repositories_test.go
+package attachment
+
+import (
+	"bytes"
+	"context"
+	"mime/multipart"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/imadbg01/go-todo/blob"
+	"github.com/imadbg01/go-todo/outbox"
+	"github.com/imadbg01/go-todo/todo"
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+)
+
+// newTestRepository returns a repository on a fresh SQLite database and a
+// local store, with a todo to attach to.
+func newTestRepository(t *testing.T) (*AttachmentRepository, todo.Todo) {
+	database, err := gorm.Open("sqlite3", filepath.Join(t.TempDir(), "attachment.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	database.DB().SetMaxOpenConns(1)
+	t.Cleanup(func() { database.Close() })
+	database.AutoMigrate(&outbox.Message{})
+	todo.Register(fiber.New(), database)
+	database.AutoMigrate(&Attachment{}, &Blob{})
+
+	store, err := blob.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	item, err := todo.NewTodoRepository(database).Create(todo.Todo{Name: "Report"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewAttachmentRepository(database, store), item
+}
+
+// upload returns the header of a file uploaded in a multipart form.
+func upload(t *testing.T, name, content string) *multipart.FileHeader {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("file", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write([]byte(content))
+	writer.Close()
+
+	form, err := multipart.NewReader(&body, writer.Boundary()).ReadForm(1 << 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { form.RemoveAll() })
+	return form.File["file"][0]
+}
+
+func stored(t *testing.T, repository *AttachmentRepository, attachment Attachment) bool {
+	exists, err := repository.store.Exists(context.Background(), attachment.BlobKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return exists
+}
+
+func TestDeleteLeavesBlobForPurge(t *testing.T) {
+	repository, item := newTestRepository(t)
+	ctx := context.Background()
+
+	first, err := repository.Create(ctx, Attachment{TodoID: item.ID, Filename: "a.txt"}, upload(t, "a.txt", "same"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := repository.Create(ctx, Attachment{TodoID: item.ID, Filename: "b.txt"}, upload(t, "b.txt", "same"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repository.Delete(ctx, first); err != nil {
+		t.Fatal(err)
+	}
+	if err := repository.Purge(ctx, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if !stored(t, repository, second) {
+		t.Fatal("blob removed while another attachment uses it")
+	}
+
+	if err := repository.Delete(ctx, second); err != nil {
+		t.Fatal(err)
+	}
+	if !stored(t, repository, second) {
+		t.Fatal("blob removed before the purge")
+	}
+	if err := repository.Purge(ctx, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if stored(t, repository, second) {
+		t.Fatal("orphaned blob still stored after the purge")
+	}
+	var count int
+	repository.database.Model(&Blob{}).Count(&count)
+	if count != 0 {
+		t.Fatalf("%d blob rows after the purge, want 0", count)
+	}
+}
+
+func TestUploadRescuesOrphanedBlob(t *testing.T) {
+	repository, item := newTestRepository(t)
+	ctx := context.Background()
+
+	first, err := repository.Create(ctx, Attachment{TodoID: item.ID, Filename: "a.txt"}, upload(t, "a.txt", "same"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repository.Delete(ctx, first); err != nil {
+		t.Fatal(err)
+	}
+	second, err := repository.Create(ctx, Attachment{TodoID: item.ID, Filename: "b.txt"}, upload(t, "b.txt", "same"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repository.Purge(ctx, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if !stored(t, repository, second) {
+		t.Fatal("purge removed a blob uploaded again")
+	}
+	var blob Blob
+	if err := repository.database.First(&blob, "hash = ?", second.Hash).Error; err != nil {
+		t.Fatal(err)
+	}
+	if blob.OrphanedAt != nil {
+		t.Fatal("blob still marked orphaned after an upload")
+	}
+}
+
+func TestPurgeKeepsRecentlyDeletedTodos(t *testing.T) {
+	repository, item := newTestRepository(t)
+	ctx := context.Background()
+
+	attachment, err := repository.Create(ctx, Attachment{TodoID: item.ID, Filename: "a.txt"}, upload(t, "a.txt", "kept"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	repository.database.Delete(&item)
+
+	if err := repository.Purge(ctx, time.Now().Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if len(repository.FindByTodo(item.ID)) != 1 || !stored(t, repository, attachment) {
+		t.Fatal("attachment of a recently deleted todo purged")
+	}
+
+	if err := repository.Purge(ctx, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if len(repository.FindByTodo(item.ID)) != 0 || stored(t, repository, attachment) {
+		t.Fatal("attachment of a long deleted todo kept")
+	}
+}
@@ -0,0 +1,24 @@
+// auth/auth.go
+package auth
+
+import "github.com/gofiber/fiber/v2"
+
+// Anonymous is the actor recorded for requests that carry no identity.
+const Anonymous = "anonymous"
+
+// Actor returns the user a request acts on behalf of. The identity is set by
+// the proxy in front of the API in the X-User header, and only believed from
+// the TrustedProxies of the app: any other client could name any user.
+func Actor(c *fiber.Ctx) string {
+	if user := c.Get("X-User"); user != "" && FromTrustedProxy(c) {
+		return user
+	}
+	return Anonymous
+}
+
+// FromTrustedProxy tells whether the request came from one of the
+// TrustedProxies of the app. Without EnableTrustedProxyCheck no client is
+// trusted.
+func FromTrustedProxy(c *fiber.Ctx) bool {
+	return c.App().Config().EnableTrustedProxyCheck && c.IsProxyTrusted()
+}
@@ -0,0 +1,79 @@
+// auth/auth_test.go
+package auth
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func actorOf(t *testing.T, config fiber.Config) string {
+	app := fiber.New(config)
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString(Actor(c))
+	})
+	request := httptest.NewRequest("GET", "/", nil)
+	request.Header.Set("X-User", "alice")
+	response, err := app.Test(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(response.Body)
+	return string(body)
+}
+
+func TestActor(t *testing.T) {
+	// app.Test serves requests from 0.0.0.0.
+	if actor := actorOf(t, fiber.Config{EnableTrustedProxyCheck: true, TrustedProxies: []string{"0.0.0.0"}}); actor != "alice" {
+		t.Errorf("actor from a trusted proxy = %q, want alice", actor)
+	}
+	if actor := actorOf(t, fiber.Config{EnableTrustedProxyCheck: true, TrustedProxies: []string{"10.0.0.1"}}); actor != Anonymous {
+		t.Errorf("actor from another address = %q, want %q", actor, Anonymous)
+	}
+	if actor := actorOf(t, fiber.Config{}); actor != Anonymous {
+		t.Errorf("actor without trusted proxies = %q, want %q", actor, Anonymous)
+	}
+}
+
+func TestOriginsAllow(t *testing.T) {
+	origins := Origins{"https://app.example.com"}
+	for _, test := range []struct {
+		origin, host string
+		allow        bool
+	}{
+		{"", "api.example.com", true},
+		{"https://api.example.com", "api.example.com", true},
+		{"http://localhost:5000", "localhost:5000", true},
+		{"https://app.example.com", "api.example.com", true},
+		{"https://evil.example.com", "api.example.com", false},
+		{"https://api.example.com.evil.com", "api.example.com", false},
+		{"null", "api.example.com", false},
+	} {
+		if allow := origins.Allow(test.origin, test.host); allow != test.allow {
+			t.Errorf("Allow(%q, %q) = %v, want %v", test.origin, test.host, allow, test.allow)
+		}
+	}
+	if !(Origins{"*"}).Allow("https://evil.example.com", "api.example.com") {
+		t.Error("* does not allow every origin")
+	}
+}
+
+func TestProxiesTrust(t *testing.T) {
+	proxies := Proxies{"10.0.0.1", "192.168.0.0/16"}
+	for ip, trust := range map[string]bool{
+		"10.0.0.1":    true,
+		"10.0.0.2":    false,
+		"192.168.4.2": true,
+		"::1":         false,
+	} {
+		if got := proxies.Trust(net.ParseIP(ip)); got != trust {
+			t.Errorf("Trust(%s) = %v, want %v", ip, got, trust)
+		}
+	}
+	if proxies.Trust(nil) {
+		t.Error("trusted a peer without an IP")
+	}
+}
@@ -0,0 +1,42 @@
+// auth/origin.go
+package auth
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/imadbg01/go-todo/config"
+)
+
+// Origins are the web origins, besides the API's own host, whose pages may
+// open connections that carry the user's credentials, such as WebSockets,
+// which browsers do not hold to the same-origin policy.
+type Origins []string
+
+// OriginsFromConfig reads the comma separated ALLOWED_ORIGINS, for example
+// "https://app.example.com". A single * allows every origin.
+func OriginsFromConfig() Origins {
+	var origins Origins
+	for _, origin := range strings.Split(config.Config("ALLOWED_ORIGINS"), ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins = append(origins, strings.TrimSuffix(origin, "/"))
+		}
+	}
+	return origins
+}
+
+// Allow tells whether a request from a page at origin to host may proceed.
+// Requests without an Origin header do not come from a browser page and are
+// allowed.
+func (origins Origins) Allow(origin, host string) bool {
+	if origin == "" {
+		return true
+	}
+	for _, allowed := range origins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	parsed, err := url.Parse(origin)
+	return err == nil && parsed.Host != "" && strings.EqualFold(parsed.Host, host)
+}
@@ -0,0 +1,42 @@
+// auth/proxy.go
+package auth
+
+import (
+	"net"
+	"strings"
+
+	"github.com/imadbg01/go-todo/config"
+)
+
+// Proxies are the addresses of the proxies in front of the API, as single
+// IPs or CIDR ranges. Only requests that come through them name their user.
+type Proxies []string
+
+// ProxiesFromConfig reads the comma separated TRUSTED_PROXIES, for example
+// "10.0.0.1,10.1.0.0/16". Fiber is given the same list for HTTP requests.
+func ProxiesFromConfig() Proxies {
+	var proxies Proxies
+	for _, proxy := range strings.Split(config.Config("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
+}
+
+// Trust tells whether a connection from ip comes from one of the proxies.
+func (proxies Proxies) Trust(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, proxy := range proxies {
+		if _, network, err := net.ParseCIDR(proxy); err == nil {
+			if network.Contains(ip) {
+				return true
+			}
+		} else if ip.Equal(net.ParseIP(proxy)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,182 @@
+// blob/s3.go
+package blob
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+type S3Options struct {
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+}
+
+// S3Store keeps blobs in a bucket of any S3-compatible service, such as
+// MinIO, using path-style requests signed with AWS Signature Version 4.
+type S3Store struct {
+	options S3Options
+	client  *http.Client
+}
+
+const unsignedPayload = "UNSIGNED-PAYLOAD"
+
+func (store *S3Store) Put(ctx context.Context, key string, body io.Reader, size int64) error {
+	req, err := store.request(ctx, http.MethodPut, key, body)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	res, err := store.do(req)
+	if err != nil {
+		return err
+	}
+	return res.Body.Close()
+}
+
+func (store *S3Store) Get(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	req, err := store.request(ctx, http.MethodGet, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case length >= 0:
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+length-1))
+	case offset > 0:
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	res, err := store.do(req)
+	if err != nil {
+		return nil, err
+	}
+	return res.Body, nil
+}
+
+func (store *S3Store) Exists(ctx context.Context, key string) (bool, error) {
+	req, err := store.request(ctx, http.MethodHead, key, nil)
+	if err != nil {
+		return false, err
+	}
+	res, err := store.do(req)
+	if err == ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, res.Body.Close()
+}
+
+func (store *S3Store) Delete(ctx context.Context, key string) error {
+	req, err := store.request(ctx, http.MethodDelete, key, nil)
+	if err != nil {
+		return err
+	}
+	res, err := store.do(req)
+	if err == ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return res.Body.Close()
+}
+
+func (store *S3Store) request(ctx context.Context, method string, key string, body io.Reader) (*http.Request, error) {
+	endpoint := strings.TrimRight(store.options.Endpoint, "/")
+	return http.NewRequestWithContext(ctx, method, endpoint+"/"+store.options.Bucket+"/"+escapePath(key), body)
+}
+
+func (store *S3Store) do(req *http.Request) (*http.Response, error) {
+	store.sign(req, time.Now().UTC())
+	res, err := store.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode == http.StatusNotFound {
+		res.Body.Close()
+		return nil, ErrNotFound
+	}
+	if res.StatusCode >= 300 {
+		message, _ := ioutil.ReadAll(io.LimitReader(res.Body, 1024))
+		res.Body.Close()
+		return nil, fmt.Errorf("s3 %s %s: %s: %s", req.Method, req.URL.Path, res.Status, message)
+	}
+	return res, nil
+}
+
+// sign adds an AWS Signature Version 4 Authorization header to req.
+func (store *S3Store) sign(req *http.Request, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", unsignedPayload)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalHeaders := "host:" + req.URL.Host + "\n" +
+		"x-amz-content-sha256:" + unsignedPayload + "\n" +
+		"x-amz-date:" + amzDate + "\n"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.Query().Encode(),
+		canonicalHeaders,
+		signedHeaders,
+		unsignedPayload,
+	}, "\n")
+
+	scope := date + "/" + store.options.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hashHex(canonicalRequest)
+
+	key := hmacSHA256([]byte("AWS4"+store.options.SecretKey), date)
+	key = hmacSHA256(key, store.options.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		store.options.AccessKey, scope, signedHeaders, signature,
+	))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func hashHex(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+func escapePath(key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+func NewS3Store(options S3Options) (*S3Store, error) {
+	if options.Endpoint == "" || options.Bucket == "" {
+		return nil, errors.New("S3_ENDPOINT and S3_BUCKET are required")
+	}
+	return &S3Store{
+		options: options,
+		client:  &http.Client{Timeout: 5 * time.Minute},
+	}, nil
+}
@@ -0,0 +1,41 @@
+// blob/store.go
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/imadbg01/go-todo/config"
+)
+
+var ErrNotFound = errors.New("blob not found")
+
+// Store keeps immutable blobs addressed by key.
+type Store interface {
+	Put(ctx context.Context, key string, body io.Reader, size int64) error
+	// Get streams length bytes of the blob starting at offset; a negative
+	// length reads to the end.
+	Get(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error)
+	Exists(ctx context.Context, key string) (bool, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// FromConfig builds the store selected by BLOB_DRIVER.
+func FromConfig() (Store, error) {
+	switch driver := config.ConfigOr("BLOB_DRIVER", "local"); driver {
+	case "local":
+		return NewLocalStore(config.ConfigOr("BLOB_PATH", "data/blobs"))
+	case "s3":
+		return NewS3Store(S3Options{
+			Endpoint:  config.Config("S3_ENDPOINT"),
+			Region:    config.ConfigOr("S3_REGION", "us-east-1"),
+			Bucket:    config.Config("S3_BUCKET"),
+			AccessKey: config.Config("S3_ACCESS_KEY"),
+			SecretKey: config.Config("S3_SECRET_KEY"),
+		})
+	default:
+		return nil, fmt.Errorf("unknown blob driver %q", driver)
+	}
+}
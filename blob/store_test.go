@@ -0,0 +1,227 @@
+// blob/store_test.go
+package blob
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testStore runs the behaviour every Store has to share.
+func testStore(t *testing.T, store Store) {
+	ctx := context.Background()
+	key := "sha256/ab/abc def"
+	content := []byte("0123456789")
+
+	if exists, err := store.Exists(ctx, key); err != nil || exists {
+		t.Fatalf("Exists before Put = %v, %v", exists, err)
+	}
+	if _, err := store.Get(ctx, key, 0, -1); err != ErrNotFound {
+		t.Fatalf("Get before Put = %v, want ErrNotFound", err)
+	}
+	if err := store.Put(ctx, key, bytes.NewReader(content), int64(len(content))); err != nil {
+		t.Fatal(err)
+	}
+	if exists, err := store.Exists(ctx, key); err != nil || !exists {
+		t.Fatalf("Exists after Put = %v, %v", exists, err)
+	}
+
+	ranges := []struct {
+		offset, length int64
+		want           string
+	}{
+		{0, -1, "0123456789"},
+		{3, -1, "3456789"},
+		{2, 4, "2345"},
+		{9, 1, "9"},
+	}
+	for _, r := range ranges {
+		body, err := store.Get(ctx, key, r.offset, r.length)
+		if err != nil {
+			t.Fatalf("Get(%d, %d): %v", r.offset, r.length, err)
+		}
+		got, err := ioutil.ReadAll(body)
+		body.Close()
+		if err != nil || string(got) != r.want {
+			t.Errorf("Get(%d, %d) = %q, %v, want %q", r.offset, r.length, got, err, r.want)
+		}
+	}
+
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	if exists, err := store.Exists(ctx, key); err != nil || exists {
+		t.Fatalf("Exists after Delete = %v, %v", exists, err)
+	}
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete of a missing blob = %v", err)
+	}
+}
+
+func TestLocalStore(t *testing.T) {
+	store, err := NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	testStore(t, store)
+
+	if err := store.Put(context.Background(), "../escape", strings.NewReader("x"), 1); err == nil {
+		t.Error("Put outside the root succeeded")
+	}
+}
+
+func TestS3Store(t *testing.T) {
+	fake := newFakeS3("bucket", "access", "secret", "eu-west-1")
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	store, err := NewS3Store(S3Options{
+		Endpoint:  server.URL,
+		Region:    "eu-west-1",
+		Bucket:    "bucket",
+		AccessKey: "access",
+		SecretKey: "secret",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testStore(t, store)
+
+	store.options.SecretKey = "wrong"
+	if _, err := store.Exists(context.Background(), "key"); err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("Exists with a wrong secret = %v, want 403", err)
+	}
+}
+
+func TestNewS3StoreRequiresEndpointAndBucket(t *testing.T) {
+	if _, err := NewS3Store(S3Options{Endpoint: "http://localhost:9000"}); err == nil {
+		t.Error("NewS3Store without a bucket succeeded")
+	}
+}
+
+// fakeS3 is a stand-in for an S3-compatible service. It keeps objects in
+// memory, checks Signature Version 4 from what the server received and
+// serves single byte ranges.
+type fakeS3 struct {
+	bucket, accessKey, secretKey, region string
+
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func newFakeS3(bucket, accessKey, secretKey, region string) *fakeS3 {
+	return &fakeS3{
+		bucket:    bucket,
+		accessKey: accessKey,
+		secretKey: secretKey,
+		region:    region,
+		objects:   map[string][]byte{},
+	}
+}
+
+func (fake *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := fake.verify(r); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	prefix := "/" + fake.bucket + "/"
+	if !strings.HasPrefix(r.URL.Path, prefix) {
+		http.Error(w, "no such bucket", http.StatusNotFound)
+		return
+	}
+	key := strings.TrimPrefix(r.URL.Path, prefix)
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	object, ok := fake.objects[key]
+	switch r.Method {
+	case http.MethodPut:
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil || int64(len(body)) != r.ContentLength {
+			http.Error(w, "incomplete body", http.StatusBadRequest)
+			return
+		}
+		fake.objects[key] = body
+	case http.MethodHead, http.MethodGet:
+		if !ok {
+			http.Error(w, "no such key", http.StatusNotFound)
+			return
+		}
+		if r.Method == http.MethodGet {
+			start, end, err := byteRange(r.Header.Get("Range"), int64(len(object)))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusRequestedRangeNotSatisfiable)
+				return
+			}
+			w.Write(object[start : end+1])
+		}
+	case http.MethodDelete:
+		delete(fake.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (fake *fakeS3) verify(r *http.Request) error {
+	amzDate := r.Header.Get("X-Amz-Date")
+	now, err := time.Parse("20060102T150405Z", amzDate)
+	if err != nil {
+		return fmt.Errorf("bad X-Amz-Date %q", amzDate)
+	}
+	scope := now.Format("20060102") + "/" + fake.region + "/s3/aws4_request"
+	canonicalRequest := strings.Join([]string{
+		r.Method,
+		r.URL.EscapedPath(),
+		r.URL.Query().Encode(),
+		"host:" + r.Host + "\nx-amz-content-sha256:" + r.Header.Get("X-Amz-Content-Sha256") + "\nx-amz-date:" + amzDate + "\n",
+		"host;x-amz-content-sha256;x-amz-date",
+		r.Header.Get("X-Amz-Content-Sha256"),
+	}, "\n")
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hashHex(canonicalRequest)
+
+	key := hmacSHA256([]byte("AWS4"+fake.secretKey), now.Format("20060102"))
+	key = hmacSHA256(key, fake.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	want := fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=host;x-amz-content-sha256;x-amz-date, Signature=%s",
+		fake.accessKey, scope, hex.EncodeToString(hmacSHA256(key, stringToSign)))
+	if !hmac.Equal([]byte(r.Header.Get("Authorization")), []byte(want)) {
+		return fmt.Errorf("signature does not match")
+	}
+	return nil
+}
+
+func byteRange(header string, size int64) (int64, int64, error) {
+	if header == "" {
+		return 0, size - 1, nil
+	}
+	parts := strings.SplitN(strings.TrimPrefix(header, "bytes="), "-", 2)
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("bad range %q", header)
+	}
+	start, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || start >= size {
+		return 0, 0, fmt.Errorf("bad range %q", header)
+	}
+	end := size - 1
+	if parts[1] != "" {
+		if end, err = strconv.ParseInt(parts[1], 10, 64); err != nil || end < start {
+			return 0, 0, fmt.Errorf("bad range %q", header)
+		}
+		if end >= size {
+			end = size - 1
+		}
+	}
+	return start, end, nil
+}
@@ -0,0 +1,113 @@
+// bodylimit/bodylimit.go
+// Package bodylimit reads request bodies for apps that stream them. With
+// StreamRequestBody fasthttp no longer refuses bodies over the BodyLimit, so
+// New enforces the limit itself and reads the body of every request into
+// memory, except those of the routes that read theirs as it arrives.
+package bodylimit
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const streamKey = "bodylimit.stream"
+
+// Config is the limit of request bodies.
+type Config struct {
+	// Limit is the size of the largest body read into memory.
+	Limit int
+	// Stream tells whether a request is handed its body as a stream, read
+	// with Reader, whatever its size.
+	Stream func(c *fiber.Ctx) bool
+}
+
+// New limits the bodies of requests to the next handlers. Bodies over the
+// limit are answered with 413. When a streamed body is not read to its end
+// the connection is closed after the response, since what is left of the
+// body would be read as the next request.
+func New(config Config) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		body := c.Context().RequestBodyStream()
+		if body == nil {
+			return c.Next()
+		}
+
+		if config.Stream != nil && config.Stream(c) {
+			stream := &stream{Reader: body}
+			c.Locals(streamKey, stream)
+			defer func() {
+				if !stream.done {
+					c.Context().SetConnectionClose()
+				}
+			}()
+			return c.Next()
+		}
+
+		if c.Request().Header.ContentLength() > config.Limit {
+			return tooLarge(c, config.Limit)
+		}
+		data, err := ioutil.ReadAll(io.LimitReader(body, int64(config.Limit)+1))
+		if err != nil {
+			c.Context().SetConnectionClose()
+			return c.Status(400).JSON(fiber.Map{
+				"status":  400,
+				"message": "Failed reading the request body",
+				"error":   err.Error(),
+			})
+		}
+		if len(data) > config.Limit {
+			return tooLarge(c, config.Limit)
+		}
+		c.Request().SetBody(data)
+		return c.Next()
+	}
+}
+
+// Reader returns the body of the request: the stream of a streamed request,
+// or else the body read into memory.
+func Reader(c *fiber.Ctx) io.Reader {
+	if stream, ok := c.Locals(streamKey).(*stream); ok {
+		return stream
+	}
+	return bytes.NewReader(c.Body())
+}
+
+// Replace hands the next handlers r in place of the streamed body, for
+// middleware that read the stream before them. It does nothing for requests
+// whose body was read into memory.
+func Replace(c *fiber.Ctx, r io.Reader) {
+	if _, ok := c.Locals(streamKey).(*stream); ok {
+		c.Locals(streamKey, &stream{Reader: r})
+	}
+}
+
+func tooLarge(c *fiber.Ctx, limit int) error {
+	c.Context().SetConnectionClose()
+	return c.Status(413).JSON(fiber.Map{
+		"status":  413,
+		"message": "Request body too large",
+		"error":   "the body may hold at most " + strconv.Itoa(limit) + " bytes",
+	})
+}
+
+// stream records whether the body was read to its end. It does not read
+// past the end, where the next request on the connection starts.
+type stream struct {
+	io.Reader
+	done bool
+}
+
+func (stream *stream) Read(p []byte) (int, error) {
+	if stream.done {
+		return 0, io.EOF
+	}
+	n, err := stream.Reader.Read(p)
+	if err == io.EOF {
+		stream.done = true
+	}
+	return n, err
+}
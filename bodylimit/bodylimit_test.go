@@ -0,0 +1,115 @@
+// bodylimit/bodylimit_test.go
+package bodylimit
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const limit = 64
+
+// serve runs an app streaming request bodies like main does, with a /stream
+// route reading its body as a stream and an /echo route reading it whole.
+func serve(t *testing.T) string {
+	app := fiber.New(fiber.Config{
+		BodyLimit:                    limit,
+		StreamRequestBody:            true,
+		DisablePreParseMultipartForm: true,
+		DisableStartupMessage:        true,
+	})
+	app.Use(New(Config{
+		Limit: limit,
+		Stream: func(c *fiber.Ctx) bool {
+			return strings.HasPrefix(c.Path(), "/stream")
+		},
+	}))
+	app.Post("/echo", func(c *fiber.Ctx) error {
+		return c.Send(c.Body())
+	})
+	app.Post("/stream", func(c *fiber.Ctx) error {
+		n, err := io.Copy(ioutil.Discard, Reader(c))
+		if err != nil {
+			return err
+		}
+		return c.SendString(strconv.FormatInt(n, 10))
+	})
+	app.Post("/stream/ignored", func(c *fiber.Ctx) error {
+		return c.SendStatus(400)
+	})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go app.Listener(listener)
+	t.Cleanup(func() { app.Shutdown() })
+	return "http://" + listener.Addr().String()
+}
+
+func post(t *testing.T, url string, body io.Reader, length int64) (*http.Response, string) {
+	request, err := http.NewRequest("POST", url, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request.ContentLength = length
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	data, _ := ioutil.ReadAll(response.Body)
+	return response, string(data)
+}
+
+// chunked hides the length of a reader, so the body is sent chunked.
+type chunked struct{ io.Reader }
+
+func TestLimit(t *testing.T) {
+	url := serve(t)
+	small := strings.Repeat("a", limit)
+	large := strings.Repeat("a", limit+1)
+
+	if response, body := post(t, url+"/echo", strings.NewReader(small), int64(len(small))); response.StatusCode != 200 || body != small {
+		t.Errorf("body at the limit = %d %q", response.StatusCode, body)
+	}
+	if response, body := post(t, url+"/echo", chunked{strings.NewReader(small)}, -1); response.StatusCode != 200 || body != small {
+		t.Errorf("chunked body at the limit = %d %q", response.StatusCode, body)
+	}
+	if response, _ := post(t, url+"/echo", strings.NewReader(large), int64(len(large))); response.StatusCode != 413 || !response.Close {
+		t.Errorf("body over the limit = %d, close %v, want 413 and close", response.StatusCode, response.Close)
+	}
+	if response, _ := post(t, url+"/echo", chunked{strings.NewReader(large)}, -1); response.StatusCode != 413 {
+		t.Errorf("chunked body over the limit = %d, want 413", response.StatusCode)
+	}
+}
+
+func TestStream(t *testing.T) {
+	url := serve(t)
+	size := 100 * limit
+	large := strings.Repeat("a", size)
+
+	response, body := post(t, url+"/stream", strings.NewReader(large), int64(size))
+	if response.StatusCode != 200 || body != strconv.Itoa(size) || response.Close {
+		t.Errorf("streamed body = %d %q, close %v", response.StatusCode, body, response.Close)
+	}
+	response, body = post(t, url+"/stream", chunked{strings.NewReader(large)}, -1)
+	if response.StatusCode != 200 || body != strconv.Itoa(size) || response.Close {
+		t.Errorf("chunked streamed body = %d %q, close %v", response.StatusCode, body, response.Close)
+	}
+
+	// What is left of a body the handler did not read must not be taken
+	// for the next request on the connection.
+	if response, _ := post(t, url+"/stream/ignored", strings.NewReader(large), int64(size)); !response.Close {
+		t.Error("connection kept open after an unread body")
+	}
+	if response, body := post(t, url+"/echo", strings.NewReader("hello"), 5); response.StatusCode != 200 || body != "hello" {
+		t.Errorf("request after an unread body = %d %q", response.StatusCode, body)
+	}
+}
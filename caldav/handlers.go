@@ -0,0 +1,634 @@
+// caldav/handlers.go
+package caldav
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/imadbg01/go-todo/auth"
+	"github.com/imadbg01/go-todo/ical"
+	"github.com/imadbg01/go-todo/openapi"
+	"github.com/imadbg01/go-todo/todo"
+	"github.com/jinzhu/gorm"
+)
+
+// Lists do not exist yet, so every user has one task collection holding all
+// todos.
+const collectionName = "todos"
+
+const syncTokenPrefix = "urn:go-todo:sync:"
+
+var errInvalidFilter = errors.New("invalid calendar-query filter")
+
+const (
+	kindRoot = iota
+	kindPrincipal
+	kindHome
+	kindCollection
+	kindObject
+)
+
+// target is the resource a request path points at.
+type target struct {
+	kind int
+	user string
+	name string
+}
+
+type CalDAVHandler struct {
+	resources *ResourceRepository
+	todos     *todo.TodoRepository
+}
+
+// Serve dispatches on the request method, including the WebDAV methods
+// Methods lets through as POST.
+func (handler *CalDAVHandler) Serve(c *fiber.Ctx) error {
+	base := strings.TrimSuffix(c.Route().Path, "/*")
+	t, ok := parsePath(strings.TrimPrefix(c.Path(), base))
+	if !ok {
+		return c.SendStatus(404)
+	}
+	if t.kind != kindRoot && t.user != auth.Actor(c) {
+		return c.SendStatus(403)
+	}
+
+	method := c.Method()
+	if override, ok := c.Locals(davMethod).(string); ok {
+		method = override
+	}
+
+	switch method {
+	case fiber.MethodOptions:
+		c.Set("DAV", "1, 3, calendar-access")
+		c.Set(fiber.HeaderAllow, "OPTIONS, GET, HEAD, PUT, DELETE, PROPFIND, PROPPATCH, REPORT")
+		return c.SendStatus(200)
+	case "PROPFIND":
+		return handler.propfind(c, base, t)
+	case "PROPPATCH":
+		return handler.proppatch(c)
+	case "REPORT":
+		return handler.report(c, base, t)
+	case fiber.MethodGet, fiber.MethodHead:
+		return handler.get(c, t)
+	case fiber.MethodPut:
+		return handler.put(c, base, t)
+	case fiber.MethodDelete:
+		return handler.delete(c, t)
+	}
+	return c.SendStatus(405)
+}
+
+func parsePath(path string) (target, bool) {
+	var parts []string
+	for _, part := range strings.Split(path, "/") {
+		if part != "" {
+			unescaped, err := url.PathUnescape(part)
+			if err != nil {
+				return target{}, false
+			}
+			parts = append(parts, unescaped)
+		}
+	}
+
+	switch {
+	case len(parts) == 0:
+		return target{kind: kindRoot}, true
+	case len(parts) == 2 && parts[0] == "principals":
+		return target{kind: kindPrincipal, user: parts[1]}, true
+	case len(parts) == 2 && parts[0] == "calendars":
+		return target{kind: kindHome, user: parts[1]}, true
+	case len(parts) == 3 && parts[0] == "calendars" && parts[2] == collectionName:
+		return target{kind: kindCollection, user: parts[1]}, true
+	case len(parts) == 4 && parts[0] == "calendars" && parts[2] == collectionName:
+		return target{kind: kindObject, user: parts[1], name: parts[3]}, true
+	}
+	return target{}, false
+}
+
+func (handler *CalDAVHandler) propfind(c *fiber.Ctx, base string, t target) error {
+	root, err := parseBody(c.Body())
+	if err != nil {
+		return c.Status(400).SendString(err.Error())
+	}
+	names := requestedProps(root)
+	user := auth.Actor(c)
+
+	var ms multistatus
+	switch t.kind {
+	case kindObject:
+		item, ok := handler.object(t.name)
+		if !ok {
+			return c.SendStatus(404)
+		}
+		ms.responses = append(ms.responses, handler.objectResponse(base, user, t.name, item, names))
+	default:
+		ms.responses = append(ms.responses, handler.response(c.Path(), base, user, t.kind, names))
+		if c.Get("Depth", "infinity") == "0" {
+			break
+		}
+		switch t.kind {
+		case kindHome:
+			ms.responses = append(ms.responses, handler.response(collectionPath(base, user), base, user, kindCollection, names))
+		case kindCollection:
+			todos := handler.todos.FindAll()
+			resourceNames := handler.names(todos)
+			for _, item := range todos {
+				ms.responses = append(ms.responses, handler.objectResponse(base, user, resourceNames[item.ID], item, names))
+			}
+		}
+	}
+	return multi(c, ms)
+}
+
+// proppatch refuses every change; collection properties are fixed.
+func (handler *CalDAVHandler) proppatch(c *fiber.Ctx) error {
+	root, err := parseBody(c.Body())
+	if err != nil {
+		return c.Status(400).SendString(err.Error())
+	}
+	var out strings.Builder
+	out.WriteString(xmlHeader)
+	out.WriteString(`<d:multistatus xmlns:d="DAV:"><d:response><d:href>` + escape(c.Path()) + `</d:href><d:propstat><d:prop>`)
+	for _, prop := range root.find(nsDAV, "prop") {
+		for _, child := range prop.Children {
+			writeProp(&out, child.XMLName, "")
+		}
+	}
+	out.WriteString(`</d:prop><d:status>` + statusLine(http.StatusForbidden) + `</d:status></d:propstat></d:response></d:multistatus>`)
+	c.Set(fiber.HeaderContentType, "application/xml; charset=utf-8")
+	return c.Status(207).Send([]byte(out.String()))
+}
+
+func (handler *CalDAVHandler) report(c *fiber.Ctx, base string, t target) error {
+	root, err := parseBody(c.Body())
+	if err != nil {
+		return c.Status(400).SendString(err.Error())
+	}
+	names := requestedProps(root)
+	user := auth.Actor(c)
+	if t.kind != kindCollection && t.kind != kindObject {
+		return c.Status(403).Send(errorBody(nsDAV, "supported-report"))
+	}
+
+	var ms multistatus
+	switch {
+	case root.is(nsCalDAV, "calendar-multiget"):
+		for _, h := range root.find(nsDAV, "href") {
+			path := strings.TrimSpace(h.Text)
+			if u, err := url.Parse(path); err == nil {
+				path = u.Path
+			}
+			ref, ok := parsePath(strings.TrimPrefix(path, base))
+			item, found := handler.object(ref.name)
+			if !ok || ref.kind != kindObject || !found {
+				ms.responses = append(ms.responses, response{href: path, status: 404})
+				continue
+			}
+			ms.responses = append(ms.responses, handler.objectResponse(base, user, ref.name, item, names))
+		}
+
+	case root.is(nsCalDAV, "calendar-query"):
+		todos, err := handler.query(root)
+		if err != nil {
+			return c.Status(403).Send(errorBody(nsCalDAV, "valid-filter"))
+		}
+		resourceNames := handler.names(todos)
+		for _, item := range todos {
+			ms.responses = append(ms.responses, handler.objectResponse(base, user, resourceNames[item.ID], item, names))
+		}
+
+	case root.is(nsDAV, "sync-collection"):
+		var since uint64
+		if token, _ := root.child(nsDAV, "sync-token"); strings.TrimSpace(token.Text) != "" {
+			position, err := parseSyncToken(strings.TrimSpace(token.Text))
+			if err != nil {
+				return c.Status(403).Send(errorBody(nsDAV, "valid-sync-token"))
+			}
+			since = position
+		}
+		changed, latest, err := handler.todos.ChangedSince(since)
+		if err != nil {
+			return c.Status(500).SendString(err.Error())
+		}
+		resourceNames := handler.names(changed)
+		for _, item := range changed {
+			path := objectPath(base, user, resourceNames[item.ID])
+			if item.DeletedAt != nil {
+				if since > 0 {
+					ms.responses = append(ms.responses, response{href: path, status: 404})
+				}
+				continue
+			}
+			ms.responses = append(ms.responses, handler.objectResponse(base, user, resourceNames[item.ID], item, names))
+		}
+		ms.syncToken = syncToken(latest)
+
+	default:
+		return c.Status(403).Send(errorBody(nsDAV, "supported-report"))
+	}
+	return multi(c, ms)
+}
+
+// query reads the filter of a calendar-query and returns the todos it
+// selects. The collection holds only tasks, so a filter that requires
+// another component selects nothing. Within the VTODO filter the time range
+// and whether COMPLETED is defined are applied; other property filters are
+// not, so a query may return more than it asked for and clients filter
+// again.
+func (handler *CalDAVHandler) query(root element) ([]todo.Todo, error) {
+	filter, _ := root.child(nsCalDAV, "filter")
+	calendar, ok := filter.child(nsCalDAV, "comp-filter")
+	if !ok || attr(calendar, "name") != "VCALENDAR" {
+		return nil, errInvalidFilter
+	}
+	if _, undefined := calendar.child(nsCalDAV, "is-not-defined"); undefined {
+		return nil, nil
+	}
+
+	var query todo.CalendarQuery
+	for _, component := range calendar.Children {
+		if !component.is(nsCalDAV, "comp-filter") {
+			continue
+		}
+		_, undefined := component.child(nsCalDAV, "is-not-defined")
+		if attr(component, "name") != "VTODO" {
+			if undefined {
+				continue
+			}
+			return nil, nil
+		}
+		if undefined {
+			return nil, nil
+		}
+
+		if timeRange, ok := component.child(nsCalDAV, "time-range"); ok {
+			var err error
+			if query.Start, err = parseUTC(attr(timeRange, "start")); err != nil {
+				return nil, err
+			}
+			if query.End, err = parseUTC(attr(timeRange, "end")); err != nil {
+				return nil, err
+			}
+			if query.Start == nil && query.End == nil {
+				return nil, errInvalidFilter
+			}
+		}
+		for _, property := range component.Children {
+			if property.is(nsCalDAV, "prop-filter") && strings.EqualFold(attr(property, "name"), "COMPLETED") {
+				_, undefined := property.child(nsCalDAV, "is-not-defined")
+				completed := !undefined
+				query.Completed = &completed
+			}
+		}
+	}
+	return handler.todos.FindForCalendar(query), nil
+}
+
+// parseUTC reads a time-range bound, which RFC 4791 requires in UTC. An
+// empty bound leaves the range open on that side.
+func parseUTC(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	at, err := time.Parse("20060102T150405Z", value)
+	if err != nil {
+		return nil, errInvalidFilter
+	}
+	return &at, nil
+}
+
+func (handler *CalDAVHandler) get(c *fiber.Ctx, t target) error {
+	if t.kind != kindObject {
+		return c.SendStatus(405)
+	}
+	item, ok := handler.object(t.name)
+	if !ok {
+		return c.SendStatus(404)
+	}
+	var body bytes.Buffer
+	todo.WriteVTODO(&body, item)
+	c.Set(fiber.HeaderContentType, "text/calendar; charset=utf-8")
+	c.Set(fiber.HeaderETag, item.ETag())
+	return c.Send(body.Bytes())
+}
+
+// put creates or replaces a todo from the VTODO in the body. If-Match and
+// If-None-Match are honoured so clients do not overwrite each other.
+func (handler *CalDAVHandler) put(c *fiber.Ctx, base string, t target) error {
+	if t.kind != kindObject || !strings.HasSuffix(t.name, ".ics") {
+		return c.SendStatus(405)
+	}
+	calendar, err := ical.Parse(bytes.NewReader(c.Body()))
+	if err != nil || calendar.Name != "VCALENDAR" {
+		return c.Status(400).Send(errorBody(nsCalDAV, "valid-calendar-data"))
+	}
+	var vtodos []ical.Component
+	for _, component := range calendar.Components {
+		switch component.Name {
+		case "VTODO":
+			vtodos = append(vtodos, component)
+		case "VEVENT", "VJOURNAL", "VFREEBUSY":
+			return c.Status(403).Send(errorBody(nsCalDAV, "supported-calendar-component"))
+		}
+	}
+	if len(vtodos) != 1 {
+		return c.Status(403).Send(errorBody(nsCalDAV, "valid-calendar-object-resource"))
+	}
+	data, err := todo.FromVTODO(vtodos[0])
+	if err == nil {
+		err = todo.Validate(data)
+	}
+	if err != nil {
+		return c.Status(403).Send(errorBody(nsCalDAV, "valid-calendar-data"))
+	}
+
+	repository := handler.todos.As(actor(c))
+	existing, exists := handler.object(t.name)
+	ifMatch, ifNoneMatch := c.Get(fiber.HeaderIfMatch), c.Get(fiber.HeaderIfNoneMatch)
+	if exists && ifNoneMatch != "" && existing.MatchesETag(ifNoneMatch) || !exists && ifMatch != "" || exists && ifMatch != "" && !existing.MatchesETag(ifMatch) {
+		return c.SendStatus(412)
+	}
+
+	if !exists {
+		if strings.HasPrefix(t.name, "todo-") {
+			return c.Status(403).SendString("Resource names starting with todo- are reserved")
+		}
+		// The todo and its name are written together, so a failed bind
+		// leaves no todo behind that the client would create again.
+		item, err := repository.CreateWith(data, func(tx *gorm.DB, item todo.Todo) error {
+			return handler.resources.Bind(tx, item.ID, t.name)
+		})
+		if quotaReached(err) {
+			return c.Status(507).Send(errorBody(nsDAV, "quota-not-exceeded"))
+		}
+		if err != nil {
+			if _, taken := handler.object(t.name); taken {
+				// Another request created the resource first.
+				return c.SendStatus(412)
+			}
+			if err == todo.ErrUIDTaken {
+				return handler.uidConflict(c, base, t.user, data.UID)
+			}
+			return c.Status(500).SendString(err.Error())
+		}
+		c.Set(fiber.HeaderETag, item.ETag())
+		return c.SendStatus(201)
+	}
+
+	existing.Name = data.Name
+	existing.Description = data.Description
+	existing.Status = data.Status
+	existing.Priority = data.Priority
+	existing.Due = data.Due
+	existing.TimeZone = data.TimeZone
+	existing.Recurrence = data.Recurrence
+	if data.CompletedAt != nil {
+		existing.CompletedAt = data.CompletedAt
+	}
+	if existing.UID == "" && data.UID != existing.CalendarUID() {
+		existing.UID = data.UID
+	}
+	item, err := repository.Save(existing)
+	if err == todo.ErrConflict {
+		return c.SendStatus(412)
+	}
+	if quotaReached(err) {
+		// Completing a recurring todo creates its next occurrence.
+		return c.Status(507).Send(errorBody(nsDAV, "quota-not-exceeded"))
+	}
+	if err == todo.ErrUIDTaken {
+		return handler.uidConflict(c, base, t.user, existing.UID)
+	}
+	if err != nil {
+		return c.Status(500).SendString(err.Error())
+	}
+	c.Set(fiber.HeaderETag, item.ETag())
+	return c.SendStatus(204)
+}
+
+// quotaReached tells whether err is the workspace reaching its todo quota,
+// which WebDAV answers with 507 Insufficient Storage.
+func quotaReached(err error) bool {
+	var quota *todo.QuotaError
+	return errors.As(err, &quota)
+}
+
+// uidConflict refuses a write whose UID another todo holds, naming the
+// resource that holds it as CalDAV asks.
+func (handler *CalDAVHandler) uidConflict(c *fiber.Ctx, base, user, uid string) error {
+	other, err := handler.todos.FindByUID(uid)
+	if err != nil {
+		return c.Status(500).SendString(err.Error())
+	}
+	name := handler.resources.Names([]uint{other.ID})[other.ID]
+	return c.Status(403).Send(uidConflictBody(objectPath(base, user, name)))
+}
+
+func (handler *CalDAVHandler) delete(c *fiber.Ctx, t target) error {
+	if t.kind != kindObject {
+		return c.SendStatus(403)
+	}
+	item, ok := handler.object(t.name)
+	if !ok {
+		return c.SendStatus(404)
+	}
+	if ifMatch := c.Get(fiber.HeaderIfMatch); ifMatch != "" && !item.MatchesETag(ifMatch) {
+		return c.SendStatus(412)
+	}
+	if handler.todos.As(actor(c)).Delete(int(item.ID)) == 0 {
+		return c.SendStatus(404)
+	}
+	return c.SendStatus(204)
+}
+
+// object finds the todo served under a resource name.
+func (handler *CalDAVHandler) object(name string) (todo.Todo, bool) {
+	id, ok := handler.resources.TodoID(name)
+	if !ok {
+		return todo.Todo{}, false
+	}
+	item, err := handler.todos.Find(int(id))
+	return item, err == nil
+}
+
+func (handler *CalDAVHandler) names(todos []todo.Todo) map[uint]string {
+	ids := make([]uint, len(todos))
+	for i, item := range todos {
+		ids[i] = item.ID
+	}
+	return handler.resources.Names(ids)
+}
+
+var defaultProps = []xml.Name{
+	{Space: nsDAV, Local: "resourcetype"},
+	{Space: nsDAV, Local: "displayname"},
+	{Space: nsDAV, Local: "getetag"},
+	{Space: nsDAV, Local: "getcontenttype"},
+	{Space: nsServer, Local: "getctag"},
+	{Space: nsDAV, Local: "sync-token"},
+}
+
+func (handler *CalDAVHandler) response(path, base, user string, kind int, names []xml.Name) response {
+	r := response{href: path}
+	explicit := names != nil
+	if !explicit {
+		names = defaultProps
+	}
+	for _, name := range names {
+		if inner, ok := handler.prop(base, user, kind, name); ok {
+			r.found = append(r.found, prop{name: name, inner: inner})
+		} else if explicit {
+			r.missing = append(r.missing, name)
+		}
+	}
+	return r
+}
+
+func (handler *CalDAVHandler) prop(base, user string, kind int, name xml.Name) (string, bool) {
+	principal := base + "/principals/" + url.PathEscape(user) + "/"
+	home := base + "/calendars/" + url.PathEscape(user) + "/"
+
+	switch name {
+	case xml.Name{Space: nsDAV, Local: "resourcetype"}:
+		switch kind {
+		case kindPrincipal:
+			return "<d:principal/>", true
+		case kindCollection:
+			return "<d:collection/><c:calendar/>", true
+		}
+		return "<d:collection/>", true
+	case xml.Name{Space: nsDAV, Local: "displayname"}:
+		switch kind {
+		case kindPrincipal:
+			return escape(user), true
+		case kindCollection:
+			return "Todos", true
+		}
+	case xml.Name{Space: nsDAV, Local: "current-user-principal"}:
+		return href(principal), true
+	case xml.Name{Space: nsDAV, Local: "principal-URL"}:
+		if kind == kindPrincipal {
+			return href(principal), true
+		}
+	case xml.Name{Space: nsCalDAV, Local: "calendar-home-set"}:
+		if kind == kindPrincipal || kind == kindRoot {
+			return href(home), true
+		}
+	case xml.Name{Space: nsDAV, Local: "owner"}:
+		if kind == kindCollection {
+			return href(principal), true
+		}
+	case xml.Name{Space: nsCalDAV, Local: "supported-calendar-component-set"}:
+		if kind == kindCollection {
+			return `<c:comp name="VTODO"/>`, true
+		}
+	case xml.Name{Space: nsDAV, Local: "supported-report-set"}:
+		if kind == kindCollection {
+			return "<d:supported-report><d:report><c:calendar-query/></d:report></d:supported-report>" +
+				"<d:supported-report><d:report><c:calendar-multiget/></d:report></d:supported-report>" +
+				"<d:supported-report><d:report><d:sync-collection/></d:report></d:supported-report>", true
+		}
+	case xml.Name{Space: nsDAV, Local: "current-user-privilege-set"}:
+		if kind == kindCollection {
+			return privileges, true
+		}
+	case xml.Name{Space: nsServer, Local: "getctag"}, xml.Name{Space: nsDAV, Local: "sync-token"}:
+		if kind == kindCollection {
+			return escape(syncToken(handler.todos.HistoryPosition())), true
+		}
+	}
+	return "", false
+}
+
+const privileges = "<d:privilege><d:read/></d:privilege><d:privilege><d:write/></d:privilege>" +
+	"<d:privilege><d:write-content/></d:privilege><d:privilege><d:bind/></d:privilege>" +
+	"<d:privilege><d:unbind/></d:privilege>"
+
+func (handler *CalDAVHandler) objectResponse(base, user, name string, item todo.Todo, names []xml.Name) response {
+	r := response{href: objectPath(base, user, name)}
+	explicit := names != nil
+	if !explicit {
+		names = defaultProps
+	}
+	for _, n := range names {
+		switch n {
+		case xml.Name{Space: nsDAV, Local: "getetag"}:
+			r.found = append(r.found, prop{name: n, inner: escape(item.ETag())})
+		case xml.Name{Space: nsDAV, Local: "getcontenttype"}:
+			r.found = append(r.found, prop{name: n, inner: "text/calendar; charset=utf-8; component=VTODO"})
+		case xml.Name{Space: nsDAV, Local: "resourcetype"}:
+			r.found = append(r.found, prop{name: n})
+		case xml.Name{Space: nsDAV, Local: "getlastmodified"}:
+			r.found = append(r.found, prop{name: n, inner: item.UpdatedAt.UTC().Format(http.TimeFormat)})
+		case xml.Name{Space: nsDAV, Local: "current-user-privilege-set"}:
+			r.found = append(r.found, prop{name: n, inner: privileges})
+		case xml.Name{Space: nsCalDAV, Local: "calendar-data"}:
+			var body bytes.Buffer
+			todo.WriteVTODO(&body, item)
+			r.found = append(r.found, prop{name: n, inner: escape(body.String())})
+		default:
+			if explicit {
+				r.missing = append(r.missing, n)
+			}
+		}
+	}
+	return r
+}
+
+func collectionPath(base, user string) string {
+	return base + "/calendars/" + url.PathEscape(user) + "/" + collectionName + "/"
+}
+
+func objectPath(base, user, name string) string {
+	return collectionPath(base, user) + url.PathEscape(name)
+}
+
+// Sync tokens are positions in the todo history, so that changes since a
+// token are the todos with newer history entries.
+func syncToken(position uint64) string {
+	return syncTokenPrefix + strconv.FormatUint(position, 10)
+}
+
+func parseSyncToken(token string) (uint64, error) {
+	position, err := strconv.ParseUint(strings.TrimPrefix(token, syncTokenPrefix), 10, 64)
+	if err == nil && !strings.HasPrefix(token, syncTokenPrefix) {
+		err = strconv.ErrSyntax
+	}
+	return position, err
+}
+
+func multi(c *fiber.Ctx, ms multistatus) error {
+	c.Set(fiber.HeaderContentType, "application/xml; charset=utf-8")
+	return c.Status(207).Send(ms.bytes())
+}
+
+// actor attributes CalDAV changes in the todo history.
+func actor(c *fiber.Ctx) todo.Actor {
+	requestID, _ := c.Locals("requestid").(string)
+	return todo.Actor{User: auth.Actor(c), RequestID: requestID}
+}
+
+func NewCalDAVHandler(resources *ResourceRepository, todos *todo.TodoRepository) *CalDAVHandler {
+	return &CalDAVHandler{
+		resources: resources,
+		todos:     todos,
+	}
+}
+
+// Register mounts the CalDAV server. Its WebDAV methods only get through
+// Fiber when the server handler is wrapped with Methods.
+func Register(router fiber.Router, database *gorm.DB) {
+	database.AutoMigrate(&Resource{})
+	caldavHandler := NewCalDAVHandler(NewResourceRepository(database), todo.NewTodoRepository(database))
+	openapi.Describe(caldavHandler.Serve, openapi.Doc{Hidden: true})
+
+	router.All("/caldav", caldavHandler.Serve)
+	router.All("/caldav/*", caldavHandler.Serve)
+}
@@ -0,0 +1,316 @@
+// caldav/handlers_test.go
+package caldav
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/imadbg01/go-todo/outbox"
+	"github.com/imadbg01/go-todo/todo"
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"github.com/valyala/fasthttp"
+)
+
+// server serves the API with CalDAV mounted as main does, on a fresh SQLite
+// database.
+func server(t *testing.T) (string, *gorm.DB) {
+	database, err := gorm.Open("sqlite3", filepath.Join(t.TempDir(), "caldav.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	database.DB().SetMaxOpenConns(1)
+	t.Cleanup(func() { database.Close() })
+	database.AutoMigrate(&outbox.Message{})
+
+	// The recorded requests name their user in X-User, as the proxy in
+	// front of the API does.
+	app := fiber.New(fiber.Config{
+		DisableStartupMessage:   true,
+		EnableTrustedProxyCheck: true,
+		TrustedProxies:          []string{"127.0.0.1"},
+	})
+	api := app.Group("/api")
+	todo.Register(api, database)
+	Register(api, database)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &fasthttp.Server{Handler: Methods(app.Handler(), "/api/caldav")}
+	go server.Serve(listener)
+	t.Cleanup(func() { server.Shutdown() })
+	return "http://" + listener.Addr().String(), database
+}
+
+// replay sends the request recorded in a testdata file, with {{name}}
+// placeholders filled from vars.
+func replay(t *testing.T, base, file string, vars map[string]string) (*http.Response, string) {
+	data, err := ioutil.ReadFile(filepath.Join("testdata", file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, value := range vars {
+		data = bytes.ReplaceAll(data, []byte("{{"+name+"}}"), []byte(value))
+	}
+	parts := strings.SplitN(string(data), "\n\n", 2)
+	head := strings.ReplaceAll(parts[0], "\n", "\r\n") + "\r\n\r\n"
+	recorded, err := http.ReadRequest(bufio.NewReader(strings.NewReader(head)))
+	if err != nil {
+		t.Fatalf("%s: %v", file, err)
+	}
+
+	body := ""
+	if len(parts) == 2 {
+		body = parts[1]
+	}
+	if strings.HasPrefix(recorded.Header.Get("Content-Type"), "text/calendar") {
+		body = strings.ReplaceAll(body, "\n", "\r\n")
+	}
+	request, err := http.NewRequest(recorded.Method, base+recorded.URL.RequestURI(), strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	request.Header = recorded.Header
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatalf("%s: %v", file, err)
+	}
+	defer response.Body.Close()
+	answer, _ := ioutil.ReadAll(response.Body)
+	return response, string(answer)
+}
+
+var syncTokenPattern = regexp.MustCompile(`<d:sync-token>([^<]+)</d:sync-token>`)
+
+// TestClients replays the requests Apple Reminders, Thunderbird and DAVx5
+// send when they discover the collection, sync it and write tasks.
+func TestClients(t *testing.T) {
+	base, _ := server(t)
+	vars := map[string]string{"name": "3A1F4B2C-9D7E-4E4B-8F0A-6C1D2E3F4A5B.ics"}
+
+	steps := []struct {
+		file     string
+		status   int
+		contains []string
+		lacks    []string
+	}{
+		{"reminders/01-propfind-root.http", 207, []string{
+			`<current-user-principal xmlns="DAV:"><d:href>/api/caldav/principals/alice/</d:href>`,
+		}, nil},
+		{"reminders/02-propfind-principal.http", 207, []string{
+			`<calendar-home-set xmlns="urn:ietf:params:xml:ns:caldav"><d:href>/api/caldav/calendars/alice/</d:href>`,
+			"HTTP/1.1 404",
+		}, nil},
+		{"reminders/03-propfind-home.http", 207, []string{
+			"<d:href>/api/caldav/calendars/alice/todos/</d:href>",
+			`<c:comp name="VTODO"/>`,
+			`<getctag xmlns="http://calendarserver.org/ns/">urn:go-todo:sync:`,
+		}, nil},
+		{"reminders/04-sync-initial.http", 207, []string{"<d:sync-token>"}, []string{".ics"}},
+		{"reminders/05-put-new.http", 201, nil, nil},
+		{"reminders/06-sync-since.http", 207, []string{
+			"/api/caldav/calendars/alice/todos/3A1F4B2C-9D7E-4E4B-8F0A-6C1D2E3F4A5B.ics",
+			"text/calendar; charset=utf-8; component=VTODO",
+		}, nil},
+		{"reminders/07-multiget.http", 207, []string{
+			"SUMMARY:Buy milk",
+			"UID:3A1F4B2C-9D7E-4E4B-8F0A-6C1D2E3F4A5B",
+			"<d:href>/api/caldav/calendars/alice/todos/missing.ics</d:href><d:status>HTTP/1.1 404",
+		}, nil},
+
+		{"thunderbird/01-propfind-collection.http", 207, []string{
+			"<d:collection/><c:calendar/>",
+			"<c:calendar-query/>",
+			`<owner xmlns="DAV:"><d:href>/api/caldav/principals/alice/</d:href>`,
+		}, nil},
+		{"thunderbird/02-query-open.http", 207, []string{"3A1F4B2C-9D7E-4E4B-8F0A-6C1D2E3F4A5B.ics"}, nil},
+		{"thunderbird/03-put-new.http", 201, nil, nil},
+		{"thunderbird/04-put-update.http", 204, nil, nil},
+		{"thunderbird/02-query-open.http", 207, nil, []string{"1c5d7a3e-2f4b-4a6c-9e8d-0b1a2c3d4e5f.ics"}},
+		{"thunderbird/05-delete.http", 204, nil, nil},
+
+		{"davx5/01-propfind-home.http", 207, []string{`<displayname xmlns="DAV:">Todos</displayname>`}, nil},
+		{"davx5/02-sync-initial.http", 207, []string{"3A1F4B2C-9D7E-4E4B-8F0A-6C1D2E3F4A5B.ics"}, []string{"1c5d7a3e"}},
+		{"davx5/03-put-stale.http", 412, nil, nil},
+		{"davx5/04-put-reserved.http", 403, nil, nil},
+	}
+	for _, step := range steps {
+		response, body := replay(t, base, step.file, vars)
+		if response.StatusCode != step.status {
+			t.Fatalf("%s: status %d, want %d\n%s", step.file, response.StatusCode, step.status, body)
+		}
+		for _, want := range step.contains {
+			if !strings.Contains(body, want) {
+				t.Errorf("%s: response lacks %q\n%s", step.file, want, body)
+			}
+		}
+		for _, unwanted := range step.lacks {
+			if strings.Contains(body, unwanted) {
+				t.Errorf("%s: response has %q\n%s", step.file, unwanted, body)
+			}
+		}
+
+		if match := syncTokenPattern.FindStringSubmatch(body); match != nil {
+			vars["sync-token"] = match[1]
+		}
+		if etag := response.Header.Get("ETag"); etag != "" {
+			vars["etag"] = etag
+		}
+	}
+}
+
+// TestPutIsAtomic checks that a todo whose resource name cannot be bound is
+// not created either, so the client's retry does not make a second one.
+func TestPutIsAtomic(t *testing.T) {
+	base, database := server(t)
+	database.DropTable(&Resource{})
+	database.LogMode(false)
+
+	if response, _ := replay(t, base, "reminders/05-put-new.http", nil); response.StatusCode != 500 {
+		t.Fatalf("put without a resource table = %d, want 500", response.StatusCode)
+	}
+	var count int
+	database.Unscoped().Model(&todo.Todo{}).Count(&count)
+	if count != 0 {
+		t.Errorf("%d todos left after the failed put", count)
+	}
+}
+
+// TestPutQuota checks that a workspace at its todo quota answers WebDAV's
+// 507 Insufficient Storage, which clients show as the account being full.
+func TestPutQuota(t *testing.T) {
+	t.Setenv("TODO_QUOTA", "1")
+	base, database := server(t)
+	if _, err := todo.NewTodoRepository(database).Create(todo.Todo{Name: "Only", Status: todo.PENDING}); err != nil {
+		t.Fatal(err)
+	}
+
+	response, body := replay(t, base, "reminders/05-put-new.http", nil)
+	if response.StatusCode != 507 || !strings.Contains(body, "quota-not-exceeded") {
+		t.Fatalf("put past the quota = %d %s, want 507 quota-not-exceeded", response.StatusCode, body)
+	}
+}
+
+// TestCalendarQuery checks that time ranges are matched against DUE, or
+// against CREATED and COMPLETED for todos without one, and that filters on
+// other components select nothing.
+func TestCalendarQuery(t *testing.T) {
+	base, database := server(t)
+	repository := todo.NewTodoRepository(database)
+	inJanuary := time.Date(2024, time.January, 10, 9, 0, 0, 0, time.UTC)
+	inMarch := time.Date(2024, time.March, 10, 9, 0, 0, 0, time.UTC)
+	names := map[string]string{}
+	for _, item := range []todo.Todo{
+		{Name: "January", Status: todo.PENDING, Due: &inJanuary},
+		{Name: "March", Status: todo.PENDING, Due: &inMarch},
+		{Name: "Open", Status: todo.PENDING},
+		{Name: "Done", Status: todo.DONE},
+	} {
+		created, err := repository.Create(item)
+		if err != nil {
+			t.Fatal(err)
+		}
+		names[item.Name] = defaultName(created.ID)
+	}
+
+	for _, test := range []struct {
+		start, end string
+		want       []string
+	}{
+		{"20240101T000000Z", "20240201T000000Z", []string{"January"}},
+		{"20240201T000000Z", "", []string{"March", "Open", "Done"}},
+		{"", "20240110T090000Z", []string{"January"}},
+	} {
+		vars := map[string]string{"start": test.start, "end": test.end}
+		response, body := replay(t, base, "thunderbird/06-query-range.http", vars)
+		if response.StatusCode != 207 {
+			t.Fatalf("%v: status %d\n%s", vars, response.StatusCode, body)
+		}
+		if count := strings.Count(body, "<d:response>"); count != len(test.want) {
+			t.Errorf("%v: %d todos, want %v\n%s", vars, count, test.want, body)
+		}
+		for _, name := range test.want {
+			if !strings.Contains(body, names[name]) {
+				t.Errorf("%v: response lacks %s\n%s", vars, name, body)
+			}
+		}
+	}
+
+	response, body := replay(t, base, "thunderbird/06-query-range.http", map[string]string{"start": "2024-01-01"})
+	if response.StatusCode != 403 || !strings.Contains(body, "valid-filter") {
+		t.Errorf("malformed time range answered %d\n%s", response.StatusCode, body)
+	}
+	if _, body := replay(t, base, "thunderbird/07-query-events.http", nil); strings.Contains(body, "<d:response>") {
+		t.Errorf("event query returned todos\n%s", body)
+	}
+}
+
+// TestPutCompletesRecurringTodo checks that completing a recurring task
+// over CalDAV schedules its next occurrence, as the REST API does, and that
+// If-Match lists and weak tags are understood.
+func TestPutCompletesRecurringTodo(t *testing.T) {
+	base, database := server(t)
+	response, _ := replay(t, base, "reminders/05-put-new.http", nil)
+	etag := response.Header.Get("ETag")
+	vars := map[string]string{"name": "3A1F4B2C-9D7E-4E4B-8F0A-6C1D2E3F4A5B.ics"}
+
+	vars["if-match"] = `W/"1-1"`
+	if response, _ := replay(t, base, "davx5/05-put-complete.http", vars); response.StatusCode != 412 {
+		t.Fatalf("put with a stale weak tag = %d, want 412", response.StatusCode)
+	}
+	vars["if-match"] = `"1-1", W/` + etag
+	if response, body := replay(t, base, "davx5/05-put-complete.http", vars); response.StatusCode != 204 {
+		t.Fatalf("put with a matching weak tag = %d, want 204\n%s", response.StatusCode, body)
+	}
+
+	var todos []todo.Todo
+	database.Order("id asc").Find(&todos)
+	if len(todos) != 2 || todos[0].Status != todo.DONE || todos[0].CompletedAt == nil {
+		t.Fatalf("todos after completing %+v, want the completed todo and its next occurrence", todos)
+	}
+	if next := todos[1]; next.Status != todo.PENDING || next.Due == nil || !next.Due.Equal(time.Date(2024, time.January, 15, 9, 0, 0, 0, time.UTC)) {
+		t.Errorf("next occurrence %+v, want it due a week later", next)
+	}
+}
+
+// TestUIDConflict checks that a second resource with the UID of a todo is
+// refused with the resource that already holds it.
+func TestUIDConflict(t *testing.T) {
+	base, _ := server(t)
+	if response, _ := replay(t, base, "reminders/05-put-new.http", nil); response.StatusCode != 201 {
+		t.Fatalf("put = %d, want 201", response.StatusCode)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join("testdata", "reminders", "05-put-new.http"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := strings.ReplaceAll(strings.SplitN(string(data), "\n\n", 2)[1], "\n", "\r\n")
+	request, err := http.NewRequest("PUT", base+"/api/caldav/calendars/alice/todos/copy.ics", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	request.Header.Set("Content-Type", "text/calendar")
+	request.Header.Set("X-User", "alice")
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	answer, _ := ioutil.ReadAll(response.Body)
+	if response.StatusCode != 403 || !strings.Contains(string(answer), "no-uid-conflict") ||
+		!strings.Contains(string(answer), "/todos/3A1F4B2C-9D7E-4E4B-8F0A-6C1D2E3F4A5B.ics</d:href>") {
+		t.Errorf("put of a taken uid = %d %s", response.StatusCode, answer)
+	}
+}
@@ -0,0 +1,54 @@
+// caldav/methods.go
+package caldav
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/imadbg01/go-todo/openapi"
+	"github.com/valyala/fasthttp"
+)
+
+// davMethod is the local holding the WebDAV method of a request that was
+// passed to Fiber as a POST.
+const davMethod = "caldav.method"
+
+var webdavMethods = map[string]bool{
+	"PROPFIND":   true,
+	"PROPPATCH":  true,
+	"REPORT":     true,
+	"MKCOL":      true,
+	"MKCALENDAR": true,
+	"COPY":       true,
+	"MOVE":       true,
+	"LOCK":       true,
+	"UNLOCK":     true,
+}
+
+// Methods wraps the server handler so WebDAV methods, which Fiber rejects
+// before routing, reach the routes under the given path prefixes as POST
+// requests. Serve restores the original method.
+func Methods(next fasthttp.RequestHandler, prefixes ...string) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		method := strings.ToUpper(string(ctx.Method()))
+		if webdavMethods[method] {
+			for _, prefix := range prefixes {
+				if strings.HasPrefix(string(ctx.Path()), prefix) {
+					ctx.Request.Header.SetMethod(fiber.MethodPost)
+					ctx.SetUserValue(davMethod, method)
+					break
+				}
+			}
+		}
+		next(ctx)
+	}
+}
+
+// WellKnown redirects the /.well-known/caldav discovery URL to the server.
+func WellKnown(root string) fiber.Handler {
+	handler := func(c *fiber.Ctx) error {
+		return c.Redirect(root, 301)
+	}
+	openapi.Describe(handler, openapi.Doc{Hidden: true})
+	return handler
+}
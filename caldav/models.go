@@ -0,0 +1,14 @@
+// caldav/models.go
+package caldav
+
+// Resource records the name a client gave the .ics resource of a todo it
+// created. Other todos are served under a name derived from their ID.
+type Resource struct {
+	ID     uint   `gorm:"primary_key"`
+	TodoID uint   `gorm:"Not Null;unique_index"`
+	Name   string `gorm:"Not Null;unique_index"`
+}
+
+func (Resource) TableName() string {
+	return "caldav_resources"
+}
@@ -0,0 +1,69 @@
+// caldav/repositories.go
+package caldav
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+)
+
+type ResourceRepository struct {
+	database *gorm.DB
+}
+
+// Names returns the resource names of the given todos.
+func (repository *ResourceRepository) Names(ids []uint) map[uint]string {
+	names := make(map[uint]string, len(ids))
+	for _, id := range ids {
+		names[id] = defaultName(id)
+	}
+	if len(ids) == 0 {
+		return names
+	}
+	var resources []Resource
+	repository.database.Where("todo_id IN (?)", ids).Find(&resources)
+	for _, resource := range resources {
+		names[resource.TodoID] = resource.Name
+	}
+	return names
+}
+
+// TodoID resolves a resource name to the todo it is served for.
+func (repository *ResourceRepository) TodoID(name string) (uint, bool) {
+	var resource Resource
+	if err := repository.database.Where("name = ?", name).First(&resource).Error; err == nil {
+		return resource.TodoID, true
+	}
+	if !strings.HasPrefix(name, "todo-") || !strings.HasSuffix(name, ".ics") {
+		return 0, false
+	}
+	id, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimPrefix(name, "todo-"), ".ics"), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	// A todo renamed by its client is no longer served under its default name.
+	var renamed int
+	repository.database.Model(&Resource{}).Where("todo_id = ?", id).Count(&renamed)
+	return uint(id), renamed == 0
+}
+
+// Bind serves the todo under the name a client chose for it. It writes in
+// tx, the transaction the todo is created in.
+func (repository *ResourceRepository) Bind(tx *gorm.DB, todoID uint, name string) error {
+	if name == defaultName(todoID) {
+		return nil
+	}
+	return tx.Create(&Resource{TodoID: todoID, Name: name}).Error
+}
+
+func defaultName(id uint) string {
+	return fmt.Sprintf("todo-%d.ics", id)
+}
+
+func NewResourceRepository(database *gorm.DB) *ResourceRepository {
+	return &ResourceRepository{
+		database: database,
+	}
+}
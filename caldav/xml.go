@@ -0,0 +1,163 @@
+// caldav/xml.go
+package caldav
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	nsDAV     = "DAV:"
+	nsCalDAV  = "urn:ietf:params:xml:ns:caldav"
+	nsServer  = "http://calendarserver.org/ns/"
+	xmlHeader = `<?xml version="1.0" encoding="utf-8"?>` + "\n"
+)
+
+// element is a generic XML element, enough to read WebDAV request bodies.
+type element struct {
+	XMLName  xml.Name
+	Attrs    []xml.Attr `xml:",any,attr"`
+	Children []element  `xml:",any"`
+	Text     string     `xml:",chardata"`
+}
+
+func parseBody(body []byte) (element, error) {
+	var root element
+	if len(bytes.TrimSpace(body)) == 0 {
+		return root, nil
+	}
+	err := xml.Unmarshal(body, &root)
+	return root, err
+}
+
+func (e element) is(space, local string) bool {
+	return e.XMLName.Space == space && e.XMLName.Local == local
+}
+
+func (e element) child(space, local string) (element, bool) {
+	for _, child := range e.Children {
+		if child.is(space, local) {
+			return child, true
+		}
+	}
+	return element{}, false
+}
+
+// attr returns the value of the attribute with the given local name.
+func attr(e element, local string) string {
+	for _, a := range e.Attrs {
+		if a.Name.Local == local {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// find returns the descendants with the given name, depth first.
+func (e element) find(space, local string) []element {
+	var found []element
+	for _, child := range e.Children {
+		if child.is(space, local) {
+			found = append(found, child)
+		}
+		found = append(found, child.find(space, local)...)
+	}
+	return found
+}
+
+// requestedProps returns the names inside the request's DAV:prop element, or
+// nil when the client asked for all properties.
+func requestedProps(root element) []xml.Name {
+	prop, ok := root.child(nsDAV, "prop")
+	if !ok {
+		return nil
+	}
+	names := make([]xml.Name, 0, len(prop.Children))
+	for _, child := range prop.Children {
+		names = append(names, child.XMLName)
+	}
+	return names
+}
+
+// prop is a property value as raw XML, using the d:, c: and cs: prefixes
+// declared on the multistatus element.
+type prop struct {
+	name  xml.Name
+	inner string
+}
+
+type response struct {
+	href    string
+	status  int
+	found   []prop
+	missing []xml.Name
+}
+
+type multistatus struct {
+	responses []response
+	syncToken string
+}
+
+func (ms multistatus) bytes() []byte {
+	var out strings.Builder
+	out.WriteString(xmlHeader)
+	out.WriteString(`<d:multistatus xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav" xmlns:cs="http://calendarserver.org/ns/">`)
+	for _, r := range ms.responses {
+		out.WriteString("<d:response><d:href>" + escape(r.href) + "</d:href>")
+		if r.status != 0 {
+			out.WriteString("<d:status>" + statusLine(r.status) + "</d:status>")
+		}
+		if len(r.found) > 0 {
+			out.WriteString("<d:propstat><d:prop>")
+			for _, p := range r.found {
+				writeProp(&out, p.name, p.inner)
+			}
+			out.WriteString("</d:prop><d:status>" + statusLine(http.StatusOK) + "</d:status></d:propstat>")
+		}
+		if len(r.missing) > 0 {
+			out.WriteString("<d:propstat><d:prop>")
+			for _, name := range r.missing {
+				writeProp(&out, name, "")
+			}
+			out.WriteString("</d:prop><d:status>" + statusLine(http.StatusNotFound) + "</d:status></d:propstat>")
+		}
+		out.WriteString("</d:response>")
+	}
+	if ms.syncToken != "" {
+		out.WriteString("<d:sync-token>" + escape(ms.syncToken) + "</d:sync-token>")
+	}
+	out.WriteString("</d:multistatus>")
+	return []byte(out.String())
+}
+
+func writeProp(out *strings.Builder, name xml.Name, inner string) {
+	fmt.Fprintf(out, `<%s xmlns="%s">%s</%s>`, name.Local, escape(name.Space), inner, name.Local)
+}
+
+// errorBody is a DAV:error naming the precondition that failed.
+func errorBody(space, local string) []byte {
+	return []byte(fmt.Sprintf(`%s<d:error xmlns:d="DAV:"><%s xmlns="%s"/></d:error>`, xmlHeader, local, escape(space)))
+}
+
+// uidConflictBody is the CALDAV:no-uid-conflict error, with the resource
+// that already holds the UID.
+func uidConflictBody(href string) []byte {
+	return []byte(fmt.Sprintf(`%s<d:error xmlns:d="DAV:"><no-uid-conflict xmlns="%s"><d:href>%s</d:href></no-uid-conflict></d:error>`, xmlHeader, escape(nsCalDAV), escape(href)))
+}
+
+func statusLine(code int) string {
+	return fmt.Sprintf("HTTP/1.1 %d %s", code, http.StatusText(code))
+}
+
+func escape(text string) string {
+	var out bytes.Buffer
+	xml.EscapeText(&out, []byte(text))
+	return out.String()
+}
+
+func href(path string) string {
+	return "<d:href>" + escape(path) + "</d:href>"
+}
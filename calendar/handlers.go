@@ -0,0 +1,128 @@
+// calendar/handlers.go
+package calendar
+
+import (
+	"bufio"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/imadbg01/go-todo/auth"
+	"github.com/imadbg01/go-todo/openapi"
+	"github.com/imadbg01/go-todo/todo"
+	"github.com/jinzhu/gorm"
+)
+
+type CalendarHandler struct {
+	repository *CalendarRepository
+	todos      *todo.TodoRepository
+}
+
+func (handler *CalendarHandler) GetFeed(c *fiber.Ctx) error {
+	feed, err := handler.repository.FindByOwner(auth.Actor(c))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status": 404,
+			"error":  err.Error(),
+		})
+	}
+	return c.JSON(fiber.Map{
+		"owner":      feed.Owner,
+		"created_at": feed.CreatedAt,
+	})
+}
+
+// CreateFeed issues a new feed URL for the user. The URL is only returned
+// here; calling it again revokes the previous one.
+func (handler *CalendarHandler) CreateFeed(c *fiber.Ctx) error {
+	feed, err := handler.repository.Rotate(auth.Actor(c))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  500,
+			"message": "Failed creating calendar feed",
+			"error":   err.Error(),
+		})
+	}
+	return c.Status(201).JSON(fiber.Map{
+		"owner":      feed.Owner,
+		"url":        c.BaseURL() + strings.TrimSuffix(c.Path(), "/feed") + "/feeds/" + feed.Token + ".ics",
+		"created_at": feed.CreatedAt,
+	})
+}
+
+func (handler *CalendarHandler) DeleteFeed(c *fiber.Ctx) error {
+	if handler.repository.Delete(auth.Actor(c)) == 0 {
+		return c.Status(404).JSON(fiber.Map{
+			"message": "Calendar feed not found",
+		})
+	}
+	return c.Status(204).JSON(nil)
+}
+
+// Feed serves the calendar to subscribed clients, which authenticate with
+// the token in the URL alone.
+func (handler *CalendarHandler) Feed(c *fiber.Ctx) error {
+	token := strings.TrimSuffix(c.Params("token"), ".ics")
+	if _, err := handler.repository.FindByToken(token); err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"message": "Calendar feed not found",
+		})
+	}
+
+	c.Set(fiber.HeaderContentType, "text/calendar; charset=utf-8")
+	c.Set(fiber.HeaderCacheControl, "private, max-age=300")
+	todos := handler.todos
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		if err := todos.WriteCalendar(w, "Todos"); err != nil {
+			log.Printf("calendar: feed failed: %v", err)
+		}
+		w.Flush()
+	})
+	return nil
+}
+
+func NewCalendarHandler(repository *CalendarRepository, todos *todo.TodoRepository) *CalendarHandler {
+	return &CalendarHandler{
+		repository: repository,
+		todos:      todos,
+	}
+}
+
+// describe documents the routes of the handler in the OpenAPI document.
+func describe(handler *CalendarHandler) {
+	feed := struct {
+		Owner     string    `json:"owner"`
+		URL       string    `json:"url,omitempty"`
+		CreatedAt time.Time `json:"created_at"`
+	}{}
+
+	openapi.Describe(handler.GetFeed, openapi.Doc{Summary: "Get your calendar feed", Result: feed, Errors: []int{404}})
+	openapi.Describe(handler.CreateFeed, openapi.Doc{
+		Summary:     "Issue a new calendar feed URL",
+		Description: "The URL is only returned here. Issuing a new one revokes the previous URL.",
+		Result:      feed,
+		Status:      201,
+		Errors:      []int{500},
+	})
+	openapi.Describe(handler.DeleteFeed, openapi.Doc{Summary: "Revoke your calendar feed", Status: 204, Errors: []int{404}})
+	openapi.Describe(handler.Feed, openapi.Doc{
+		Summary:     "Subscribe to a calendar feed",
+		Description: "Authenticated by the token in the URL alone.",
+		ResultTypes: []string{"text/calendar"},
+		Errors:      []int{404},
+	})
+}
+
+func Register(router fiber.Router, database *gorm.DB) {
+	database.AutoMigrate(&Feed{})
+	calendarRepository := NewCalendarRepository(database)
+	calendarHandler := NewCalendarHandler(calendarRepository, todo.NewTodoRepository(database))
+	describe(calendarHandler)
+
+	calendarRouter := router.Group("/calendar")
+	calendarRouter.Get("/feed", calendarHandler.GetFeed)
+	calendarRouter.Post("/feed", calendarHandler.CreateFeed)
+	calendarRouter.Delete("/feed", calendarHandler.DeleteFeed)
+	calendarRouter.Get("/feeds/:token", calendarHandler.Feed)
+}
@@ -0,0 +1,19 @@
+// calendar/models.go
+package calendar
+
+import "time"
+
+// Feed is a user's secret calendar subscription. Anyone holding the token
+// can read the feed, so it is rotated rather than shown in listings. Todos
+// have no owner, so every feed lists all of them; tokens are per user so
+// that each can be revoked on its own.
+type Feed struct {
+	ID        uint      `gorm:"primary_key" json:"-"`
+	Owner     string    `gorm:"Not Null;unique_index" json:"owner"`
+	Token     string    `gorm:"Not Null;unique_index" json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (Feed) TableName() string {
+	return "calendar_feeds"
+}
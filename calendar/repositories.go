@@ -0,0 +1,63 @@
+// calendar/repositories.go
+package calendar
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+
+	"github.com/jinzhu/gorm"
+)
+
+type CalendarRepository struct {
+	database *gorm.DB
+}
+
+func (repository *CalendarRepository) FindByOwner(owner string) (Feed, error) {
+	var feed Feed
+	err := repository.database.Where("owner = ?", owner).First(&feed).Error
+	if gorm.IsRecordNotFoundError(err) {
+		err = errors.New("Calendar feed not found")
+	}
+	return feed, err
+}
+
+func (repository *CalendarRepository) FindByToken(token string) (Feed, error) {
+	var feed Feed
+	err := repository.database.Where("token = ?", token).First(&feed).Error
+	if gorm.IsRecordNotFoundError(err) {
+		err = errors.New("Calendar feed not found")
+	}
+	return feed, err
+}
+
+// Rotate gives the owner a feed with a new token, revoking the previous URL.
+func (repository *CalendarRepository) Rotate(owner string) (Feed, error) {
+	var feed Feed
+	err := repository.database.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("owner = ?", owner).Delete(&Feed{}).Error; err != nil {
+			return err
+		}
+		feed = Feed{Owner: owner, Token: randomHex(24)}
+		return tx.Create(&feed).Error
+	})
+	return feed, err
+}
+
+func (repository *CalendarRepository) Delete(owner string) int64 {
+	return repository.database.Where("owner = ?", owner).Delete(&Feed{}).RowsAffected
+}
+
+func randomHex(n int) string {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(buf)
+}
+
+func NewCalendarRepository(database *gorm.DB) *CalendarRepository {
+	return &CalendarRepository{
+		database: database,
+	}
+}
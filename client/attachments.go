@@ -0,0 +1,85 @@
+// client/attachments.go
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"time"
+)
+
+type Attachment struct {
+	ID          uint      `json:"id"`
+	TodoID      uint      `json:"todo_id"`
+	Filename    string    `json:"filename"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	Hash        string    `json:"hash"`
+	Uploader    string    `json:"uploader"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// ListAttachments returns the files attached to a todo.
+func (client *Client) ListAttachments(ctx context.Context, todoID uint) ([]Attachment, error) {
+	var attachments []Attachment
+	_, err := client.do(ctx, request{method: "GET", path: attachmentsPath(todoID)}, &attachments)
+	return attachments, err
+}
+
+// UploadAttachment attaches the content read from r to a todo, as a file
+// named filename. The server detects its type from the content.
+func (client *Client) UploadAttachment(ctx context.Context, todoID uint, filename string, r io.Reader) (Attachment, error) {
+	// The form is built in memory so that the upload can be retried.
+	var form bytes.Buffer
+	writer := multipart.NewWriter(&form)
+	part, err := writer.CreateFormFile("file", filename)
+	if err != nil {
+		return Attachment{}, err
+	}
+	if _, err := io.Copy(part, r); err != nil {
+		return Attachment{}, err
+	}
+	if err := writer.Close(); err != nil {
+		return Attachment{}, err
+	}
+
+	var attachments []Attachment
+	_, err = client.do(ctx, request{
+		method:      "POST",
+		path:        attachmentsPath(todoID),
+		rawBody:     bytes.NewReader(form.Bytes()),
+		contentType: writer.FormDataContentType(),
+	}, &attachments)
+	if err != nil {
+		return Attachment{}, err
+	}
+	if len(attachments) == 0 {
+		return Attachment{}, fmt.Errorf("uploading %s: the server stored no attachment", filename)
+	}
+	return attachments[0], nil
+}
+
+// OpenAttachment returns the content of an attachment, which the caller must
+// close.
+func (client *Client) OpenAttachment(ctx context.Context, todoID, id uint) (io.ReadCloser, error) {
+	res, err := client.send(ctx, request{method: "GET", path: attachmentPath(todoID, id)})
+	if err != nil {
+		return nil, err
+	}
+	return res.Body, nil
+}
+
+func (client *Client) DeleteAttachment(ctx context.Context, todoID, id uint) error {
+	_, err := client.do(ctx, request{method: "DELETE", path: attachmentPath(todoID, id)}, nil)
+	return err
+}
+
+func attachmentsPath(todoID uint) string {
+	return todoPath(todoID) + "/attachments"
+}
+
+func attachmentPath(todoID, id uint) string {
+	return fmt.Sprintf("%s/%d", attachmentsPath(todoID), id)
+}
@@ -0,0 +1,47 @@
+// client/auth.go
+package client
+
+import "net/http"
+
+// Authenticator sets the credentials of a request before it is sent, and
+// again before every retry, so that it can renew expiring tokens.
+type Authenticator interface {
+	Authenticate(req *http.Request) error
+}
+
+// AuthenticatorFunc turns a function into an Authenticator.
+type AuthenticatorFunc func(req *http.Request) error
+
+func (fn AuthenticatorFunc) Authenticate(req *http.Request) error {
+	return fn(req)
+}
+
+// BearerToken authenticates with a fixed token in the Authorization header.
+func BearerToken(token string) Authenticator {
+	return AuthenticatorFunc(func(req *http.Request) error {
+		req.Header.Set("Authorization", "Bearer "+token)
+		return nil
+	})
+}
+
+// TokenSource authenticates with the bearer token returned by source, which
+// is asked for one on every request.
+func TokenSource(source func() (string, error)) Authenticator {
+	return AuthenticatorFunc(func(req *http.Request) error {
+		token, err := source()
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		return nil
+	})
+}
+
+// UserHeader acts as user through the X-User header, which servers trust
+// when they are reached without an authenticating proxy.
+func UserHeader(user string) Authenticator {
+	return AuthenticatorFunc(func(req *http.Request) error {
+		req.Header.Set("X-User", user)
+		return nil
+	})
+}
@@ -0,0 +1,36 @@
+// client/calendar.go
+package client
+
+import (
+	"context"
+	"time"
+)
+
+// CalendarFeed is the user's secret calendar subscription.
+type CalendarFeed struct {
+	Owner string `json:"owner"`
+	// URL is where calendar apps subscribe to the feed. It is only returned
+	// when the feed is created.
+	URL       string    `json:"url"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// GetCalendarFeed returns the calendar feed of the user, without its URL.
+func (client *Client) GetCalendarFeed(ctx context.Context) (CalendarFeed, error) {
+	var feed CalendarFeed
+	_, err := client.do(ctx, request{method: "GET", path: "/calendar/feed"}, &feed)
+	return feed, err
+}
+
+// CreateCalendarFeed issues a new feed URL, revoking the previous one.
+func (client *Client) CreateCalendarFeed(ctx context.Context) (CalendarFeed, error) {
+	var feed CalendarFeed
+	_, err := client.do(ctx, request{method: "POST", path: "/calendar/feed"}, &feed)
+	return feed, err
+}
+
+// DeleteCalendarFeed revokes the feed URL of the user.
+func (client *Client) DeleteCalendarFeed(ctx context.Context) error {
+	_, err := client.do(ctx, request{method: "DELETE", path: "/calendar/feed"}, nil)
+	return err
+}
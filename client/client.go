@@ -0,0 +1,222 @@
+// client/client.go
+// Package client calls the todo API over HTTP. It speaks v2 of the API, in
+// which every field is in snake_case.
+//
+// Every call takes a context. Errors answered by the API are *Error values;
+// reads, and writes sent with an Idempotency-Key, are retried with backoff
+// when the server is overloaded or out of reach.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// DefaultServer is the server of a local development setup.
+const DefaultServer = "http://localhost:5000"
+
+// apiPrefix is where v2 of the API is served. Routes shared by every
+// version, such as /versions, are under /api alone.
+const (
+	apiPrefix    = "/api/v2"
+	sharedPrefix = "/api"
+)
+
+type Client struct {
+	server          string
+	httpClient      *http.Client
+	auth            []Authenticator
+	retry           RetryPolicy
+	idempotencyKeys bool
+}
+
+type Option func(*Client)
+
+// WithHTTPClient sends requests through httpClient instead of
+// http.DefaultClient.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(client *Client) {
+		client.httpClient = httpClient
+	}
+}
+
+// WithAuth authenticates requests with authenticator, after those given
+// before it.
+func WithAuth(authenticator Authenticator) Option {
+	return func(client *Client) {
+		client.auth = append(client.auth, authenticator)
+	}
+}
+
+// WithToken authenticates requests with a bearer token.
+func WithToken(token string) Option {
+	return WithAuth(BearerToken(token))
+}
+
+// WithUser sends requests on behalf of user, for servers reached without
+// the proxy that sets the user.
+func WithUser(user string) Option {
+	return WithAuth(UserHeader(user))
+}
+
+// WithRetry replaces DefaultRetryPolicy.
+func WithRetry(policy RetryPolicy) Option {
+	return func(client *Client) {
+		client.retry = policy
+	}
+}
+
+// WithIdempotencyKeys sends a new Idempotency-Key with every POST, so that
+// creating calls are retried like the others without being applied twice.
+func WithIdempotencyKeys() Option {
+	return func(client *Client) {
+		client.idempotencyKeys = true
+	}
+}
+
+// New returns a client of the API served at server, such as
+// http://localhost:5000.
+func New(server string, options ...Option) *Client {
+	client := &Client{
+		server:     strings.TrimRight(server, "/"),
+		httpClient: http.DefaultClient,
+		retry:      DefaultRetryPolicy,
+	}
+	for _, option := range options {
+		option(client)
+	}
+	return client
+}
+
+// request is a call of the API.
+type request struct {
+	method string
+	// prefix is where path is, apiPrefix when empty.
+	prefix      string
+	path        string
+	query       map[string]string
+	headers     map[string]string
+	body        interface{}
+	rawBody     io.Reader
+	contentType string
+}
+
+// send makes the request and returns the response when it succeeded, or
+// the error the API answered with. Requests that are safe to repeat are
+// retried as the retry policy allows.
+func (client *Client) send(ctx context.Context, r request) (*http.Response, error) {
+	var data []byte
+	if r.body != nil {
+		var err error
+		if data, err = json.Marshal(r.body); err != nil {
+			return nil, err
+		}
+		r.contentType = "application/json"
+	}
+
+	key := idempotencyKeyOf(ctx)
+	if key == "" && client.idempotencyKeys && r.method == http.MethodPost {
+		key = newIdempotencyKey()
+	}
+	// A PUT or DELETE repeated after its response was lost gets the first
+	// answer under its key, instead of failing its If-Match on its own
+	// change.
+	if key == "" && (r.method == http.MethodPut || r.method == http.MethodDelete) && client.retry.Attempts > 1 {
+		key = newIdempotencyKey()
+	}
+	// A streamed body can only be sent again if it can be rewound.
+	seeker, rewindable := r.rawBody.(io.Seeker)
+	retryable := (safe(r.method) || key != "") && (r.rawBody == nil || rewindable)
+
+	for attempt := 1; ; attempt++ {
+		body := r.rawBody
+		if data != nil {
+			body = bytes.NewReader(data)
+		} else if attempt > 1 && rewindable {
+			if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+				return nil, err
+			}
+		}
+
+		req, err := client.newRequest(ctx, r, body)
+		if err != nil {
+			return nil, err
+		}
+		if key != "" {
+			req.Header.Set(IdempotencyKeyHeader, key)
+		}
+
+		res, err := client.httpClient.Do(req)
+		if err == nil && res.StatusCode < 400 {
+			return res, nil
+		}
+		if err == nil {
+			err = decodeError(res)
+			res.Body.Close()
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if !retryable || attempt >= client.retry.Attempts || !shouldRetry(err, key != "") {
+			return nil, err
+		}
+		if err := client.retry.wait(ctx, attempt, err); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (client *Client) newRequest(ctx context.Context, r request, body io.Reader) (*http.Request, error) {
+	prefix := r.prefix
+	if prefix == "" {
+		prefix = apiPrefix
+	}
+	req, err := http.NewRequestWithContext(ctx, r.method, client.server+prefix+r.path, body)
+	if err != nil {
+		return nil, err
+	}
+	query := req.URL.Query()
+	for key, value := range r.query {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	req.URL.RawQuery = query.Encode()
+	req.Header.Set("Accept", "application/json")
+	if r.contentType != "" {
+		req.Header.Set("Content-Type", r.contentType)
+	}
+	for key, value := range r.headers {
+		req.Header.Set(key, value)
+	}
+	for _, authenticator := range client.auth {
+		if err := authenticator.Authenticate(req); err != nil {
+			return nil, fmt.Errorf("authenticating %s %s: %v", r.method, r.path, err)
+		}
+	}
+	return req, nil
+}
+
+// do makes the request and decodes the JSON response into out, unless out
+// is nil.
+func (client *Client) do(ctx context.Context, r request, out interface{}) (*http.Response, error) {
+	res, err := client.send(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if out == nil || res.StatusCode == http.StatusNoContent {
+		io.Copy(ioutil.Discard, res.Body)
+		return res, nil
+	}
+	if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+		return res, fmt.Errorf("decoding response to %s %s: %v", r.method, r.path, err)
+	}
+	return res, nil
+}
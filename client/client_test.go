@@ -0,0 +1,279 @@
+// client/client_test.go
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/imadbg01/go-todo/outbox"
+	"github.com/imadbg01/go-todo/server"
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+)
+
+// serve runs the app main serves on a fresh SQLite database and returns
+// its address.
+func serve(t *testing.T) string {
+	db, err := gorm.Open("sqlite3", filepath.Join(t.TempDir(), "client.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.DB().SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	db.AutoMigrate(&outbox.Message{})
+
+	app, _ := server.New(db, "")
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go app.Listener(listener)
+	t.Cleanup(func() { app.Shutdown() })
+	return "http://" + listener.Addr().String()
+}
+
+// fastRetry retries as the default policy does, without the waits.
+var fastRetry = RetryPolicy{Attempts: 3, MinBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond}
+
+func TestTodos(t *testing.T) {
+	ctx := context.Background()
+	c := New(serve(t), WithRetry(fastRetry))
+
+	created, err := c.CreateTodo(ctx, TodoInput{Name: "Buy milk", Priority: PriorityHigh})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.ID == 0 || created.Name != "Buy milk" || created.Priority != PriorityHigh || created.CreatedAt.IsZero() {
+		t.Fatalf("created %+v", created)
+	}
+
+	fetched, err := c.GetTodo(ctx, created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fetched.ETag == "" {
+		t.Fatal("GetTodo returned no ETag")
+	}
+	input := fetched.Input()
+	input.Status = StatusDone
+	updated, err := c.UpdateTodo(ctx, created.ID, input, fetched.ETag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Status != StatusDone || updated.ETag == fetched.ETag {
+		t.Errorf("updated %+v", updated)
+	}
+	if _, err := c.UpdateTodo(ctx, created.ID, input, fetched.ETag); !IsPreconditionFailed(err) {
+		t.Errorf("update with a stale ETag = %v, want 412", err)
+	}
+
+	revisions, err := c.TodoHistory(ctx, created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revisions) != 2 || revisions[1].Action != "update" || revisions[1].Changes["status"].After != StatusDone {
+		t.Errorf("history %+v", revisions)
+	}
+
+	if err := c.DeleteTodo(ctx, created.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetTodo(ctx, created.ID); !IsNotFound(err) {
+		t.Errorf("GetTodo after delete = %v, want 404", err)
+	}
+	if _, err := c.RestoreTodo(ctx, created.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = c.CreateTodo(ctx, TodoInput{Name: "Plan", Priority: "whenever"})
+	var apiError *Error
+	if !IsValidation(err) || !errors.As(err, &apiError) || apiError.Message == "" {
+		t.Errorf("invalid create = %v, want a 400 with a message", err)
+	}
+}
+
+func TestPages(t *testing.T) {
+	ctx := context.Background()
+	c := New(serve(t))
+	for _, name := range []string{"one", "two", "three", "four", "five"} {
+		if _, err := c.CreateTodo(ctx, TodoInput{Name: name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	page, err := c.ListTodosPage(ctx, ListOptions{PageSize: 2}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Todos) != 2 || page.Total != 5 || page.Next == "" {
+		t.Fatalf("first page = %d todos of %d, next %q", len(page.Todos), page.Total, page.Next)
+	}
+
+	var names []string
+	todos := c.Todos(ctx, ListOptions{PageSize: 2})
+	for todos.Next() {
+		names = append(names, todos.Todo().Name)
+	}
+	if err := todos.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(names, ",") != "one,two,three,four,five" {
+		t.Errorf("iterated %v", names)
+	}
+}
+
+func TestSync(t *testing.T) {
+	ctx := context.Background()
+	c := New(serve(t))
+
+	changes, err := c.Pull(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changes.Full || changes.Token == "" {
+		t.Fatalf("first pull = %+v", changes)
+	}
+
+	mutations := []Mutation{{Op: SyncCreate, UID: "offline-1", Todo: &TodoInput{Name: "Written offline"}}}
+	results, err := c.Push(ctx, mutations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Status != SyncApplied || results[0].ID == 0 {
+		t.Fatalf("push = %+v", results)
+	}
+	if again, err := c.Push(ctx, mutations); err != nil || again[0].ID != results[0].ID {
+		t.Errorf("pushing the create again = %+v, %v, want todo %d", again, err, results[0].ID)
+	}
+
+	changes, err = c.Pull(ctx, changes.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changes.Full || len(changes.Changed) != 1 || changes.Changed[0].UID != "offline-1" {
+		t.Errorf("pull since the first = %+v", changes)
+	}
+}
+
+func TestImportExport(t *testing.T) {
+	ctx := context.Background()
+	c := New(serve(t))
+
+	report, err := c.Import(ctx, FormatTodoTxt, strings.NewReader("(A) Call mom +family\nx Pay rent\n"), ImportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Created != 2 || report.Failed != 0 {
+		t.Fatalf("import = %+v", report)
+	}
+
+	var exported bytes.Buffer
+	if err := c.Export(ctx, FormatTodoTxt, &exported); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(exported.String(), "Call mom +family") || !strings.Contains(exported.String(), "x Pay rent") {
+		t.Errorf("export = %q", exported.String())
+	}
+}
+
+// loser proxies to the app but answers the first write it forwards with a
+// 502, as when the connection drops after the server applied the write.
+type loser struct {
+	proxy *httputil.ReverseProxy
+	mu    sync.Mutex
+	lost  bool
+	keys  []string
+}
+
+func (l *loser) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		l.proxy.ServeHTTP(w, r)
+		return
+	}
+	l.mu.Lock()
+	l.keys = append(l.keys, r.Header.Get(IdempotencyKeyHeader))
+	lose := !l.lost
+	l.lost = true
+	l.mu.Unlock()
+	if !lose {
+		l.proxy.ServeHTTP(w, r)
+		return
+	}
+	l.proxy.ServeHTTP(httptest.NewRecorder(), r)
+	w.WriteHeader(http.StatusBadGateway)
+}
+
+func (l *loser) reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lost = false
+	l.keys = nil
+}
+
+// TestRetriedWrites checks that a conditional update or a delete whose
+// response was lost is retried under the same Idempotency-Key, and gets
+// the first answer instead of failing on its own change.
+func TestRetriedWrites(t *testing.T) {
+	ctx := context.Background()
+	target, _ := url.Parse(serve(t))
+	l := &loser{proxy: httputil.NewSingleHostReverseProxy(target), lost: true}
+	proxy := httptest.NewServer(l)
+	defer proxy.Close()
+	c := New(proxy.URL, WithRetry(fastRetry))
+
+	created, err := c.CreateTodo(ctx, TodoInput{Name: "Buy milk"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fetched, err := c.GetTodo(ctx, created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l.reset()
+	input := fetched.Input()
+	input.Status = StatusDone
+	updated, err := c.UpdateTodo(ctx, created.ID, input, fetched.ETag)
+	if err != nil {
+		t.Fatalf("retried conditional update = %v", err)
+	}
+	if updated.Status != StatusDone {
+		t.Errorf("retried update answered %+v", updated)
+	}
+	if len(l.keys) != 2 || l.keys[0] == "" || l.keys[0] != l.keys[1] {
+		t.Errorf("update sent keys %q, want one key twice", l.keys)
+	}
+
+	l.reset()
+	if err := c.DeleteTodo(ctx, created.ID); err != nil {
+		t.Fatalf("retried delete = %v", err)
+	}
+	if len(l.keys) != 2 || l.keys[0] == "" || l.keys[0] != l.keys[1] {
+		t.Errorf("delete sent keys %q, want one key twice", l.keys)
+	}
+
+	// Without retries there is nothing to key.
+	l.reset()
+	l.lost = true
+	once := New(proxy.URL, WithRetry(NoRetry))
+	if _, err := once.RestoreTodo(ctx, created.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := once.DeleteTodo(ctx, created.ID); err != nil {
+		t.Fatal(err)
+	}
+	if len(l.keys) != 2 || l.keys[1] != "" {
+		t.Errorf("delete without retries sent keys %q", l.keys)
+	}
+}
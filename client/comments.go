@@ -0,0 +1,87 @@
+// client/comments.go
+package client
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+type Comment struct {
+	ID        uint              `json:"id"`
+	TodoID    uint              `json:"todo_id"`
+	ParentID  *uint             `json:"parent_id"`
+	Author    string            `json:"author"`
+	Body      string            `json:"body"`
+	Edited    bool              `json:"edited"`
+	Deleted   bool              `json:"deleted"`
+	Mentions  []string          `json:"mentions"`
+	Replies   []Comment         `json:"replies"`
+	Revisions []CommentRevision `json:"revisions"`
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+// CommentRevision is the body a comment had before an edit.
+type CommentRevision struct {
+	ID        uint      `json:"id"`
+	CommentID uint      `json:"comment_id"`
+	Body      string    `json:"body"`
+	Editor    string    `json:"editor"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ListComments returns the comments of a todo as threads: the top level
+// comments with their replies.
+func (client *Client) ListComments(ctx context.Context, todoID uint) ([]Comment, error) {
+	var comments []Comment
+	_, err := client.do(ctx, request{method: "GET", path: commentsPath(todoID)}, &comments)
+	return comments, err
+}
+
+// GetComment returns a comment with its revisions.
+func (client *Client) GetComment(ctx context.Context, todoID, id uint) (Comment, error) {
+	var comment Comment
+	_, err := client.do(ctx, request{method: "GET", path: commentPath(todoID, id)}, &comment)
+	return comment, err
+}
+
+// CreateComment comments on a todo, or replies to the comment parentID when
+// it is not zero. Users @mentioned in body are notified.
+func (client *Client) CreateComment(ctx context.Context, todoID uint, body string, parentID uint) (Comment, error) {
+	input := struct {
+		ParentID *uint  `json:"parent_id,omitempty"`
+		Body     string `json:"body"`
+	}{Body: body}
+	if parentID != 0 {
+		input.ParentID = &parentID
+	}
+	var comment Comment
+	_, err := client.do(ctx, request{method: "POST", path: commentsPath(todoID), body: input}, &comment)
+	return comment, err
+}
+
+// UpdateComment replaces the body of a comment, keeping the previous one as
+// a revision. Only its author can.
+func (client *Client) UpdateComment(ctx context.Context, todoID, id uint, body string) (Comment, error) {
+	input := struct {
+		Body string `json:"body"`
+	}{body}
+	var comment Comment
+	_, err := client.do(ctx, request{method: "PUT", path: commentPath(todoID, id), body: input}, &comment)
+	return comment, err
+}
+
+// DeleteComment deletes a comment. Only its author can.
+func (client *Client) DeleteComment(ctx context.Context, todoID, id uint) error {
+	_, err := client.do(ctx, request{method: "DELETE", path: commentPath(todoID, id)}, nil)
+	return err
+}
+
+func commentsPath(todoID uint) string {
+	return todoPath(todoID) + "/comments"
+}
+
+func commentPath(todoID, id uint) string {
+	return fmt.Sprintf("%s/%d", commentsPath(todoID), id)
+}
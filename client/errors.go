@@ -0,0 +1,127 @@
+// client/errors.go
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Error is an error answered by the API, with the fields of its body.
+type Error struct {
+	StatusCode int
+	Message    string
+	// Detail is the error field of the body, which the server fills with
+	// the cause of the error.
+	Detail string
+	// RequestID is the X-Request-Id of the response, to find the request in
+	// the server's logs.
+	RequestID string
+	// RetryAfter is how long the server asked to wait before trying again,
+	// when it did.
+	RetryAfter time.Duration
+}
+
+func (err *Error) Error() string {
+	message := err.Message
+	if message == "" {
+		message = http.StatusText(err.StatusCode)
+	}
+	if err.Detail != "" {
+		return fmt.Sprintf("%s (%d): %s", message, err.StatusCode, err.Detail)
+	}
+	return fmt.Sprintf("%s (%d)", message, err.StatusCode)
+}
+
+// StatusOf returns the status of the response err was answered with, or zero
+// when err is not an API error.
+func StatusOf(err error) int {
+	var apiError *Error
+	if errors.As(err, &apiError) {
+		return apiError.StatusCode
+	}
+	return 0
+}
+
+// IsNotFound tells whether err is an API error with status 404.
+func IsNotFound(err error) bool {
+	return StatusOf(err) == http.StatusNotFound
+}
+
+// IsForbidden tells whether the server refused the call, such as when the
+// todo quota is reached or a comment is someone else's.
+func IsForbidden(err error) bool {
+	return StatusOf(err) == http.StatusForbidden
+}
+
+// IsConflict tells whether the call conflicted with another one, such as a
+// concurrent update or a request with the same Idempotency-Key.
+func IsConflict(err error) bool {
+	return StatusOf(err) == http.StatusConflict
+}
+
+// IsPreconditionFailed tells whether a todo changed since the ETag given to
+// an update was read.
+func IsPreconditionFailed(err error) bool {
+	return StatusOf(err) == http.StatusPreconditionFailed
+}
+
+// IsRateLimited tells whether the call was refused by a rate limit.
+func IsRateLimited(err error) bool {
+	return StatusOf(err) == http.StatusTooManyRequests
+}
+
+// IsValidation tells whether the server found the input invalid.
+func IsValidation(err error) bool {
+	status := StatusOf(err)
+	return status == http.StatusBadRequest || status == http.StatusUnprocessableEntity
+}
+
+func decodeError(res *http.Response) error {
+	apiError := &Error{
+		StatusCode: res.StatusCode,
+		RequestID:  res.Header.Get("X-Request-Id"),
+		RetryAfter: retryAfter(res.Header.Get("Retry-After")),
+	}
+	var body struct {
+		Message string          `json:"message"`
+		Error   json.RawMessage `json:"error"`
+	}
+	data, _ := ioutil.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if json.Unmarshal(data, &body) != nil {
+		apiError.Detail = strings.TrimSpace(string(data))
+		return apiError
+	}
+	apiError.Message = body.Message
+	// The server answers some errors with a string and others with the
+	// error value it got, encoded as an object.
+	var detail string
+	if json.Unmarshal(body.Error, &detail) == nil {
+		apiError.Detail = detail
+	} else if len(body.Error) > 0 && string(body.Error) != "null" && string(body.Error) != "{}" {
+		apiError.Detail = string(body.Error)
+	}
+	return apiError
+}
+
+// retryAfter reads a Retry-After header given in seconds or as a date.
+func retryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if wait := time.Until(at); wait > 0 {
+			return wait
+		}
+	}
+	return 0
+}
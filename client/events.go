@@ -0,0 +1,127 @@
+// client/events.go
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// EventReset is sent instead of the events a stream fell too far behind to
+// receive. Clients should fetch the todos again before following on.
+const EventReset = "reset"
+
+// Event is a change made to a todo, as sent by the change feed.
+type Event struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	Todo Todo   `json:"todo"`
+}
+
+// EventOptions narrow the events of a stream. Empty fields match every
+// event.
+type EventOptions struct {
+	Types  []string
+	Status []string
+	IDs    []uint
+	// LastEventID resumes a stream after the event with this ID.
+	LastEventID string
+}
+
+// EventStream reads the events of the change feed as they happen.
+type EventStream struct {
+	body    io.ReadCloser
+	scanner *bufio.Scanner
+	event   Event
+	lastID  string
+	err     error
+}
+
+// Events follows the changes made to todos until ctx is done or the stream
+// is closed. A stream that ends can be resumed after LastEventID.
+func (client *Client) Events(ctx context.Context, options EventOptions) (*EventStream, error) {
+	ids := make([]string, len(options.IDs))
+	for i, id := range options.IDs {
+		ids[i] = fmt.Sprint(id)
+	}
+	r := request{
+		method: "GET",
+		path:   "/todo/events",
+		query: map[string]string{
+			"types":  strings.Join(options.Types, ","),
+			"status": strings.Join(options.Status, ","),
+			"ids":    strings.Join(ids, ","),
+		},
+		headers: map[string]string{"Accept": "text/event-stream"},
+	}
+	if options.LastEventID != "" {
+		r.headers["Last-Event-ID"] = options.LastEventID
+	}
+	res, err := client.send(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	scanner := bufio.NewScanner(res.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	return &EventStream{body: res.Body, scanner: scanner, lastID: options.LastEventID}, nil
+}
+
+// Next waits for the next event. It returns false when the stream ends,
+// because of an error, ctx or Close.
+func (stream *EventStream) Next() bool {
+	var id, data string
+	for stream.scanner.Scan() {
+		line := stream.scanner.Text()
+		switch {
+		case line == "":
+			if data == "" {
+				id = ""
+				continue
+			}
+			var event Event
+			if err := json.Unmarshal([]byte(data), &event); err != nil {
+				stream.err = fmt.Errorf("decoding event %s: %v", id, err)
+				return false
+			}
+			if event.ID == "" {
+				event.ID = id
+			}
+			stream.event = event
+			stream.lastID = event.ID
+			return true
+		case strings.HasPrefix(line, ":"):
+			// A comment, sent to keep the connection open.
+		case strings.HasPrefix(line, "id:"):
+			id = strings.TrimSpace(strings.TrimPrefix(line, "id:"))
+		case strings.HasPrefix(line, "data:"):
+			if data != "" {
+				data += "\n"
+			}
+			data += strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " ")
+		}
+	}
+	stream.err = stream.scanner.Err()
+	return false
+}
+
+// Event returns the event Next waited for.
+func (stream *EventStream) Event() Event {
+	return stream.event
+}
+
+// LastEventID is the ID of the last event read, to resume the stream after.
+func (stream *EventStream) LastEventID() string {
+	return stream.lastID
+}
+
+// Err returns the error that ended the stream, if any.
+func (stream *EventStream) Err() error {
+	return stream.err
+}
+
+func (stream *EventStream) Close() error {
+	return stream.body.Close()
+}
@@ -0,0 +1,132 @@
+// client/pages.go
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// ListOptions narrow and page a listing of todos. Empty fields match every
+// todo.
+type ListOptions struct {
+	Status   []string
+	Priority []string
+	// PageSize is how many todos a page holds, 100 when zero and at most 500.
+	PageSize int
+}
+
+// TodoPage is one page of a listing.
+type TodoPage struct {
+	Todos []Todo
+	// Total counts the todos of every page.
+	Total int
+	// Next is the cursor of the next page, empty on the last one.
+	Next string
+}
+
+// ListTodosPage returns the page of todos starting at cursor, or the first
+// page when cursor is empty.
+func (client *Client) ListTodosPage(ctx context.Context, options ListOptions, cursor string) (TodoPage, error) {
+	pageSize := options.PageSize
+	if pageSize == 0 {
+		pageSize = 100
+	}
+	var page TodoPage
+	res, err := client.do(ctx, request{
+		method: "GET",
+		path:   "/todo",
+		query: map[string]string{
+			"limit":    strconv.Itoa(pageSize),
+			"after":    cursor,
+			"status":   strings.Join(options.Status, ","),
+			"priority": strings.Join(options.Priority, ","),
+		},
+	}, &page.Todos)
+	if err != nil {
+		return page, err
+	}
+	page.Total, _ = strconv.Atoi(res.Header.Get("X-Total-Count"))
+	page.Next = nextCursor(res.Header)
+	return page, nil
+}
+
+// nextCursor reads the cursor of the next page from the Link header.
+func nextCursor(header http.Header) string {
+	for _, link := range strings.Split(header.Get("Link"), ",") {
+		parts := strings.Split(link, ";")
+		if len(parts) < 2 || strings.TrimSpace(parts[1]) != `rel="next"` {
+			continue
+		}
+		target, err := url.Parse(strings.Trim(strings.TrimSpace(parts[0]), "<>"))
+		if err != nil {
+			return ""
+		}
+		return target.Query().Get("after")
+	}
+	return ""
+}
+
+// TodoIterator goes through a listing of todos a page at a time:
+//
+//	todos := c.Todos(ctx, client.ListOptions{Status: []string{client.StatusPending}})
+//	for todos.Next() {
+//		fmt.Println(todos.Todo().Name)
+//	}
+//	if err := todos.Err(); err != nil {
+//		...
+//	}
+type TodoIterator struct {
+	ctx     context.Context
+	client  *Client
+	options ListOptions
+	page    TodoPage
+	index   int
+	started bool
+	err     error
+}
+
+// Todos returns an iterator over the todos matching options, in list order.
+func (client *Client) Todos(ctx context.Context, options ListOptions) *TodoIterator {
+	return &TodoIterator{ctx: ctx, client: client, options: options, index: -1}
+}
+
+// Next moves to the next todo, fetching the next page when needed. It
+// returns false at the end of the listing or on an error.
+func (iterator *TodoIterator) Next() bool {
+	if iterator.err != nil {
+		return false
+	}
+	iterator.index++
+	for iterator.index >= len(iterator.page.Todos) {
+		if iterator.started && iterator.page.Next == "" {
+			return false
+		}
+		page, err := iterator.client.ListTodosPage(iterator.ctx, iterator.options, iterator.page.Next)
+		if err != nil {
+			iterator.err = err
+			return false
+		}
+		iterator.page = page
+		iterator.index = 0
+		iterator.started = true
+	}
+	return true
+}
+
+// Todo returns the todo Next moved to.
+func (iterator *TodoIterator) Todo() Todo {
+	return iterator.page.Todos[iterator.index]
+}
+
+// Total counts the todos of the listing, once Next has been called.
+func (iterator *TodoIterator) Total() int {
+	return iterator.page.Total
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (iterator *TodoIterator) Err() error {
+	return iterator.err
+}
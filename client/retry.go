@@ -0,0 +1,115 @@
+// client/retry.go
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"math"
+	mathrand "math/rand"
+	"net/http"
+	"time"
+)
+
+// IdempotencyKeyHeader is the header the server remembers writes by, to
+// answer a repeated one with the first response instead of applying it
+// again.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// RetryPolicy says how often and how patiently calls are retried. Only calls
+// that are safe to repeat are: GET and HEAD, and writes with an
+// Idempotency-Key. PUT and DELETE always send one when they may be retried,
+// since a conditional write repeated after its response was lost would
+// otherwise fail on its own change; POSTs send one with WithIdempotencyKeys.
+type RetryPolicy struct {
+	// Attempts is the most times a call is made, one for no retries.
+	Attempts int
+	// MinBackoff is the wait before the first retry, doubled for each
+	// following one up to MaxBackoff. A Retry-After sent by the server
+	// takes precedence.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	Attempts:   3,
+	MinBackoff: 250 * time.Millisecond,
+	MaxBackoff: 10 * time.Second,
+}
+
+// NoRetry makes every call once.
+var NoRetry = RetryPolicy{Attempts: 1}
+
+// backoff is the wait before the retry following attempt, with up to a
+// fifth of jitter so that clients that failed together do not retry
+// together.
+func (policy RetryPolicy) backoff(attempt int, err error) time.Duration {
+	var apiError *Error
+	if errors.As(err, &apiError) && apiError.RetryAfter > 0 {
+		if policy.MaxBackoff > 0 && apiError.RetryAfter > policy.MaxBackoff {
+			return policy.MaxBackoff
+		}
+		return apiError.RetryAfter
+	}
+	wait := time.Duration(float64(policy.MinBackoff) * math.Pow(2, float64(attempt-1)))
+	if policy.MaxBackoff > 0 && wait > policy.MaxBackoff {
+		wait = policy.MaxBackoff
+	}
+	if wait > 0 {
+		wait -= time.Duration(mathrand.Int63n(int64(wait)/5 + 1))
+	}
+	return wait
+}
+
+func (policy RetryPolicy) wait(ctx context.Context, attempt int, err error) error {
+	timer := time.NewTimer(policy.backoff(attempt, err))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func safe(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead
+}
+
+// shouldRetry tells whether a call that failed with err may succeed when
+// made again: when the server could not be reached, was overloaded or rate
+// limited it, or was still serving the first request with the same
+// Idempotency-Key.
+func shouldRetry(err error, keyed bool) bool {
+	switch StatusOf(err) {
+	case 0:
+		return true
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	case http.StatusConflict:
+		var apiError *Error
+		return keyed && errors.As(err, &apiError) && apiError.RetryAfter > 0
+	}
+	return false
+}
+
+type idempotencyKey struct{}
+
+// WithIdempotencyKey makes the calls made with ctx send key as their
+// Idempotency-Key, for callers that keep the key to retry a call after a
+// restart.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKey{}, key)
+}
+
+func idempotencyKeyOf(ctx context.Context) string {
+	key, _ := ctx.Value(idempotencyKey{}).(string)
+	return key
+}
+
+func newIdempotencyKey() string {
+	key := make([]byte, 16)
+	rand.Read(key)
+	return hex.EncodeToString(key)
+}
@@ -0,0 +1,87 @@
+// client/sync.go
+package client
+
+import (
+	"context"
+	"time"
+)
+
+// Ops of sync mutations.
+const (
+	SyncCreate = "create"
+	SyncUpdate = "update"
+	SyncDelete = "delete"
+)
+
+// Statuses of sync results.
+const (
+	SyncApplied  = "applied"
+	SyncConflict = "conflict"
+	SyncError    = "error"
+)
+
+// Changes are the todos changed since a sync token. When Full is set they
+// are every todo, and whatever the client holds besides them is gone.
+type Changes struct {
+	Token   string      `json:"token"`
+	Full    bool        `json:"full"`
+	Changed []Todo      `json:"changed"`
+	Deleted []Tombstone `json:"deleted"`
+}
+
+// Tombstone stands for a deleted todo.
+type Tombstone struct {
+	ID        uint      `json:"id"`
+	UID       string    `json:"uid"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+// Mutation is a change made offline. A create names the todo by a UID the
+// client generates, which later mutations can use until the server ID is
+// known. BaseUpdatedAt is the UpdatedAt of the todo the change was made
+// to; when the server holds another version the mutation conflicts.
+type Mutation struct {
+	Op            string     `json:"op"`
+	ID            uint       `json:"id,omitempty"`
+	UID           string     `json:"uid,omitempty"`
+	BaseUpdatedAt *time.Time `json:"base_updated_at,omitempty"`
+	Todo          *TodoInput `json:"todo,omitempty"`
+}
+
+// MutationResult is the outcome of the mutation at the same index. Todo is
+// the server state after the mutation, or the one it conflicted with.
+type MutationResult struct {
+	Op      string `json:"op"`
+	ID      uint   `json:"id"`
+	UID     string `json:"uid"`
+	Status  string `json:"status"`
+	Todo    *Todo  `json:"todo"`
+	Deleted bool   `json:"deleted"`
+	Error   string `json:"error"`
+}
+
+// Pull returns the changes since the token of the previous pull, or every
+// todo for an empty token.
+func (client *Client) Pull(ctx context.Context, token string) (Changes, error) {
+	var changes Changes
+	_, err := client.do(ctx, request{
+		method: "GET",
+		path:   "/sync",
+		query:  map[string]string{"since": token},
+	}, &changes)
+	return changes, err
+}
+
+// Push sends changes made offline, at most 500 at a time, and returns the
+// result of each. Creates are named by UID, so pushing a batch again after
+// a failure does not create its todos twice.
+func (client *Client) Push(ctx context.Context, mutations []Mutation) ([]MutationResult, error) {
+	var response struct {
+		Results []MutationResult `json:"results"`
+	}
+	body := struct {
+		Mutations []Mutation `json:"mutations"`
+	}{mutations}
+	_, err := client.do(ctx, request{method: "POST", path: "/sync", body: body}, &response)
+	return response.Results, err
+}
@@ -0,0 +1,292 @@
+// client/todos.go
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+const (
+	StatusPending    = "pending"
+	StatusInProgress = "in_progress"
+	StatusDone       = "done"
+)
+
+const (
+	PriorityNone   = "none"
+	PriorityLow    = "low"
+	PriorityMedium = "medium"
+	PriorityHigh   = "high"
+	PriorityUrgent = "urgent"
+)
+
+// Formats of imports and exports. ICS is only exported.
+const (
+	FormatJSON    = "json"
+	FormatNDJSON  = "ndjson"
+	FormatCSV     = "csv"
+	FormatTodoTxt = "todotxt"
+	FormatICS     = "ics"
+)
+
+type Todo struct {
+	ID          uint       `json:"id"`
+	ExternalID  string     `json:"external_id"`
+	UID         string     `json:"uid"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Status      string     `json:"status"`
+	Priority    string     `json:"priority"`
+	Rank        float64    `json:"rank"`
+	Due         *time.Time `json:"due"`
+	TimeZone    string     `json:"time_zone"`
+	Recurrence  string     `json:"recurrence"`
+	RepeatFrom  string     `json:"repeat_from"`
+	SeriesID    uint       `json:"series_id"`
+	Occurrence  int        `json:"occurrence"`
+	CompletedAt *time.Time `json:"completed_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	// ETag is the version of the todo when it was fetched on its own, to
+	// update it only if nobody changed it since.
+	ETag string `json:"-"`
+}
+
+// TodoInput holds the fields of a todo that clients write. An update
+// replaces all of them.
+type TodoInput struct {
+	Name        string     `json:"name"`
+	Description string     `json:"description,omitempty"`
+	Status      string     `json:"status,omitempty"`
+	Priority    string     `json:"priority,omitempty"`
+	Due         *time.Time `json:"due"`
+	TimeZone    string     `json:"time_zone,omitempty"`
+	Recurrence  string     `json:"recurrence,omitempty"`
+	RepeatFrom  string     `json:"repeat_from,omitempty"`
+}
+
+// Input returns the writable fields of the todo, to update some of them.
+func (todo Todo) Input() TodoInput {
+	return TodoInput{
+		Name:        todo.Name,
+		Description: todo.Description,
+		Status:      todo.Status,
+		Priority:    todo.Priority,
+		Due:         todo.Due,
+		TimeZone:    todo.TimeZone,
+		Recurrence:  todo.Recurrence,
+		RepeatFrom:  todo.RepeatFrom,
+	}
+}
+
+type SearchResult struct {
+	Todo    Todo    `json:"todo"`
+	Rank    float64 `json:"rank"`
+	Snippet string  `json:"snippet"`
+}
+
+type ImportReport struct {
+	DryRun  bool          `json:"dry_run"`
+	Created int           `json:"created"`
+	Updated int           `json:"updated"`
+	Failed  int           `json:"failed"`
+	Errors  []ImportError `json:"errors"`
+}
+
+type ImportError struct {
+	Row        int    `json:"row"`
+	ExternalID string `json:"external_id,omitempty"`
+	Error      string `json:"error"`
+}
+
+// ImportOptions are the options of an import. Insert adds every record as
+// a new todo instead of updating those with a known external ID.
+type ImportOptions struct {
+	Insert bool
+	DryRun bool
+}
+
+// Revision is one change made to a todo, with the fields it changed.
+type Revision struct {
+	ID        uint                   `json:"id"`
+	TodoID    uint                   `json:"todo_id"`
+	Action    string                 `json:"action"`
+	Actor     string                 `json:"actor"`
+	RequestID string                 `json:"request_id"`
+	Changes   map[string]FieldChange `json:"changes"`
+	CreatedAt time.Time              `json:"created_at"`
+}
+
+type FieldChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// ListTodos returns every todo in list order.
+func (client *Client) ListTodos(ctx context.Context) ([]Todo, error) {
+	var todos []Todo
+	_, err := client.do(ctx, request{method: "GET", path: "/todo"}, &todos)
+	return todos, err
+}
+
+// GetTodo returns a todo with its ETag.
+func (client *Client) GetTodo(ctx context.Context, id uint) (Todo, error) {
+	var todo Todo
+	res, err := client.do(ctx, request{method: "GET", path: todoPath(id)}, &todo)
+	if err == nil {
+		todo.ETag = res.Header.Get("ETag")
+	}
+	return todo, err
+}
+
+func (client *Client) CreateTodo(ctx context.Context, input TodoInput) (Todo, error) {
+	var todo Todo
+	_, err := client.do(ctx, request{method: "POST", path: "/todo", body: input}, &todo)
+	return todo, err
+}
+
+// UpdateTodo replaces the writable fields of a todo. With an etag the update
+// only succeeds if the todo still has it.
+func (client *Client) UpdateTodo(ctx context.Context, id uint, input TodoInput, etag string) (Todo, error) {
+	r := request{method: "PUT", path: todoPath(id), body: input}
+	if etag != "" {
+		r.headers = map[string]string{"If-Match": etag}
+	}
+	var todo Todo
+	res, err := client.do(ctx, r, &todo)
+	if err == nil {
+		todo.ETag = res.Header.Get("ETag")
+	}
+	return todo, err
+}
+
+func (client *Client) DeleteTodo(ctx context.Context, id uint) error {
+	_, err := client.do(ctx, request{method: "DELETE", path: todoPath(id)}, nil)
+	return err
+}
+
+// SearchTodos returns up to limit todos matching query, best first.
+func (client *Client) SearchTodos(ctx context.Context, query string, limit int) ([]SearchResult, error) {
+	var results []SearchResult
+	_, err := client.do(ctx, request{
+		method: "GET",
+		path:   "/todo/search",
+		query:  map[string]string{"q": query, "limit": strconv.Itoa(limit)},
+	}, &results)
+	return results, err
+}
+
+// GetTodoAsOf returns a todo as it was at a time, rebuilt from its history.
+func (client *Client) GetTodoAsOf(ctx context.Context, id uint, at time.Time) (Todo, error) {
+	var todo Todo
+	_, err := client.do(ctx, request{
+		method: "GET",
+		path:   todoPath(id),
+		query:  map[string]string{"as_of": at.Format(time.RFC3339Nano)},
+	}, &todo)
+	return todo, err
+}
+
+// Occurrences returns when the next count occurrences of a recurring todo
+// are due.
+func (client *Client) Occurrences(ctx context.Context, id uint, count int) ([]time.Time, error) {
+	var body struct {
+		Occurrences []time.Time `json:"occurrences"`
+	}
+	_, err := client.do(ctx, request{
+		method: "GET",
+		path:   todoPath(id) + "/occurrences",
+		query:  map[string]string{"count": strconv.Itoa(count)},
+	}, &body)
+	return body.Occurrences, err
+}
+
+// MoveTodo moves a todo between the todos after and before, which are next
+// to each other in the list. One of them may be zero, to move the todo right
+// after or right before the other.
+func (client *Client) MoveTodo(ctx context.Context, id uint, after, before uint) (Todo, error) {
+	anchors := struct {
+		Before *uint `json:"before"`
+		After  *uint `json:"after"`
+	}{}
+	if before != 0 {
+		anchors.Before = &before
+	}
+	if after != 0 {
+		anchors.After = &after
+	}
+	var todo Todo
+	_, err := client.do(ctx, request{method: "POST", path: todoPath(id) + "/move", body: anchors}, &todo)
+	return todo, err
+}
+
+// RestoreTodo brings back a deleted todo.
+func (client *Client) RestoreTodo(ctx context.Context, id uint) (Todo, error) {
+	var todo Todo
+	_, err := client.do(ctx, request{method: "POST", path: todoPath(id) + "/restore"}, &todo)
+	return todo, err
+}
+
+// RevertTodo sets the fields of a todo back to what they were after a
+// revision from its history. With an etag it only succeeds if the todo still
+// has it.
+func (client *Client) RevertTodo(ctx context.Context, id uint, revision uint, etag string) (Todo, error) {
+	r := request{
+		method: "POST",
+		path:   todoPath(id) + "/revert",
+		body: struct {
+			Revision uint `json:"revision"`
+		}{revision},
+	}
+	if etag != "" {
+		r.headers = map[string]string{"If-Match": etag}
+	}
+	var todo Todo
+	res, err := client.do(ctx, r, &todo)
+	if err == nil {
+		todo.ETag = res.Header.Get("ETag")
+	}
+	return todo, err
+}
+
+// TodoHistory returns the changes made to a todo, oldest first.
+func (client *Client) TodoHistory(ctx context.Context, id uint) ([]Revision, error) {
+	var revisions []Revision
+	_, err := client.do(ctx, request{method: "GET", path: todoPath(id) + "/history"}, &revisions)
+	return revisions, err
+}
+
+// Export writes every todo to w in format.
+func (client *Client) Export(ctx context.Context, format string, w io.Writer) error {
+	res, err := client.send(ctx, request{method: "GET", path: "/todo/export", query: map[string]string{"format": format}})
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	_, err = io.Copy(w, res.Body)
+	return err
+}
+
+// Import reads todos in format from r.
+func (client *Client) Import(ctx context.Context, format string, r io.Reader, options ImportOptions) (ImportReport, error) {
+	mode := "upsert"
+	if options.Insert {
+		mode = "insert"
+	}
+	var report ImportReport
+	_, err := client.do(ctx, request{
+		method:      "POST",
+		path:        "/todo/import",
+		query:       map[string]string{"format": format, "mode": mode, "dry_run": strconv.FormatBool(options.DryRun)},
+		rawBody:     r,
+		contentType: "application/octet-stream",
+	}, &report)
+	return report, err
+}
+
+func todoPath(id uint) string {
+	return fmt.Sprintf("/todo/%d", id)
+}
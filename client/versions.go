@@ -0,0 +1,30 @@
+// client/versions.go
+package client
+
+import (
+	"context"
+	"time"
+)
+
+// Version is a version of the API the server serves, with its use since the
+// server started.
+type Version struct {
+	Name   string `json:"name"`
+	Prefix string `json:"prefix"`
+	// Deprecation and Sunset are set for deprecated versions, which stop
+	// answering at their sunset.
+	Deprecation   *time.Time        `json:"deprecation"`
+	Sunset        *time.Time        `json:"sunset"`
+	Successor     string            `json:"successor"`
+	Requests      uint64            `json:"requests"`
+	Errors        uint64            `json:"errors"`
+	LastRequestAt *time.Time        `json:"last_request_at"`
+	Clients       map[string]uint64 `json:"clients"`
+}
+
+// ListVersions returns the versions of the API the server serves.
+func (client *Client) ListVersions(ctx context.Context) ([]Version, error) {
+	var versions []Version
+	_, err := client.do(ctx, request{method: "GET", prefix: sharedPrefix, path: "/versions"}, &versions)
+	return versions, err
+}
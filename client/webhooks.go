@@ -0,0 +1,124 @@
+// client/webhooks.go
+package client
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Event types, which webhooks subscribe to and the change feed sends. "*"
+// subscribes a webhook to every type.
+const (
+	EventCreated       = "todo.created"
+	EventUpdated       = "todo.updated"
+	EventDeleted       = "todo.deleted"
+	EventRestored      = "todo.restored"
+	EventStatusChanged = "todo.status_changed"
+)
+
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+type Webhook struct {
+	ID  uint   `json:"id"`
+	URL string `json:"url"`
+	// Secret signs the deliveries. It is only returned when the webhook is
+	// created.
+	Secret string `json:"secret"`
+	// Events are the comma separated event types delivered.
+	Events     string     `json:"events"`
+	Owner      string     `json:"owner"`
+	Active     bool       `json:"active"`
+	Failures   int        `json:"failures"`
+	DisabledAt *time.Time `json:"disabled_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// WebhookInput holds the fields of a webhook that clients write. The secret
+// is only taken on creation, where the server makes one up when it is empty,
+// and Active only on update, as new webhooks are active.
+type WebhookInput struct {
+	URL    string `json:"url"`
+	Secret string `json:"secret,omitempty"`
+	Events string `json:"events"`
+	Active bool   `json:"active"`
+}
+
+// Delivery is one attempt, or series of attempts, at delivering an event to
+// a webhook.
+type Delivery struct {
+	ID            uint       `json:"id"`
+	EndpointID    uint       `json:"endpoint_id"`
+	EventID       string     `json:"event_id"`
+	EventType     string     `json:"event_type"`
+	Payload       string     `json:"payload"`
+	Status        string     `json:"status"`
+	Attempts      int        `json:"attempts"`
+	NextAttemptAt time.Time  `json:"next_attempt_at"`
+	LastStatus    int        `json:"last_status"`
+	LastError     string     `json:"last_error"`
+	DeliveredAt   *time.Time `json:"delivered_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// ListWebhooks returns the webhooks of the user, without their secrets.
+func (client *Client) ListWebhooks(ctx context.Context) ([]Webhook, error) {
+	var webhooks []Webhook
+	_, err := client.do(ctx, request{method: "GET", path: "/webhooks"}, &webhooks)
+	return webhooks, err
+}
+
+func (client *Client) GetWebhook(ctx context.Context, id uint) (Webhook, error) {
+	var webhook Webhook
+	_, err := client.do(ctx, request{method: "GET", path: webhookPath(id)}, &webhook)
+	return webhook, err
+}
+
+// CreateWebhook registers a webhook. The webhook returned holds the secret
+// its deliveries are signed with, which is not returned again.
+func (client *Client) CreateWebhook(ctx context.Context, input WebhookInput) (Webhook, error) {
+	var webhook Webhook
+	_, err := client.do(ctx, request{method: "POST", path: "/webhooks", body: input}, &webhook)
+	return webhook, err
+}
+
+// UpdateWebhook replaces the URL and events of a webhook, and activates or
+// disables it. Activating a webhook disabled after failures resets them.
+func (client *Client) UpdateWebhook(ctx context.Context, id uint, input WebhookInput) (Webhook, error) {
+	input.Secret = ""
+	var webhook Webhook
+	_, err := client.do(ctx, request{method: "PUT", path: webhookPath(id), body: input}, &webhook)
+	return webhook, err
+}
+
+func (client *Client) DeleteWebhook(ctx context.Context, id uint) error {
+	_, err := client.do(ctx, request{method: "DELETE", path: webhookPath(id)}, nil)
+	return err
+}
+
+// ListDeliveries returns the latest deliveries of a webhook, newest first.
+func (client *Client) ListDeliveries(ctx context.Context, webhookID uint) ([]Delivery, error) {
+	var deliveries []Delivery
+	_, err := client.do(ctx, request{method: "GET", path: webhookPath(webhookID) + "/deliveries"}, &deliveries)
+	return deliveries, err
+}
+
+// Redeliver queues a delivery to be made again.
+func (client *Client) Redeliver(ctx context.Context, webhookID, deliveryID uint) (Delivery, error) {
+	var delivery Delivery
+	_, err := client.do(ctx, request{
+		method: "POST",
+		path:   fmt.Sprintf("%s/deliveries/%d/redeliver", webhookPath(webhookID), deliveryID),
+	}, &delivery)
+	return delivery, err
+}
+
+func webhookPath(id uint) string {
+	return fmt.Sprintf("/webhooks/%d", id)
+}
@@ -0,0 +1,441 @@
+// cmd/todo/commands.go
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/imadbg01/go-todo/client"
+)
+
+// todoFlags are the fields of a todo that add and edit take as flags.
+type todoFlags struct {
+	name        string
+	description string
+	status      string
+	priority    string
+	due         string
+	timeZone    string
+	recurrence  string
+	repeatFrom  string
+}
+
+func (flags *todoFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&flags.description, "d", "", "description")
+	fs.StringVar(&flags.status, "s", "", "status: pending, in_progress or done")
+	fs.StringVar(&flags.priority, "p", "", "priority: none, low, medium, high or urgent")
+	fs.StringVar(&flags.due, "due", "", "due date, such as 2006-01-02 or 2006-01-02 15:04, or none")
+	fs.StringVar(&flags.timeZone, "tz", "", "time zone of the due date and recurrence, such as Europe/Paris")
+	fs.StringVar(&flags.recurrence, "repeat", "", "recurrence rule, such as FREQ=WEEKLY;BYDAY=MO")
+	fs.StringVar(&flags.repeatFrom, "repeat-from", "", "repeat from the due date or the completion: due or completion")
+}
+
+// apply sets the fields of input given as flags.
+func (flags *todoFlags) apply(fs *flag.FlagSet, input *client.TodoInput) error {
+	var err error
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "name":
+			input.Name = flags.name
+		case "d":
+			input.Description = flags.description
+		case "s":
+			input.Status = flags.status
+		case "p":
+			input.Priority = flags.priority
+		case "due":
+			input.Due, err = parseDue(flags.due)
+		case "tz":
+			input.TimeZone = flags.timeZone
+		case "repeat":
+			input.Recurrence = flags.recurrence
+		case "repeat-from":
+			input.RepeatFrom = flags.repeatFrom
+		}
+	})
+	return err
+}
+
+func runAdd(e *env, args []string) error {
+	fs := e.flagSet("add")
+	var flags todoFlags
+	flags.register(fs)
+	args, err := e.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	input := client.TodoInput{Name: joinArgs(args)}
+	if input.Name == "" {
+		return errUsage
+	}
+	if err := flags.apply(fs, &input); err != nil {
+		return err
+	}
+
+	c, err := e.client()
+	if err != nil {
+		return err
+	}
+	todo, err := c.CreateTodo(e.ctx, input)
+	if err != nil {
+		return err
+	}
+	switch e.flags.output {
+	case outputJSON:
+		return writeJSON(e.stdout, todo)
+	case outputPlain:
+		fmt.Fprintln(e.stdout, todo.ID)
+		return nil
+	}
+	fmt.Fprintf(e.stdout, "Added %d: %s\n", todo.ID, todo.Name)
+	return nil
+}
+
+func runList(e *env, args []string) error {
+	fs := e.flagSet("ls")
+	all := fs.Bool("a", false, "include done todos")
+	status := fs.String("status", "", "only todos with this status")
+	priority := fs.String("priority", "", "only todos with this priority")
+	query := fs.String("q", "", "search the name and description")
+	limit := fs.Int("limit", 20, "most search results")
+	args, err := e.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) > 0 {
+		return errUsage
+	}
+
+	c, err := e.client()
+	if err != nil {
+		return err
+	}
+	var todos []client.Todo
+	if *query != "" {
+		results, err := c.SearchTodos(e.ctx, *query, *limit)
+		if err != nil {
+			return err
+		}
+		for _, result := range results {
+			todos = append(todos, result.Todo)
+		}
+	} else if todos, err = c.ListTodos(e.ctx); err != nil {
+		return err
+	}
+
+	var shown []client.Todo
+	for _, todo := range todos {
+		if *status != "" && todo.Status != *status {
+			continue
+		}
+		if *status == "" && !*all && todo.Status == client.StatusDone {
+			continue
+		}
+		if *priority != "" && todo.Priority != *priority {
+			continue
+		}
+		shown = append(shown, todo)
+	}
+	return writeTodos(e.stdout, e.flags.output, shown)
+}
+
+func runShow(e *env, args []string) error {
+	fs := e.flagSet("show")
+	args, err := e.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	ids, err := parseIDs(args, 1)
+	if err != nil {
+		return err
+	}
+
+	c, err := e.client()
+	if err != nil {
+		return err
+	}
+	todo, err := c.GetTodo(e.ctx, ids[0])
+	if err != nil {
+		return err
+	}
+	return writeTodo(e.stdout, e.flags.output, todo)
+}
+
+func runEdit(e *env, args []string) error {
+	fs := e.flagSet("edit")
+	var flags todoFlags
+	fs.StringVar(&flags.name, "name", "", "name")
+	flags.register(fs)
+	args, err := e.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	ids, err := parseIDs(args, 1)
+	if err != nil {
+		return err
+	}
+
+	c, err := e.client()
+	if err != nil {
+		return err
+	}
+	todo, err := c.GetTodo(e.ctx, ids[0])
+	if err != nil {
+		return err
+	}
+	input := todo.Input()
+	if err := flags.apply(fs, &input); err != nil {
+		return err
+	}
+	if input == todo.Input() {
+		return fmt.Errorf("nothing to change; give the fields to change as flags")
+	}
+	todo, err = c.UpdateTodo(e.ctx, todo.ID, input, todo.ETag)
+	if err != nil {
+		return err
+	}
+	return writeTodo(e.stdout, e.flags.output, todo)
+}
+
+func runDone(e *env, args []string) error {
+	fs := e.flagSet("done")
+	args, err := e.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	ids, err := parseIDs(args, -1)
+	if err != nil {
+		return err
+	}
+
+	c, err := e.client()
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		todo, err := c.GetTodo(e.ctx, id)
+		if err != nil {
+			return err
+		}
+		input := todo.Input()
+		input.Status = client.StatusDone
+		if _, err := c.UpdateTodo(e.ctx, id, input, todo.ETag); err != nil {
+			return err
+		}
+		if e.flags.output == outputTable {
+			fmt.Fprintf(e.stdout, "Done %d: %s\n", todo.ID, todo.Name)
+		}
+	}
+	return nil
+}
+
+func runRemove(e *env, args []string) error {
+	fs := e.flagSet("rm")
+	args, err := e.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	ids, err := parseIDs(args, -1)
+	if err != nil {
+		return err
+	}
+
+	c, err := e.client()
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := c.DeleteTodo(e.ctx, id); err != nil {
+			return err
+		}
+		if e.flags.output == outputTable {
+			fmt.Fprintf(e.stdout, "Deleted %d\n", id)
+		}
+	}
+	return nil
+}
+
+func runImport(e *env, args []string) error {
+	fs := e.flagSet("import")
+	format := fs.String("f", "", "format: json, ndjson, csv or todotxt; taken from the file name when not given")
+	insert := fs.Bool("insert", false, "add every record as a new todo instead of updating those with a known external_id")
+	dryRun := fs.Bool("dry-run", false, "report what would change without changing anything")
+	args, err := e.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) > 1 {
+		return errUsage
+	}
+
+	in := e.stdin
+	if len(args) == 1 && args[0] != "-" {
+		file, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		in = file
+		if *format == "" {
+			*format = formatOf(args[0])
+		}
+	}
+	if *format == "" {
+		*format = client.FormatJSON
+	}
+
+	c, err := e.client()
+	if err != nil {
+		return err
+	}
+	report, err := c.Import(e.ctx, *format, in, client.ImportOptions{Insert: *insert, DryRun: *dryRun})
+	if err != nil {
+		return err
+	}
+	if e.flags.output == outputJSON {
+		return writeJSON(e.stdout, report)
+	}
+	for _, failure := range report.Errors {
+		fmt.Fprintf(e.stderr, "row %d: %s\n", failure.Row, failure.Error)
+	}
+	if report.DryRun {
+		fmt.Fprintf(e.stdout, "Would create %d, update %d, fail %d\n", report.Created, report.Updated, report.Failed)
+	} else {
+		fmt.Fprintf(e.stdout, "Created %d, updated %d, failed %d\n", report.Created, report.Updated, report.Failed)
+	}
+	if report.Failed > 0 {
+		return fmt.Errorf("%d record%s failed", report.Failed, plural(report.Failed))
+	}
+	return nil
+}
+
+func runExport(e *env, args []string) error {
+	fs := e.flagSet("export")
+	format := fs.String("f", "", "format: json, ndjson, csv, todotxt or ics; taken from the file name when not given")
+	args, err := e.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) > 1 {
+		return errUsage
+	}
+	if len(args) == 1 && *format == "" {
+		*format = formatOf(args[0])
+	}
+	if *format == "" {
+		*format = client.FormatJSON
+	}
+
+	c, err := e.client()
+	if err != nil {
+		return err
+	}
+	var out io.Writer = e.stdout
+	if len(args) == 1 && args[0] != "-" {
+		file, err := os.Create(args[0])
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+	return c.Export(e.ctx, *format, out)
+}
+
+func runConfig(e *env, args []string) error {
+	fs := e.flagSet("config")
+	args, err := e.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return errUsage
+	}
+
+	config := e.config
+	switch {
+	case args[0] == "ls" && len(args) == 1:
+		if e.flags.output == outputJSON {
+			return writeJSON(e.stdout, config)
+		}
+		for _, name := range config.names() {
+			current := " "
+			if name == config.Current {
+				current = "*"
+			}
+			fmt.Fprintf(e.stdout, "%s %s\t%s\n", current, name, config.Profiles[name].Server)
+		}
+		return nil
+	case args[0] == "use" && len(args) == 2:
+		if _, ok := config.Profiles[args[1]]; !ok {
+			return fmt.Errorf("no profile %q", args[1])
+		}
+		config.Current = args[1]
+	case args[0] == "set" && len(args) == 2:
+		stored, ok := config.Profiles[args[1]]
+		if !ok {
+			stored = &Profile{Server: defaultServer}
+			config.Profiles[args[1]] = stored
+		}
+		if e.flags.server != "" {
+			stored.Server = e.flags.server
+		}
+		if e.flags.token != "" {
+			stored.Token = e.flags.token
+		}
+		if e.flags.user != "" {
+			stored.User = e.flags.user
+		}
+		if config.Current == "" {
+			config.Current = args[1]
+		}
+	case args[0] == "rm" && len(args) == 2:
+		if _, ok := config.Profiles[args[1]]; !ok {
+			return fmt.Errorf("no profile %q", args[1])
+		}
+		delete(config.Profiles, args[1])
+		if config.Current == args[1] {
+			config.Current = ""
+		}
+	default:
+		return errUsage
+	}
+	return config.save()
+}
+
+// parseIDs reads the todo IDs among args, which must be exactly n of them,
+// or at least one when n is negative.
+func parseIDs(args []string, n int) ([]uint, error) {
+	if (n >= 0 && len(args) != n) || len(args) == 0 {
+		return nil, errUsage
+	}
+	ids := make([]uint, len(args))
+	for i, arg := range args {
+		id, err := strconv.ParseUint(strings.TrimPrefix(arg, "#"), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a todo ID", arg)
+		}
+		ids[i] = uint(id)
+	}
+	return ids, nil
+}
+
+// formatOf guesses the format of a file from its name.
+func formatOf(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".ndjson", ".jsonl":
+		return client.FormatNDJSON
+	case ".csv":
+		return client.FormatCSV
+	case ".txt":
+		return client.FormatTodoTxt
+	case ".ics":
+		return client.FormatICS
+	}
+	return client.FormatJSON
+}
@@ -0,0 +1,103 @@
+// cmd/todo/completion.go
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// idCommands are the commands that take todo IDs, completed by asking the
+// server for them with the hidden __ids command.
+const idCommands = "show edit done rm"
+
+const bashCompletion = `# bash completion for todo, from: todo completion bash
+_todo() {
+	local cur=${COMP_WORDS[COMP_CWORD]}
+	if [ "$COMP_CWORD" -eq 1 ]; then
+		COMPREPLY=($(compgen -W "%[1]s" -- "$cur"))
+		return
+	fi
+	case "$cur" in
+	-*)
+		COMPREPLY=($(compgen -W "--profile --server --token --user -o" -- "$cur"))
+		return
+		;;
+	esac
+	case "${COMP_WORDS[1]}" in
+	%[2]s)
+		COMPREPLY=($(compgen -W "$(todo __ids 2>/dev/null)" -- "$cur"))
+		;;
+	import|export)
+		COMPREPLY=($(compgen -f -- "$cur"))
+		;;
+	config)
+		if [ "$COMP_CWORD" -eq 2 ]; then
+			COMPREPLY=($(compgen -W "ls use set rm" -- "$cur"))
+		fi
+		;;
+	completion)
+		COMPREPLY=($(compgen -W "bash zsh fish" -- "$cur"))
+		;;
+	esac
+}
+complete -F _todo todo
+`
+
+const zshCompletion = `# zsh completion for todo, from: todo completion zsh
+autoload -U +X bashcompinit && bashcompinit
+`
+
+const fishCompletion = `# fish completion for todo, from: todo completion fish
+complete -c todo -f
+complete -c todo -n "__fish_use_subcommand" -a "%[1]s"
+complete -c todo -n "__fish_seen_subcommand_from %[2]s" -a "(todo __ids 2>/dev/null)"
+complete -c todo -n "__fish_seen_subcommand_from import export" -F
+complete -c todo -n "__fish_seen_subcommand_from config" -a "ls use set rm"
+complete -c todo -n "__fish_seen_subcommand_from completion" -a "bash zsh fish"
+complete -c todo -l profile -r
+complete -c todo -l server -r
+complete -c todo -l token -r
+complete -c todo -l user -r
+complete -c todo -s o -r -a "table json plain"
+`
+
+func runCompletion(e *env, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	names := make([]string, len(commands))
+	for i, cmd := range commands {
+		names[i] = cmd.name
+	}
+	bash := fmt.Sprintf(bashCompletion, strings.Join(names, " "), strings.Replace(idCommands, " ", "|", -1))
+
+	switch args[0] {
+	case "bash":
+		fmt.Fprint(e.stdout, bash)
+	case "zsh":
+		fmt.Fprint(e.stdout, zshCompletion+bash)
+	case "fish":
+		fmt.Fprintf(e.stdout, fishCompletion, strings.Join(names, " "), idCommands)
+	default:
+		return errUsage
+	}
+	return nil
+}
+
+// completeIDs prints the IDs of the todos that are not done.
+func completeIDs(e *env) error {
+	c, err := e.client()
+	if err != nil {
+		return err
+	}
+	todos, err := c.ListTodos(e.ctx)
+	if err != nil {
+		return err
+	}
+	for _, todo := range todos {
+		if todo.Status != "done" {
+			fmt.Fprintln(e.stdout, todo.ID)
+		}
+	}
+	return nil
+}
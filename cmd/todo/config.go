@@ -0,0 +1,116 @@
+// cmd/todo/config.go
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Profile is a server the CLI talks to.
+type Profile struct {
+	Server string `json:"server"`
+	Token  string `json:"token,omitempty"`
+	User   string `json:"user,omitempty"`
+}
+
+// Config is the file holding the profiles, and the one in use.
+type Config struct {
+	Current  string              `json:"current"`
+	Profiles map[string]*Profile `json:"profiles"`
+}
+
+// configPath is TODO_CONFIG, or todo/config.json in the user's config
+// directory.
+func configPath() (string, error) {
+	if path := os.Getenv("TODO_CONFIG"); path != "" {
+		return path, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "todo", "config.json"), nil
+}
+
+// loadConfig reads the config file, which is empty until a profile is set.
+func loadConfig() (*Config, error) {
+	config := &Config{Profiles: map[string]*Profile{}}
+	path, err := configPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return config, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("reading %s: %v", path, err)
+	}
+	if config.Profiles == nil {
+		config.Profiles = map[string]*Profile{}
+	}
+	return config, nil
+}
+
+// save writes the config file, readable only by the user as it holds
+// tokens.
+func (config *Config) save() error {
+	path, err := configPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0600)
+}
+
+func (config *Config) names() []string {
+	names := make([]string, 0, len(config.Profiles))
+	for name := range config.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// profile resolves the profile in use from, in order, the flags, the
+// TODO_SERVER, TODO_TOKEN and TODO_USER variables, the profile named by
+// --profile, TODO_PROFILE or the config file, and the defaults.
+func (config *Config) profile(flags *globalFlags) (Profile, error) {
+	name := firstOf(flags.profile, os.Getenv("TODO_PROFILE"), config.Current)
+	profile := Profile{}
+	if name != "" {
+		stored, ok := config.Profiles[name]
+		if !ok && (flags.profile != "" || os.Getenv("TODO_PROFILE") != "") {
+			return profile, fmt.Errorf("no profile %q", name)
+		}
+		if ok {
+			profile = *stored
+		}
+	}
+	profile.Server = firstOf(flags.server, os.Getenv("TODO_SERVER"), profile.Server, defaultServer)
+	profile.Token = firstOf(flags.token, os.Getenv("TODO_TOKEN"), profile.Token)
+	profile.User = firstOf(flags.user, os.Getenv("TODO_USER"), profile.User)
+	return profile, nil
+}
+
+func firstOf(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
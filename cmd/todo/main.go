@@ -0,0 +1,215 @@
+// cmd/todo/main.go
+// Command todo manages the todos of a todo API server from the shell.
+//
+//	todo add Buy milk --due 2026-11-02 -p high
+//	todo ls
+//	todo done 12
+//	todo tui
+//
+// Servers and tokens are kept in profiles, set with todo config.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/imadbg01/go-todo/client"
+)
+
+const defaultServer = client.DefaultServer
+
+// globalFlags are accepted by every command.
+type globalFlags struct {
+	profile string
+	server  string
+	token   string
+	user    string
+	output  string
+}
+
+func (flags *globalFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&flags.profile, "profile", flags.profile, "profile to use")
+	fs.StringVar(&flags.server, "server", flags.server, "URL of the server, such as "+defaultServer)
+	fs.StringVar(&flags.token, "token", flags.token, "bearer token to authenticate with")
+	fs.StringVar(&flags.user, "user", flags.user, "user to act as, for servers without an authenticating proxy")
+	fs.StringVar(&flags.output, "o", flags.output, "output format: table, json or plain")
+}
+
+// env is what a command runs with.
+type env struct {
+	ctx    context.Context
+	flags  *globalFlags
+	config *Config
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+type command struct {
+	name    string
+	usage   string
+	summary string
+	run     func(env *env, args []string) error
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{"add", "add [flags] NAME...", "Add a todo", runAdd},
+		{"ls", "ls [-a] [--status S] [--priority P] [-q QUERY]", "List todos", runList},
+		{"show", "show ID", "Show a todo", runShow},
+		{"edit", "edit ID [flags]", "Change the fields given as flags", runEdit},
+		{"done", "done ID...", "Mark todos done", runDone},
+		{"rm", "rm ID...", "Delete todos", runRemove},
+		{"import", "import [-f FORMAT] [--insert] [--dry-run] [FILE]", "Import todos from FILE or stdin", runImport},
+		{"export", "export [-f FORMAT] [FILE]", "Export todos to FILE or stdout", runExport},
+		{"tui", "tui", "Manage todos in an interactive terminal UI", runTUI},
+		{"config", "config ls | use NAME | set NAME [--server URL] [--token T] [--user U] | rm NAME", "Manage server profiles", runConfig},
+		{"completion", "completion bash|zsh|fish", "Print a shell completion script", runCompletion},
+	}
+}
+
+// errUsage makes run print the usage of the command.
+var errUsage = errors.New("usage")
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	code := run(ctx, os.Args[1:], os.Stdin, os.Stdout, os.Stderr)
+	stop()
+	os.Exit(code)
+}
+
+func run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := &globalFlags{output: outputTable}
+	fs := flag.NewFlagSet("todo", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	flags.register(fs)
+	fs.Usage = func() { usage(stderr) }
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	args = fs.Args()
+	if len(args) == 0 || args[0] == "help" {
+		usage(stdout)
+		return 0
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		fmt.Fprintf(stderr, "todo: %v\n", err)
+		return 1
+	}
+	e := &env{ctx: ctx, flags: flags, config: config, stdin: stdin, stdout: stdout, stderr: stderr}
+
+	if args[0] == "__ids" {
+		return exit(e, "", completeIDs(e))
+	}
+	for _, cmd := range commands {
+		if cmd.name != args[0] {
+			continue
+		}
+		err := cmd.run(e, args[1:])
+		if err == errUsage {
+			fmt.Fprintf(stderr, "usage: todo %s\n", cmd.usage)
+			return 2
+		}
+		return exit(e, cmd.name, err)
+	}
+	fmt.Fprintf(stderr, "todo: unknown command %q\n", args[0])
+	usage(stderr)
+	return 2
+}
+
+func exit(e *env, name string, err error) int {
+	switch {
+	case err == nil:
+		return 0
+	case err == flag.ErrHelp:
+		return 0
+	case errors.Is(err, context.Canceled):
+		return 130
+	}
+	if name != "" {
+		fmt.Fprintf(e.stderr, "todo %s: %v\n", name, err)
+	}
+	return 1
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: todo [flags] COMMAND [ARGS]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-11s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Flags, accepted by every command:")
+	fs := flag.NewFlagSet("todo", flag.ContinueOnError)
+	(&globalFlags{}).register(fs)
+	fs.SetOutput(w)
+	fs.PrintDefaults()
+}
+
+// flagSet returns the flags of a command, which include the global ones.
+func (e *env) flagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet("todo "+name, flag.ContinueOnError)
+	fs.SetOutput(e.stderr)
+	e.flags.register(fs)
+	return fs
+}
+
+// parse parses flags found anywhere among args, so that they can follow
+// the arguments of a command, and returns the arguments.
+func (e *env) parse(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			break
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+	if !validOutput(e.flags.output) {
+		return nil, fmt.Errorf("-o must be table, json or plain")
+	}
+	return positional, nil
+}
+
+// client returns a client of the server of the profile in use.
+func (e *env) client() (*client.Client, error) {
+	profile, err := e.config.profile(e.flags)
+	if err != nil {
+		return nil, err
+	}
+	var options []client.Option
+	if profile.Token != "" {
+		options = append(options, client.WithToken(profile.Token))
+	}
+	if profile.User != "" {
+		options = append(options, client.WithUser(profile.User))
+	}
+	return client.New(profile.Server, options...), nil
+}
+
+// plural returns s for counts other than one.
+func plural(n int) string {
+	if n == 1 {
+		return ""
+	}
+	return "s"
+}
+
+func joinArgs(args []string) string {
+	return strings.TrimSpace(strings.Join(args, " "))
+}
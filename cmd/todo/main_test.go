@@ -0,0 +1,271 @@
+// cmd/todo/main_test.go
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/imadbg01/go-todo/client"
+)
+
+// fakeServer serves the todo routes of v2 of the API from memory, and
+// remembers the headers of the last request.
+type fakeServer struct {
+	*httptest.Server
+	mu      sync.Mutex
+	todos   []client.Todo
+	headers http.Header
+}
+
+func newFakeServer(t *testing.T, todos ...client.Todo) *fakeServer {
+	server := &fakeServer{todos: todos}
+	server.Server = httptest.NewServer(http.HandlerFunc(server.serve))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func (server *fakeServer) serve(w http.ResponseWriter, r *http.Request) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	server.headers = r.Header.Clone()
+	w.Header().Set("Content-Type", "application/json")
+
+	path := strings.TrimPrefix(r.URL.Path, "/api/v2/todo")
+	if path == "" {
+		switch r.Method {
+		case "GET":
+			json.NewEncoder(w).Encode(server.todos)
+		case "POST":
+			var input client.TodoInput
+			json.NewDecoder(r.Body).Decode(&input)
+			todo := client.Todo{ID: uint(len(server.todos) + 1), Name: input.Name, Status: input.Status, Priority: input.Priority, Due: input.Due}
+			server.todos = append(server.todos, todo)
+			json.NewEncoder(w).Encode(todo)
+		}
+		return
+	}
+	id, _ := strconv.Atoi(strings.TrimPrefix(path, "/"))
+	if id < 1 || id > len(server.todos) {
+		w.WriteHeader(404)
+		fmt.Fprint(w, `{"status":404,"message":"Item not found"}`)
+		return
+	}
+	todo := &server.todos[id-1]
+	etag := fmt.Sprintf(`"%d-%s"`, todo.ID, todo.Status)
+	switch r.Method {
+	case "GET":
+		w.Header().Set("ETag", etag)
+	case "PUT":
+		if r.Header.Get("If-Match") != etag {
+			w.WriteHeader(412)
+			fmt.Fprint(w, `{"status":412,"message":"Todo has changed"}`)
+			return
+		}
+		var input client.TodoInput
+		json.NewDecoder(r.Body).Decode(&input)
+		todo.Name, todo.Status, todo.Priority = input.Name, input.Status, input.Priority
+	case "DELETE":
+		w.WriteHeader(204)
+		return
+	}
+	json.NewEncoder(w).Encode(todo)
+}
+
+func (server *fakeServer) header(name string) string {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	return server.headers.Get(name)
+}
+
+// cli runs the command with args against server, with a config file of its
+// own, and returns its exit code and output.
+func cli(t *testing.T, args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), args, strings.NewReader(""), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+// isolate keeps the test from reading the user's config and environment.
+func isolate(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "config.json")
+	t.Setenv("TODO_CONFIG", path)
+	for _, name := range []string{"TODO_PROFILE", "TODO_SERVER", "TODO_TOKEN", "TODO_USER"} {
+		t.Setenv(name, "")
+	}
+	return path
+}
+
+func TestArguments(t *testing.T) {
+	isolate(t)
+	server := newFakeServer(t)
+
+	if code, stdout, _ := cli(t); code != 0 || !strings.Contains(stdout, "Commands:") {
+		t.Errorf("no arguments = %d %q, want the usage", code, stdout)
+	}
+	if code, _, stderr := cli(t, "frobnicate"); code != 2 || !strings.Contains(stderr, `unknown command "frobnicate"`) {
+		t.Errorf("unknown command = %d %q", code, stderr)
+	}
+	if code, _, stderr := cli(t, "--server", server.URL, "add"); code != 2 || !strings.HasPrefix(stderr, "usage: todo add") {
+		t.Errorf("add without a name = %d %q, want its usage", code, stderr)
+	}
+	if code, _, stderr := cli(t, "--server", server.URL, "show", "abc"); code != 1 || !strings.Contains(stderr, `"abc" is not a todo ID`) {
+		t.Errorf("show abc = %d %q", code, stderr)
+	}
+	if code, _, stderr := cli(t, "--server", server.URL, "-o", "xml", "ls"); code != 1 || !strings.Contains(stderr, "-o must be") {
+		t.Errorf("-o xml = %d %q", code, stderr)
+	}
+
+	// Flags may follow the words of the name.
+	code, stdout, stderr := cli(t, "add", "Buy", "milk", "-p", "high", "--due", "2026-11-02", "--server", server.URL, "-o", "plain")
+	if code != 0 || stdout != "1\n" {
+		t.Fatalf("add = %d %q %q", code, stdout, stderr)
+	}
+	added := server.todos[0]
+	want := time.Date(2026, time.November, 2, 0, 0, 0, 0, time.Local)
+	if added.Name != "Buy milk" || added.Priority != "high" || added.Due == nil || !added.Due.Equal(want) {
+		t.Errorf("added %+v", added)
+	}
+	if code, _, stderr := cli(t, "--server", server.URL, "add", "Plan", "--due", "soon"); code != 1 || !strings.Contains(stderr, `due "soon" is not a date`) {
+		t.Errorf("add with a bad due date = %d %q", code, stderr)
+	}
+	if code, _, stderr := cli(t, "--server", server.URL, "show", "9"); code != 1 || !strings.Contains(stderr, "Item not found (404)") {
+		t.Errorf("show of a missing todo = %d %q", code, stderr)
+	}
+}
+
+func TestOutputFormats(t *testing.T) {
+	isolate(t)
+	due := time.Date(2026, time.November, 2, 0, 0, 0, 0, time.Local)
+	server := newFakeServer(t,
+		client.Todo{ID: 1, Name: "Buy milk", Status: "pending", Priority: "high", Due: &due},
+		client.Todo{ID: 2, Name: "File taxes", Status: "done", Priority: "none"},
+	)
+
+	_, table, _ := cli(t, "--server", server.URL, "ls")
+	lines := strings.Split(strings.TrimSpace(table), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "ID  STATUS") || !strings.Contains(lines[1], "2026-11-02  Buy milk") {
+		t.Errorf("table:\n%s", table)
+	}
+	if _, all, _ := cli(t, "--server", server.URL, "ls", "-a"); !strings.Contains(all, "File taxes") {
+		t.Errorf("ls -a left out the done todo:\n%s", all)
+	}
+	if _, plain, _ := cli(t, "--server", server.URL, "-o", "plain", "ls", "--status", "done"); plain != "2\tdone\tnone\t\tFile taxes\n" {
+		t.Errorf("plain %q", plain)
+	}
+
+	_, out, _ := cli(t, "--server", server.URL, "-o", "json", "ls", "--priority", "low")
+	if strings.TrimSpace(out) != "[]" {
+		t.Errorf("json of no todos %q, want an empty array", out)
+	}
+	_, out, _ = cli(t, "--server", server.URL, "-o", "json", "show", "1")
+	var shown client.Todo
+	if err := json.Unmarshal([]byte(out), &shown); err != nil || shown.Name != "Buy milk" {
+		t.Errorf("json of a todo %q: %v", out, err)
+	}
+	if _, out, _ := cli(t, "--server", server.URL, "-o", "plain", "show", "1"); !strings.Contains(out, "name=Buy milk\n") || !strings.Contains(out, "due=2026-11-02\n") {
+		t.Errorf("plain todo %q", out)
+	}
+}
+
+func TestDone(t *testing.T) {
+	isolate(t)
+	server := newFakeServer(t, client.Todo{ID: 1, Name: "Buy milk", Status: "pending"})
+
+	code, stdout, stderr := cli(t, "--server", server.URL, "done", "#1")
+	if code != 0 || stdout != "Done 1: Buy milk\n" {
+		t.Fatalf("done = %d %q %q", code, stdout, stderr)
+	}
+	if server.todos[0].Status != "done" || server.header("If-Match") != `"1-pending"` {
+		t.Errorf("done sent If-Match %q and left %+v", server.header("If-Match"), server.todos[0])
+	}
+}
+
+func TestProfiles(t *testing.T) {
+	path := isolate(t)
+	work := newFakeServer(t)
+	home := newFakeServer(t)
+
+	if code, _, stderr := cli(t, "config", "set", "work", "--server", work.URL, "--token", "secret"); code != 0 {
+		t.Fatalf("config set = %d %q", code, stderr)
+	}
+	if code, _, stderr := cli(t, "config", "set", "home", "--server", home.URL, "--user", "alice"); code != 0 {
+		t.Fatalf("config set = %d %q", code, stderr)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("config file %v %v, want it readable only by the user", info.Mode(), err)
+	}
+	if _, out, _ := cli(t, "config", "ls"); out != fmt.Sprintf("  home\t%s\n* work\t%s\n", home.URL, work.URL) {
+		t.Errorf("config ls %q, want work in use as the first profile set", out)
+	}
+
+	cli(t, "ls")
+	if work.header("Authorization") != "Bearer secret" {
+		t.Errorf("profile in use sent Authorization %q", work.header("Authorization"))
+	}
+	cli(t, "--profile", "home", "ls")
+	if home.header("X-User") != "alice" || home.header("Authorization") != "" {
+		t.Errorf("--profile home sent X-User %q and Authorization %q", home.header("X-User"), home.header("Authorization"))
+	}
+	t.Setenv("TODO_TOKEN", "from-env")
+	cli(t, "ls", "--token", "from-flag")
+	if work.header("Authorization") != "Bearer from-flag" {
+		t.Errorf("flag gave way to the environment: Authorization %q", work.header("Authorization"))
+	}
+	t.Setenv("TODO_TOKEN", "")
+
+	if code, _, stderr := cli(t, "--profile", "missing", "ls"); code != 1 || !strings.Contains(stderr, `no profile "missing"`) {
+		t.Errorf("missing profile = %d %q", code, stderr)
+	}
+	if code, _, _ := cli(t, "config", "use", "home"); code != 0 {
+		t.Fatal("config use failed")
+	}
+	cli(t, "config", "rm", "work")
+	config, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Current != "home" || len(config.Profiles) != 1 {
+		t.Errorf("config after use and rm %+v", config)
+	}
+	if code, _, _ := cli(t, "config", "use", "work"); code != 1 {
+		t.Errorf("using a removed profile = %d, want 1", code)
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	isolate(t)
+	server := newFakeServer(t,
+		client.Todo{ID: 1, Name: "Buy milk", Status: "pending"},
+		client.Todo{ID: 2, Name: "File taxes", Status: "done"},
+		client.Todo{ID: 3, Name: "Call Ana", Status: "in_progress"},
+	)
+
+	_, bash, _ := cli(t, "completion", "bash")
+	if !strings.Contains(bash, `compgen -W "add ls show edit done rm import export tui config completion"`) || !strings.Contains(bash, "show|edit|done|rm)") {
+		t.Errorf("bash completion does not complete the commands and IDs:\n%s", bash)
+	}
+	if _, zsh, _ := cli(t, "completion", "zsh"); !strings.HasPrefix(zsh, "# zsh") || !strings.Contains(zsh, "bashcompinit") || !strings.HasSuffix(zsh, bash[strings.Index(bash, "_todo()"):]) {
+		t.Errorf("zsh completion does not load the bash one:\n%s", zsh)
+	}
+	if _, fish, _ := cli(t, "completion", "fish"); !strings.Contains(fish, `__fish_seen_subcommand_from show edit done rm" -a "(todo __ids 2>/dev/null)"`) {
+		t.Errorf("fish completion does not complete IDs:\n%s", fish)
+	}
+	if code, _, _ := cli(t, "completion", "powershell"); code != 2 {
+		t.Errorf("unknown shell = %d, want 2", code)
+	}
+
+	if _, ids, _ := cli(t, "--server", server.URL, "__ids"); ids != "1\n3\n" {
+		t.Errorf("__ids %q, want the todos that are not done", ids)
+	}
+}
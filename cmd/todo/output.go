@@ -0,0 +1,132 @@
+// cmd/todo/output.go
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/imadbg01/go-todo/client"
+)
+
+// Output formats.
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputPlain = "plain"
+)
+
+func validOutput(output string) bool {
+	return output == outputTable || output == outputJSON || output == outputPlain
+}
+
+func writeJSON(w io.Writer, value interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
+
+// writeTodos lists todos: aligned with a header as a table, or one per line
+// with tab separated fields as plain text for scripts.
+func writeTodos(w io.Writer, output string, todos []client.Todo) error {
+	switch output {
+	case outputJSON:
+		if todos == nil {
+			todos = []client.Todo{}
+		}
+		return writeJSON(w, todos)
+	case outputPlain:
+		for _, todo := range todos {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", todo.ID, todo.Status, todo.Priority, formatDue(todo.Due), todo.Name)
+		}
+		return nil
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tSTATUS\tPRIORITY\tDUE\tNAME")
+	for _, todo := range todos {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\n", todo.ID, orDash(todo.Status), orDash(todo.Priority), orDash(formatDue(todo.Due)), todo.Name)
+	}
+	return tw.Flush()
+}
+
+// writeTodo shows every field of a todo.
+func writeTodo(w io.Writer, output string, todo client.Todo) error {
+	if output == outputJSON {
+		return writeJSON(w, todo)
+	}
+	fields := [][2]string{
+		{"id", fmt.Sprint(todo.ID)},
+		{"name", todo.Name},
+		{"description", todo.Description},
+		{"status", todo.Status},
+		{"priority", todo.Priority},
+		{"due", formatDue(todo.Due)},
+		{"time_zone", todo.TimeZone},
+		{"recurrence", todo.Recurrence},
+		{"repeat_from", todo.RepeatFrom},
+		{"external_id", todo.ExternalID},
+		{"completed_at", formatTime(todo.CompletedAt)},
+		{"created_at", todo.CreatedAt.Format(time.RFC3339)},
+		{"updated_at", todo.UpdatedAt.Format(time.RFC3339)},
+	}
+	if output == outputPlain {
+		for _, field := range fields {
+			fmt.Fprintf(w, "%s=%s\n", field[0], field[1])
+		}
+		return nil
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, field := range fields {
+		if field[1] == "" {
+			continue
+		}
+		label := strings.Title(strings.Replace(field[0], "_", " ", -1))
+		if field[0] == "id" {
+			label = "ID"
+		}
+		fmt.Fprintf(tw, "%s:\t%s\n", label, field[1])
+	}
+	return tw.Flush()
+}
+
+// formatDue writes a due date as a date alone when it is at midnight.
+func formatDue(due *time.Time) string {
+	if due == nil {
+		return ""
+	}
+	if due.Hour() == 0 && due.Minute() == 0 && due.Second() == 0 {
+		return due.Format("2006-01-02")
+	}
+	return due.Format("2006-01-02 15:04")
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// parseDue reads a due date given as 2006-01-02, 2006-01-02 15:04 or RFC
+// 3339, in local time. "none" clears it.
+func parseDue(value string) (*time.Time, error) {
+	if value == "none" || value == "" {
+		return nil, nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04", "2006-01-02T15:04", "2006-01-02"} {
+		if due, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return &due, nil
+		}
+	}
+	return nil, fmt.Errorf("due %q is not a date such as 2006-01-02 or 2006-01-02 15:04", value)
+}
@@ -0,0 +1,9 @@
+// cmd/todo/terminal_darwin.go
+package main
+
+import "golang.org/x/sys/unix"
+
+const (
+	ioctlGetTermios = unix.TIOCGETA
+	ioctlSetTermios = unix.TIOCSETA
+)
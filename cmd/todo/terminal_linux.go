@@ -0,0 +1,9 @@
+// cmd/todo/terminal_linux.go
+package main
+
+import "golang.org/x/sys/unix"
+
+const (
+	ioctlGetTermios = unix.TCGETS
+	ioctlSetTermios = unix.TCSETS
+)
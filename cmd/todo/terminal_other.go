@@ -0,0 +1,28 @@
+// cmd/todo/terminal_other.go
+//go:build !linux && !darwin
+// +build !linux,!darwin
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+type terminal struct {
+	out *os.File
+}
+
+func openTerminal(in, out *os.File) (*terminal, error) {
+	return nil, errors.New("the terminal UI is not supported on this system")
+}
+
+func (term *terminal) restore() {}
+
+func (term *terminal) size() (int, int) {
+	return 80, 24
+}
+
+func (term *terminal) resized() (resized <-chan os.Signal, stop func()) {
+	return nil, func() {}
+}
@@ -0,0 +1,68 @@
+// cmd/todo/terminal_unix.go
+//go:build linux || darwin
+// +build linux darwin
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"golang.org/x/sys/unix"
+)
+
+// terminal is the terminal the TUI draws on, in raw mode and on the
+// alternate screen until it is restored.
+type terminal struct {
+	in    *os.File
+	out   *os.File
+	saved unix.Termios
+}
+
+func openTerminal(in, out *os.File) (*terminal, error) {
+	fd := int(in.Fd())
+	termios, err := unix.IoctlGetTermios(fd, ioctlGetTermios)
+	if err != nil {
+		return nil, errors.New("the terminal UI needs a terminal")
+	}
+
+	raw := *termios
+	raw.Iflag &^= unix.IGNBRK | unix.BRKINT | unix.PARMRK | unix.ISTRIP | unix.INLCR | unix.IGNCR | unix.ICRNL | unix.IXON
+	raw.Oflag &^= unix.OPOST
+	raw.Lflag &^= unix.ECHO | unix.ECHONL | unix.ICANON | unix.ISIG | unix.IEXTEN
+	raw.Cflag &^= unix.CSIZE | unix.PARENB
+	raw.Cflag |= unix.CS8
+	raw.Cc[unix.VMIN] = 1
+	raw.Cc[unix.VTIME] = 0
+	if err := unix.IoctlSetTermios(fd, ioctlSetTermios, &raw); err != nil {
+		return nil, err
+	}
+
+	fmt.Fprint(out, "\x1b[?1049h\x1b[?25l")
+	return &terminal{in: in, out: out, saved: *termios}, nil
+}
+
+// restore leaves the alternate screen and raw mode.
+func (term *terminal) restore() {
+	fmt.Fprint(term.out, "\x1b[?25h\x1b[?1049l")
+	unix.IoctlSetTermios(int(term.in.Fd()), ioctlSetTermios, &term.saved)
+}
+
+// size returns the width and height of the terminal.
+func (term *terminal) size() (int, int) {
+	ws, err := unix.IoctlGetWinsize(int(term.out.Fd()), unix.TIOCGWINSZ)
+	if err != nil || ws.Col == 0 || ws.Row == 0 {
+		return 80, 24
+	}
+	return int(ws.Col), int(ws.Row)
+}
+
+// resized receives a value whenever the terminal is resized, until stop is
+// called.
+func (term *terminal) resized() (resized <-chan os.Signal, stop func()) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, unix.SIGWINCH)
+	return signals, func() { signal.Stop(signals) }
+}
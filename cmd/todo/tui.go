@@ -0,0 +1,602 @@
+// cmd/todo/tui.go
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/imadbg01/go-todo/client"
+)
+
+// statusCycle is the order space moves a todo through.
+var statusCycle = []string{client.StatusPending, client.StatusInProgress, client.StatusDone}
+
+var priorityCycle = []string{client.PriorityNone, client.PriorityLow, client.PriorityMedium, client.PriorityHigh, client.PriorityUrgent}
+
+// statusFilters are the status filters, in the order tab goes through them.
+// The empty one shows every todo.
+var statusFilters = []string{"", client.StatusPending, client.StatusInProgress, client.StatusDone}
+
+var statusLabels = map[string]string{
+	"":                      "PENDING",
+	client.StatusPending:    "PENDING",
+	client.StatusInProgress: "PROGRESS",
+	client.StatusDone:       "DONE",
+}
+
+type inputMode int
+
+const (
+	modeNormal inputMode = iota
+	modeFilter
+	modeAdd
+	modeEditName
+	modeEditDescription
+	modeEditDue
+	modeConfirmDelete
+)
+
+var prompts = map[inputMode]string{
+	modeFilter:          "Filter: ",
+	modeAdd:             "New todo: ",
+	modeEditName:        "Name: ",
+	modeEditDescription: "Description: ",
+	modeEditDue:         "Due (2006-01-02 15:04, or none): ",
+}
+
+// tui is the state of the terminal UI. It is only used by the goroutine
+// running its loop.
+type tui struct {
+	ctx    context.Context
+	client *client.Client
+	server string
+	term   *terminal
+
+	// todos are every todo in list order, and shown those matching the
+	// filters.
+	todos    []client.Todo
+	shown    []client.Todo
+	selected int
+	offset   int
+	status   string
+	query    string
+
+	mode    inputMode
+	input   []rune
+	message string
+	live    bool
+}
+
+func runTUI(e *env, args []string) error {
+	fs := e.flagSet("tui")
+	args, err := e.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) > 0 {
+		return errUsage
+	}
+	in, inFile := e.stdin.(*os.File)
+	out, outFile := e.stdout.(*os.File)
+	if !inFile || !outFile {
+		return fmt.Errorf("the terminal UI needs a terminal")
+	}
+
+	profile, err := e.config.profile(e.flags)
+	if err != nil {
+		return err
+	}
+	c, err := e.client()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(e.ctx)
+	defer cancel()
+
+	ui := &tui{ctx: ctx, client: c, server: profile.Server}
+	// The first load happens before the screen is taken over, so that a
+	// server out of reach is reported like by the other commands.
+	if err := ui.reload(); err != nil {
+		return err
+	}
+	term, err := openTerminal(in, out)
+	if err != nil {
+		return err
+	}
+	defer term.restore()
+	ui.term = term
+
+	keys := make(chan []byte)
+	go readKeys(in, keys)
+	events := make(chan client.Event, 64)
+	live := make(chan bool)
+	go follow(ctx, c, events, live)
+	resized, stop := term.resized()
+	defer stop()
+
+	for {
+		ui.draw()
+		select {
+		case <-ctx.Done():
+			return nil
+		case data, ok := <-keys:
+			if !ok {
+				return nil
+			}
+			for _, key := range parseKeys(data) {
+				if ui.handle(key) {
+					return nil
+				}
+			}
+		case event := <-events:
+			ui.apply(event)
+		case ui.live = <-live:
+		case <-resized:
+		}
+	}
+}
+
+// readKeys sends what is typed, as it is read.
+func readKeys(in *os.File, keys chan<- []byte) {
+	defer close(keys)
+	buffer := make([]byte, 256)
+	for {
+		n, err := in.Read(buffer)
+		if err != nil {
+			return
+		}
+		keys <- append([]byte(nil), buffer[:n]...)
+	}
+}
+
+// Names of the keys that do not type a character.
+const (
+	keyUp        = "up"
+	keyDown      = "down"
+	keyLeft      = "left"
+	keyRight     = "right"
+	keyHome      = "home"
+	keyEnd       = "end"
+	keyPageUp    = "pgup"
+	keyPageDown  = "pgdown"
+	keyDelete    = "delete"
+	keyEnter     = "enter"
+	keyTab       = "tab"
+	keyBackspace = "backspace"
+	keyEscape    = "esc"
+	keyCtrlC     = "ctrl-c"
+	keyCtrlU     = "ctrl-u"
+)
+
+var escapeSequences = map[string]string{
+	"[A": keyUp, "[B": keyDown, "[C": keyRight, "[D": keyLeft,
+	"OA": keyUp, "OB": keyDown, "OC": keyRight, "OD": keyLeft,
+	"[H": keyHome, "[F": keyEnd, "OH": keyHome, "OF": keyEnd,
+	"[1~": keyHome, "[4~": keyEnd, "[7~": keyHome, "[8~": keyEnd,
+	"[3~": keyDelete, "[5~": keyPageUp, "[6~": keyPageDown,
+}
+
+// parseKeys splits what was read from the terminal into keys: the names of
+// special keys, or the characters typed. An escape alone is the escape key.
+func parseKeys(data []byte) []string {
+	var keys []string
+	for len(data) > 0 {
+		switch b := data[0]; {
+		case b == 0x1b && len(data) == 1:
+			keys = append(keys, keyEscape)
+			data = data[1:]
+		case b == 0x1b:
+			// A sequence runs to its final byte, a letter or ~.
+			end := 2
+			for end < len(data) && end < 8 && !(data[end-1] >= 'A' && data[end-1] <= 'Z' || data[end-1] == '~') {
+				end++
+			}
+			if key, ok := escapeSequences[string(data[1:end])]; ok {
+				keys = append(keys, key)
+			} else if data[1] != '[' && data[1] != 'O' {
+				keys = append(keys, keyEscape)
+				end = 1
+			}
+			data = data[end:]
+		case b == '\r' || b == '\n':
+			keys = append(keys, keyEnter)
+			data = data[1:]
+		case b == '\t':
+			keys = append(keys, keyTab)
+			data = data[1:]
+		case b == 0x7f || b == 0x08:
+			keys = append(keys, keyBackspace)
+			data = data[1:]
+		case b == 0x03:
+			keys = append(keys, keyCtrlC)
+			data = data[1:]
+		case b == 0x15:
+			keys = append(keys, keyCtrlU)
+			data = data[1:]
+		case b < 0x20:
+			data = data[1:]
+		default:
+			r, size := utf8.DecodeRune(data)
+			keys = append(keys, string(r))
+			data = data[size:]
+		}
+	}
+	return keys
+}
+
+// follow sends the changes made to todos, reconnecting after the last event
+// received when the change feed is interrupted. live tells whether it is
+// connected.
+func follow(ctx context.Context, c *client.Client, events chan<- client.Event, live chan<- bool) {
+	lastEventID := ""
+	wait := time.Second
+	for {
+		stream, err := c.Events(ctx, client.EventOptions{LastEventID: lastEventID})
+		if err == nil {
+			sendLive(ctx, live, true)
+			wait = time.Second
+			for stream.Next() {
+				select {
+				case events <- stream.Event():
+				case <-ctx.Done():
+				}
+			}
+			lastEventID = stream.LastEventID()
+			stream.Close()
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		sendLive(ctx, live, false)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+		if wait < 30*time.Second {
+			wait *= 2
+		}
+	}
+}
+
+func sendLive(ctx context.Context, live chan<- bool, connected bool) {
+	select {
+	case live <- connected:
+	case <-ctx.Done():
+	}
+}
+
+// handle acts on a key and tells whether to quit.
+func (ui *tui) handle(key string) bool {
+	if key == keyCtrlC {
+		return true
+	}
+	if ui.mode == modeConfirmDelete {
+		ui.mode = modeNormal
+		if key == "y" || key == "Y" {
+			ui.remove()
+		} else {
+			ui.message = ""
+		}
+		return false
+	}
+	if ui.mode != modeNormal {
+		ui.edit(key)
+		return false
+	}
+
+	ui.message = ""
+	switch key {
+	case "q":
+		return true
+	case "j", keyDown:
+		ui.move(1)
+	case "k", keyUp:
+		ui.move(-1)
+	case keyPageDown:
+		ui.move(ui.listHeight())
+	case keyPageUp:
+		ui.move(-ui.listHeight())
+	case "g", keyHome:
+		ui.move(-len(ui.shown))
+	case "G", keyEnd:
+		ui.move(len(ui.shown))
+	case keyTab:
+		ui.filterStatus(statusFilters[(indexOf(statusFilters, ui.status)+1)%len(statusFilters)])
+	case "1", "2", "3", "4":
+		ui.filterStatus(statusFilters[key[0]-'1'])
+	case "/":
+		ui.prompt(modeFilter, ui.query)
+	case keyEscape:
+		ui.query = ""
+		ui.refilter()
+	case " ":
+		ui.update(func(input *client.TodoInput) {
+			input.Status = statusCycle[(indexOf(statusCycle, input.Status)+1)%len(statusCycle)]
+		})
+	case "x":
+		ui.update(func(input *client.TodoInput) {
+			if input.Status == client.StatusDone {
+				input.Status = client.StatusPending
+			} else {
+				input.Status = client.StatusDone
+			}
+		})
+	case "p":
+		ui.update(func(input *client.TodoInput) {
+			input.Priority = priorityCycle[(indexOf(priorityCycle, input.Priority)+1)%len(priorityCycle)]
+		})
+	case "a":
+		ui.prompt(modeAdd, "")
+	case "e", keyEnter:
+		if todo, ok := ui.current(); ok {
+			ui.prompt(modeEditName, todo.Name)
+		}
+	case "d":
+		if todo, ok := ui.current(); ok {
+			ui.prompt(modeEditDescription, todo.Description)
+		}
+	case "u":
+		if todo, ok := ui.current(); ok {
+			ui.prompt(modeEditDue, formatDue(todo.Due))
+		}
+	case "D", keyDelete:
+		if todo, ok := ui.current(); ok {
+			ui.mode = modeConfirmDelete
+			ui.message = fmt.Sprintf("Delete %q? y/n", todo.Name)
+		}
+	case "r":
+		if err := ui.reload(); err != nil {
+			ui.message = err.Error()
+		} else {
+			ui.message = "Refreshed"
+		}
+	}
+	return false
+}
+
+func (ui *tui) prompt(mode inputMode, value string) {
+	ui.mode = mode
+	ui.input = []rune(value)
+}
+
+// edit types key in the input line and acts on it once entered.
+func (ui *tui) edit(key string) {
+	switch key {
+	case keyEscape:
+		if ui.mode == modeFilter {
+			ui.query = ""
+			ui.refilter()
+		}
+		ui.mode = modeNormal
+		return
+	case keyEnter:
+		mode, value := ui.mode, strings.TrimSpace(string(ui.input))
+		ui.mode = modeNormal
+		ui.submit(mode, value)
+		return
+	case keyBackspace:
+		if len(ui.input) > 0 {
+			ui.input = ui.input[:len(ui.input)-1]
+		}
+	case keyCtrlU:
+		ui.input = nil
+	default:
+		if utf8.RuneCountInString(key) == 1 {
+			ui.input = append(ui.input, []rune(key)...)
+		}
+	}
+	if ui.mode == modeFilter {
+		ui.query = string(ui.input)
+		ui.refilter()
+	}
+}
+
+func (ui *tui) submit(mode inputMode, value string) {
+	switch mode {
+	case modeFilter:
+		ui.query = value
+		ui.refilter()
+	case modeAdd:
+		if value == "" {
+			return
+		}
+		input := client.TodoInput{Name: value, Status: ui.status}
+		if input.Status == "" {
+			input.Status = client.StatusPending
+		}
+		todo, err := ui.client.CreateTodo(ui.ctx, input)
+		if err != nil {
+			ui.message = err.Error()
+			return
+		}
+		ui.upsert(todo)
+		ui.refilter()
+		ui.selectID(todo.ID)
+		ui.message = "Added " + todo.Name
+	case modeEditName:
+		if value == "" {
+			ui.message = "A todo needs a name"
+			return
+		}
+		ui.update(func(input *client.TodoInput) { input.Name = value })
+	case modeEditDescription:
+		ui.update(func(input *client.TodoInput) { input.Description = value })
+	case modeEditDue:
+		due, err := parseDue(value)
+		if err != nil {
+			ui.message = err.Error()
+			return
+		}
+		ui.update(func(input *client.TodoInput) { input.Due = due })
+	}
+}
+
+// update changes the selected todo as it is shown. When it changed on the
+// server in the meantime, the change is not made and the todo is shown as
+// it now is instead.
+func (ui *tui) update(change func(input *client.TodoInput)) {
+	shown, ok := ui.current()
+	if !ok {
+		return
+	}
+	fresh, err := ui.client.GetTodo(ui.ctx, shown.ID)
+	if err != nil {
+		ui.message = err.Error()
+		return
+	}
+	if !fresh.UpdatedAt.Equal(shown.UpdatedAt) {
+		ui.upsert(fresh)
+		ui.refilter()
+		ui.message = "The todo changed on the server; try again"
+		return
+	}
+
+	input := fresh.Input()
+	change(&input)
+	todo, err := ui.client.UpdateTodo(ui.ctx, fresh.ID, input, fresh.ETag)
+	if err != nil {
+		ui.message = err.Error()
+		return
+	}
+	ui.upsert(todo)
+	ui.refilter()
+}
+
+func (ui *tui) remove() {
+	todo, ok := ui.current()
+	if !ok {
+		return
+	}
+	if err := ui.client.DeleteTodo(ui.ctx, todo.ID); err != nil {
+		ui.message = err.Error()
+		return
+	}
+	ui.drop(todo.ID)
+	ui.refilter()
+	ui.message = "Deleted " + todo.Name
+}
+
+// reload fetches every todo.
+func (ui *tui) reload() error {
+	var todos []client.Todo
+	iterator := ui.client.Todos(ui.ctx, client.ListOptions{PageSize: 500})
+	for iterator.Next() {
+		todos = append(todos, iterator.Todo())
+	}
+	if err := iterator.Err(); err != nil {
+		return err
+	}
+	ui.todos = todos
+	ui.refilter()
+	return nil
+}
+
+// apply updates the todos with an event of the change feed.
+func (ui *tui) apply(event client.Event) {
+	switch event.Type {
+	case client.EventReset:
+		if err := ui.reload(); err != nil {
+			ui.message = err.Error()
+		}
+		return
+	case client.EventDeleted:
+		ui.drop(event.Todo.ID)
+	default:
+		ui.upsert(event.Todo)
+	}
+	ui.refilter()
+}
+
+// upsert puts todo in the list, where its rank places it.
+func (ui *tui) upsert(todo client.Todo) {
+	ui.drop(todo.ID)
+	ui.todos = append(ui.todos, todo)
+	sort.SliceStable(ui.todos, func(i, j int) bool {
+		if ui.todos[i].Rank != ui.todos[j].Rank {
+			return ui.todos[i].Rank < ui.todos[j].Rank
+		}
+		return ui.todos[i].ID < ui.todos[j].ID
+	})
+}
+
+func (ui *tui) drop(id uint) {
+	for i, todo := range ui.todos {
+		if todo.ID == id {
+			ui.todos = append(ui.todos[:i], ui.todos[i+1:]...)
+			return
+		}
+	}
+}
+
+func (ui *tui) filterStatus(status string) {
+	ui.status = status
+	ui.refilter()
+}
+
+// refilter lists the todos matching the filters, keeping the selection on
+// the same todo while it is shown.
+func (ui *tui) refilter() {
+	var selectedID uint
+	if todo, ok := ui.current(); ok {
+		selectedID = todo.ID
+	}
+	query := strings.ToLower(ui.query)
+	ui.shown = ui.shown[:0]
+	for _, todo := range ui.todos {
+		status := todo.Status
+		if status == "" {
+			status = client.StatusPending
+		}
+		if ui.status != "" && status != ui.status {
+			continue
+		}
+		if query != "" && !strings.Contains(strings.ToLower(todo.Name+"\n"+todo.Description), query) {
+			continue
+		}
+		ui.shown = append(ui.shown, todo)
+	}
+	ui.selectID(selectedID)
+}
+
+func (ui *tui) selectID(id uint) {
+	for i, todo := range ui.shown {
+		if todo.ID == id {
+			ui.selected = i
+			return
+		}
+	}
+	ui.move(0)
+}
+
+func (ui *tui) move(by int) {
+	ui.selected += by
+	if ui.selected >= len(ui.shown) {
+		ui.selected = len(ui.shown) - 1
+	}
+	if ui.selected < 0 {
+		ui.selected = 0
+	}
+}
+
+func (ui *tui) current() (client.Todo, bool) {
+	if ui.selected < len(ui.shown) {
+		return ui.shown[ui.selected], true
+	}
+	return client.Todo{}, false
+}
+
+func indexOf(values []string, value string) int {
+	for i, v := range values {
+		if v == value {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,153 @@
+// cmd/todo/tui_test.go
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/imadbg01/go-todo/client"
+)
+
+// newTestTUI returns the UI on server, loaded but without a terminal.
+func newTestTUI(t *testing.T, server *fakeServer) *tui {
+	ui := &tui{ctx: context.Background(), client: client.New(server.URL), server: server.URL}
+	if err := ui.reload(); err != nil {
+		t.Fatal(err)
+	}
+	return ui
+}
+
+// names returns the names of the todos shown, in order.
+func (ui *tui) names() string {
+	var names []string
+	for _, todo := range ui.shown {
+		names = append(names, todo.Name)
+	}
+	return strings.Join(names, ",")
+}
+
+func (ui *tui) press(keys ...string) {
+	for _, key := range keys {
+		ui.handle(key)
+	}
+}
+
+func TestTUIFilters(t *testing.T) {
+	server := newFakeServer(t,
+		client.Todo{ID: 1, Name: "Buy milk", Status: "pending"},
+		client.Todo{ID: 2, Name: "File taxes", Status: "done", Description: "Ask about the milk receipts"},
+		client.Todo{ID: 3, Name: "Call Ana", Status: "in_progress"},
+	)
+	ui := newTestTUI(t, server)
+
+	if ui.names() != "Buy milk,File taxes,Call Ana" {
+		t.Fatalf("shown %q, want every todo", ui.names())
+	}
+	ui.press(keyTab)
+	if ui.status != client.StatusPending || ui.names() != "Buy milk" {
+		t.Errorf("tab shows %q of status %q", ui.names(), ui.status)
+	}
+	ui.press("4")
+	if ui.names() != "File taxes" {
+		t.Errorf("4 shows %q, want the done todos", ui.names())
+	}
+	ui.press(keyTab)
+	if ui.status != "" {
+		t.Errorf("tab from the last filter chose %q, want every status", ui.status)
+	}
+
+	// The list narrows as the query is typed, on names and descriptions.
+	ui.press("/", "M", "I", "L")
+	if ui.mode != modeFilter || ui.names() != "Buy milk,File taxes" {
+		t.Errorf("typing /MIL shows %q", ui.names())
+	}
+	ui.press(keyBackspace, keyBackspace, keyBackspace, "a", "n", "a", keyEnter)
+	if ui.mode != modeNormal || ui.query != "ana" || ui.names() != "Call Ana" {
+		t.Errorf("query %q shows %q", ui.query, ui.names())
+	}
+	ui.press("3")
+	if ui.names() != "Call Ana" {
+		t.Errorf("the query and the status filter together show %q", ui.names())
+	}
+	ui.press(keyEscape)
+	if ui.query != "" || ui.names() != "Call Ana" {
+		t.Errorf("escape left query %q showing %q", ui.query, ui.names())
+	}
+}
+
+func TestTUIStatus(t *testing.T) {
+	server := newFakeServer(t,
+		client.Todo{ID: 1, Name: "Buy milk", Status: "pending"},
+		client.Todo{ID: 2, Name: "File taxes", Status: "pending"},
+	)
+	ui := newTestTUI(t, server)
+	ui.press("j")
+
+	ui.press(" ")
+	if server.todos[1].Status != client.StatusInProgress || server.header("If-Match") != `"2-pending"` {
+		t.Fatalf("space left %+v, sending If-Match %q", server.todos[1], server.header("If-Match"))
+	}
+	ui.press(" ", " ")
+	if server.todos[1].Status != client.StatusPending {
+		t.Errorf("space did not cycle back to pending: %+v", server.todos[1])
+	}
+	ui.press("x")
+	if server.todos[1].Status != client.StatusDone || ui.shown[1].Status != client.StatusDone {
+		t.Errorf("x left %+v, shown as %+v", server.todos[1], ui.shown[1])
+	}
+	ui.press("x")
+	if server.todos[1].Status != client.StatusPending {
+		t.Errorf("x on a done todo left %+v", server.todos[1])
+	}
+	if server.todos[0].Status != client.StatusPending {
+		t.Errorf("the todo not selected changed: %+v", server.todos[0])
+	}
+
+	// The selection follows the todo out of the filter.
+	ui.press("2", " ")
+	if ui.names() != "Buy milk" || ui.selected != 0 {
+		t.Errorf("after the selected todo left the filter, shown %q with %d selected", ui.names(), ui.selected)
+	}
+}
+
+func TestTUIApply(t *testing.T) {
+	server := newFakeServer(t,
+		client.Todo{ID: 1, Name: "Buy milk", Status: "pending", Rank: 1},
+		client.Todo{ID: 2, Name: "File taxes", Status: "pending", Rank: 2},
+	)
+	ui := newTestTUI(t, server)
+	ui.press("j")
+
+	ui.apply(client.Event{Type: client.EventCreated, Todo: client.Todo{ID: 3, Name: "Call Ana", Status: "pending", Rank: 1.5}})
+	if ui.names() != "Buy milk,Call Ana,File taxes" {
+		t.Errorf("created todo shown in %q, want it placed by rank", ui.names())
+	}
+	if todo, _ := ui.current(); todo.ID != 2 {
+		t.Errorf("selected todo %d after a todo was created, want 2", todo.ID)
+	}
+
+	ui.apply(client.Event{Type: client.EventUpdated, Todo: client.Todo{ID: 1, Name: "Buy oat milk", Status: "pending", Rank: 3}})
+	if ui.names() != "Call Ana,File taxes,Buy oat milk" {
+		t.Errorf("updated todo shown in %q", ui.names())
+	}
+	ui.press("2")
+	ui.apply(client.Event{Type: client.EventStatusChanged, Todo: client.Todo{ID: 3, Name: "Call Ana", Status: "done", Rank: 1.5}})
+	if ui.names() != "File taxes,Buy oat milk" || len(ui.todos) != 3 {
+		t.Errorf("todo done elsewhere: shown %q of %d", ui.names(), len(ui.todos))
+	}
+
+	ui.apply(client.Event{Type: client.EventDeleted, Todo: client.Todo{ID: 2}})
+	if ui.names() != "Buy oat milk" || len(ui.todos) != 2 {
+		t.Errorf("deleted todo: shown %q of %d", ui.names(), len(ui.todos))
+	}
+	if todo, _ := ui.current(); todo.ID != 1 {
+		t.Errorf("selected todo %d after the selected one was deleted, want 1", todo.ID)
+	}
+
+	// A reset loads the todos from the server again.
+	ui.apply(client.Event{Type: client.EventReset})
+	if len(ui.todos) != 2 || ui.names() != "Buy milk,File taxes" {
+		t.Errorf("after a reset, shown %q of %d", ui.names(), len(ui.todos))
+	}
+}
@@ -0,0 +1,201 @@
+// cmd/todo/tui_view.go
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/imadbg01/go-todo/client"
+)
+
+const (
+	reverse = "\x1b[7m"
+	dim     = "\x1b[2m"
+	bold    = "\x1b[1m"
+	reset   = "\x1b[0m"
+)
+
+const help = "j/k move  space status  x done  p priority  e name  d description  u due  a add  D delete  / filter  tab status  r refresh  q quit"
+
+// listHeight is how many todos the list pane shows, between the header and
+// the two lines at the bottom.
+func (ui *tui) listHeight() int {
+	_, height := ui.term.size()
+	if height < 4 {
+		return 1
+	}
+	return height - 3
+}
+
+// draw paints the whole screen: a header, the list and detail panes, a
+// message line and the input line or the keys.
+func (ui *tui) draw() {
+	width, height := ui.term.size()
+	var screen strings.Builder
+	screen.WriteString("\x1b[?25l")
+	line := func(row int, text string) {
+		fmt.Fprintf(&screen, "\x1b[%d;1H%s\x1b[K", row, text)
+	}
+
+	if width < 40 || height < 8 {
+		screen.WriteString("\x1b[2J")
+		line(1, fit("Enlarge the terminal to use the UI", width))
+		ui.term.out.WriteString(screen.String())
+		return
+	}
+
+	line(1, reverse+fit(ui.header(), width)+reset)
+
+	rows := ui.listHeight()
+	if ui.selected < ui.offset {
+		ui.offset = ui.selected
+	}
+	if ui.selected >= ui.offset+rows {
+		ui.offset = ui.selected - rows + 1
+	}
+	listWidth := width * 55 / 100
+	detailWidth := width - listWidth - 3
+	details := ui.details(detailWidth)
+	for row := 0; row < rows; row++ {
+		left := strings.Repeat(" ", listWidth)
+		if i := ui.offset + row; i < len(ui.shown) {
+			left = ui.listRow(ui.shown[i], listWidth, i == ui.selected)
+		} else if row == 0 && len(ui.shown) == 0 {
+			left = dim + fit(" No todos", listWidth) + reset
+		}
+		right := ""
+		if row < len(details) {
+			right = details[row]
+		}
+		line(row+2, left+" │ "+right)
+	}
+
+	line(height-1, bold+fit(ui.message, width)+reset)
+	if prompt, ok := prompts[ui.mode]; ok {
+		input := string(ui.input)
+		// Show the end of long input.
+		if room := width - utf8.RuneCountInString(prompt) - 1; utf8.RuneCountInString(input) > room {
+			runes := []rune(input)
+			input = string(runes[len(runes)-room:])
+		}
+		line(height, prompt+input)
+		screen.WriteString("\x1b[?25h")
+	} else {
+		line(height, dim+fit(help, width)+reset)
+	}
+	ui.term.out.WriteString(screen.String())
+}
+
+func (ui *tui) header() string {
+	state := "offline"
+	if ui.live {
+		state = "live"
+	}
+	var filters []string
+	for i, status := range statusFilters {
+		name := "ALL"
+		if status != "" {
+			name = statusLabels[status]
+		}
+		if status == ui.status {
+			name = "[" + name + "]"
+		}
+		filters = append(filters, fmt.Sprintf("%d %s", i+1, name))
+	}
+	header := fmt.Sprintf(" todo  %s (%s)   %s   %d/%d", ui.server, state, strings.Join(filters, "  "), len(ui.shown), len(ui.todos))
+	if ui.query != "" {
+		header += "   /" + ui.query
+	}
+	return header
+}
+
+func (ui *tui) listRow(todo client.Todo, width int, selected bool) string {
+	due := ""
+	if todo.Due != nil {
+		due = todo.Due.Local().Format("Jan 02")
+	}
+	row := fit(fmt.Sprintf(" %-8s %-6s %-6s %s", statusLabels[todo.Status], todo.Priority, due, todo.Name), width)
+	switch {
+	case selected:
+		return reverse + row + reset
+	case todo.Status == client.StatusDone:
+		return dim + row + reset
+	}
+	return row
+}
+
+// details are the lines of the detail pane, about the selected todo.
+func (ui *tui) details(width int) []string {
+	todo, ok := ui.current()
+	if !ok || width < 10 {
+		return nil
+	}
+	lines := wrap(todo.Name, width)
+	for i := range lines {
+		lines[i] = bold + lines[i] + reset
+	}
+	lines = append(lines, "")
+	field := func(name, value string) {
+		if value != "" {
+			lines = append(lines, fit(fmt.Sprintf("%-10s %s", name, value), width))
+		}
+	}
+	field("ID", fmt.Sprint(todo.ID))
+	field("Status", statusLabels[todo.Status])
+	field("Priority", todo.Priority)
+	field("Due", formatDue(todo.Due))
+	field("Time zone", todo.TimeZone)
+	field("Repeats", todo.Recurrence)
+	field("Completed", formatTime(todo.CompletedAt))
+	field("Updated", todo.UpdatedAt.Local().Format(time.RFC1123))
+	if todo.Description != "" {
+		lines = append(lines, "")
+		lines = append(lines, wrap(todo.Description, width)...)
+	}
+	return lines
+}
+
+// fit pads or cuts text to width characters.
+func fit(text string, width int) string {
+	count := utf8.RuneCountInString(text)
+	if count <= width {
+		return text + strings.Repeat(" ", width-count)
+	}
+	if width < 1 {
+		return ""
+	}
+	return string([]rune(text)[:width-1]) + "…"
+}
+
+// wrap breaks text into lines of at most width characters, between words
+// where it can.
+func wrap(text string, width int) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(text, "\n") {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			for utf8.RuneCountInString(word) > width {
+				if line != "" {
+					lines = append(lines, line)
+					line = ""
+				}
+				runes := []rune(word)
+				lines = append(lines, string(runes[:width]))
+				word = string(runes[width:])
+			}
+			switch {
+			case line == "":
+				line = word
+			case utf8.RuneCountInString(line)+1+utf8.RuneCountInString(word) <= width:
+				line += " " + word
+			default:
+				lines = append(lines, line)
+				line = word
+			}
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
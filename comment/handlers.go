@@ -0,0 +1,224 @@
+// comment/handlers.go
+package comment
+
+import (
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/imadbg01/go-todo/auth"
+	"github.com/imadbg01/go-todo/openapi"
+	"github.com/imadbg01/go-todo/todo"
+	"github.com/jinzhu/gorm"
+)
+
+type CommentHandler struct {
+	repository *CommentRepository
+	todos      *todo.TodoRepository
+	notifier   Notifier
+}
+
+func (handler *CommentHandler) GetAll(c *fiber.Ctx) error {
+	item, err := handler.todo(c)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"message": "Item not found",
+		})
+	}
+
+	return c.JSON(handler.repository.FindByTodo(item.ID))
+}
+
+func (handler *CommentHandler) Get(c *fiber.Ctx) error {
+	comment, err := handler.comment(c)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status": 404,
+			"error":  err.Error(),
+		})
+	}
+
+	return c.JSON(comment)
+}
+
+func (handler *CommentHandler) Create(c *fiber.Ctx) error {
+	item, err := handler.todo(c)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"message": "Item not found",
+		})
+	}
+
+	data := new(Comment)
+
+	if err := c.BodyParser(data); err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Review your input", "error": err.Error()})
+	}
+
+	if strings.TrimSpace(data.Body) == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  400,
+			"message": "Comment body is required",
+		})
+	}
+
+	comment, err := handler.repository.Create(Comment{
+		TodoID:   item.ID,
+		ParentID: data.ParentID,
+		Author:   auth.Actor(c),
+		Body:     data.Body,
+	})
+
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  400,
+			"message": "Failed creating comment",
+			"error":   err.Error(),
+		})
+	}
+
+	handler.reindex(comment.TodoID)
+	handler.notify(comment, comment.Mentions)
+	return c.Status(201).JSON(comment)
+}
+
+func (handler *CommentHandler) Update(c *fiber.Ctx) error {
+	comment, err := handler.comment(c)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"message": "Comment not found",
+		})
+	}
+
+	actor := auth.Actor(c)
+	if actor == auth.Anonymous || comment.Author != actor {
+		return c.Status(403).JSON(fiber.Map{
+			"status":  403,
+			"message": "Only the author can edit a comment",
+		})
+	}
+
+	data := new(Comment)
+
+	if err := c.BodyParser(data); err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Review your input", "error": err.Error()})
+	}
+
+	if strings.TrimSpace(data.Body) == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  400,
+			"message": "Comment body is required",
+		})
+	}
+
+	comment, added, err := handler.repository.Edit(comment, data.Body, actor)
+
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"message": "Error updating comment",
+			"error":   err.Error(),
+		})
+	}
+
+	handler.reindex(comment.TodoID)
+	handler.notify(comment, added)
+	return c.JSON(comment)
+}
+
+func (handler *CommentHandler) Delete(c *fiber.Ctx) error {
+	comment, err := handler.comment(c)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"message": "Comment not found",
+		})
+	}
+
+	if actor := auth.Actor(c); actor == auth.Anonymous || comment.Author != actor {
+		return c.Status(403).JSON(fiber.Map{
+			"status":  403,
+			"message": "Only the author can delete a comment",
+		})
+	}
+
+	if err := handler.repository.Delete(comment); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  400,
+			"message": "Failed deleting comment",
+			"error":   err.Error(),
+		})
+	}
+	handler.reindex(comment.TodoID)
+	return c.SendStatus(204)
+}
+
+// reindex refreshes the todo's search index with its comments. The comment
+// is already saved, so a failure only leaves search behind until the next
+// change to the todo.
+func (handler *CommentHandler) reindex(todoID uint) {
+	if err := handler.todos.Reindex(todoID); err != nil {
+		log.Printf("comment: reindexing todo %d failed: %v", todoID, err)
+	}
+}
+
+func (handler *CommentHandler) todo(c *fiber.Ctx) (todo.Todo, error) {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return todo.Todo{}, err
+	}
+	return handler.todos.Find(id)
+}
+
+func (handler *CommentHandler) comment(c *fiber.Ctx) (Comment, error) {
+	item, err := handler.todo(c)
+	if err != nil {
+		return Comment{}, err
+	}
+	id, err := strconv.Atoi(c.Params("commentId"))
+	if err != nil {
+		return Comment{}, err
+	}
+	return handler.repository.Find(item.ID, id)
+}
+
+func (handler *CommentHandler) notify(comment Comment, users []string) {
+	for _, user := range users {
+		handler.notifier.Mentioned(user, comment)
+	}
+}
+
+func NewCommentHandler(repository *CommentRepository, todos *todo.TodoRepository, notifier Notifier) *CommentHandler {
+	return &CommentHandler{
+		repository: repository,
+		todos:      todos,
+		notifier:   notifier,
+	}
+}
+
+// describe documents the routes of the handler in the OpenAPI document.
+func describe(handler *CommentHandler) {
+	body := struct {
+		ParentID *uint  `json:"parent_id"`
+		Body     string `json:"body"`
+	}{}
+
+	openapi.Describe(handler.GetAll, openapi.Doc{Summary: "List the comments of a todo as threads", Result: []Comment{}, Errors: []int{404}})
+	openapi.Describe(handler.Get, openapi.Doc{Summary: "Get a comment with its revisions", Result: Comment{}, Errors: []int{404}})
+	openapi.Describe(handler.Create, openapi.Doc{Summary: "Comment on a todo", Body: body, Result: Comment{}, Status: 201, Errors: []int{400, 404}})
+	openapi.Describe(handler.Update, openapi.Doc{Summary: "Edit a comment", Body: body, Result: Comment{}, Errors: []int{400, 403, 404}})
+	openapi.Describe(handler.Delete, openapi.Doc{Summary: "Delete a comment", Status: 204, Errors: []int{400, 403, 404}})
+}
+
+func Register(router fiber.Router, database *gorm.DB) {
+	database.AutoMigrate(&Comment{}, &Revision{}, &Mention{})
+	commentRepository := NewCommentRepository(database)
+	commentHandler := NewCommentHandler(commentRepository, todo.NewTodoRepository(database), logNotifier{})
+	describe(commentHandler)
+
+	commentRouter := router.Group("/todo/:id/comments")
+	commentRouter.Get("/", commentHandler.GetAll)
+	commentRouter.Get("/:commentId", commentHandler.Get)
+	commentRouter.Post("/", commentHandler.Create)
+	commentRouter.Put("/:commentId", commentHandler.Update)
+	commentRouter.Delete("/:commentId", commentHandler.Delete)
+}
@@ -0,0 +1,36 @@
+// comment/mentions.go
+package comment
+
+import (
+	"log"
+	"regexp"
+	"strings"
+)
+
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@])@(\w[\w.-]*)`)
+
+// ParseMentions returns the distinct users mentioned as @name in body.
+func ParseMentions(body string) []string {
+	users := []string{}
+	seen := map[string]bool{}
+	for _, match := range mentionPattern.FindAllStringSubmatch(body, -1) {
+		user := strings.TrimRight(match[1], ".-")
+		if user == "" || seen[user] {
+			continue
+		}
+		seen[user] = true
+		users = append(users, user)
+	}
+	return users
+}
+
+// Notifier is told about every user newly mentioned in a comment.
+type Notifier interface {
+	Mentioned(user string, comment Comment)
+}
+
+type logNotifier struct{}
+
+func (logNotifier) Mentioned(user string, comment Comment) {
+	log.Printf("comment %d on todo %d mentions @%s", comment.ID, comment.TodoID, user)
+}
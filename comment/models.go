@@ -0,0 +1,38 @@
+// comment/models.go
+package comment
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+type Comment struct {
+	gorm.Model
+	TodoID    uint       `gorm:"Not Null;index" json:"todo_id"`
+	ParentID  *uint      `gorm:"index" json:"parent_id"`
+	Author    string     `gorm:"Not Null" json:"author"`
+	Body      string     `gorm:"Not Null" json:"body"`
+	Edited    bool       `json:"edited"`
+	Deleted   bool       `gorm:"-" json:"deleted"`
+	Mentions  []string   `gorm:"-" json:"mentions"`
+	Replies   []Comment  `gorm:"-" json:"replies,omitempty"`
+	Revisions []Revision `json:"revisions,omitempty"`
+}
+
+// Revision keeps the body a comment had before an edit.
+type Revision struct {
+	ID        uint      `gorm:"primary_key" json:"id"`
+	CommentID uint      `gorm:"Not Null;index" json:"comment_id"`
+	Body      string    `json:"body"`
+	Editor    string    `json:"editor"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Mention struct {
+	ID        uint      `gorm:"primary_key" json:"id"`
+	CommentID uint      `gorm:"Not Null;index" json:"comment_id"`
+	TodoID    uint      `gorm:"Not Null" json:"todo_id"`
+	User      string    `gorm:"Not Null;index" json:"user"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,154 @@
+// comment/repositories.go
+package comment
+
+import (
+	"errors"
+
+	"github.com/jinzhu/gorm"
+)
+
+type CommentRepository struct {
+	database *gorm.DB
+}
+
+// FindByTodo returns the comments of a todo as threads. Deleted comments are
+// kept as placeholders while they still have replies.
+func (repository *CommentRepository) FindByTodo(todoID uint) []Comment {
+	var comments []Comment
+	repository.database.Unscoped().Where("todo_id = ?", todoID).Order("created_at asc, id asc").Find(&comments)
+	return thread(comments)
+}
+
+// FindByTodos returns the threads of several todos with one query, keyed by
+// todo ID, so a list of todos can show its comments without a query each.
+func (repository *CommentRepository) FindByTodos(todoIDs []uint) map[uint][]Comment {
+	var comments []Comment
+	repository.database.Unscoped().Where("todo_id IN (?)", todoIDs).Order("created_at asc, id asc").Find(&comments)
+
+	byTodo := map[uint][]Comment{}
+	for _, comment := range comments {
+		byTodo[comment.TodoID] = append(byTodo[comment.TodoID], comment)
+	}
+	threads := map[uint][]Comment{}
+	for _, id := range todoIDs {
+		threads[id] = thread(byTodo[id])
+	}
+	return threads
+}
+
+func (repository *CommentRepository) Find(todoID uint, id int) (Comment, error) {
+	var comment Comment
+	err := repository.database.Preload("Revisions").Where("todo_id = ?", todoID).First(&comment, id).Error
+	if gorm.IsRecordNotFoundError(err) {
+		err = errors.New("Comment not found")
+	}
+	comment.Mentions = ParseMentions(comment.Body)
+	return comment, err
+}
+
+func (repository *CommentRepository) Create(comment Comment) (Comment, error) {
+	if comment.ParentID != nil {
+		parent, err := repository.Find(comment.TodoID, int(*comment.ParentID))
+		if err != nil {
+			return comment, errors.New("Parent comment not found")
+		}
+		comment.ParentID = &parent.ID
+	}
+
+	err := repository.database.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&comment).Error; err != nil {
+			return err
+		}
+		return saveMentions(tx, comment, ParseMentions(comment.Body))
+	})
+	comment.Mentions = ParseMentions(comment.Body)
+	return comment, err
+}
+
+// Edit replaces the body of a comment, keeping the old one as a revision. It
+// returns the users that were not mentioned before the edit.
+func (repository *CommentRepository) Edit(comment Comment, body string, editor string) (Comment, []string, error) {
+	before := ParseMentions(comment.Body)
+	var added []string
+	for _, user := range ParseMentions(body) {
+		if !contains(before, user) {
+			added = append(added, user)
+		}
+	}
+
+	err := repository.database.Transaction(func(tx *gorm.DB) error {
+		revision := Revision{CommentID: comment.ID, Body: comment.Body, Editor: editor}
+		if err := tx.Create(&revision).Error; err != nil {
+			return err
+		}
+		err := tx.Model(&Comment{}).Where("id = ?", comment.ID).Updates(map[string]interface{}{"body": body, "edited": true}).Error
+		if err != nil {
+			return err
+		}
+		comment.Body = body
+		comment.Edited = true
+		comment.Revisions = append(comment.Revisions, revision)
+		return saveMentions(tx, comment, added)
+	})
+	comment.Mentions = ParseMentions(comment.Body)
+	return comment, added, err
+}
+
+func (repository *CommentRepository) Delete(comment Comment) error {
+	return repository.database.Delete(&comment).Error
+}
+
+func saveMentions(tx *gorm.DB, comment Comment, users []string) error {
+	for _, user := range users {
+		mention := Mention{CommentID: comment.ID, TodoID: comment.TodoID, User: user}
+		if err := tx.Create(&mention).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func thread(comments []Comment) []Comment {
+	children := map[uint][]Comment{}
+	for _, comment := range comments {
+		var parent uint
+		if comment.ParentID != nil {
+			parent = *comment.ParentID
+		}
+		children[parent] = append(children[parent], comment)
+	}
+
+	var build func(parent uint) []Comment
+	build = func(parent uint) []Comment {
+		threads := []Comment{}
+		for _, comment := range children[parent] {
+			comment.Replies = build(comment.ID)
+			if comment.DeletedAt != nil {
+				if len(comment.Replies) == 0 {
+					continue
+				}
+				comment.Deleted = true
+				comment.Body = ""
+			}
+			comment.Mentions = ParseMentions(comment.Body)
+			threads = append(threads, comment)
+		}
+		return threads
+	}
+	return build(0)
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+func NewCommentRepository(database *gorm.DB) *CommentRepository {
+	return &CommentRepository{
+		database: database,
+	}
+}
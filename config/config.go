@@ -15,3 +15,11 @@ func Config(key string) string {
 	}
 	return os.Getenv(key)
 }
+
+// ConfigOr returns the value of key, or fallback when it is not set.
+func ConfigOr(key string, fallback string) string {
+	if value := Config(key); value != "" {
+		return value
+	}
+	return fallback
+}
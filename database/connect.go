@@ -16,7 +16,7 @@ func ConnectDB() {
 	p := config.Config("DB_PORT")
 	port, err := strconv.ParseUint(p, 10, 32)
 
-	configData := fmt.Sprintf(
+	DSN = fmt.Sprintf(
 		"host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
 		config.Config("DB_HOST"),
 		port,
@@ -27,7 +27,7 @@ func ConnectDB() {
 
 	DB, err = gorm.Open(
 		"postgres",
-		configData,
+		DSN,
 	)
 
 	if err != nil {
@@ -5,3 +5,6 @@ import "github.com/jinzhu/gorm"
 
 // DB gorm connector
 var DB *gorm.DB
+
+// DSN connection string DB was opened with
+var DSN string
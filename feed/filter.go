@@ -0,0 +1,71 @@
+// feed/filter.go
+package feed
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+// Filter selects the messages a client is interested in. Empty fields match
+// everything.
+type Filter struct {
+	Types  []string
+	Status []string
+	IDs    []uint
+}
+
+// ParseFilter reads a filter from the types, status and ids query parameters,
+// each a comma separated list. The values are copied, as streams outlive the
+// request context they were parsed from.
+func ParseFilter(c *fiber.Ctx) Filter {
+	filter := Filter{
+		Types:  splitList(utils.CopyString(c.Query("types"))),
+		Status: splitList(utils.CopyString(c.Query("status"))),
+	}
+	for _, id := range splitList(c.Query("ids")) {
+		if n, err := strconv.ParseUint(id, 10, 64); err == nil {
+			filter.IDs = append(filter.IDs, uint(n))
+		}
+	}
+	return filter
+}
+
+func (filter Filter) Match(message Message) bool {
+	if len(filter.Types) > 0 && !contains(filter.Types, message.Type) {
+		return false
+	}
+	if len(filter.Status) > 0 && !contains(filter.Status, message.Todo.Status) {
+		return false
+	}
+	if len(filter.IDs) > 0 {
+		for _, id := range filter.IDs {
+			if id == message.Todo.ID {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func splitList(value string) []string {
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,167 @@
+// feed/handlers.go
+package feed
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/fasthttp/websocket"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/imadbg01/go-todo/auth"
+	"github.com/imadbg01/go-todo/openapi"
+	"github.com/imadbg01/go-todo/todo"
+	"github.com/imadbg01/go-todo/version"
+	"github.com/jinzhu/gorm"
+	"github.com/valyala/fasthttp"
+)
+
+const (
+	heartbeat    = 15 * time.Second
+	writeTimeout = 10 * time.Second
+)
+
+type FeedHandler struct {
+	hub      *Hub
+	upgrader websocket.FastHTTPUpgrader
+}
+
+// Events streams todo changes as Server-Sent Events. A reconnecting client
+// resumes after the Last-Event-ID header.
+func (handler *FeedHandler) Events(c *fiber.Ctx) error {
+	filter := ParseFilter(c)
+	cursor := utils.CopyString(c.Get("Last-Event-ID", c.Query("last_event_id")))
+	marshal := version.Of(c).Marshal
+
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderConnection, "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		fmt.Fprintf(w, "retry: 3000\n\n")
+		if w.Flush() != nil {
+			return
+		}
+		handler.hub.Follow(cursor, filter, func(message Message) error {
+			data, err := marshal(message)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", message.ID, message.Type, data)
+			return w.Flush()
+		}, func() error {
+			fmt.Fprintf(w, ": ping\n\n")
+			return w.Flush()
+		}, nil)
+	})
+	return nil
+}
+
+// WebSocket streams the same messages as Events over a WebSocket. Browsers
+// cannot set headers there, so clients resume with ?last_event_id=.
+func (handler *FeedHandler) WebSocket(c *fiber.Ctx) error {
+	if !websocket.FastHTTPIsWebSocketUpgrade(c.Context()) {
+		return c.Status(426).JSON(fiber.Map{
+			"status":  426,
+			"message": "Expected a WebSocket upgrade",
+		})
+	}
+
+	filter := ParseFilter(c)
+	cursor := utils.CopyString(c.Query("last_event_id"))
+	marshal := version.Of(c).Marshal
+
+	err := handler.upgrader.Upgrade(c.Context(), func(conn *websocket.Conn) {
+		defer conn.Close()
+
+		closed := make(chan struct{})
+		go func() {
+			defer close(closed)
+			for {
+				if _, _, err := conn.ReadMessage(); err != nil {
+					return
+				}
+			}
+		}()
+
+		handler.hub.Follow(cursor, filter, func(message Message) error {
+			data, err := marshal(message)
+			if err != nil {
+				return err
+			}
+			conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+			return conn.WriteMessage(websocket.TextMessage, data)
+		}, func() error {
+			return conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeTimeout))
+		}, closed)
+	})
+	if err != nil {
+		// The upgrader has answered the failed handshake already, with 403
+		// for an origin that is not allowed.
+		log.Printf("feed: websocket upgrade failed: %v", err)
+	}
+	return nil
+}
+
+// NewFeedHandler serves the hub. WebSockets are only accepted from pages on
+// the API's own host or in ALLOWED_ORIGINS, as any other site could open one
+// with the user's cookies.
+func NewFeedHandler(hub *Hub) *FeedHandler {
+	origins := auth.OriginsFromConfig()
+	return &FeedHandler{
+		hub: hub,
+		upgrader: websocket.FastHTTPUpgrader{
+			CheckOrigin: func(ctx *fasthttp.RequestCtx) bool {
+				return origins.Allow(string(ctx.Request.Header.Peek(fiber.HeaderOrigin)), string(ctx.Host()))
+			},
+		},
+	}
+}
+
+// describe documents the routes of the handler in the OpenAPI document.
+func describe(handler *FeedHandler) {
+	query := []*openapi.Parameter{
+		{Name: "types", Description: "Comma separated event types to follow", Schema: openapi.Type("string")},
+		{Name: "status", Description: "Comma separated statuses of the todos to follow", Schema: openapi.Type("string")},
+		{Name: "ids", Description: "Comma separated IDs of the todos to follow", Schema: openapi.Type("string")},
+		{Name: "last_event_id", Description: "Resume after this event", Schema: openapi.Type("string")},
+	}
+
+	openapi.Describe(handler.Events, openapi.Doc{
+		Summary:     "Stream todo changes as Server-Sent Events",
+		Query:       query,
+		Headers:     []*openapi.Parameter{{Name: "Last-Event-ID", Description: "Resume after this event", Schema: openapi.Type("string")}},
+		ResultTypes: []string{"text/event-stream"},
+	})
+	openapi.Describe(handler.WebSocket, openapi.Doc{
+		Summary:     "Stream todo changes over a WebSocket",
+		Description: "Each message is a JSON event like those of the Server-Sent Events stream.",
+		Query:       query,
+		Status:      fiber.StatusSwitchingProtocols,
+		Errors:      []int{426},
+	})
+}
+
+// Register mounts the change feed. It has to run before todo.Register, whose
+// /todo/:id route would otherwise match /todo/events. On Postgres events go
+// through LISTEN/NOTIFY so clients of every replica receive them.
+// The hub is returned for other transports of the same events.
+func Register(router fiber.Router, database *gorm.DB, dsn string) *Hub {
+	hub := NewHub(1024)
+	if database.Dialect().GetName() == "postgres" {
+		relay := NewRelay(database, dsn, hub)
+		todo.Subscribe(relay.Notify)
+		go relay.Listen()
+	} else {
+		todo.Subscribe(hub.Publish)
+	}
+	feedHandler := NewFeedHandler(hub)
+	describe(feedHandler)
+
+	router.Get("/todo/events", feedHandler.Events)
+	router.Get("/todo/events/ws", feedHandler.WebSocket)
+	return hub
+}
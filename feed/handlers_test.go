@@ -0,0 +1,30 @@
+// feed/handlers_test.go
+package feed
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// TestWebSocketOrigin checks that an upgrade from another site is refused
+// with the 403 the upgrader answers, rather than an error of the handler.
+func TestWebSocketOrigin(t *testing.T) {
+	app := fiber.New()
+	app.Get("/feed/ws", NewFeedHandler(nil).WebSocket)
+
+	req := httptest.NewRequest("GET", "http://api.example/feed/ws", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	req.Header.Set("Origin", "https://elsewhere.example")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 403 {
+		t.Errorf("upgrade from another origin answered %d, want 403", resp.StatusCode)
+	}
+}
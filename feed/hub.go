@@ -0,0 +1,193 @@
+// feed/hub.go
+package feed
+
+import (
+	"sync"
+	"time"
+
+	"github.com/imadbg01/go-todo/todo"
+)
+
+// Message is a todo event as it is streamed to clients. Its ID is the one
+// of the outbox message that carried the event, which every replica
+// receives in the same order, so a client can resume on any replica.
+type Message struct {
+	ID   string    `json:"id"`
+	Type string    `json:"type"`
+	Todo todo.Todo `json:"todo"`
+
+	seq uint64
+}
+
+// Hub keeps the most recent events in a ring buffer. Clients read from it at
+// their own pace: a slow client never holds up publishers, and one that falls
+// further behind than the buffer is told to reset. Positions in the buffer
+// only grow; start is the position the buffer was last reset at.
+type Hub struct {
+	mu      sync.Mutex
+	seq     uint64
+	start   uint64
+	buffer  []Message
+	changed chan struct{}
+}
+
+// Publish adds the event to the buffer. An event offered again by the
+// outbox is only added once.
+func (hub *Hub) Publish(event todo.Event) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	if _, ok := hub.find(event.ID); ok {
+		return
+	}
+	hub.seq++
+	message := Message{
+		ID:   event.ID,
+		Type: event.Type,
+		Todo: event.Todo,
+		seq:  hub.seq,
+	}
+	hub.buffer[hub.seq%uint64(len(hub.buffer))] = message
+
+	close(hub.changed)
+	hub.changed = make(chan struct{})
+}
+
+// Since returns the buffered messages after lastID and a channel that is
+// closed on the next publish. It reports false when lastID is not in the
+// buffer, because it has already left it or the hub was reset since.
+func (hub *Hub) Since(lastID string) ([]Message, <-chan struct{}, bool) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	seq, ok := hub.find(lastID)
+	if !ok {
+		return nil, hub.changed, false
+	}
+	return hub.after(seq), hub.changed, true
+}
+
+// Reset empties the buffer, so every client is told to reset. It is used
+// when events may have been missed.
+func (hub *Hub) Reset() {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	// Skip a position, so that clients that had read everything are told
+	// to reset as well.
+	hub.seq++
+	hub.start = hub.seq
+	hub.buffer = make([]Message, len(hub.buffer))
+
+	close(hub.changed)
+	hub.changed = make(chan struct{})
+}
+
+// Latest returns the ID of the newest message, or an empty one while the
+// buffer is empty.
+func (hub *Hub) Latest() string {
+	_, id := hub.newest()
+	return id
+}
+
+// Follow sends the messages after cursor that pass the filter until sending
+// fails or done is closed, pinging while idle; ping may be nil. An empty
+// cursor starts at the newest message. A client that fell behind the buffer
+// gets a reset message and should refetch the list before following on.
+func (hub *Hub) Follow(cursor string, filter Filter, send func(Message) error, ping func() error, done <-chan struct{}) {
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+
+	position, _ := hub.newest()
+	ok := true
+	if cursor != "" {
+		hub.mu.Lock()
+		position, ok = hub.find(cursor)
+		hub.mu.Unlock()
+	}
+	for {
+		var messages []Message
+		var changed <-chan struct{}
+		if ok {
+			messages, changed, ok = hub.next(position)
+		}
+		if !ok {
+			var latest string
+			position, latest = hub.newest()
+			ok = true
+			if send(Message{ID: latest, Type: "reset"}) != nil {
+				return
+			}
+			continue
+		}
+
+		for _, message := range messages {
+			position = message.seq
+			if !filter.Match(message) {
+				continue
+			}
+			if send(message) != nil {
+				return
+			}
+		}
+
+		select {
+		case <-changed:
+		case <-done:
+			return
+		case <-ticker.C:
+			if ping != nil && ping() != nil {
+				return
+			}
+		}
+	}
+}
+
+// next returns the messages after the position, like Since. It reports
+// false when the position has left the buffer or the hub was reset since.
+func (hub *Hub) next(position uint64) ([]Message, <-chan struct{}, bool) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	if position < hub.start || hub.seq-position > uint64(len(hub.buffer)) {
+		return nil, hub.changed, false
+	}
+	return hub.after(position), hub.changed, true
+}
+
+// newest returns the position and ID of the newest message.
+func (hub *Hub) newest() (uint64, string) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	if hub.seq == hub.start {
+		return hub.seq, ""
+	}
+	return hub.seq, hub.buffer[hub.seq%uint64(len(hub.buffer))].ID
+}
+
+func (hub *Hub) after(position uint64) []Message {
+	messages := make([]Message, 0, hub.seq-position)
+	for seq := position + 1; seq <= hub.seq; seq++ {
+		messages = append(messages, hub.buffer[seq%uint64(len(hub.buffer))])
+	}
+	return messages
+}
+
+// find returns the position of the buffered message with the given ID.
+func (hub *Hub) find(id string) (uint64, bool) {
+	size := uint64(len(hub.buffer))
+	for seq := hub.seq; seq > hub.start && hub.seq-seq < size; seq-- {
+		if hub.buffer[seq%size].ID == id {
+			return seq, true
+		}
+	}
+	return 0, false
+}
+
+func NewHub(size int) *Hub {
+	return &Hub{
+		buffer:  make([]Message, size),
+		changed: make(chan struct{}),
+	}
+}
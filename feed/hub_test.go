@@ -0,0 +1,109 @@
+// feed/hub_test.go
+package feed
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/imadbg01/go-todo/todo"
+)
+
+func event(id int) todo.Event {
+	return todo.Event{ID: fmt.Sprint(id), Type: todo.EventUpdated, Todo: todo.Todo{Name: fmt.Sprint("todo ", id)}}
+}
+
+func ids(messages []Message) []string {
+	var ids []string
+	for _, message := range messages {
+		ids = append(ids, message.ID)
+	}
+	return ids
+}
+
+// TestResumeOnAnotherReplica checks that an ID handed out by one hub can be
+// resumed from on another that received the same events.
+func TestResumeOnAnotherReplica(t *testing.T) {
+	first, second := NewHub(8), NewHub(8)
+	for id := 1; id <= 5; id++ {
+		first.Publish(event(id))
+		second.Publish(event(id))
+	}
+	second.Publish(event(4))
+
+	messages, _, ok := second.Since(first.Latest())
+	if !ok || len(messages) != 0 {
+		t.Fatalf("Since(latest) = %v, %v", ids(messages), ok)
+	}
+	messages, _, ok = second.Since("3")
+	if got := fmt.Sprint(ids(messages)); !ok || got != "[4 5]" {
+		t.Errorf("Since(3) = %s, %v, want [4 5]", got, ok)
+	}
+}
+
+func TestSinceUnknownOrEvicted(t *testing.T) {
+	hub := NewHub(4)
+	for id := 1; id <= 6; id++ {
+		hub.Publish(event(id))
+	}
+	for _, id := range []string{"1", "2", "", "unknown"} {
+		if _, _, ok := hub.Since(id); ok {
+			t.Errorf("Since(%q) succeeded", id)
+		}
+	}
+	if messages, _, ok := hub.Since("3"); !ok || len(messages) != 3 {
+		t.Errorf("Since(3) = %v, %v", ids(messages), ok)
+	}
+}
+
+var errStop = errors.New("stop")
+
+func TestFollow(t *testing.T) {
+	hub := NewHub(4)
+	received := make(chan Message, 16)
+	done := make(chan struct{})
+	defer close(done)
+	go hub.Follow("", Filter{}, func(message Message) error {
+		received <- message
+		return nil
+	}, nil, done)
+
+	next := func() Message {
+		select {
+		case message := <-received:
+			return message
+		case <-time.After(time.Second):
+			t.Fatal("no message")
+			return Message{}
+		}
+	}
+
+	// Give Follow time to start at the empty buffer.
+	time.Sleep(20 * time.Millisecond)
+	hub.Publish(event(1))
+	hub.Publish(event(2))
+	if got := next().ID + next().ID; got != "12" {
+		t.Fatalf("followed %s, want 12", got)
+	}
+
+	hub.Reset()
+	if message := next(); message.Type != "reset" || message.ID != "" {
+		t.Fatalf("after Reset got %+v", message)
+	}
+	hub.Publish(event(3))
+	if message := next(); message.ID != "3" {
+		t.Fatalf("after reset followed %s, want 3", message.ID)
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		hub.Follow("2", Filter{}, func(Message) error { return errStop }, nil, nil)
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Follow from an ID gone with a reset kept running")
+	}
+}
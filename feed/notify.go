@@ -0,0 +1,105 @@
+// feed/notify.go
+package feed
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/imadbg01/go-todo/todo"
+	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
+)
+
+// notifyChannel is the Postgres channel todo events are sent on.
+const notifyChannel = "todo_events"
+
+// maxPayload keeps NOTIFY payloads under the server's 8000 byte limit. Larger
+// events are sent as a pointer and the todo is read back by the receiver.
+const maxPayload = 7000
+
+// notification is the NOTIFY payload. Todo is nil when the event was too
+// large to send whole.
+type notification struct {
+	ID     string     `json:"id"`
+	Type   string     `json:"type"`
+	TodoID uint       `json:"todo_id"`
+	Todo   *todo.Todo `json:"todo,omitempty"`
+}
+
+// Relay carries todo events between replicas over Postgres LISTEN/NOTIFY.
+// Every replica, including the one that handled the write, receives its
+// events from Postgres, so all hubs see the same stream.
+type Relay struct {
+	database *gorm.DB
+	dsn      string
+	hub      *Hub
+}
+
+// Notify sends the event to every listening replica.
+func (relay *Relay) Notify(event todo.Event) {
+	payload, err := json.Marshal(notification{ID: event.ID, Type: event.Type, TodoID: event.Todo.ID, Todo: &event.Todo})
+	if err == nil && len(payload) > maxPayload {
+		payload, err = json.Marshal(notification{ID: event.ID, Type: event.Type, TodoID: event.Todo.ID})
+	}
+	if err != nil {
+		log.Printf("feed: encoding %s event: %v", event.Type, err)
+		return
+	}
+	if err := relay.database.Exec("SELECT pg_notify(?, ?)", notifyChannel, string(payload)).Error; err != nil {
+		log.Printf("feed: notifying %s event: %v", event.Type, err)
+	}
+}
+
+// Listen publishes the events received from Postgres to the hub. The listener
+// reconnects by itself; as events sent while it was away are lost, the hub is
+// reset so clients refetch.
+func (relay *Relay) Listen() {
+	listener := pq.NewListener(relay.dsn, 10*time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("feed: listener: %v", err)
+		}
+	})
+	if err := listener.Listen(notifyChannel); err != nil {
+		log.Printf("feed: listening on %s: %v", notifyChannel, err)
+		return
+	}
+
+	for {
+		select {
+		case n := <-listener.Notify:
+			if n == nil {
+				relay.hub.Reset()
+				continue
+			}
+			relay.receive(n.Extra)
+		case <-time.After(90 * time.Second):
+			go listener.Ping()
+		}
+	}
+}
+
+func (relay *Relay) receive(payload string) {
+	var message notification
+	if err := json.Unmarshal([]byte(payload), &message); err != nil {
+		log.Printf("feed: decoding notification: %v", err)
+		return
+	}
+	if message.Todo == nil {
+		var found todo.Todo
+		if err := relay.database.Unscoped().First(&found, message.TodoID).Error; err != nil {
+			log.Printf("feed: fetching todo %d: %v", message.TodoID, err)
+			return
+		}
+		message.Todo = &found
+	}
+	relay.hub.Publish(todo.Event{ID: message.ID, Type: message.Type, Todo: *message.Todo})
+}
+
+func NewRelay(database *gorm.DB, dsn string, hub *Hub) *Relay {
+	return &Relay{
+		database: database,
+		dsn:      dsn,
+		hub:      hub,
+	}
+}
@@ -0,0 +1,308 @@
+// graph/handlers.go
+package graph
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/fasthttp/websocket"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/imadbg01/go-todo/auth"
+	"github.com/imadbg01/go-todo/comment"
+	"github.com/imadbg01/go-todo/config"
+	"github.com/imadbg01/go-todo/feed"
+	"github.com/imadbg01/go-todo/graphql"
+	"github.com/imadbg01/go-todo/openapi"
+	"github.com/imadbg01/go-todo/todo"
+	"github.com/jinzhu/gorm"
+	"github.com/valyala/fasthttp"
+)
+
+// protocol is the graphql-transport-ws subprotocol spoken over WebSockets.
+const protocol = "graphql-transport-ws"
+
+const (
+	initTimeout  = 10 * time.Second
+	writeTimeout = 10 * time.Second
+)
+
+type GraphHandler struct {
+	schema   *graphql.Schema
+	comments *comment.CommentRepository
+	upgrader websocket.FastHTTPUpgrader
+}
+
+// Query runs a query or mutation sent as JSON in a POST body, or a query in
+// the query string of a GET. A GET that asks for a WebSocket upgrade starts
+// a subscription session instead.
+func (handler *GraphHandler) Query(c *fiber.Ctx) error {
+	if websocket.FastHTTPIsWebSocketUpgrade(c.Context()) {
+		return handler.WebSocket(c)
+	}
+
+	var request graphql.Request
+	if c.Method() == fiber.MethodGet {
+		request.Query = c.Query("query")
+		request.OperationName = c.Query("operationName")
+		if variables := c.Query("variables"); variables != "" {
+			if err := decode([]byte(variables), &request.Variables); err != nil {
+				return requestError(c, 400, "variables must be a JSON object")
+			}
+		}
+	} else if err := decode(c.Body(), &request); err != nil {
+		return requestError(c, 400, "Body must be a JSON object with a query")
+	}
+
+	switch graphql.OperationType(request) {
+	case "mutation":
+		if c.Method() == fiber.MethodGet {
+			c.Set(fiber.HeaderAllow, fiber.MethodPost)
+			return requestError(c, 405, "Mutations must be sent with POST")
+		}
+	case "subscription":
+		return requestError(c, 400, "Subscriptions are served over WebSocket with the "+protocol+" protocol")
+	}
+
+	response := handler.schema.Execute(withLoader(handler.context(c), handler.comments), request)
+	status := 200
+	if response.Data == nil {
+		status = 400
+	}
+	return c.Status(status).JSON(response)
+}
+
+// Schema serves the schema in SDL.
+func (handler *GraphHandler) Schema(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderContentType, "text/plain; charset=utf-8")
+	return c.SendString(handler.schema.SDL())
+}
+
+// message is a graphql-transport-ws message.
+type message struct {
+	ID      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// session is one WebSocket connection, which can run several operations
+// at once, each under the ID the client gave it.
+type session struct {
+	handler    *GraphHandler
+	conn       *websocket.Conn
+	writeMu    sync.Mutex
+	mu         sync.Mutex
+	operations map[string]context.CancelFunc
+}
+
+// WebSocket speaks graphql-transport-ws: the client sends connection_init,
+// then subscribe messages that are answered with next messages until the
+// operation completes or the client sends complete. Only subscriptions are
+// run; queries and mutations are answered with an error, so that they
+// always go through the checks HTTP requests pass. The upgrade is refused
+// to pages from other origins than the API's own or ALLOWED_ORIGINS.
+func (handler *GraphHandler) WebSocket(c *fiber.Ctx) error {
+	base := handler.context(c)
+	err := handler.upgrader.Upgrade(c.Context(), func(conn *websocket.Conn) {
+		ctx, cancel := context.WithCancel(base)
+		defer cancel()
+		defer conn.Close()
+
+		s := &session{handler: handler, conn: conn, operations: map[string]context.CancelFunc{}}
+		if conn.Subprotocol() != protocol {
+			s.close(4406, "Subprotocol not acceptable")
+			return
+		}
+
+		acknowledged := false
+		conn.SetReadDeadline(time.Now().Add(initTimeout))
+		for {
+			var incoming message
+			if err := conn.ReadJSON(&incoming); err != nil {
+				if !acknowledged {
+					s.close(4408, "Connection initialisation timeout")
+				}
+				return
+			}
+
+			switch incoming.Type {
+			case "connection_init":
+				if acknowledged {
+					s.close(4429, "Too many initialisation requests")
+					return
+				}
+				acknowledged = true
+				conn.SetReadDeadline(time.Time{})
+				s.send(message{Type: "connection_ack"})
+			case "ping":
+				s.send(message{Type: "pong"})
+			case "pong":
+			case "subscribe":
+				if !acknowledged {
+					s.close(4401, "Unauthorized")
+					return
+				}
+				var request graphql.Request
+				if err := decode(incoming.Payload, &request); err != nil || incoming.ID == "" {
+					s.close(4400, "Invalid subscribe message")
+					return
+				}
+				if !s.start(ctx, incoming.ID, request) {
+					s.close(4409, "Subscriber for "+incoming.ID+" already exists")
+					return
+				}
+			case "complete":
+				s.stop(incoming.ID)
+			default:
+				s.close(4400, "Unknown message type "+strconv.Quote(incoming.Type))
+				return
+			}
+		}
+	})
+	if err != nil {
+		// The upgrader has answered the failed handshake already.
+		log.Printf("graph: websocket upgrade failed: %v", err)
+	}
+	return nil
+}
+
+func (s *session) start(ctx context.Context, id string, request graphql.Request) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.operations[id]; exists {
+		return false
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	s.operations[id] = cancel
+	go s.run(ctx, id, request)
+	return true
+}
+
+func (s *session) stop(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cancel, ok := s.operations[id]; ok {
+		cancel()
+		delete(s.operations, id)
+	}
+}
+
+// run executes a subscription, answering for every event until it is
+// stopped. Subscribe refuses other operations.
+func (s *session) run(ctx context.Context, id string, request graphql.Request) {
+	defer s.stop(id)
+	ctx = withLoader(ctx, s.handler.comments)
+
+	failure := s.handler.schema.Subscribe(ctx, request, func(response *graphql.Response) error {
+		return s.sendPayload(id, "next", response)
+	})
+	if failure != nil {
+		s.sendPayload(id, "error", failure.Errors)
+		return
+	}
+	if ctx.Err() == nil {
+		s.send(message{ID: id, Type: "complete"})
+	}
+}
+
+func (s *session) send(outgoing message) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	s.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	return s.conn.WriteJSON(outgoing)
+}
+
+func (s *session) sendPayload(id, kind string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return s.send(message{ID: id, Type: kind, Payload: data})
+}
+
+func (s *session) close(code int, reason string) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	s.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(writeTimeout))
+}
+
+// context carries who is making the request to the resolvers. The values
+// are copied, as subscriptions outlive the request they started in.
+func (handler *GraphHandler) context(c *fiber.Ctx) context.Context {
+	requestID, _ := c.Locals("requestid").(string)
+	actor := todo.Actor{User: utils.CopyString(auth.Actor(c)), RequestID: utils.CopyString(requestID)}
+	return context.WithValue(context.Background(), actorKey, actor)
+}
+
+// decode reads JSON keeping numbers as json.Number, which the GraphQL
+// scalars expect.
+func decode(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+func requestError(c *fiber.Ctx, status int, text string) error {
+	return c.Status(status).JSON(graphql.Response{Errors: []*graphql.Error{{Message: text}}})
+}
+
+func NewGraphHandler(schema *graphql.Schema, comments *comment.CommentRepository) *GraphHandler {
+	origins := auth.OriginsFromConfig()
+	return &GraphHandler{
+		schema:   schema,
+		comments: comments,
+		upgrader: websocket.FastHTTPUpgrader{
+			CheckOrigin: func(ctx *fasthttp.RequestCtx) bool {
+				return origins.Allow(string(ctx.Request.Header.Peek(fiber.HeaderOrigin)), string(ctx.Host()))
+			},
+			Subprotocols: []string{protocol},
+		},
+	}
+}
+
+// describe documents the routes of the handler in the OpenAPI document.
+func describe(handler *GraphHandler) {
+	openapi.Describe(handler.Query, openapi.Doc{
+		Summary:     "Run a GraphQL operation",
+		Description: "Queries may be sent with GET, mutations only with POST. Subscriptions are served over a WebSocket upgrade of GET with the " + protocol + " protocol.",
+		Query: []*openapi.Parameter{
+			{Name: "query", Description: "The document, for GET", Schema: openapi.Type("string")},
+			{Name: "operationName", Schema: openapi.Type("string")},
+			{Name: "variables", Description: "A JSON object, for GET", Schema: openapi.Type("string")},
+		},
+		Body:   graphql.Request{},
+		Result: graphql.Response{},
+		Errors: []int{400, 405},
+	})
+	openapi.Describe(handler.Schema, openapi.Doc{Summary: "Get the GraphQL schema in SDL", ResultTypes: []string{fiber.MIMETextPlain}})
+}
+
+// Register mounts the GraphQL endpoint. Subscriptions follow the hub of
+// the change feed.
+func Register(router fiber.Router, database *gorm.DB, hub *feed.Hub) {
+	schema, err := newSchema(&resolver{repository: todo.NewTodoRepository(database), hub: hub})
+	if err != nil {
+		panic("failed to build GraphQL schema: " + err.Error())
+	}
+	schema.MaxDepth = limit("GRAPHQL_MAX_DEPTH", 10)
+	schema.MaxComplexity = limit("GRAPHQL_MAX_COMPLEXITY", 1000)
+	graphHandler := NewGraphHandler(schema, comment.NewCommentRepository(database))
+	describe(graphHandler)
+
+	router.Get("/graphql", graphHandler.Query)
+	router.Post("/graphql", graphHandler.Query)
+	router.Get("/graphql/schema.graphql", graphHandler.Schema)
+}
+
+func limit(key string, fallback int) int {
+	value, err := strconv.Atoi(config.ConfigOr(key, strconv.Itoa(fallback)))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,205 @@
+// graph/handlers_test.go
+package graph
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fasthttp/websocket"
+	"github.com/gofiber/fiber/v2"
+	"github.com/imadbg01/go-todo/comment"
+	"github.com/imadbg01/go-todo/feed"
+	"github.com/imadbg01/go-todo/outbox"
+	"github.com/imadbg01/go-todo/todo"
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+)
+
+// newTestApp serves the REST and GraphQL APIs on a fresh SQLite database.
+func newTestApp(t *testing.T) *fiber.App {
+	app, _ := newTestAppWithHub(t)
+	return app
+}
+
+// newTestAppWithHub is newTestApp returning the hub subscriptions follow.
+func newTestAppWithHub(t *testing.T) (*fiber.App, *feed.Hub) {
+	database, err := gorm.Open("sqlite3", filepath.Join(t.TempDir(), "graph.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	database.DB().SetMaxOpenConns(1)
+	t.Cleanup(func() { database.Close() })
+	database.AutoMigrate(&outbox.Message{}, &comment.Comment{}, &comment.Revision{}, &comment.Mention{})
+
+	app := fiber.New()
+	api := app.Group("/api")
+	todo.Register(api, database)
+	hub := feed.NewHub(16)
+	Register(api, database, hub)
+	return app, hub
+}
+
+// query posts a GraphQL document and decodes the response into v.
+func query(t *testing.T, app *fiber.App, document string, v interface{}) {
+	body, _ := json.Marshal(map[string]string{"query": document})
+	request := httptest.NewRequest("POST", "/api/graphql", strings.NewReader(string(body)))
+	request.Header.Set("Content-Type", "application/json")
+	response, err := app.Test(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.NewDecoder(response.Body).Decode(v); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestCursor checks that a listing resumes after the cursor of its last
+// page.
+func TestCursor(t *testing.T) {
+	app := newTestApp(t)
+	for _, name := range []string{"first", "second", "third"} {
+		var created struct{ Errors []interface{} }
+		query(t, app, `mutation { createTodo(input: {name: "`+name+`"}) { id } }`, &created)
+		if len(created.Errors) > 0 {
+			t.Fatalf("createTodo: %v", created.Errors)
+		}
+	}
+
+	type page struct {
+		Data struct {
+			Todos struct {
+				Edges []struct {
+					Cursor string
+					Node   struct{ Name string }
+				}
+				PageInfo struct{ EndCursor string }
+			}
+		}
+	}
+	var first page
+	query(t, app, `{ todos(first: 2) { edges { cursor node { name } } pageInfo { endCursor } } }`, &first)
+	edges := first.Data.Todos.Edges
+	if len(edges) != 2 || first.Data.Todos.PageInfo.EndCursor != edges[1].Cursor {
+		t.Fatalf("first page = %+v", first.Data.Todos)
+	}
+	var second page
+	query(t, app, `{ todos(first: 2, after: "`+edges[1].Cursor+`") { edges { cursor node { name } } } }`, &second)
+	if rest := second.Data.Todos.Edges; len(rest) != 1 || rest[0].Node.Name != "third" {
+		t.Errorf("page after the end cursor = %+v", rest)
+	}
+	if rank, id, err := todo.DecodeCursor(edges[0].Cursor); err != nil || rank != 1024 || id != 1 {
+		t.Errorf("cursor %q is not the shared todo cursor of the first todo", edges[0].Cursor)
+	}
+
+	var invalid struct {
+		Errors []struct {
+			Message    string
+			Extensions map[string]interface{}
+		}
+	}
+	query(t, app, `{ todos(after: "not a cursor") { edges { cursor } } }`, &invalid)
+	if len(invalid.Errors) != 1 || invalid.Errors[0].Message != "invalid cursor" || invalid.Errors[0].Extensions["code"] != codeBadUserInput {
+		t.Errorf("invalid cursor = %+v", invalid.Errors)
+	}
+}
+
+// dial serves the app on a local port and opens a graphql-transport-ws
+// connection to it from the given origin.
+func dial(t *testing.T, app *fiber.App, origin string) (*websocket.Conn, *http.Response, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go app.Listener(listener)
+	t.Cleanup(func() { app.Shutdown() })
+
+	dialer := websocket.Dialer{Subprotocols: []string{protocol}, HandshakeTimeout: 5 * time.Second}
+	header := http.Header{}
+	if origin != "" {
+		header.Set("Origin", origin)
+	}
+	conn, response, err := dialer.Dial("ws://"+listener.Addr().String()+"/api/graphql", header)
+	if conn != nil {
+		t.Cleanup(func() { conn.Close() })
+	}
+	return conn, response, err
+}
+
+func TestWebSocketChecksOrigin(t *testing.T) {
+	app := newTestApp(t)
+	_, response, err := dial(t, app, "http://evil.example")
+	if err == nil || response == nil || response.StatusCode != 403 {
+		t.Fatalf("upgrade from another origin answered %v, %v", response, err)
+	}
+}
+
+func TestWebSocketRunsOnlySubscriptions(t *testing.T) {
+	app, hub := newTestAppWithHub(t)
+	conn, _, err := dial(t, app, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	exchange := func(outgoing message) message {
+		if err := conn.WriteJSON(outgoing); err != nil {
+			t.Fatal(err)
+		}
+		var incoming message
+		if err := conn.ReadJSON(&incoming); err != nil {
+			t.Fatal(err)
+		}
+		return incoming
+	}
+	payload := func(document string) json.RawMessage {
+		data, _ := json.Marshal(map[string]string{"query": document})
+		return data
+	}
+
+	if ack := exchange(message{Type: "connection_init"}); ack.Type != "connection_ack" {
+		t.Fatalf("init answered %+v", ack)
+	}
+	for _, document := range []string{
+		`{ todos { edges { cursor } } }`,
+		`mutation { createTodo(input: {name: "over a socket"}) { id } }`,
+	} {
+		answer := exchange(message{ID: "op", Type: "subscribe", Payload: payload(document)})
+		if answer.Type != "error" || answer.ID != "op" {
+			t.Errorf("%s answered %+v, want an error", document, answer)
+		}
+	}
+	var listed struct {
+		Data struct{ Todos struct{ Edges []interface{} } }
+	}
+	query(t, app, `{ todos { edges { cursor } } }`, &listed)
+	if len(listed.Data.Todos.Edges) != 0 {
+		t.Fatal("mutation sent over the socket was run")
+	}
+
+	if err := conn.WriteJSON(message{ID: "sub", Type: "subscribe", Payload: payload(`subscription { todoChanged { type todo { name } } }`)}); err != nil {
+		t.Fatal(err)
+	}
+	// Publish until the subscription, which starts asynchronously, sees an
+	// event.
+	go func() {
+		for i := 1; i <= 50; i++ {
+			item := todo.Todo{Name: "published"}
+			item.ID = uint(i)
+			hub.Publish(todo.Event{ID: strconv.Itoa(i), Type: todo.EventCreated, Todo: item})
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+	var next message
+	if err := conn.ReadJSON(&next); err != nil {
+		t.Fatal(err)
+	}
+	if next.Type != "next" || next.ID != "sub" || !strings.Contains(string(next.Payload), `"published"`) {
+		t.Errorf("subscription answered %+v", next)
+	}
+}
@@ -0,0 +1,85 @@
+// graph/loaders.go
+package graph
+
+import (
+	"context"
+	"sync"
+
+	"github.com/imadbg01/go-todo/comment"
+	"github.com/imadbg01/go-todo/todo"
+)
+
+type contextKey int
+
+const (
+	loaderKey contextKey = iota
+	actorKey
+)
+
+// commentLoader batches comment lookups. Resolvers that return todos prime
+// it with their IDs, and the first todo whose comments are resolved loads
+// the comments of every primed todo in one query instead of one each.
+type commentLoader struct {
+	repository *comment.CommentRepository
+	mu         sync.Mutex
+	pending    map[uint]bool
+	loaded     map[uint][]comment.Comment
+}
+
+func newCommentLoader(repository *comment.CommentRepository) *commentLoader {
+	return &commentLoader{
+		repository: repository,
+		pending:    map[uint]bool{},
+		loaded:     map[uint][]comment.Comment{},
+	}
+}
+
+func (loader *commentLoader) prime(ids ...uint) {
+	loader.mu.Lock()
+	defer loader.mu.Unlock()
+	for _, id := range ids {
+		if _, ok := loader.loaded[id]; !ok {
+			loader.pending[id] = true
+		}
+	}
+}
+
+func (loader *commentLoader) load(id uint) []comment.Comment {
+	loader.mu.Lock()
+	defer loader.mu.Unlock()
+	if comments, ok := loader.loaded[id]; ok {
+		return comments
+	}
+
+	loader.pending[id] = true
+	ids := make([]uint, 0, len(loader.pending))
+	for pending := range loader.pending {
+		ids = append(ids, pending)
+	}
+	for todoID, comments := range loader.repository.FindByTodos(ids) {
+		loader.loaded[todoID] = comments
+	}
+	loader.pending = map[uint]bool{}
+	return loader.loaded[id]
+}
+
+// forget drops what was loaded for a todo, so a subscription sees the
+// comments as they are at each event.
+func (loader *commentLoader) forget(id uint) {
+	loader.mu.Lock()
+	defer loader.mu.Unlock()
+	delete(loader.loaded, id)
+}
+
+func withLoader(ctx context.Context, repository *comment.CommentRepository) context.Context {
+	return context.WithValue(ctx, loaderKey, newCommentLoader(repository))
+}
+
+func loaderOf(ctx context.Context) *commentLoader {
+	return ctx.Value(loaderKey).(*commentLoader)
+}
+
+func actorOf(ctx context.Context) todo.Actor {
+	actor, _ := ctx.Value(actorKey).(todo.Actor)
+	return actor
+}
@@ -0,0 +1,307 @@
+// graph/resolvers.go
+package graph
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/imadbg01/go-todo/feed"
+	"github.com/imadbg01/go-todo/graphql"
+	"github.com/imadbg01/go-todo/todo"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+const (
+	codeNotFound           = "NOT_FOUND"
+	codeBadUserInput       = "BAD_USER_INPUT"
+	codeConflict           = "CONFLICT"
+	codePreconditionFailed = "PRECONDITION_FAILED"
+	codeQuotaExceeded      = "QUOTA_EXCEEDED"
+)
+
+// userError is a resolver error with a code in its extensions, the GraphQL
+// counterpart of the status codes the REST handlers answer with.
+type userError struct {
+	code    string
+	message string
+}
+
+func (err userError) Error() string {
+	return err.message
+}
+
+func (err userError) Extensions() map[string]interface{} {
+	return map[string]interface{}{"code": err.code}
+}
+
+type resolver struct {
+	repository *todo.TodoRepository
+	hub        *feed.Hub
+}
+
+// connection is one page of todos.
+type connection struct {
+	todos   []todo.Todo
+	hasNext bool
+	filter  todo.Filter
+}
+
+func (resolver *resolver) todo(p graphql.ResolveParams) (interface{}, error) {
+	id, err := parseID(p.Args["id"])
+	if err != nil {
+		return nil, err
+	}
+	item, err := resolver.repository.Find(id)
+	if err != nil {
+		return nil, nil
+	}
+	loaderOf(p.Context).prime(item.ID)
+	return item, nil
+}
+
+func (resolver *resolver) todos(p graphql.ResolveParams) (interface{}, error) {
+	first, _ := p.Args["first"].(int)
+	if first < 1 || first > maxPageSize {
+		return nil, userError{codeBadUserInput, fmt.Sprintf("first must be between 1 and %d", maxPageSize)}
+	}
+
+	var filter todo.Filter
+	if input, ok := p.Args["filter"].(map[string]interface{}); ok {
+		filter.Status = stringList(input["status"])
+		filter.Priority = stringList(input["priority"])
+		if due, ok := input["dueBefore"].(time.Time); ok {
+			filter.DueBefore = &due
+		}
+	}
+
+	var afterRank float64
+	var afterID uint
+	if after, ok := p.Args["after"].(string); ok {
+		var err error
+		if afterRank, afterID, err = todo.DecodeCursor(after); err != nil {
+			return nil, userError{codeBadUserInput, "invalid cursor"}
+		}
+	}
+
+	todos, err := resolver.repository.FindPage(filter, afterRank, afterID, first+1)
+	if err != nil {
+		return nil, err
+	}
+	page := &connection{todos: todos, filter: filter}
+	if len(todos) > first {
+		page.todos, page.hasNext = todos[:first], true
+	}
+
+	ids := make([]uint, len(page.todos))
+	for i, item := range page.todos {
+		ids[i] = item.ID
+	}
+	loaderOf(p.Context).prime(ids...)
+	return page, nil
+}
+
+func (resolver *resolver) totalCount(p graphql.ResolveParams) (interface{}, error) {
+	return resolver.repository.Count(p.Source.(*connection).filter)
+}
+
+func (resolver *resolver) comments(p graphql.ResolveParams) (interface{}, error) {
+	return loaderOf(p.Context).load(p.Source.(todo.Todo).ID), nil
+}
+
+func (resolver *resolver) createTodo(p graphql.ResolveParams) (interface{}, error) {
+	data := todo.Todo{Status: todo.PENDING}
+	apply(&data, p.Args["input"].(map[string]interface{}))
+	if err := validate(data); err != nil {
+		return nil, err
+	}
+
+	item, err := resolver.repository.As(actorOf(p.Context)).Create(data)
+	if err != nil {
+		return nil, quotaError(err)
+	}
+	return item, nil
+}
+
+// updateTodo changes the fields given in the input. Save schedules the next
+// occurrence when that completes a recurring todo.
+func (resolver *resolver) updateTodo(p graphql.ResolveParams) (interface{}, error) {
+	id, err := parseID(p.Args["id"])
+	if err != nil {
+		return nil, err
+	}
+	current, err := resolver.repository.Find(id)
+	if err != nil {
+		return nil, userError{codeNotFound, "Todo not found"}
+	}
+	if ifMatch, ok := p.Args["ifMatch"].(string); ok && !current.MatchesETag(ifMatch) {
+		return nil, userError{codePreconditionFailed, "Todo has changed since it was read"}
+	}
+
+	data := current
+	apply(&data, p.Args["input"].(map[string]interface{}))
+	if err := validate(data); err != nil {
+		return nil, err
+	}
+
+	repository := resolver.repository.As(actorOf(p.Context))
+	item, err := repository.Save(data)
+	if err == todo.ErrConflict {
+		return nil, userError{codeConflict, err.Error()}
+	}
+	if err != nil {
+		return nil, quotaError(err)
+	}
+	return item, nil
+}
+
+func (resolver *resolver) deleteTodo(p graphql.ResolveParams) (interface{}, error) {
+	id, err := parseID(p.Args["id"])
+	if err != nil {
+		return nil, err
+	}
+	if resolver.repository.As(actorOf(p.Context)).Delete(id) == 0 {
+		return nil, userError{codeNotFound, "Todo not found"}
+	}
+	return true, nil
+}
+
+func (resolver *resolver) restoreTodo(p graphql.ResolveParams) (interface{}, error) {
+	id, err := parseID(p.Args["id"])
+	if err != nil {
+		return nil, err
+	}
+	item, err := resolver.repository.As(actorOf(p.Context)).Restore(id)
+	var quota *todo.QuotaError
+	if errors.As(err, &quota) {
+		return nil, quotaError(err)
+	}
+	if err != nil {
+		return nil, userError{codeNotFound, err.Error()}
+	}
+	return item, nil
+}
+
+func (resolver *resolver) moveTodo(p graphql.ResolveParams) (interface{}, error) {
+	id, err := parseID(p.Args["id"])
+	if err != nil {
+		return nil, err
+	}
+	if _, err := resolver.repository.Find(id); err != nil {
+		return nil, userError{codeNotFound, "Todo not found"}
+	}
+
+	var anchors [2]*int
+	for i, name := range []string{"before", "after"} {
+		if value, ok := p.Args[name]; ok && value != nil {
+			anchor, err := parseID(value)
+			if err != nil {
+				return nil, err
+			}
+			anchors[i] = &anchor
+		}
+	}
+
+	item, err := resolver.repository.As(actorOf(p.Context)).Move(id, anchors[0], anchors[1])
+	if err != nil {
+		return nil, userError{codeBadUserInput, err.Error()}
+	}
+	return item, nil
+}
+
+// todoChanged follows the change feed, the same events the SSE and
+// WebSocket feeds stream, until the subscription ends.
+func (resolver *resolver) todoChanged(p graphql.ResolveParams, emit func(interface{}) error) error {
+	filter := feed.Filter{
+		Types:  stringList(p.Args["types"]),
+		Status: stringList(p.Args["status"]),
+	}
+	if ids, ok := p.Args["ids"].([]interface{}); ok {
+		for _, value := range ids {
+			id, err := parseID(value)
+			if err != nil {
+				return err
+			}
+			filter.IDs = append(filter.IDs, uint(id))
+		}
+	}
+	after, _ := p.Args["after"].(string)
+
+	loader := loaderOf(p.Context)
+	resolver.hub.Follow(after, filter, func(message feed.Message) error {
+		loader.forget(message.Todo.ID)
+		return emit(message)
+	}, nil, p.Context.Done())
+	return nil
+}
+
+// apply copies the fields present in a todo input onto data.
+func apply(data *todo.Todo, input map[string]interface{}) {
+	text := func(key string, field *string) {
+		if value, ok := input[key]; ok {
+			*field, _ = value.(string)
+		}
+	}
+	text("name", &data.Name)
+	text("description", &data.Description)
+	text("status", &data.Status)
+	text("priority", &data.Priority)
+	text("timeZone", &data.TimeZone)
+	text("recurrence", &data.Recurrence)
+	text("repeatFrom", &data.RepeatFrom)
+	if value, ok := input["due"]; ok {
+		data.Due = nil
+		if due, ok := value.(time.Time); ok {
+			data.Due = &due
+		}
+	}
+}
+
+// validate applies the checks of the REST handlers, plus a name, which the
+// schema cannot require on updates.
+func validate(data todo.Todo) error {
+	if strings.TrimSpace(data.Name) == "" {
+		return userError{codeBadUserInput, "name must not be empty"}
+	}
+	if err := todo.Validate(data); err != nil {
+		return userError{codeBadUserInput, "Invalid todo: " + err.Error()}
+	}
+	return nil
+}
+
+// quotaError reports the workspace reaching its quota as a user error and
+// returns other errors as they are.
+func quotaError(err error) error {
+	var quota *todo.QuotaError
+	if errors.As(err, &quota) {
+		return userError{codeQuotaExceeded, "Todo quota reached: " + quota.Error()}
+	}
+	return err
+}
+
+func parseID(value interface{}) (int, error) {
+	s, _ := value.(string)
+	id, err := strconv.Atoi(s)
+	if err != nil || id < 1 {
+		return 0, userError{codeBadUserInput, fmt.Sprintf("invalid id %q", s)}
+	}
+	return id, nil
+}
+
+// stringList converts a coerced list of enum values to strings.
+func stringList(value interface{}) []string {
+	items, _ := value.([]interface{})
+	list := make([]string, 0, len(items))
+	for _, item := range items {
+		if s, ok := item.(string); ok {
+			list = append(list, s)
+		}
+	}
+	return list
+}
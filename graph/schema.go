@@ -0,0 +1,347 @@
+// graph/schema.go
+package graph
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/imadbg01/go-todo/comment"
+	"github.com/imadbg01/go-todo/feed"
+	"github.com/imadbg01/go-todo/graphql"
+	"github.com/imadbg01/go-todo/todo"
+)
+
+// The schema covers todos and their comments. Lists and tags are not part
+// of it, as the API has neither.
+
+var timeScalar = &graphql.Scalar{
+	Name:        "Time",
+	Description: "An RFC 3339 timestamp.",
+	Serialize: func(v interface{}) (interface{}, error) {
+		switch v := v.(type) {
+		case time.Time:
+			return v.Format(time.RFC3339Nano), nil
+		case *time.Time:
+			return v.Format(time.RFC3339Nano), nil
+		}
+		return nil, fmt.Errorf("Time cannot represent %v", v)
+	},
+	ParseValue: func(v interface{}) (interface{}, error) {
+		s, ok := v.(string)
+		if !ok {
+			return nil, errors.New("Time must be an RFC 3339 string")
+		}
+		at, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return nil, errors.New("Time must be an RFC 3339 string")
+		}
+		return at, nil
+	},
+}
+
+var statusEnum = &graphql.Enum{
+	Name: "TodoStatus",
+	Values: []graphql.EnumValue{
+		{Name: "PENDING", Value: todo.PENDING},
+		{Name: "IN_PROGRESS", Value: todo.PROGRESS},
+		{Name: "DONE", Value: todo.DONE},
+	},
+}
+
+var priorityEnum = &graphql.Enum{
+	Name: "Priority",
+	Values: []graphql.EnumValue{
+		{Name: "NONE", Value: todo.PriorityNone},
+		{Name: "LOW", Value: todo.PriorityLow},
+		{Name: "MEDIUM", Value: todo.PriorityMedium},
+		{Name: "HIGH", Value: todo.PriorityHigh},
+		{Name: "URGENT", Value: todo.PriorityUrgent},
+	},
+}
+
+var eventTypeEnum = &graphql.Enum{
+	Name: "TodoEventType",
+	Values: []graphql.EnumValue{
+		{Name: "CREATED", Value: todo.EventCreated},
+		{Name: "UPDATED", Value: todo.EventUpdated},
+		{Name: "STATUS_CHANGED", Value: todo.EventStatusChanged},
+		{Name: "DELETED", Value: todo.EventDeleted},
+		{Name: "RESTORED", Value: todo.EventRestored},
+		{Name: "RESET", Value: "reset", Description: "Events were missed; refetch before relying on further events."},
+	},
+}
+
+func nonNull(t graphql.Type) graphql.Type {
+	return graphql.NonNullOf(t)
+}
+
+// todoField resolves a field of a todo.Todo source with get.
+func todoField(name string, t graphql.Type, get func(todo.Todo) interface{}) *graphql.Field {
+	return &graphql.Field{
+		Name: name,
+		Type: t,
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return get(p.Source.(todo.Todo)), nil
+		},
+	}
+}
+
+func commentField(name string, t graphql.Type, get func(comment.Comment) interface{}) *graphql.Field {
+	return &graphql.Field{
+		Name: name,
+		Type: t,
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return get(p.Source.(comment.Comment)), nil
+		},
+	}
+}
+
+func newSchema(resolver *resolver) (*graphql.Schema, error) {
+	commentType := &graphql.Object{Name: "Comment"}
+	commentType.Fields = []*graphql.Field{
+		commentField("id", nonNull(graphql.ID), func(c comment.Comment) interface{} { return c.ID }),
+		commentField("parentId", graphql.ID, func(c comment.Comment) interface{} { return c.ParentID }),
+		commentField("author", nonNull(graphql.String), func(c comment.Comment) interface{} { return c.Author }),
+		commentField("body", nonNull(graphql.String), func(c comment.Comment) interface{} { return c.Body }),
+		commentField("edited", nonNull(graphql.Boolean), func(c comment.Comment) interface{} { return c.Edited }),
+		commentField("deleted", nonNull(graphql.Boolean), func(c comment.Comment) interface{} { return c.Deleted }),
+		commentField("mentions", nonNull(graphql.ListOf(nonNull(graphql.String))), func(c comment.Comment) interface{} { return nonNilStrings(c.Mentions) }),
+		commentField("createdAt", nonNull(timeScalar), func(c comment.Comment) interface{} { return c.CreatedAt }),
+		commentField("replies", nonNull(graphql.ListOf(nonNull(commentType))), func(c comment.Comment) interface{} { return c.Replies }),
+	}
+
+	todoType := &graphql.Object{
+		Name: "Todo",
+		Fields: []*graphql.Field{
+			todoField("id", nonNull(graphql.ID), func(t todo.Todo) interface{} { return t.ID }),
+			todoField("uid", nonNull(graphql.String), func(t todo.Todo) interface{} { return t.CalendarUID() }),
+			todoField("name", nonNull(graphql.String), func(t todo.Todo) interface{} { return t.Name }),
+			todoField("description", nonNull(graphql.String), func(t todo.Todo) interface{} { return t.Description }),
+			todoField("status", nonNull(statusEnum), func(t todo.Todo) interface{} { return statusOf(t) }),
+			todoField("priority", nonNull(priorityEnum), func(t todo.Todo) interface{} { return priorityOf(t) }),
+			todoField("rank", nonNull(graphql.Float), func(t todo.Todo) interface{} { return t.Rank }),
+			todoField("due", timeScalar, func(t todo.Todo) interface{} { return t.Due }),
+			todoField("timeZone", nonNull(graphql.String), func(t todo.Todo) interface{} { return t.TimeZone }),
+			todoField("recurrence", nonNull(graphql.String), func(t todo.Todo) interface{} { return t.Recurrence }),
+			todoField("repeatFrom", nonNull(graphql.String), func(t todo.Todo) interface{} { return t.RepeatFrom }),
+			todoField("completedAt", timeScalar, func(t todo.Todo) interface{} { return t.CompletedAt }),
+			todoField("createdAt", nonNull(timeScalar), func(t todo.Todo) interface{} { return t.CreatedAt }),
+			todoField("updatedAt", nonNull(timeScalar), func(t todo.Todo) interface{} { return t.UpdatedAt }),
+			todoField("deletedAt", timeScalar, func(t todo.Todo) interface{} { return t.DeletedAt }),
+			todoField("etag", nonNull(graphql.String), func(t todo.Todo) interface{} { return t.ETag() }),
+			{
+				Name:        "comments",
+				Description: "Comment threads, oldest first. Comments of the todos on one page are loaded together.",
+				Type:        nonNull(graphql.ListOf(nonNull(commentType))),
+				Cost:        5,
+				Resolve:     resolver.comments,
+			},
+		},
+	}
+
+	pageInfoType := &graphql.Object{
+		Name: "PageInfo",
+		Fields: []*graphql.Field{
+			{Name: "hasNextPage", Type: nonNull(graphql.Boolean), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(*connection).hasNext, nil
+			}},
+			{Name: "endCursor", Type: graphql.String, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				todos := p.Source.(*connection).todos
+				if len(todos) == 0 {
+					return nil, nil
+				}
+				return todo.EncodeCursor(todos[len(todos)-1]), nil
+			}},
+		},
+	}
+
+	edgeType := &graphql.Object{
+		Name: "TodoEdge",
+		Fields: []*graphql.Field{
+			{Name: "cursor", Type: nonNull(graphql.String), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return todo.EncodeCursor(p.Source.(todo.Todo)), nil
+			}},
+			{Name: "node", Type: nonNull(todoType), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source, nil
+			}},
+		},
+	}
+
+	connectionType := &graphql.Object{
+		Name: "TodoConnection",
+		Fields: []*graphql.Field{
+			{Name: "edges", Type: nonNull(graphql.ListOf(nonNull(edgeType))), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(*connection).todos, nil
+			}},
+			{Name: "nodes", Type: nonNull(graphql.ListOf(nonNull(todoType))), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(*connection).todos, nil
+			}},
+			{Name: "pageInfo", Type: nonNull(pageInfoType), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source, nil
+			}},
+			{Name: "totalCount", Type: nonNull(graphql.Int), Cost: 5, Resolve: resolver.totalCount},
+		},
+	}
+
+	filterType := &graphql.InputObject{
+		Name: "TodoFilter",
+		Fields: []*graphql.Argument{
+			{Name: "status", Type: graphql.ListOf(nonNull(statusEnum))},
+			{Name: "priority", Type: graphql.ListOf(nonNull(priorityEnum))},
+			{Name: "dueBefore", Type: timeScalar},
+		},
+	}
+
+	todoInputFields := func(required bool) []*graphql.Argument {
+		var name graphql.Type = graphql.String
+		if required {
+			name = nonNull(graphql.String)
+		}
+		return []*graphql.Argument{
+			{Name: "name", Type: name},
+			{Name: "description", Type: graphql.String},
+			{Name: "status", Type: statusEnum},
+			{Name: "priority", Type: priorityEnum},
+			{Name: "due", Type: timeScalar},
+			{Name: "timeZone", Type: graphql.String, Description: "IANA time zone the due date and recurrence are in."},
+			{Name: "recurrence", Type: graphql.String, Description: "An RFC 5545 RRULE, such as FREQ=WEEKLY;BYDAY=MO."},
+			{Name: "repeatFrom", Type: graphql.String, Description: "due or completion."},
+		}
+	}
+	createInput := &graphql.InputObject{Name: "CreateTodoInput", Fields: todoInputFields(true)}
+	updateInput := &graphql.InputObject{
+		Name:        "UpdateTodoInput",
+		Description: "Fields left out keep their value; due set to null clears it.",
+		Fields:      todoInputFields(false),
+	}
+
+	query := &graphql.Object{
+		Name: "Query",
+		Fields: []*graphql.Field{
+			{
+				Name:    "todo",
+				Type:    todoType,
+				Args:    []*graphql.Argument{{Name: "id", Type: nonNull(graphql.ID)}},
+				Resolve: resolver.todo,
+			},
+			{
+				Name:        "todos",
+				Description: "Todos in list order, paged with first and the endCursor of the previous page.",
+				Type:        nonNull(connectionType),
+				Args: []*graphql.Argument{
+					{Name: "filter", Type: filterType},
+					{Name: "first", Type: graphql.Int, Default: defaultPageSize},
+					{Name: "after", Type: graphql.String},
+				},
+				Resolve: resolver.todos,
+			},
+		},
+	}
+
+	mutation := &graphql.Object{
+		Name: "Mutation",
+		Fields: []*graphql.Field{
+			{
+				Name:    "createTodo",
+				Type:    nonNull(todoType),
+				Args:    []*graphql.Argument{{Name: "input", Type: nonNull(createInput)}},
+				Resolve: resolver.createTodo,
+			},
+			{
+				Name:        "updateTodo",
+				Description: "ifMatch takes the etag the todo was read with and fails if it changed since.",
+				Type:        nonNull(todoType),
+				Args: []*graphql.Argument{
+					{Name: "id", Type: nonNull(graphql.ID)},
+					{Name: "input", Type: nonNull(updateInput)},
+					{Name: "ifMatch", Type: graphql.String},
+				},
+				Resolve: resolver.updateTodo,
+			},
+			{
+				Name:    "deleteTodo",
+				Type:    nonNull(graphql.Boolean),
+				Args:    []*graphql.Argument{{Name: "id", Type: nonNull(graphql.ID)}},
+				Resolve: resolver.deleteTodo,
+			},
+			{
+				Name:    "restoreTodo",
+				Type:    nonNull(todoType),
+				Args:    []*graphql.Argument{{Name: "id", Type: nonNull(graphql.ID)}},
+				Resolve: resolver.restoreTodo,
+			},
+			{
+				Name:        "moveTodo",
+				Description: "Places the todo after one todo and before another; either may be left out.",
+				Type:        nonNull(todoType),
+				Args: []*graphql.Argument{
+					{Name: "id", Type: nonNull(graphql.ID)},
+					{Name: "before", Type: graphql.ID},
+					{Name: "after", Type: graphql.ID},
+				},
+				Resolve: resolver.moveTodo,
+			},
+		},
+	}
+
+	eventType := &graphql.Object{
+		Name: "TodoEvent",
+		Fields: []*graphql.Field{
+			{Name: "id", Type: nonNull(graphql.String), Description: "Pass as after to resume from this event.", Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(feed.Message).ID, nil
+			}},
+			{Name: "type", Type: nonNull(eventTypeEnum), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(feed.Message).Type, nil
+			}},
+			{Name: "todo", Type: todoType, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				message := p.Source.(feed.Message)
+				if message.Todo.ID == 0 {
+					return nil, nil
+				}
+				return message.Todo, nil
+			}},
+		},
+	}
+
+	subscription := &graphql.Object{
+		Name: "Subscription",
+		Fields: []*graphql.Field{
+			{
+				Name:        "todoChanged",
+				Description: "Todo changes as they happen. Empty filters match every change.",
+				Type:        nonNull(eventType),
+				Args: []*graphql.Argument{
+					{Name: "types", Type: graphql.ListOf(nonNull(eventTypeEnum))},
+					{Name: "status", Type: graphql.ListOf(nonNull(statusEnum))},
+					{Name: "ids", Type: graphql.ListOf(nonNull(graphql.ID))},
+					{Name: "after", Type: graphql.String},
+				},
+				Subscribe: resolver.todoChanged,
+			},
+		},
+	}
+
+	return graphql.NewSchema(query, mutation, subscription)
+}
+
+func statusOf(t todo.Todo) string {
+	if t.Status == "" {
+		return todo.PENDING
+	}
+	return t.Status
+}
+
+func priorityOf(t todo.Todo) string {
+	if t.Priority == "" {
+		return todo.PriorityNone
+	}
+	return t.Priority
+}
+
+func nonNilStrings(list []string) []string {
+	if list == nil {
+		return []string{}
+	}
+	return list
+}
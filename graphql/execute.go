@@ -0,0 +1,689 @@
+// graphql/execute.go
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+)
+
+type executor struct {
+	schema    *Schema
+	ctx       context.Context
+	fragments map[string]*fragment
+	variables map[string]interface{}
+	errors    []*Error
+}
+
+func (schema *Schema) root(kind string) *Object {
+	switch kind {
+	case "query":
+		return schema.Query
+	case "mutation":
+		return schema.Mutation
+	case "subscription":
+		return schema.Subscription
+	}
+	return nil
+}
+
+// OperationType returns query, mutation or subscription for the operation
+// a request would run, or an empty string when the request does not parse
+// or does not name a single operation. Transports use it to refuse, say,
+// mutations over GET.
+func OperationType(request Request) string {
+	doc, err := parse(request.Query)
+	if err != nil {
+		return ""
+	}
+	if op := selectOperation(doc, request.OperationName); op != nil {
+		return op.kind
+	}
+	return ""
+}
+
+func selectOperation(doc *document, name string) *operation {
+	if name == "" {
+		if len(doc.operations) == 1 {
+			return doc.operations[0]
+		}
+		return nil
+	}
+	for _, op := range doc.operations {
+		if op.name == name {
+			return op
+		}
+	}
+	return nil
+}
+
+// prepare parses and validates a request, picks its operation and coerces
+// its variables. A non-nil response reports why the request cannot run.
+func (schema *Schema) prepare(ctx context.Context, request Request) (*executor, *operation, *Response) {
+	doc, err := parse(request.Query)
+	if err != nil {
+		return nil, nil, failed(err.(*Error))
+	}
+	if errs := validate(schema, doc); len(errs) > 0 {
+		return nil, nil, failed(errs...)
+	}
+
+	op := selectOperation(doc, request.OperationName)
+	if op == nil {
+		message := "Must provide operation name if query contains multiple operations."
+		if request.OperationName != "" {
+			message = fmt.Sprintf("Unknown operation named %q.", request.OperationName)
+		}
+		return nil, nil, failed(&Error{Message: message, Extensions: map[string]interface{}{"code": CodeBadUserInput}})
+	}
+
+	e := &executor{schema: schema, ctx: ctx, fragments: map[string]*fragment{}, variables: map[string]interface{}{}}
+	for _, f := range doc.fragments {
+		e.fragments[f.name] = f
+	}
+	for _, definition := range op.variables {
+		t := schema.resolveType(definition.typ)
+		given, ok := request.Variables[definition.name]
+		var value interface{}
+		switch {
+		case ok:
+			value, err = coerceInput(given, t)
+		case definition.defaultValue != nil:
+			value, err = e.coerceLiteral(definition.defaultValue, t)
+		default:
+			if _, required := t.(*NonNull); required {
+				err = fmt.Errorf("of required type %q was not provided", t)
+			} else {
+				continue
+			}
+		}
+		if err != nil {
+			return nil, nil, failed(newError(CodeBadUserInput, definition.loc, "Variable \"$%s\" %v.", definition.name, variableError(err)))
+		}
+		e.variables[definition.name] = value
+	}
+
+	root := schema.root(op.kind)
+	if schema.MaxDepth > 0 {
+		if d := depth(op.selectionSet, e.fragments); d > schema.MaxDepth {
+			return nil, nil, failed(newError(CodeQueryTooDeep, op.loc, "Query depth %d exceeds the maximum of %d.", d, schema.MaxDepth))
+		}
+	}
+	if schema.MaxComplexity > 0 {
+		if c := e.complexity(root, op.selectionSet); c > schema.MaxComplexity {
+			return nil, nil, failed(newError(CodeQueryTooComplex, op.loc, "Query complexity %d exceeds the maximum of %d.", c, schema.MaxComplexity))
+		}
+	}
+	return e, op, nil
+}
+
+func variableError(err error) string {
+	if strings.HasPrefix(err.Error(), "of required type") {
+		return err.Error()
+	}
+	return "got invalid value; " + err.Error()
+}
+
+// Execute runs a query or mutation. Subscriptions have to go through
+// Subscribe.
+func (schema *Schema) Execute(ctx context.Context, request Request) *Response {
+	e, op, response := schema.prepare(ctx, request)
+	if response != nil {
+		return response
+	}
+	if op.kind == "subscription" {
+		return failed(newError(CodeBadUserInput, op.loc, "Subscriptions must be run with Subscribe."))
+	}
+
+	data, ok := e.executeFields(schema.root(op.kind), nil, op.selectionSet, nil)
+	response = &Response{Errors: e.errors}
+	if ok {
+		response.Data = data
+	}
+	return response
+}
+
+// Subscribe runs a subscription, calling send with the result of executing
+// the selection for each event until the context is done or send fails. A
+// request that cannot run is returned as a response without calling send.
+func (schema *Schema) Subscribe(ctx context.Context, request Request, send func(*Response) error) *Response {
+	e, op, response := schema.prepare(ctx, request)
+	if response != nil {
+		return response
+	}
+	if op.kind != "subscription" {
+		return failed(newError(CodeBadUserInput, op.loc, "Subscribe requires a subscription operation."))
+	}
+
+	root := schema.Subscription
+	group := collectFields(root, op.selectionSet, e.fragments, e.variables)[0]
+	definition := root.field(group.fields[0].name)
+	if definition == nil || definition.Subscribe == nil {
+		return failed(newError(CodeBadUserInput, group.fields[0].loc, "Field %q cannot be subscribed to.", group.fields[0].name))
+	}
+	args, err := e.arguments(definition.Args, group.fields[0].arguments)
+	if err != nil {
+		return failed(e.fieldError(err, group.fields[0], []interface{}{group.key}))
+	}
+
+	params := ResolveParams{Context: ctx, Args: args, Path: []interface{}{group.key}}
+	err = definition.Subscribe(params, func(source interface{}) error {
+		event := &executor{schema: schema, ctx: ctx, fragments: e.fragments, variables: e.variables}
+		value, ok := event.complete(definition.Type, group.fields, source, []interface{}{group.key})
+		response := &Response{Errors: event.errors}
+		if ok {
+			data := &orderedMap{}
+			data.set(group.key, value)
+			response.Data = data
+		}
+		return send(response)
+	})
+	if err != nil && ctx.Err() == nil {
+		return failed(e.fieldError(err, group.fields[0], []interface{}{group.key}))
+	}
+	return nil
+}
+
+type fieldGroup struct {
+	key    string
+	fields []*field
+}
+
+// collectFields flattens fragments and groups the fields of a selection set
+// by response key, in document order. Without variables the @skip and
+// @include directives are ignored.
+func collectFields(object *Object, selections []selection, fragments map[string]*fragment, variables map[string]interface{}) []*fieldGroup {
+	var groups []*fieldGroup
+	index := map[string]*fieldGroup{}
+	visited := map[string]bool{}
+
+	var walk func(selections []selection)
+	walk = func(selections []selection) {
+		for _, sel := range selections {
+			switch sel := sel.(type) {
+			case *field:
+				if !included(sel.directives, variables) {
+					continue
+				}
+				key := sel.responseKey()
+				if group := index[key]; group != nil {
+					group.fields = append(group.fields, sel)
+					continue
+				}
+				group := &fieldGroup{key: key, fields: []*field{sel}}
+				index[key] = group
+				groups = append(groups, group)
+			case *inlineFragment:
+				if included(sel.directives, variables) && (sel.typeCondition == "" || sel.typeCondition == object.Name) {
+					walk(sel.selectionSet)
+				}
+			case *fragmentSpread:
+				f := fragments[sel.name]
+				if f == nil || visited[sel.name] || !included(sel.directives, variables) || f.typeCondition != object.Name {
+					continue
+				}
+				visited[sel.name] = true
+				walk(f.selectionSet)
+			}
+		}
+	}
+	walk(selections)
+	return groups
+}
+
+func included(directives []*directive, variables map[string]interface{}) bool {
+	if variables == nil {
+		return true
+	}
+	for _, d := range directives {
+		condition := false
+		for _, arg := range d.arguments {
+			if arg.name != "if" {
+				continue
+			}
+			if arg.value.kind == variableValue {
+				condition, _ = variables[arg.value.raw].(bool)
+			} else {
+				condition = arg.value.raw == "true"
+			}
+		}
+		if d.name == "skip" && condition || d.name == "include" && !condition {
+			return false
+		}
+	}
+	return true
+}
+
+func mergeSelections(fields []*field) []selection {
+	if len(fields) == 1 {
+		return fields[0].selectionSet
+	}
+	var merged []selection
+	for _, f := range fields {
+		merged = append(merged, f.selectionSet...)
+	}
+	return merged
+}
+
+// executeFields resolves the selection set on source. It reports false when
+// a non-null field failed, so the object has to become null.
+func (e *executor) executeFields(object *Object, source interface{}, selections []selection, path []interface{}) (*orderedMap, bool) {
+	result := &orderedMap{}
+	for _, group := range collectFields(object, selections, e.fragments, e.variables) {
+		f := group.fields[0]
+		if f.name == "__typename" {
+			result.set(group.key, object.Name)
+			continue
+		}
+		value, ok := e.resolveField(object, source, group, appendPath(path, group.key))
+		if !ok {
+			return nil, false
+		}
+		result.set(group.key, value)
+	}
+	return result, true
+}
+
+func (e *executor) resolveField(object *Object, source interface{}, group *fieldGroup, path []interface{}) (interface{}, bool) {
+	f := group.fields[0]
+	definition := object.field(f.name)
+	_, required := definition.Type.(*NonNull)
+
+	args, err := e.arguments(definition.Args, f.arguments)
+	if err != nil {
+		e.errors = append(e.errors, e.fieldError(err, f, path))
+		return nil, !required
+	}
+
+	var resolved interface{}
+	if definition.Resolve != nil {
+		resolved, err = e.call(definition.Resolve, ResolveParams{Context: e.ctx, Source: source, Args: args, Path: path})
+	} else if m, ok := source.(map[string]interface{}); ok {
+		resolved = m[f.name]
+	} else {
+		err = fmt.Errorf("no resolver for %s.%s", object.Name, f.name)
+	}
+	if err != nil {
+		e.errors = append(e.errors, e.fieldError(err, f, path))
+		return nil, !required
+	}
+	return e.complete(definition.Type, group.fields, resolved, path)
+}
+
+// call runs a resolver, turning a panic into a field error so one broken
+// resolver cannot take down the whole request. The panic is logged rather
+// than returned, since its value may hold internals clients must not see.
+func (e *executor) call(resolve ResolveFunc, params ResolveParams) (value interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("graphql: resolver panicked: %v", r)
+			err = errors.New("internal error")
+		}
+	}()
+	return resolve(params)
+}
+
+func (e *executor) fieldError(err error, f *field, path []interface{}) *Error {
+	if graphErr, ok := err.(*Error); ok {
+		return graphErr
+	}
+	result := &Error{Message: err.Error(), Locations: []Location{f.loc}, Path: path}
+	if ext, ok := err.(extender); ok {
+		result.Extensions = ext.Extensions()
+	}
+	return result
+}
+
+// complete turns a resolved value into its response form. The bool is false
+// when null has to propagate to the parent because t is non-null.
+func (e *executor) complete(t Type, fields []*field, value interface{}, path []interface{}) (interface{}, bool) {
+	if nonNull, ok := t.(*NonNull); ok {
+		completed, ok := e.completeNullable(nonNull.Of, fields, value, path)
+		if ok && completed == nil {
+			e.errors = append(e.errors, &Error{
+				Message:   fmt.Sprintf("Cannot return null for non-nullable field %s.", fields[0].name),
+				Locations: []Location{fields[0].loc},
+				Path:      path,
+			})
+		}
+		return completed, ok && completed != nil
+	}
+	completed, ok := e.completeNullable(t, fields, value, path)
+	if !ok {
+		return nil, true
+	}
+	return completed, true
+}
+
+func (e *executor) completeNullable(t Type, fields []*field, value interface{}, path []interface{}) (interface{}, bool) {
+	if isNil(value) {
+		return nil, true
+	}
+
+	switch t := t.(type) {
+	case *List:
+		items := reflect.ValueOf(value)
+		if items.Kind() != reflect.Slice && items.Kind() != reflect.Array {
+			e.errors = append(e.errors, &Error{Message: fmt.Sprintf("Expected a list for field %s.", fields[0].name), Locations: []Location{fields[0].loc}, Path: path})
+			return nil, false
+		}
+		list := make([]interface{}, items.Len())
+		for i := range list {
+			item, ok := e.complete(t.Of, fields, items.Index(i).Interface(), appendPath(path, i))
+			if !ok {
+				return nil, false
+			}
+			list[i] = item
+		}
+		return list, true
+	case *Object:
+		object, ok := e.executeFields(t, value, mergeSelections(fields), path)
+		if !ok {
+			return nil, false
+		}
+		return object, true
+	case *Enum:
+		for _, v := range t.Values {
+			if reflect.DeepEqual(v.Value, value) {
+				return v.Name, true
+			}
+		}
+		e.errors = append(e.errors, &Error{Message: fmt.Sprintf("Enum %q cannot represent value: %v", t.Name, value), Locations: []Location{fields[0].loc}, Path: path})
+		return nil, false
+	case *Scalar:
+		serialized, err := t.Serialize(value)
+		if err != nil {
+			e.errors = append(e.errors, &Error{Message: err.Error(), Locations: []Location{fields[0].loc}, Path: path})
+			return nil, false
+		}
+		return serialized, true
+	}
+	return nil, false
+}
+
+func isNil(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	switch rv := reflect.ValueOf(value); rv.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface, reflect.Func:
+		return rv.IsNil()
+	}
+	return false
+}
+
+func appendPath(path []interface{}, key interface{}) []interface{} {
+	next := make([]interface{}, len(path), len(path)+1)
+	copy(next, path)
+	return append(next, key)
+}
+
+// arguments coerces the arguments given to a field. Absent arguments get
+// their default; those without one are left out of the map.
+func (e *executor) arguments(definitions []*Argument, given []*argument) (map[string]interface{}, error) {
+	args := map[string]interface{}{}
+	for _, definition := range definitions {
+		var arg *argument
+		for _, candidate := range given {
+			if candidate.name == definition.Name {
+				arg = candidate
+			}
+		}
+
+		if arg != nil && arg.value.kind == variableValue {
+			if value, ok := e.variables[arg.value.raw]; ok {
+				if _, required := definition.Type.(*NonNull); required && value == nil {
+					return nil, &Error{Message: fmt.Sprintf("Argument %q of non-null type %q must not be null.", definition.Name, definition.Type), Locations: []Location{arg.loc}, Extensions: map[string]interface{}{"code": CodeBadUserInput}}
+				}
+				args[definition.Name] = value
+				continue
+			}
+			arg = nil
+		}
+		if arg == nil {
+			if definition.Default != nil {
+				args[definition.Name] = definition.Default
+			} else if _, required := definition.Type.(*NonNull); required {
+				return nil, &Error{Message: fmt.Sprintf("Argument %q of required type %q was not provided.", definition.Name, definition.Type), Extensions: map[string]interface{}{"code": CodeBadUserInput}}
+			}
+			continue
+		}
+
+		value, err := e.coerceLiteral(arg.value, definition.Type)
+		if err != nil {
+			return nil, &Error{Message: fmt.Sprintf("Argument %q has invalid value: %v", definition.Name, err), Locations: []Location{arg.loc}, Extensions: map[string]interface{}{"code": CodeBadUserInput}}
+		}
+		args[definition.Name] = value
+	}
+	return args, nil
+}
+
+// coerceLiteral reads a value written in the document as type t.
+func (e *executor) coerceLiteral(v *value, t Type) (interface{}, error) {
+	if v.kind == variableValue {
+		value := e.variables[v.raw]
+		if _, required := t.(*NonNull); required && value == nil {
+			return nil, fmt.Errorf("variable \"$%s\" must not be null", v.raw)
+		}
+		return value, nil
+	}
+	if nonNull, ok := t.(*NonNull); ok {
+		if v.kind == nullValue {
+			return nil, fmt.Errorf("expected non-null %s", t)
+		}
+		return e.coerceLiteral(v, nonNull.Of)
+	}
+	if v.kind == nullValue {
+		return nil, nil
+	}
+
+	switch t := t.(type) {
+	case *List:
+		if v.kind != listValue {
+			item, err := e.coerceLiteral(v, t.Of)
+			if err != nil {
+				return nil, err
+			}
+			return []interface{}{item}, nil
+		}
+		list := make([]interface{}, len(v.list))
+		for i, item := range v.list {
+			coerced, err := e.coerceLiteral(item, t.Of)
+			if err != nil {
+				return nil, fmt.Errorf("at index %d: %v", i, err)
+			}
+			list[i] = coerced
+		}
+		return list, nil
+	case *InputObject:
+		if v.kind != objectValue {
+			return nil, fmt.Errorf("expected an object of type %s", t.Name)
+		}
+		given := map[string]*value{}
+		for _, f := range v.fields {
+			if t.field(f.name) == nil {
+				return nil, fmt.Errorf("field %q is not defined by type %s", f.name, t.Name)
+			}
+			given[f.name] = f.value
+		}
+		object := map[string]interface{}{}
+		for _, definition := range t.Fields {
+			fieldValue, ok := given[definition.Name]
+			if ok && fieldValue.kind == variableValue {
+				_, ok = e.variables[fieldValue.raw]
+			}
+			if !ok {
+				if err := defaultField(object, definition, t); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			coerced, err := e.coerceLiteral(fieldValue, definition.Type)
+			if err != nil {
+				return nil, fmt.Errorf("in field %q: %v", definition.Name, err)
+			}
+			object[definition.Name] = coerced
+		}
+		return object, nil
+	case *Enum:
+		if v.kind != enumValue {
+			return nil, fmt.Errorf("enum %q cannot represent non-enum value", t.Name)
+		}
+		enumValue, ok := t.value(v.raw)
+		if !ok {
+			return nil, fmt.Errorf("value %q does not exist in %q enum", v.raw, t.Name)
+		}
+		return enumValue.Value, nil
+	case *Scalar:
+		var raw interface{}
+		switch v.kind {
+		case intValue, floatValue:
+			raw = json.Number(v.raw)
+		case stringValue:
+			raw = v.raw
+		case booleanValue:
+			raw = v.raw == "true"
+		default:
+			return nil, fmt.Errorf("%s cannot represent a %s", t.Name, map[valueKind]string{enumValue: "enum value", listValue: "list", objectValue: "object"}[v.kind])
+		}
+		return t.ParseValue(raw)
+	}
+	return nil, fmt.Errorf("%s is not an input type", t)
+}
+
+// coerceInput reads a variable value, as decoded from JSON, as type t.
+func coerceInput(v interface{}, t Type) (interface{}, error) {
+	if nonNull, ok := t.(*NonNull); ok {
+		if v == nil {
+			return nil, fmt.Errorf("expected non-null %s", t)
+		}
+		return coerceInput(v, nonNull.Of)
+	}
+	if v == nil {
+		return nil, nil
+	}
+
+	switch t := t.(type) {
+	case *List:
+		items, ok := v.([]interface{})
+		if !ok {
+			item, err := coerceInput(v, t.Of)
+			if err != nil {
+				return nil, err
+			}
+			return []interface{}{item}, nil
+		}
+		list := make([]interface{}, len(items))
+		for i, item := range items {
+			coerced, err := coerceInput(item, t.Of)
+			if err != nil {
+				return nil, fmt.Errorf("at index %d: %v", i, err)
+			}
+			list[i] = coerced
+		}
+		return list, nil
+	case *InputObject:
+		given, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("expected an object of type %s", t.Name)
+		}
+		for name := range given {
+			if t.field(name) == nil {
+				return nil, fmt.Errorf("field %q is not defined by type %s", name, t.Name)
+			}
+		}
+		object := map[string]interface{}{}
+		for _, definition := range t.Fields {
+			fieldValue, ok := given[definition.Name]
+			if !ok {
+				if err := defaultField(object, definition, t); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			coerced, err := coerceInput(fieldValue, definition.Type)
+			if err != nil {
+				return nil, fmt.Errorf("in field %q: %v", definition.Name, err)
+			}
+			object[definition.Name] = coerced
+		}
+		return object, nil
+	case *Enum:
+		name, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("enum %q cannot represent %s", t.Name, describeInput(v))
+		}
+		enumValue, ok := t.value(name)
+		if !ok {
+			return nil, fmt.Errorf("value %q does not exist in %q enum", name, t.Name)
+		}
+		return enumValue.Value, nil
+	case *Scalar:
+		return t.ParseValue(jsonValue(v))
+	}
+	return nil, fmt.Errorf("%s is not an input type", t)
+}
+
+func defaultField(object map[string]interface{}, definition *Argument, t *InputObject) error {
+	if definition.Default != nil {
+		object[definition.Name] = definition.Default
+		return nil
+	}
+	if _, required := definition.Type.(*NonNull); required {
+		return fmt.Errorf("field %q of required type %q was not provided in %s", definition.Name, definition.Type, t.Name)
+	}
+	return nil
+}
+
+// jsonValue turns the numbers of variables passed from Go, rather than
+// decoded with json.Decoder.UseNumber, into json.Number.
+func jsonValue(v interface{}) interface{} {
+	switch rv := reflect.ValueOf(v); rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return json.Number(fmt.Sprint(v))
+	}
+	return v
+}
+
+// orderedMap is a response object that keeps its keys in selection order.
+type orderedMap struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func (m *orderedMap) set(key string, value interface{}) {
+	if m.values == nil {
+		m.values = map[string]interface{}{}
+	}
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = value
+}
+
+func (m *orderedMap) MarshalJSON() ([]byte, error) {
+	var out bytes.Buffer
+	out.WriteByte('{')
+	for i, key := range m.keys {
+		if i > 0 {
+			out.WriteByte(',')
+		}
+		name, _ := json.Marshal(key)
+		out.Write(name)
+		out.WriteByte(':')
+		value, err := json.Marshal(m.values[key])
+		if err != nil {
+			return nil, err
+		}
+		out.Write(value)
+	}
+	out.WriteByte('}')
+	return out.Bytes(), nil
+}
@@ -0,0 +1,150 @@
+// graphql/execute_test.go
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"testing"
+)
+
+type item struct {
+	ID   int
+	Name string
+}
+
+// codedError is a resolver error carrying a code, as the graph package's
+// are.
+type codedError string
+
+func (err codedError) Error() string { return string(err) }
+
+func (err codedError) Extensions() map[string]interface{} {
+	return map[string]interface{}{"code": "NOT_FOUND"}
+}
+
+// testSchema is a small schema of items, with resolvers that fail or panic
+// on demand.
+func testSchema(t *testing.T) *Schema {
+	items := []item{{1, "first"}, {2, "second"}}
+	itemType := &Object{Name: "Item", Fields: []*Field{
+		{Name: "id", Type: NonNullOf(Int), Resolve: func(p ResolveParams) (interface{}, error) {
+			return p.Source.(item).ID, nil
+		}},
+		{Name: "name", Type: String, Resolve: func(p ResolveParams) (interface{}, error) {
+			return p.Source.(item).Name, nil
+		}},
+		{Name: "broken", Type: NonNullOf(String), Resolve: func(p ResolveParams) (interface{}, error) {
+			return nil, errors.New("broken field")
+		}},
+	}}
+	query := &Object{Name: "Query", Fields: []*Field{
+		{Name: "hello", Type: NonNullOf(String), Args: []*Argument{{Name: "name", Type: String, Default: "world"}},
+			Resolve: func(p ResolveParams) (interface{}, error) {
+				return "hello " + p.Args["name"].(string), nil
+			}},
+		{Name: "items", Type: NonNullOf(ListOf(NonNullOf(itemType))), Resolve: func(p ResolveParams) (interface{}, error) {
+			return items, nil
+		}},
+		{Name: "item", Type: itemType, Args: []*Argument{{Name: "id", Type: NonNullOf(Int)}},
+			Resolve: func(p ResolveParams) (interface{}, error) {
+				for _, item := range items {
+					if item.ID == p.Args["id"].(int) {
+						return item, nil
+					}
+				}
+				return nil, codedError("no such item")
+			}},
+		{Name: "panics", Type: String, Resolve: func(p ResolveParams) (interface{}, error) {
+			panic("secret connection string")
+		}},
+	}}
+	mutation := &Object{Name: "Mutation", Fields: []*Field{
+		{Name: "add", Type: NonNullOf(itemType), Args: []*Argument{{Name: "name", Type: NonNullOf(String)}},
+			Resolve: func(p ResolveParams) (interface{}, error) {
+				items = append(items, item{len(items) + 1, p.Args["name"].(string)})
+				return items[len(items)-1], nil
+			}},
+	}}
+	schema, err := NewSchema(query, mutation, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return schema
+}
+
+// execute runs a request and returns its response as JSON.
+func execute(t *testing.T, schema *Schema, request Request) string {
+	data, err := json.Marshal(schema.Execute(context.Background(), request))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestExecute(t *testing.T) {
+	schema := testSchema(t)
+	tests := []struct {
+		name    string
+		request Request
+		want    string
+	}{
+		{"default argument", Request{Query: `{ hello }`},
+			`{"data":{"hello":"hello world"}}`},
+		{"aliases and fragments", Request{Query: `{ greeting: hello(name: "you") items { ...names } } fragment names on Item { id name }`},
+			`{"data":{"greeting":"hello you","items":[{"id":1,"name":"first"},{"id":2,"name":"second"}]}}`},
+		{"variables and directives", Request{
+			Query:     `query Item($id: Int!, $named: Boolean!) { item(id: $id) { id name @include(if: $named) __typename } }`,
+			Variables: map[string]interface{}{"id": json.Number("2"), "named": false},
+		}, `{"data":{"item":{"id":2,"__typename":"Item"}}}`},
+		{"resolver error with extensions", Request{Query: `{ item(id: 9) { id } }`},
+			`{"data":{"item":null},"errors":[{"message":"no such item","locations":[{"line":1,"column":3}],"path":["item"],"extensions":{"code":"NOT_FOUND"}}]}`},
+		{"non-null error propagates", Request{Query: `{ hello item(id: 1) { id broken } }`},
+			`{"data":{"hello":"hello world","item":null},"errors":[{"message":"broken field","locations":[{"line":1,"column":26}],"path":["item","broken"]}]}`},
+		{"missing variable", Request{Query: `query ($id: Int!) { item(id: $id) { id } }`},
+			`{"errors":[{"message":"Variable \"$id\" of required type \"Int!\" was not provided.","locations":[{"line":1,"column":8}],"extensions":{"code":"BAD_USER_INPUT"}}]}`},
+		{"operation name", Request{Query: `query A { hello } query B { hello(name: "b") }`, OperationName: "B"},
+			`{"data":{"hello":"hello b"}}`},
+		{"mutation", Request{Query: `mutation { add(name: "third") { id name } }`},
+			`{"data":{"add":{"id":3,"name":"third"}}}`},
+	}
+	for _, test := range tests {
+		if got := execute(t, schema, test.request); got != test.want {
+			t.Errorf("%s:\n got %s\nwant %s", test.name, got, test.want)
+		}
+	}
+}
+
+// TestExecutePanic checks that a panicking resolver fails only its field,
+// and that what it panicked with is logged but not sent to the client.
+func TestExecutePanic(t *testing.T) {
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	got := execute(t, testSchema(t), Request{Query: `{ hello panics }`})
+	want := `{"data":{"hello":"hello world","panics":null},"errors":[{"message":"internal error","locations":[{"line":1,"column":9}],"path":["panics"]}]}`
+	if got != want {
+		t.Errorf("got %s\nwant %s", got, want)
+	}
+	if !strings.Contains(logged.String(), "secret connection string") {
+		t.Errorf("panic not logged: %q", logged.String())
+	}
+}
+
+func TestLimits(t *testing.T) {
+	schema := testSchema(t)
+	schema.MaxDepth = 1
+	if got := execute(t, schema, Request{Query: `{ items { id } }`}); !strings.Contains(got, CodeQueryTooDeep) {
+		t.Errorf("query over the depth limit = %s", got)
+	}
+	schema.MaxDepth = 0
+	schema.MaxComplexity = 2
+	if got := execute(t, schema, Request{Query: `{ hello items { id name } }`}); !strings.Contains(got, CodeQueryTooComplex) {
+		t.Errorf("query over the complexity limit = %s", got)
+	}
+}
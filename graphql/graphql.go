@@ -0,0 +1,65 @@
+// graphql/graphql.go
+
+// Package graphql executes GraphQL requests against a schema built from Go
+// values: parsing, validation, depth and complexity limits, execution of
+// queries and mutations, and subscriptions driven by a callback.
+package graphql
+
+import "fmt"
+
+// Error codes reported in the extensions of errors raised by the engine.
+const (
+	CodeParseFailed      = "GRAPHQL_PARSE_FAILED"
+	CodeValidationFailed = "GRAPHQL_VALIDATION_FAILED"
+	CodeBadUserInput     = "BAD_USER_INPUT"
+	CodeQueryTooDeep     = "QUERY_TOO_DEEP"
+	CodeQueryTooComplex  = "QUERY_TOO_COMPLEX"
+)
+
+// Request is the body of a GraphQL request.
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+}
+
+// Response is the result of a request. Data is absent when the request
+// failed before execution.
+type Response struct {
+	Data   interface{} `json:"data,omitempty"`
+	Errors []*Error    `json:"errors,omitempty"`
+}
+
+type Location struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+type Error struct {
+	Message    string                 `json:"message"`
+	Locations  []Location             `json:"locations,omitempty"`
+	Path       []interface{}          `json:"path,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+func (err *Error) Error() string {
+	return err.Message
+}
+
+// extender is implemented by resolver errors that carry extensions, such
+// as an error code, for the client.
+type extender interface {
+	Extensions() map[string]interface{}
+}
+
+func newError(code string, loc Location, format string, args ...interface{}) *Error {
+	return &Error{
+		Message:    fmt.Sprintf(format, args...),
+		Locations:  []Location{loc},
+		Extensions: map[string]interface{}{"code": code},
+	}
+}
+
+func failed(errs ...*Error) *Response {
+	return &Response{Errors: errs}
+}
@@ -0,0 +1,258 @@
+// graphql/lexer.go
+package graphql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenPunct
+	tokenName
+	tokenInt
+	tokenFloat
+	tokenString
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+	loc   Location
+}
+
+// lexer splits a GraphQL document into tokens, skipping whitespace, commas
+// and comments as the grammar allows.
+type lexer struct {
+	src  string
+	pos  int
+	line int
+	col  int
+}
+
+func newLexer(src string) *lexer {
+	return &lexer{src: src, line: 1, col: 1}
+}
+
+// syntaxError is raised with panic inside the parser and recovered by parse.
+type syntaxError struct {
+	message string
+	loc     Location
+}
+
+func (lex *lexer) fail(loc Location, format string, args ...interface{}) {
+	panic(syntaxError{message: fmt.Sprintf(format, args...), loc: loc})
+}
+
+func (lex *lexer) advance(n int) {
+	for i := 0; i < n && lex.pos < len(lex.src); i++ {
+		if lex.src[lex.pos] == '\n' {
+			lex.line++
+			lex.col = 1
+		} else if utf8.RuneStart(lex.src[lex.pos]) {
+			lex.col++
+		}
+		lex.pos++
+	}
+}
+
+func (lex *lexer) skipIgnored() {
+	for lex.pos < len(lex.src) {
+		switch c := lex.src[lex.pos]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',':
+			lex.advance(1)
+		case c == '#':
+			for lex.pos < len(lex.src) && lex.src[lex.pos] != '\n' {
+				lex.advance(1)
+			}
+		case strings.HasPrefix(lex.src[lex.pos:], "\ufeff"):
+			lex.pos += len("\ufeff")
+		default:
+			return
+		}
+	}
+}
+
+func (lex *lexer) next() token {
+	lex.skipIgnored()
+	loc := Location{Line: lex.line, Column: lex.col}
+	if lex.pos >= len(lex.src) {
+		return token{kind: tokenEOF, loc: loc}
+	}
+
+	rest := lex.src[lex.pos:]
+	c := rest[0]
+	switch {
+	case strings.HasPrefix(rest, "..."):
+		lex.advance(3)
+		return token{kind: tokenPunct, value: "...", loc: loc}
+	case strings.IndexByte("!$&():=@[]{|}", c) >= 0:
+		lex.advance(1)
+		return token{kind: tokenPunct, value: string(c), loc: loc}
+	case c == '_' || isLetter(c):
+		end := 1
+		for end < len(rest) && (rest[end] == '_' || isLetter(rest[end]) || isDigit(rest[end])) {
+			end++
+		}
+		lex.advance(end)
+		return token{kind: tokenName, value: rest[:end], loc: loc}
+	case c == '-' || isDigit(c):
+		return lex.number(loc)
+	case strings.HasPrefix(rest, `"""`):
+		return lex.blockString(loc)
+	case c == '"':
+		return lex.string(loc)
+	}
+	r, _ := utf8.DecodeRuneInString(rest)
+	lex.fail(loc, "Unexpected character %q", r)
+	return token{}
+}
+
+func (lex *lexer) number(loc Location) token {
+	rest := lex.src[lex.pos:]
+	end := 0
+	if rest[end] == '-' {
+		end++
+	}
+	digits := func() int {
+		start := end
+		for end < len(rest) && isDigit(rest[end]) {
+			end++
+		}
+		return end - start
+	}
+	if n := digits(); n == 0 {
+		lex.fail(loc, "Invalid number, expected digit")
+	} else if n > 1 && rest[end-n] == '0' {
+		lex.fail(loc, "Invalid number, unexpected leading zero")
+	}
+	kind := tokenInt
+	if end < len(rest) && rest[end] == '.' {
+		kind = tokenFloat
+		end++
+		if digits() == 0 {
+			lex.fail(loc, "Invalid number, expected digit after '.'")
+		}
+	}
+	if end < len(rest) && (rest[end] == 'e' || rest[end] == 'E') {
+		kind = tokenFloat
+		end++
+		if end < len(rest) && (rest[end] == '+' || rest[end] == '-') {
+			end++
+		}
+		if digits() == 0 {
+			lex.fail(loc, "Invalid number, expected digit in exponent")
+		}
+	}
+	if end < len(rest) && (rest[end] == '_' || rest[end] == '.' || isLetter(rest[end])) {
+		lex.fail(loc, "Invalid number, unexpected %q", rest[end])
+	}
+	lex.advance(end)
+	return token{kind: kind, value: rest[:end], loc: loc}
+}
+
+func (lex *lexer) string(loc Location) token {
+	lex.advance(1)
+	var value strings.Builder
+	for {
+		if lex.pos >= len(lex.src) || lex.src[lex.pos] == '\n' || lex.src[lex.pos] == '\r' {
+			lex.fail(loc, "Unterminated string")
+		}
+		c := lex.src[lex.pos]
+		switch {
+		case c == '"':
+			lex.advance(1)
+			return token{kind: tokenString, value: value.String(), loc: loc}
+		case c == '\\':
+			if lex.pos+1 >= len(lex.src) {
+				lex.fail(loc, "Unterminated string")
+			}
+			escape := lex.src[lex.pos+1]
+			if escape == 'u' {
+				if lex.pos+6 > len(lex.src) {
+					lex.fail(loc, "Invalid unicode escape")
+				}
+				code, err := strconv.ParseUint(lex.src[lex.pos+2:lex.pos+6], 16, 32)
+				if err != nil {
+					lex.fail(loc, "Invalid unicode escape")
+				}
+				value.WriteRune(rune(code))
+				lex.advance(6)
+				continue
+			}
+			replacement, ok := map[byte]string{'"': `"`, '\\': `\`, '/': "/", 'b': "\b", 'f': "\f", 'n': "\n", 'r': "\r", 't': "\t"}[escape]
+			if !ok {
+				lex.fail(loc, "Invalid escape sequence \\%c", escape)
+			}
+			value.WriteString(replacement)
+			lex.advance(2)
+		default:
+			value.WriteByte(c)
+			lex.advance(1)
+		}
+	}
+}
+
+func (lex *lexer) blockString(loc Location) token {
+	lex.advance(3)
+	var raw strings.Builder
+	for {
+		rest := lex.src[lex.pos:]
+		switch {
+		case rest == "":
+			lex.fail(loc, "Unterminated string")
+		case strings.HasPrefix(rest, `\"""`):
+			raw.WriteString(`"""`)
+			lex.advance(4)
+		case strings.HasPrefix(rest, `"""`):
+			lex.advance(3)
+			return token{kind: tokenString, value: blockStringValue(raw.String()), loc: loc}
+		default:
+			raw.WriteByte(rest[0])
+			lex.advance(1)
+		}
+	}
+}
+
+// blockStringValue removes the common indentation and the blank first and
+// last lines of a block string.
+func blockStringValue(raw string) string {
+	lines := strings.Split(strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(raw), "\n")
+
+	common := -1
+	for _, line := range lines[1:] {
+		indent := len(line) - len(strings.TrimLeft(line, " \t"))
+		if indent < len(line) && (common < 0 || indent < common) {
+			common = indent
+		}
+	}
+	if common > 0 {
+		for i := 1; i < len(lines); i++ {
+			if len(lines[i]) >= common {
+				lines[i] = lines[i][common:]
+			} else {
+				lines[i] = ""
+			}
+		}
+	}
+
+	for len(lines) > 0 && strings.TrimLeft(lines[0], " \t") == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimLeft(lines[len(lines)-1], " \t") == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
@@ -0,0 +1,382 @@
+// graphql/parser.go
+package graphql
+
+import "fmt"
+
+type document struct {
+	operations []*operation
+	fragments  []*fragment
+}
+
+type operation struct {
+	kind         string
+	name         string
+	variables    []*variableDefinition
+	directives   []*directive
+	selectionSet []selection
+	loc          Location
+}
+
+type variableDefinition struct {
+	name         string
+	typ          *typeRef
+	defaultValue *value
+	loc          Location
+}
+
+// typeRef is a type as written in a document, such as [ID!]!.
+type typeRef struct {
+	name    string
+	elem    *typeRef
+	nonNull bool
+}
+
+func (ref *typeRef) String() string {
+	s := ref.name
+	if ref.elem != nil {
+		s = "[" + ref.elem.String() + "]"
+	}
+	if ref.nonNull {
+		s += "!"
+	}
+	return s
+}
+
+type selection interface {
+	location() Location
+}
+
+type field struct {
+	alias        string
+	name         string
+	arguments    []*argument
+	directives   []*directive
+	selectionSet []selection
+	loc          Location
+}
+
+type fragmentSpread struct {
+	name       string
+	directives []*directive
+	loc        Location
+}
+
+type inlineFragment struct {
+	typeCondition string
+	directives    []*directive
+	selectionSet  []selection
+	loc           Location
+}
+
+type fragment struct {
+	name          string
+	typeCondition string
+	directives    []*directive
+	selectionSet  []selection
+	loc           Location
+}
+
+func (f *field) location() Location          { return f.loc }
+func (f *fragmentSpread) location() Location { return f.loc }
+func (f *inlineFragment) location() Location { return f.loc }
+
+// responseKey is the name the field's value is returned under.
+func (f *field) responseKey() string {
+	if f.alias != "" {
+		return f.alias
+	}
+	return f.name
+}
+
+type argument struct {
+	name  string
+	value *value
+	loc   Location
+}
+
+type directive struct {
+	name      string
+	arguments []*argument
+	loc       Location
+}
+
+type valueKind int
+
+const (
+	variableValue valueKind = iota
+	intValue
+	floatValue
+	stringValue
+	booleanValue
+	nullValue
+	enumValue
+	listValue
+	objectValue
+)
+
+type value struct {
+	kind   valueKind
+	raw    string
+	list   []*value
+	fields []*objectField
+	loc    Location
+}
+
+type objectField struct {
+	name  string
+	value *value
+}
+
+type parser struct {
+	lex *lexer
+	tok token
+}
+
+// parse reads an executable document: operations and fragments.
+func parse(src string) (doc *document, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			failure, ok := r.(syntaxError)
+			if !ok {
+				panic(r)
+			}
+			err = &Error{
+				Message:    "Syntax Error: " + failure.message,
+				Locations:  []Location{failure.loc},
+				Extensions: map[string]interface{}{"code": CodeParseFailed},
+			}
+		}
+	}()
+
+	p := &parser{lex: newLexer(src)}
+	p.tok = p.lex.next()
+	doc = &document{}
+	if p.tok.kind == tokenEOF {
+		p.lex.fail(p.tok.loc, "Unexpected <EOF>")
+	}
+	for p.tok.kind != tokenEOF {
+		switch {
+		case p.peek(tokenPunct, "{"):
+			op := &operation{kind: "query", loc: p.tok.loc}
+			op.selectionSet = p.selectionSet()
+			doc.operations = append(doc.operations, op)
+		case p.peek(tokenName, "query"), p.peek(tokenName, "mutation"), p.peek(tokenName, "subscription"):
+			doc.operations = append(doc.operations, p.operation())
+		case p.peek(tokenName, "fragment"):
+			doc.fragments = append(doc.fragments, p.fragment())
+		default:
+			p.unexpected()
+		}
+	}
+	return doc, nil
+}
+
+func (p *parser) advance() token {
+	tok := p.tok
+	p.tok = p.lex.next()
+	return tok
+}
+
+func (p *parser) peek(kind tokenKind, value string) bool {
+	return p.tok.kind == kind && p.tok.value == value
+}
+
+func (p *parser) skip(kind tokenKind, value string) bool {
+	if p.peek(kind, value) {
+		p.advance()
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(kind tokenKind, value string) token {
+	if !p.peek(kind, value) {
+		p.lex.fail(p.tok.loc, "Expected %q, found %s", value, describe(p.tok))
+	}
+	return p.advance()
+}
+
+func (p *parser) name() string {
+	if p.tok.kind != tokenName {
+		p.lex.fail(p.tok.loc, "Expected Name, found %s", describe(p.tok))
+	}
+	return p.advance().value
+}
+
+func (p *parser) unexpected() {
+	p.lex.fail(p.tok.loc, "Unexpected %s", describe(p.tok))
+}
+
+func describe(tok token) string {
+	switch tok.kind {
+	case tokenEOF:
+		return "<EOF>"
+	case tokenString:
+		return fmt.Sprintf("string %q", tok.value)
+	case tokenName:
+		return fmt.Sprintf("Name %q", tok.value)
+	}
+	return fmt.Sprintf("%q", tok.value)
+}
+
+func (p *parser) operation() *operation {
+	loc := p.tok.loc
+	op := &operation{loc: loc, kind: p.advance().value}
+	if p.tok.kind == tokenName {
+		op.name = p.advance().value
+	}
+	if p.skip(tokenPunct, "(") {
+		for !p.skip(tokenPunct, ")") {
+			op.variables = append(op.variables, p.variableDefinition())
+		}
+	}
+	op.directives = p.directives(false)
+	op.selectionSet = p.selectionSet()
+	return op
+}
+
+func (p *parser) variableDefinition() *variableDefinition {
+	definition := &variableDefinition{loc: p.tok.loc}
+	p.expect(tokenPunct, "$")
+	definition.name = p.name()
+	p.expect(tokenPunct, ":")
+	definition.typ = p.typeRef()
+	if p.skip(tokenPunct, "=") {
+		definition.defaultValue = p.value(true)
+	}
+	p.directives(true)
+	return definition
+}
+
+func (p *parser) typeRef() *typeRef {
+	var ref *typeRef
+	if p.skip(tokenPunct, "[") {
+		ref = &typeRef{elem: p.typeRef()}
+		p.expect(tokenPunct, "]")
+	} else {
+		ref = &typeRef{name: p.name()}
+	}
+	ref.nonNull = p.skip(tokenPunct, "!")
+	return ref
+}
+
+func (p *parser) selectionSet() []selection {
+	p.expect(tokenPunct, "{")
+	var selections []selection
+	for !p.skip(tokenPunct, "}") {
+		selections = append(selections, p.selection())
+	}
+	if len(selections) == 0 {
+		p.lex.fail(p.tok.loc, "Expected a selection")
+	}
+	return selections
+}
+
+func (p *parser) selection() selection {
+	loc := p.tok.loc
+	if !p.skip(tokenPunct, "...") {
+		return p.field()
+	}
+	if p.tok.kind == tokenName && p.tok.value != "on" {
+		return &fragmentSpread{name: p.name(), directives: p.directives(false), loc: loc}
+	}
+	inline := &inlineFragment{loc: loc}
+	if p.skip(tokenName, "on") {
+		inline.typeCondition = p.name()
+	}
+	inline.directives = p.directives(false)
+	inline.selectionSet = p.selectionSet()
+	return inline
+}
+
+func (p *parser) field() *field {
+	loc := p.tok.loc
+	f := &field{loc: loc, name: p.name()}
+	if p.skip(tokenPunct, ":") {
+		f.alias, f.name = f.name, p.name()
+	}
+	f.arguments = p.arguments(false)
+	f.directives = p.directives(false)
+	if p.peek(tokenPunct, "{") {
+		f.selectionSet = p.selectionSet()
+	}
+	return f
+}
+
+func (p *parser) arguments(constant bool) []*argument {
+	if !p.skip(tokenPunct, "(") {
+		return nil
+	}
+	var arguments []*argument
+	for !p.skip(tokenPunct, ")") {
+		loc := p.tok.loc
+		arg := &argument{loc: loc, name: p.name()}
+		p.expect(tokenPunct, ":")
+		arg.value = p.value(constant)
+		arguments = append(arguments, arg)
+	}
+	return arguments
+}
+
+func (p *parser) directives(constant bool) []*directive {
+	var directives []*directive
+	for p.peek(tokenPunct, "@") {
+		loc := p.advance().loc
+		directives = append(directives, &directive{name: p.name(), arguments: p.arguments(constant), loc: loc})
+	}
+	return directives
+}
+
+func (p *parser) fragment() *fragment {
+	f := &fragment{loc: p.advance().loc}
+	if p.peek(tokenName, "on") {
+		p.unexpected()
+	}
+	f.name = p.name()
+	p.expect(tokenName, "on")
+	f.typeCondition = p.name()
+	f.directives = p.directives(false)
+	f.selectionSet = p.selectionSet()
+	return f
+}
+
+// value reads an input value. Variables are not allowed in constant values
+// such as variable defaults.
+func (p *parser) value(constant bool) *value {
+	v := &value{loc: p.tok.loc}
+	switch tok := p.tok; {
+	case tok.kind == tokenPunct && tok.value == "$" && !constant:
+		p.advance()
+		v.kind, v.raw = variableValue, p.name()
+	case tok.kind == tokenInt:
+		v.kind, v.raw = intValue, p.advance().value
+	case tok.kind == tokenFloat:
+		v.kind, v.raw = floatValue, p.advance().value
+	case tok.kind == tokenString:
+		v.kind, v.raw = stringValue, p.advance().value
+	case tok.kind == tokenName && (tok.value == "true" || tok.value == "false"):
+		v.kind, v.raw = booleanValue, p.advance().value
+	case tok.kind == tokenName && tok.value == "null":
+		p.advance()
+		v.kind = nullValue
+	case tok.kind == tokenName:
+		v.kind, v.raw = enumValue, p.advance().value
+	case tok.kind == tokenPunct && tok.value == "[":
+		p.advance()
+		v.kind = listValue
+		for !p.skip(tokenPunct, "]") {
+			v.list = append(v.list, p.value(constant))
+		}
+	case tok.kind == tokenPunct && tok.value == "{":
+		p.advance()
+		v.kind = objectValue
+		for !p.skip(tokenPunct, "}") {
+			name := p.name()
+			p.expect(tokenPunct, ":")
+			v.fields = append(v.fields, &objectField{name: name, value: p.value(constant)})
+		}
+	default:
+		p.unexpected()
+	}
+	return v
+}
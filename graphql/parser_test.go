@@ -0,0 +1,84 @@
+// graphql/parser_test.go
+package graphql
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	doc, err := parse(`
+		query Page($first: Int = 10, $after: String) {
+			todos(first: $first, after: $after) { ...item @include(if: true) }
+		}
+		fragment item on Todo { id name: title }
+		mutation { add(input: {name: "a\"b", tags: ["x", "y"], done: false, note: null}) { id } }
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.operations) != 2 || len(doc.fragments) != 1 {
+		t.Fatalf("parsed %d operations and %d fragments", len(doc.operations), len(doc.fragments))
+	}
+
+	page := doc.operations[0]
+	if page.kind != "query" || page.name != "Page" || len(page.variables) != 2 {
+		t.Errorf("query = %s %q with %d variables", page.kind, page.name, len(page.variables))
+	}
+	if page.variables[0].defaultValue == nil || page.variables[0].typ.String() != "Int" {
+		t.Errorf("$first = %s, default %v", page.variables[0].typ, page.variables[0].defaultValue)
+	}
+	todos := page.selectionSet[0].(*field)
+	if todos.name != "todos" || len(todos.arguments) != 2 {
+		t.Errorf("todos field = %q with %d arguments", todos.name, len(todos.arguments))
+	}
+	if spread := todos.selectionSet[0].(*fragmentSpread); spread.name != "item" || len(spread.directives) != 1 {
+		t.Errorf("spread = %q with %d directives", spread.name, len(spread.directives))
+	}
+
+	alias := doc.fragments[0].selectionSet[1].(*field)
+	if alias.responseKey() != "name" || alias.name != "title" {
+		t.Errorf("aliased field = %q as %q", alias.name, alias.responseKey())
+	}
+
+	add := doc.operations[1]
+	input := add.selectionSet[0].(*field).arguments[0].value
+	if add.kind != "mutation" || add.name != "" || len(input.fields) != 4 {
+		t.Fatalf("mutation = %s %q, input with %d fields", add.kind, add.name, len(input.fields))
+	}
+	if name := input.fields[0].value; name.raw != `a"b` {
+		t.Errorf("escaped string = %q", name.raw)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		query   string
+		message string
+		line    int
+		column  int
+	}{
+		{"", "Unexpected <EOF>", 1, 1},
+		{"{ todos ", "Expected Name, found <EOF>", 1, 9},
+		{"query {\n  todos(first: ) { id }\n}", "Unexpected", 2, 16},
+		{`{ todo(name: "open) { id } }`, "Unterminated string", 1, 14},
+		{"subscription", "Expected", 1, 13},
+	}
+	for _, test := range tests {
+		_, err := parse(test.query)
+		graphErr, ok := err.(*Error)
+		if !ok {
+			t.Errorf("parse(%q) = %v, want a syntax error", test.query, err)
+			continue
+		}
+		if !strings.HasPrefix(graphErr.Message, "Syntax Error: "+test.message) {
+			t.Errorf("parse(%q) = %q, want %q", test.query, graphErr.Message, test.message)
+		}
+		if loc := graphErr.Locations[0]; loc.Line != test.line || loc.Column != test.column {
+			t.Errorf("parse(%q) at %d:%d, want %d:%d", test.query, loc.Line, loc.Column, test.line, test.column)
+		}
+		if graphErr.Extensions["code"] != CodeParseFailed {
+			t.Errorf("parse(%q) code = %v", test.query, graphErr.Extensions["code"])
+		}
+	}
+}
@@ -0,0 +1,401 @@
+// graphql/schema.go
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+)
+
+// Type is one of *Scalar, *Enum, *Object, *InputObject, *List or *NonNull.
+type Type interface {
+	String() string
+}
+
+type Scalar struct {
+	Name        string
+	Description string
+	// Serialize turns a resolved Go value into its JSON representation.
+	Serialize func(interface{}) (interface{}, error)
+	// ParseValue reads an input value. It receives a string, bool,
+	// json.Number or nil, whether the value came from a literal or a
+	// variable.
+	ParseValue func(interface{}) (interface{}, error)
+}
+
+type EnumValue struct {
+	Name        string
+	Description string
+	Value       interface{}
+}
+
+type Enum struct {
+	Name        string
+	Description string
+	Values      []EnumValue
+}
+
+type Object struct {
+	Name        string
+	Description string
+	Fields      []*Field
+}
+
+type InputObject struct {
+	Name        string
+	Description string
+	Fields      []*Argument
+}
+
+type List struct {
+	Of Type
+}
+
+type NonNull struct {
+	Of Type
+}
+
+func ListOf(t Type) *List       { return &List{Of: t} }
+func NonNullOf(t Type) *NonNull { return &NonNull{Of: t} }
+
+func (t *Scalar) String() string      { return t.Name }
+func (t *Enum) String() string        { return t.Name }
+func (t *Object) String() string      { return t.Name }
+func (t *InputObject) String() string { return t.Name }
+func (t *List) String() string        { return "[" + t.Of.String() + "]" }
+func (t *NonNull) String() string     { return t.Of.String() + "!" }
+
+// Argument describes a field argument or an input object field. Default is
+// the value used when the argument is absent, already in its Go form.
+type Argument struct {
+	Name        string
+	Description string
+	Type        Type
+	Default     interface{}
+}
+
+// ResolveParams is what a resolver is called with. Args holds only the
+// arguments that were given or have a default, so a missing key means the
+// client left the argument out.
+type ResolveParams struct {
+	Context context.Context
+	Source  interface{}
+	Args    map[string]interface{}
+	Path    []interface{}
+}
+
+type ResolveFunc func(p ResolveParams) (interface{}, error)
+
+// SubscribeFunc runs a subscription until the context is done or emit
+// fails, calling emit with the source value of each event.
+type SubscribeFunc func(p ResolveParams, emit func(interface{}) error) error
+
+type Field struct {
+	Name        string
+	Description string
+	Type        Type
+	Args        []*Argument
+	Resolve     ResolveFunc
+	// Subscribe is set on the fields of the subscription type instead of
+	// Resolve.
+	Subscribe SubscribeFunc
+	// Cost is the complexity of the field itself; zero counts as one.
+	Cost int
+}
+
+func (object *Object) field(name string) *Field {
+	for _, f := range object.Fields {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+func (input *InputObject) field(name string) *Argument {
+	for _, f := range input.Fields {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+func (enum *Enum) value(name string) (EnumValue, bool) {
+	for _, v := range enum.Values {
+		if v.Name == name {
+			return v, true
+		}
+	}
+	return EnumValue{}, false
+}
+
+func argumentByName(args []*Argument, name string) *Argument {
+	for _, arg := range args {
+		if arg.Name == name {
+			return arg
+		}
+	}
+	return nil
+}
+
+// Schema is the entry point of a GraphQL API. MaxDepth and MaxComplexity
+// reject expensive operations before they run; zero disables a limit.
+type Schema struct {
+	Query         *Object
+	Mutation      *Object
+	Subscription  *Object
+	MaxDepth      int
+	MaxComplexity int
+
+	types []Type
+	named map[string]Type
+}
+
+// NewSchema collects the types reachable from the root objects. Mutation
+// and subscription may be nil.
+func NewSchema(query, mutation, subscription *Object) (*Schema, error) {
+	schema := &Schema{
+		Query:        query,
+		Mutation:     mutation,
+		Subscription: subscription,
+		named:        map[string]Type{},
+	}
+	for _, t := range []Type{String, Boolean} {
+		if err := schema.collect(t); err != nil {
+			return nil, err
+		}
+	}
+	for _, root := range []*Object{query, mutation, subscription} {
+		if root == nil {
+			continue
+		}
+		if err := schema.collect(root); err != nil {
+			return nil, err
+		}
+	}
+	return schema, nil
+}
+
+func (schema *Schema) collect(t Type) error {
+	t = namedType(t)
+	name := t.String()
+	if existing, ok := schema.named[name]; ok {
+		if existing != t {
+			return fmt.Errorf("graphql: two types are named %s", name)
+		}
+		return nil
+	}
+	schema.named[name] = t
+	schema.types = append(schema.types, t)
+
+	switch t := t.(type) {
+	case *Object:
+		for _, f := range t.Fields {
+			if err := schema.collect(f.Type); err != nil {
+				return err
+			}
+			for _, arg := range f.Args {
+				if err := schema.collect(arg.Type); err != nil {
+					return err
+				}
+			}
+		}
+	case *InputObject:
+		for _, f := range t.Fields {
+			if err := schema.collect(f.Type); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// resolveType looks up a type written in a document.
+func (schema *Schema) resolveType(ref *typeRef) Type {
+	var t Type
+	if ref.elem != nil {
+		elem := schema.resolveType(ref.elem)
+		if elem == nil {
+			return nil
+		}
+		t = ListOf(elem)
+	} else if t = schema.named[ref.name]; t == nil {
+		return nil
+	}
+	if ref.nonNull {
+		t = NonNullOf(t)
+	}
+	return t
+}
+
+func namedType(t Type) Type {
+	for {
+		switch wrapper := t.(type) {
+		case *List:
+			t = wrapper.Of
+		case *NonNull:
+			t = wrapper.Of
+		default:
+			return t
+		}
+	}
+}
+
+func nullableType(t Type) Type {
+	if nonNull, ok := t.(*NonNull); ok {
+		return nonNull.Of
+	}
+	return t
+}
+
+func isInputType(t Type) bool {
+	switch namedType(t).(type) {
+	case *Scalar, *Enum, *InputObject:
+		return true
+	}
+	return false
+}
+
+func isLeafType(t Type) bool {
+	switch namedType(t).(type) {
+	case *Scalar, *Enum:
+		return true
+	}
+	return false
+}
+
+var (
+	Int = &Scalar{
+		Name:        "Int",
+		Description: "A signed 32-bit integer.",
+		Serialize: func(v interface{}) (interface{}, error) {
+			n, ok := toInt(v)
+			if !ok || n < math.MinInt32 || n > math.MaxInt32 {
+				return nil, fmt.Errorf("Int cannot represent %v", v)
+			}
+			return n, nil
+		},
+		ParseValue: func(v interface{}) (interface{}, error) {
+			number, ok := v.(json.Number)
+			if !ok {
+				return nil, fmt.Errorf("Int cannot represent %v", describeInput(v))
+			}
+			n, err := strconv.ParseInt(string(number), 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("Int cannot represent %s", number)
+			}
+			return int(n), nil
+		},
+	}
+	Float = &Scalar{
+		Name:        "Float",
+		Description: "A double-precision floating point number.",
+		Serialize: func(v interface{}) (interface{}, error) {
+			if n, ok := toInt(v); ok {
+				return float64(n), nil
+			}
+			rv := reflect.ValueOf(v)
+			if rv.Kind() == reflect.Float32 || rv.Kind() == reflect.Float64 {
+				return rv.Float(), nil
+			}
+			return nil, fmt.Errorf("Float cannot represent %v", v)
+		},
+		ParseValue: func(v interface{}) (interface{}, error) {
+			number, ok := v.(json.Number)
+			if !ok {
+				return nil, fmt.Errorf("Float cannot represent %v", describeInput(v))
+			}
+			return number.Float64()
+		},
+	}
+	String = &Scalar{
+		Name:        "String",
+		Description: "UTF-8 text.",
+		Serialize: func(v interface{}) (interface{}, error) {
+			if rv := reflect.ValueOf(v); rv.Kind() == reflect.String {
+				return rv.String(), nil
+			}
+			return nil, fmt.Errorf("String cannot represent %v", v)
+		},
+		ParseValue: func(v interface{}) (interface{}, error) {
+			s, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("String cannot represent %v", describeInput(v))
+			}
+			return s, nil
+		},
+	}
+	Boolean = &Scalar{
+		Name:        "Boolean",
+		Description: "true or false.",
+		Serialize: func(v interface{}) (interface{}, error) {
+			if b, ok := v.(bool); ok {
+				return b, nil
+			}
+			return nil, fmt.Errorf("Boolean cannot represent %v", v)
+		},
+		ParseValue: func(v interface{}) (interface{}, error) {
+			b, ok := v.(bool)
+			if !ok {
+				return nil, fmt.Errorf("Boolean cannot represent %v", describeInput(v))
+			}
+			return b, nil
+		},
+	}
+	ID = &Scalar{
+		Name:        "ID",
+		Description: "A unique identifier, serialized as a string.",
+		Serialize: func(v interface{}) (interface{}, error) {
+			if n, ok := toInt(v); ok {
+				return strconv.FormatInt(n, 10), nil
+			}
+			if rv := reflect.ValueOf(v); rv.Kind() == reflect.String {
+				return rv.String(), nil
+			}
+			return nil, fmt.Errorf("ID cannot represent %v", v)
+		},
+		ParseValue: func(v interface{}) (interface{}, error) {
+			switch v := v.(type) {
+			case string:
+				return v, nil
+			case json.Number:
+				if _, err := v.Int64(); err == nil {
+					return string(v), nil
+				}
+			}
+			return nil, fmt.Errorf("ID cannot represent %v", describeInput(v))
+		},
+	}
+)
+
+func toInt(v interface{}) (int64, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if rv.Uint() > math.MaxInt64 {
+			return 0, false
+		}
+		return int64(rv.Uint()), true
+	}
+	return 0, false
+}
+
+func describeInput(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return strconv.Quote(v)
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return "an object"
+	case []interface{}:
+		return "a list"
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,132 @@
+// graphql/sdl.go
+package graphql
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var builtinScalars = map[string]bool{"Int": true, "Float": true, "String": true, "Boolean": true, "ID": true}
+
+// SDL describes the schema in the GraphQL schema definition language, for
+// clients and code generators that work from a schema file.
+func (schema *Schema) SDL() string {
+	var out strings.Builder
+	out.WriteString("schema {\n  query: " + schema.Query.Name + "\n")
+	if schema.Mutation != nil {
+		out.WriteString("  mutation: " + schema.Mutation.Name + "\n")
+	}
+	if schema.Subscription != nil {
+		out.WriteString("  subscription: " + schema.Subscription.Name + "\n")
+	}
+	out.WriteString("}\n")
+
+	for _, t := range schema.types {
+		switch t := t.(type) {
+		case *Scalar:
+			if builtinScalars[t.Name] {
+				continue
+			}
+			out.WriteString("\n" + description(t.Description, ""))
+			out.WriteString("scalar " + t.Name + "\n")
+		case *Enum:
+			out.WriteString("\n" + description(t.Description, ""))
+			out.WriteString("enum " + t.Name + " {\n")
+			for _, v := range t.Values {
+				out.WriteString(description(v.Description, "  ") + "  " + v.Name + "\n")
+			}
+			out.WriteString("}\n")
+		case *InputObject:
+			out.WriteString("\n" + description(t.Description, ""))
+			out.WriteString("input " + t.Name + " {\n")
+			for _, f := range t.Fields {
+				out.WriteString(description(f.Description, "  ") + "  " + inputValue(f) + "\n")
+			}
+			out.WriteString("}\n")
+		case *Object:
+			out.WriteString("\n" + description(t.Description, ""))
+			out.WriteString("type " + t.Name + " {\n")
+			for _, f := range t.Fields {
+				out.WriteString(description(f.Description, "  ") + "  " + f.Name)
+				if len(f.Args) > 0 {
+					args := make([]string, len(f.Args))
+					for i, arg := range f.Args {
+						args[i] = inputValue(arg)
+					}
+					out.WriteString("(" + strings.Join(args, ", ") + ")")
+				}
+				out.WriteString(": " + f.Type.String() + "\n")
+			}
+			out.WriteString("}\n")
+		}
+	}
+	return out.String()
+}
+
+func description(text, indent string) string {
+	if text == "" {
+		return ""
+	}
+	if !strings.Contains(text, "\n") {
+		return indent + strconv.Quote(text) + "\n"
+	}
+	lines := strings.Split(strings.Replace(text, `"""`, `\"""`, -1), "\n")
+	return indent + `"""` + "\n" + indent + strings.Join(lines, "\n"+indent) + "\n" + indent + `"""` + "\n"
+}
+
+func inputValue(arg *Argument) string {
+	s := arg.Name + ": " + arg.Type.String()
+	if arg.Default != nil {
+		s += " = " + literal(arg.Type, arg.Default)
+	}
+	return s
+}
+
+// literal writes a Go input value the way it would appear in a document.
+func literal(t Type, v interface{}) string {
+	if v == nil {
+		return "null"
+	}
+	switch t := nullableType(t).(type) {
+	case *List:
+		items := reflect.ValueOf(v)
+		if items.Kind() != reflect.Slice {
+			return literal(t.Of, v)
+		}
+		values := make([]string, items.Len())
+		for i := range values {
+			values[i] = literal(t.Of, items.Index(i).Interface())
+		}
+		return "[" + strings.Join(values, ", ") + "]"
+	case *Enum:
+		for _, value := range t.Values {
+			if reflect.DeepEqual(value.Value, v) {
+				return value.Name
+			}
+		}
+	case *InputObject:
+		if object, ok := v.(map[string]interface{}); ok {
+			keys := make([]string, 0, len(object))
+			for key := range object {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			fields := make([]string, len(keys))
+			for i, key := range keys {
+				var fieldType Type = String
+				if f := t.field(key); f != nil {
+					fieldType = f.Type
+				}
+				fields[i] = key + ": " + literal(fieldType, object[key])
+			}
+			return "{" + strings.Join(fields, ", ") + "}"
+		}
+	}
+	if s, ok := v.(string); ok {
+		return strconv.Quote(s)
+	}
+	return fmt.Sprint(v)
+}
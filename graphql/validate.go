@@ -0,0 +1,271 @@
+// graphql/validate.go
+package graphql
+
+// validator checks a document against the schema before anything runs. It
+// covers the rules that would otherwise surface as confusing runtime
+// errors: unknown fields, arguments, types, fragments and variables.
+type validator struct {
+	schema    *Schema
+	fragments map[string]*fragment
+	errors    []*Error
+}
+
+func (v *validator) fail(loc Location, format string, args ...interface{}) {
+	v.errors = append(v.errors, newError(CodeValidationFailed, loc, format, args...))
+}
+
+func validate(schema *Schema, doc *document) []*Error {
+	v := &validator{schema: schema, fragments: map[string]*fragment{}}
+
+	names := map[string]bool{}
+	for _, op := range doc.operations {
+		if op.name == "" && len(doc.operations) > 1 {
+			v.fail(op.loc, "This anonymous operation must be the only defined operation.")
+		}
+		if op.name != "" && names[op.name] {
+			v.fail(op.loc, "There can be only one operation named %q.", op.name)
+		}
+		names[op.name] = true
+	}
+
+	for _, f := range doc.fragments {
+		if v.fragments[f.name] != nil {
+			v.fail(f.loc, "There can be only one fragment named %q.", f.name)
+		}
+		v.fragments[f.name] = f
+	}
+	for _, f := range doc.fragments {
+		if _, ok := schema.named[f.typeCondition].(*Object); !ok {
+			v.fail(f.loc, "Fragment %q cannot condition on non object type %q.", f.name, f.typeCondition)
+		}
+		if v.cycle(f, map[string]bool{}) {
+			v.fail(f.loc, "Cannot spread fragment %q within itself.", f.name)
+		}
+	}
+	if len(v.errors) > 0 {
+		return v.errors
+	}
+
+	for _, op := range doc.operations {
+		root := schema.root(op.kind)
+		if root == nil {
+			v.fail(op.loc, "Schema is not configured for %ss.", op.kind)
+			continue
+		}
+
+		defined := map[string]bool{}
+		for _, definition := range op.variables {
+			if defined[definition.name] {
+				v.fail(definition.loc, "There can be only one variable named \"$%s\".", definition.name)
+			}
+			defined[definition.name] = true
+			if t := schema.resolveType(definition.typ); t == nil || !isInputType(t) {
+				v.fail(definition.loc, "Variable \"$%s\" cannot be non-input type %q.", definition.name, definition.typ)
+			}
+		}
+
+		used := map[string]Location{}
+		v.selections(root, op.selectionSet, used, map[string]bool{})
+		for name, loc := range used {
+			if !defined[name] {
+				v.fail(loc, "Variable \"$%s\" is not defined.", name)
+			}
+		}
+
+		if op.kind == "subscription" {
+			if groups := collectFields(root, op.selectionSet, v.fragments, nil); len(groups) != 1 {
+				v.fail(op.loc, "Subscription must select only one top level field.")
+			}
+		}
+	}
+	return v.errors
+}
+
+func (v *validator) cycle(f *fragment, visiting map[string]bool) bool {
+	if visiting[f.name] {
+		return true
+	}
+	visiting[f.name] = true
+	defer delete(visiting, f.name)
+	found := false
+	walkSpreads(f.selectionSet, func(spread *fragmentSpread) {
+		if next := v.fragments[spread.name]; next != nil && !found {
+			found = v.cycle(next, visiting)
+		}
+	})
+	return found
+}
+
+func walkSpreads(selections []selection, fn func(*fragmentSpread)) {
+	for _, sel := range selections {
+		switch sel := sel.(type) {
+		case *field:
+			walkSpreads(sel.selectionSet, fn)
+		case *inlineFragment:
+			walkSpreads(sel.selectionSet, fn)
+		case *fragmentSpread:
+			fn(sel)
+		}
+	}
+}
+
+// selections validates a selection set against an object type and records
+// the variables it uses. Fragments are checked once per parent type.
+func (v *validator) selections(object *Object, selections []selection, used map[string]Location, seen map[string]bool) {
+	for _, sel := range selections {
+		switch sel := sel.(type) {
+		case *field:
+			v.directives(sel.directives, used)
+			if sel.name == "__typename" {
+				if sel.selectionSet != nil {
+					v.fail(sel.loc, "Field \"__typename\" must not have a selection since type \"String!\" has no subfields.")
+				}
+				continue
+			}
+			definition := object.field(sel.name)
+			if definition == nil {
+				v.fail(sel.loc, "Cannot query field %q on type %q.", sel.name, object.Name)
+				continue
+			}
+			v.arguments(definition.Args, sel.arguments, sel.loc, "field \""+object.Name+"."+sel.name+"\"", used)
+
+			child, isObject := namedType(definition.Type).(*Object)
+			switch {
+			case isObject && sel.selectionSet == nil:
+				v.fail(sel.loc, "Field %q of type %q must have a selection of subfields.", sel.name, definition.Type)
+			case !isObject && sel.selectionSet != nil:
+				v.fail(sel.loc, "Field %q must not have a selection since type %q has no subfields.", sel.name, definition.Type)
+			case isObject:
+				v.selections(child, sel.selectionSet, used, seen)
+			}
+		case *inlineFragment:
+			v.directives(sel.directives, used)
+			if sel.typeCondition != "" && sel.typeCondition != object.Name {
+				v.fail(sel.loc, "Fragment cannot be spread here as objects of type %q can never be of type %q.", object.Name, sel.typeCondition)
+				continue
+			}
+			v.selections(object, sel.selectionSet, used, seen)
+		case *fragmentSpread:
+			v.directives(sel.directives, used)
+			f := v.fragments[sel.name]
+			if f == nil {
+				v.fail(sel.loc, "Unknown fragment %q.", sel.name)
+				continue
+			}
+			if f.typeCondition != object.Name {
+				v.fail(sel.loc, "Fragment %q cannot be spread here as objects of type %q can never be of type %q.", f.name, object.Name, f.typeCondition)
+				continue
+			}
+			key := f.name + " on " + object.Name
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			v.selections(object, f.selectionSet, used, seen)
+		}
+	}
+}
+
+func (v *validator) arguments(definitions []*Argument, given []*argument, loc Location, owner string, used map[string]Location) {
+	names := map[string]bool{}
+	for _, arg := range given {
+		if names[arg.name] {
+			v.fail(arg.loc, "There can be only one argument named %q.", arg.name)
+		}
+		names[arg.name] = true
+		if argumentByName(definitions, arg.name) == nil {
+			v.fail(arg.loc, "Unknown argument %q on %s.", arg.name, owner)
+		}
+		variables(arg.value, used)
+	}
+	for _, definition := range definitions {
+		if _, required := definition.Type.(*NonNull); required && definition.Default == nil && !names[definition.Name] {
+			v.fail(loc, "Argument %q of type %q is required on %s, but it was not provided.", definition.Name, definition.Type, owner)
+		}
+	}
+}
+
+var conditionArgs = []*Argument{{Name: "if", Type: NonNullOf(Boolean)}}
+
+func (v *validator) directives(directives []*directive, used map[string]Location) {
+	for _, d := range directives {
+		if d.name != "skip" && d.name != "include" {
+			v.fail(d.loc, "Unknown directive \"@%s\".", d.name)
+			continue
+		}
+		v.arguments(conditionArgs, d.arguments, d.loc, "directive \"@"+d.name+"\"", used)
+	}
+}
+
+func variables(val *value, used map[string]Location) {
+	switch val.kind {
+	case variableValue:
+		if _, ok := used[val.raw]; !ok {
+			used[val.raw] = val.loc
+		}
+	case listValue:
+		for _, item := range val.list {
+			variables(item, used)
+		}
+	case objectValue:
+		for _, f := range val.fields {
+			variables(f.value, used)
+		}
+	}
+}
+
+// depth returns how deeply the selection set nests fields.
+func depth(selections []selection, fragments map[string]*fragment) int {
+	deepest := 0
+	for _, sel := range selections {
+		var d int
+		switch sel := sel.(type) {
+		case *field:
+			d = 1
+			if sel.selectionSet != nil {
+				d += depth(sel.selectionSet, fragments)
+			}
+		case *inlineFragment:
+			d = depth(sel.selectionSet, fragments)
+		case *fragmentSpread:
+			if f := fragments[sel.name]; f != nil {
+				d = depth(f.selectionSet, fragments)
+			}
+		}
+		if d > deepest {
+			deepest = d
+		}
+	}
+	return deepest
+}
+
+// complexity estimates the work an operation causes. Every field costs its
+// Cost, and a field with a first argument, such as a connection, multiplies
+// the cost of its selection by the number of items asked for.
+func (e *executor) complexity(object *Object, selections []selection) int {
+	total := 0
+	for _, group := range collectFields(object, selections, e.fragments, e.variables) {
+		definition := object.field(group.fields[0].name)
+		if definition == nil {
+			total++
+			continue
+		}
+		cost := definition.Cost
+		if cost == 0 {
+			cost = 1
+		}
+		children := 0
+		if child, ok := namedType(definition.Type).(*Object); ok {
+			children = e.complexity(child, mergeSelections(group.fields))
+		}
+		multiplier := 1
+		if argumentByName(definition.Args, "first") != nil {
+			args, _ := e.arguments(definition.Args, group.fields[0].arguments)
+			if first, ok := args["first"].(int); ok && first > 0 {
+				multiplier = first
+			}
+		}
+		total += cost + multiplier*children
+	}
+	return total
+}
@@ -0,0 +1,48 @@
+// graphql/validate_test.go
+package graphql
+
+import "testing"
+
+func TestValidate(t *testing.T) {
+	schema := testSchema(t)
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{`{ hello items { id } }`, ""},
+		{`{ nope }`, `Cannot query field "nope" on type "Query".`},
+		{`{ items }`, `Field "items" of type "[Item!]!" must have a selection of subfields.`},
+		{`{ hello { id } }`, `Field "hello" must not have a selection since type "String!" has no subfields.`},
+		{`{ item { id } }`, `Argument "id" of type "Int!" is required on field "Query.item", but it was not provided.`},
+		{`{ hello(who: "me") }`, `Unknown argument "who" on field "Query.hello".`},
+		{`{ hello @defer }`, `Unknown directive "@defer".`},
+		{`{ item(id: $id) { id } }`, `Variable "$id" is not defined.`},
+		{`query ($id: Item) { hello }`, `Variable "$id" cannot be non-input type "Item".`},
+		{`{ items { ...missing } }`, `Unknown fragment "missing".`},
+		{`{ items { ...a } } fragment a on Item { ...b } fragment b on Item { ...a }`, `Cannot spread fragment "a" within itself.`},
+		{`{ ...names } fragment names on Item { name }`, `Fragment "names" cannot be spread here as objects of type "Query" can never be of type "Item".`},
+		{`query A { hello } query A { hello }`, `There can be only one operation named "A".`},
+		{`{ hello } { hello }`, "This anonymous operation must be the only defined operation."},
+		{`subscription { hello }`, "Schema is not configured for subscriptions."},
+	}
+	for _, test := range tests {
+		doc, err := parse(test.query)
+		if err != nil {
+			t.Fatalf("parse(%q): %v", test.query, err)
+		}
+		errs := validate(schema, doc)
+		switch {
+		case test.want == "" && len(errs) > 0:
+			t.Errorf("validate(%q) = %q, want no errors", test.query, errs[0].Message)
+		case test.want != "" && len(errs) == 0:
+			t.Errorf("validate(%q) passed, want %q", test.query, test.want)
+		case test.want != "" && errs[0].Message != test.want:
+			t.Errorf("validate(%q) = %q, want %q", test.query, errs[0].Message, test.want)
+		}
+		for _, err := range errs {
+			if err.Extensions["code"] != CodeValidationFailed {
+				t.Errorf("validate(%q) code = %v", test.query, err.Extensions["code"])
+			}
+		}
+	}
+}
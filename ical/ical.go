@@ -0,0 +1,166 @@
+// ical/ical.go
+
+// Package ical writes iCalendar (RFC 5545) data: components, properties,
+// text escaping and line folding.
+package ical
+
+import (
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	dateTimeLayout = "20060102T150405"
+	dateLayout     = "20060102"
+	maxLineOctets  = 75
+)
+
+type Param struct {
+	Name  string
+	Value string
+}
+
+type Property struct {
+	Name   string
+	Params []Param
+	Value  string
+}
+
+type Component struct {
+	Name       string
+	Properties []Property
+	Components []Component
+}
+
+// Add appends a property whose value is already encoded.
+func (component *Component) Add(name, value string, params ...Param) {
+	component.Properties = append(component.Properties, Property{Name: name, Params: params, Value: value})
+}
+
+// AddText appends a TEXT property, escaping its value.
+func (component *Component) AddText(name, value string, params ...Param) {
+	component.Add(name, EscapeText(value), params...)
+}
+
+// AddTime appends a DATE-TIME property in UTC.
+func (component *Component) AddTime(name string, at time.Time) {
+	component.Add(name, at.UTC().Format(dateTimeLayout)+"Z")
+}
+
+// AddLocalTime appends a DATE-TIME property as wall-clock time in the
+// location, with a TZID parameter unless the location is UTC.
+func (component *Component) AddLocalTime(name string, at time.Time, location *time.Location) {
+	if location == nil || location == time.UTC {
+		component.AddTime(name, at)
+		return
+	}
+	component.Add(name, at.In(location).Format(dateTimeLayout), Param{Name: "TZID", Value: location.String()})
+}
+
+// Get returns the first property with the given name.
+func (component Component) Get(name string) (Property, bool) {
+	for _, property := range component.Properties {
+		if strings.EqualFold(property.Name, name) {
+			return property, true
+		}
+	}
+	return Property{}, false
+}
+
+// Param returns the value of the named parameter.
+func (property Property) Param(name string) string {
+	for _, param := range property.Params {
+		if strings.EqualFold(param.Name, name) {
+			return param.Value
+		}
+	}
+	return ""
+}
+
+// EscapeText escapes a TEXT value.
+func EscapeText(value string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+		"\r", `\n`,
+	).Replace(value)
+}
+
+// Writer writes content lines, folded at 75 octets and ended with CRLF.
+type Writer struct {
+	w   io.Writer
+	err error
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+func (writer *Writer) Begin(name string) error {
+	return writer.line("BEGIN:" + name)
+}
+
+func (writer *Writer) End(name string) error {
+	return writer.line("END:" + name)
+}
+
+func (writer *Writer) Property(property Property) error {
+	var line strings.Builder
+	line.WriteString(property.Name)
+	for _, param := range property.Params {
+		line.WriteString(";" + param.Name + "=" + quoteParam(param.Value))
+	}
+	line.WriteString(":" + property.Value)
+	return writer.line(line.String())
+}
+
+func (writer *Writer) Component(component Component) error {
+	writer.Begin(component.Name)
+	for _, property := range component.Properties {
+		writer.Property(property)
+	}
+	for _, child := range component.Components {
+		writer.Component(child)
+	}
+	return writer.End(component.Name)
+}
+
+func (writer *Writer) line(line string) error {
+	if writer.err == nil {
+		_, writer.err = io.WriteString(writer.w, Fold(line)+"\r\n")
+	}
+	return writer.err
+}
+
+// Fold breaks a content line into lines of at most 75 octets, never inside
+// a UTF-8 sequence. Continuation lines start with a space.
+func Fold(line string) string {
+	if len(line) <= maxLineOctets {
+		return line
+	}
+	var folded strings.Builder
+	limit := maxLineOctets
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		folded.WriteString(line[:cut] + "\r\n ")
+		line = line[cut:]
+		limit = maxLineOctets - 1
+	}
+	folded.WriteString(line)
+	return folded.String()
+}
+
+func quoteParam(value string) string {
+	if strings.ContainsAny(value, ";:,") {
+		return `"` + strings.Replace(value, `"`, "", -1) + `"`
+	}
+	return value
+}
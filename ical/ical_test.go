@@ -0,0 +1,141 @@
+// ical/ical_test.go
+package ical
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestFold(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		line string
+	}{
+		{"short", "SUMMARY:Buy milk"},
+		{"exactly 75 octets", "SUMMARY:" + strings.Repeat("a", 67)},
+		{"ascii", "DESCRIPTION:" + strings.Repeat("abcdefghij", 20)},
+		{"two byte runes", "SUMMARY:" + strings.Repeat("é", 100)},
+		{"three byte runes", "SUMMARY:" + strings.Repeat("日本語", 40)},
+		{"four byte runes", "SUMMARY:x" + strings.Repeat("😀", 50)},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			folded := Fold(test.line)
+			lines := strings.Split(folded, "\r\n")
+			for i, line := range lines {
+				if len(line) > maxLineOctets {
+					t.Errorf("line %d has %d octets", i, len(line))
+				}
+				if i > 0 && !strings.HasPrefix(line, " ") {
+					t.Errorf("continuation line %d does not start with a space", i)
+				}
+				if !utf8.ValidString(line) {
+					t.Errorf("line %d splits a UTF-8 sequence: %q", i, line)
+				}
+			}
+			if len(test.line) <= maxLineOctets && len(lines) != 1 {
+				t.Errorf("folded a line of %d octets", len(test.line))
+			}
+			if unfolded := strings.Replace(folded, "\r\n ", "", -1); unfolded != test.line {
+				t.Errorf("unfolding gives %q, want %q", unfolded, test.line)
+			}
+		})
+	}
+}
+
+func TestEscapeText(t *testing.T) {
+	for value, want := range map[string]string{
+		"plain":                `plain`,
+		"milk, eggs; bread":    `milk\, eggs\; bread`,
+		`C:\temp`:              `C:\\temp`,
+		`\,`:                   `\\\,`,
+		"first\nsecond":        `first\nsecond`,
+		"first\r\nsecond\rend": `first\nsecond\nend`,
+	} {
+		if got := EscapeText(value); got != want {
+			t.Errorf("EscapeText(%q) = %s, want %s", value, got, want)
+		}
+	}
+}
+
+func TestWriterProperty(t *testing.T) {
+	var out bytes.Buffer
+	writer := NewWriter(&out)
+	component := Component{Name: "VTODO"}
+	component.AddText("SUMMARY", "Call Ana, then Bob")
+	component.Add("X-LABEL", "v", Param{Name: "X-NOTE", Value: "a;b"})
+	location, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Skip(err)
+	}
+	component.AddLocalTime("DUE", time.Date(2024, time.June, 1, 7, 0, 0, 0, time.UTC), location)
+	component.AddLocalTime("DTSTAMP", time.Date(2024, time.June, 1, 7, 0, 0, 0, time.UTC), time.UTC)
+	if err := writer.Component(component); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "BEGIN:VTODO\r\n" +
+		"SUMMARY:Call Ana\\, then Bob\r\n" +
+		"X-LABEL;X-NOTE=\"a;b\":v\r\n" +
+		"DUE;TZID=Europe/Paris:20240601T090000\r\n" +
+		"DTSTAMP:20240601T070000Z\r\n" +
+		"END:VTODO\r\n"
+	if out.String() != want {
+		t.Errorf("wrote\n%q\nwant\n%q", out.String(), want)
+	}
+}
+
+func TestTimezone(t *testing.T) {
+	location, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	from := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	timezone := Timezone(location, from, from.AddDate(2, 0, 0))
+
+	if tzid, _ := timezone.Get("TZID"); tzid.Value != "America/New_York" {
+		t.Errorf("TZID %q", tzid.Value)
+	}
+	if len(timezone.Components) != 2 {
+		t.Fatalf("%d observances, want daylight and standard", len(timezone.Components))
+	}
+	want := map[string][4]string{
+		// DTSTART is the local time before the change.
+		"DAYLIGHT": {"20240310T020000", "-0500", "-0400", "EDT"},
+		"STANDARD": {"20241103T020000", "-0400", "-0500", "EST"},
+	}
+	for _, observance := range timezone.Components {
+		expected, ok := want[observance.Name]
+		if !ok {
+			t.Errorf("unexpected %s observance", observance.Name)
+			continue
+		}
+		for i, name := range []string{"DTSTART", "TZOFFSETFROM", "TZOFFSETTO", "TZNAME"} {
+			if property, _ := observance.Get(name); property.Value != expected[i] {
+				t.Errorf("%s %s = %q, want %q", observance.Name, name, property.Value, expected[i])
+			}
+		}
+		if rdate, ok := observance.Get("RDATE"); !ok || !strings.HasPrefix(rdate.Value, "2025") {
+			t.Errorf("%s lists no change in 2025", observance.Name)
+		}
+	}
+}
+
+func TestTimezoneWithoutChanges(t *testing.T) {
+	location, err := time.LoadLocation("Asia/Kolkata")
+	if err != nil {
+		t.Skip(err)
+	}
+	from := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	timezone := Timezone(location, from, from.AddDate(1, 0, 0))
+	if len(timezone.Components) != 1 || timezone.Components[0].Name != "STANDARD" {
+		t.Fatalf("observances %+v, want a single standard one", timezone.Components)
+	}
+	for name, want := range map[string]string{"TZOFFSETFROM": "+0530", "TZOFFSETTO": "+0530", "TZNAME": "IST"} {
+		if property, _ := timezone.Components[0].Get(name); property.Value != want {
+			t.Errorf("%s = %q, want %q", name, property.Value, want)
+		}
+	}
+}
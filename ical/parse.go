@@ -0,0 +1,154 @@
+// ical/parse.go
+package ical
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Parse reads one iCalendar object, such as a VCALENDAR, unfolding its
+// content lines.
+func Parse(r io.Reader) (Component, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return Component{}, err
+	}
+
+	var stack []Component
+	for i, line := range lines {
+		if line == "" {
+			continue
+		}
+		property, err := parseLine(line)
+		if err != nil {
+			return Component{}, fmt.Errorf("line %d: %v", i+1, err)
+		}
+
+		switch strings.ToUpper(property.Name) {
+		case "BEGIN":
+			stack = append(stack, Component{Name: strings.ToUpper(property.Value)})
+		case "END":
+			if len(stack) == 0 || stack[len(stack)-1].Name != strings.ToUpper(property.Value) {
+				return Component{}, fmt.Errorf("line %d: unexpected END:%s", i+1, property.Value)
+			}
+			done := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if len(stack) == 0 {
+				return done, nil
+			}
+			stack[len(stack)-1].Components = append(stack[len(stack)-1].Components, done)
+		default:
+			if len(stack) == 0 {
+				return Component{}, fmt.Errorf("line %d: property outside a component", i+1)
+			}
+			stack[len(stack)-1].Properties = append(stack[len(stack)-1].Properties, property)
+		}
+	}
+	return Component{}, errors.New("unexpected end of calendar data")
+}
+
+func unfold(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, scanner.Err()
+}
+
+func parseLine(line string) (Property, error) {
+	var property Property
+	i := strings.IndexAny(line, ";:")
+	if i <= 0 {
+		return property, errors.New("malformed content line")
+	}
+	property.Name = strings.ToUpper(line[:i])
+	rest := line[i:]
+
+	for strings.HasPrefix(rest, ";") {
+		rest = rest[1:]
+		eq := strings.IndexByte(rest, '=')
+		if eq <= 0 {
+			return property, errors.New("malformed parameter")
+		}
+		param := Param{Name: strings.ToUpper(rest[:eq])}
+		rest = rest[eq+1:]
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.IndexByte(rest[1:], '"')
+			if end < 0 {
+				return property, errors.New("unterminated parameter value")
+			}
+			param.Value, rest = rest[1:end+1], rest[end+2:]
+		} else {
+			end := strings.IndexAny(rest, ";:")
+			if end < 0 {
+				return property, errors.New("malformed parameter")
+			}
+			param.Value, rest = rest[:end], rest[end:]
+		}
+		property.Params = append(property.Params, param)
+	}
+
+	if !strings.HasPrefix(rest, ":") {
+		return property, errors.New("missing property value")
+	}
+	property.Value = rest[1:]
+	return property, nil
+}
+
+// UnescapeText reverses EscapeText.
+func UnescapeText(value string) string {
+	var text strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' && i+1 < len(value) {
+			i++
+			switch value[i] {
+			case 'n', 'N':
+				text.WriteByte('\n')
+			default:
+				text.WriteByte(value[i])
+			}
+			continue
+		}
+		text.WriteByte(value[i])
+	}
+	return text.String()
+}
+
+// Text returns the unescaped value of a TEXT property.
+func (property Property) Text() string {
+	return UnescapeText(property.Value)
+}
+
+// Time parses a DATE or DATE-TIME property. Times in UTC or with a TZID
+// that cannot be loaded come back in UTC, as do floating times; the
+// location is nil unless the TZID was loaded.
+func (property Property) Time() (time.Time, *time.Location, error) {
+	value := property.Value
+	if strings.EqualFold(property.Param("VALUE"), "DATE") || len(value) == len(dateLayout) {
+		at, err := time.Parse(dateLayout, value)
+		return at, nil, err
+	}
+	if strings.HasSuffix(value, "Z") {
+		at, err := time.Parse(dateTimeLayout, strings.TrimSuffix(value, "Z"))
+		return at, nil, err
+	}
+	if tzid := property.Param("TZID"); tzid != "" {
+		if location, err := time.LoadLocation(strings.TrimPrefix(tzid, "/")); err == nil {
+			at, err := time.ParseInLocation(dateTimeLayout, value, location)
+			return at.UTC(), location, err
+		}
+	}
+	at, err := time.Parse(dateTimeLayout, value)
+	return at, nil, err
+}
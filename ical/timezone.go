@@ -0,0 +1,113 @@
+// ical/timezone.go
+package ical
+
+import (
+	"fmt"
+	"time"
+)
+
+// Timezone builds the VTIMEZONE component for a location, listing each
+// offset change between from and to. The TZID is the location's name, which
+// is what AddLocalTime refers to.
+func Timezone(location *time.Location, from, to time.Time) Component {
+	timezone := Component{Name: "VTIMEZONE"}
+	timezone.Add("TZID", location.String())
+
+	transitions := transitionsBetween(location, from, to)
+	if len(transitions) == 0 {
+		name, offset := from.In(location).Zone()
+		timezone.Components = append(timezone.Components, observance("STANDARD", name, offset, offset, []time.Time{time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)}))
+		return timezone
+	}
+
+	// The smallest offset in use is taken to be standard time.
+	standard := transitions[0].to
+	for _, transition := range transitions {
+		if transition.to < standard {
+			standard = transition.to
+		}
+		if transition.from < standard {
+			standard = transition.from
+		}
+	}
+
+	type key struct {
+		name     string
+		from, to int
+	}
+	var order []key
+	starts := map[key][]time.Time{}
+	for _, transition := range transitions {
+		k := key{transition.name, transition.from, transition.to}
+		if _, ok := starts[k]; !ok {
+			order = append(order, k)
+		}
+		// Observance start times are written in the local time before the change.
+		starts[k] = append(starts[k], transition.at.In(time.FixedZone("", transition.from)))
+	}
+	for _, k := range order {
+		kind := "DAYLIGHT"
+		if k.to == standard {
+			kind = "STANDARD"
+		}
+		timezone.Components = append(timezone.Components, observance(kind, k.name, k.from, k.to, starts[k]))
+	}
+	return timezone
+}
+
+type transition struct {
+	at       time.Time
+	name     string
+	from, to int
+}
+
+// transitionsBetween finds the offset changes of a location by stepping a
+// day at a time and narrowing each change down to the second.
+func transitionsBetween(location *time.Location, from, to time.Time) []transition {
+	var transitions []transition
+	_, offset := from.In(location).Zone()
+	for day := from; day.Before(to); day = day.Add(24 * time.Hour) {
+		next := day.Add(24 * time.Hour)
+		name, nextOffset := next.In(location).Zone()
+		if nextOffset == offset {
+			continue
+		}
+		low, high := day, next
+		for high.Sub(low) > time.Second {
+			middle := low.Add(high.Sub(low) / 2)
+			if _, o := middle.In(location).Zone(); o == offset {
+				low = middle
+			} else {
+				high = middle
+			}
+		}
+		transitions = append(transitions, transition{at: high.Truncate(time.Second), name: name, from: offset, to: nextOffset})
+		offset = nextOffset
+	}
+	return transitions
+}
+
+func observance(kind, name string, from, to int, starts []time.Time) Component {
+	component := Component{Name: kind}
+	component.Add("DTSTART", starts[0].Format(dateTimeLayout))
+	for _, start := range starts[1:] {
+		component.Add("RDATE", start.Format(dateTimeLayout))
+	}
+	component.Add("TZOFFSETFROM", formatOffset(from))
+	component.Add("TZOFFSETTO", formatOffset(to))
+	if name != "" {
+		component.AddText("TZNAME", name)
+	}
+	return component
+}
+
+func formatOffset(seconds int) string {
+	sign := "+"
+	if seconds < 0 {
+		sign, seconds = "-", -seconds
+	}
+	if seconds%60 != 0 {
+		return fmt.Sprintf("%s%02d%02d%02d", sign, seconds/3600, seconds/60%60, seconds%60)
+	}
+	return fmt.Sprintf("%s%02d%02d", sign, seconds/3600, seconds/60%60)
+}
@@ -0,0 +1,257 @@
+// idempotency/handlers.go
+package idempotency
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/imadbg01/go-todo/auth"
+	"github.com/imadbg01/go-todo/bodylimit"
+	"github.com/imadbg01/go-todo/config"
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	// Header is the request header carrying the key.
+	Header = "Idempotency-Key"
+	// maxKeyLength bounds the keys clients may send.
+	maxKeyLength = 255
+	// lockTimeout is how long a request may go without renewing its key
+	// before another attempt takes over.
+	lockTimeout = time.Minute
+)
+
+// stored are the response headers replayed with the body.
+var stored = []string{fiber.HeaderContentType, fiber.HeaderETag, fiber.HeaderLocation}
+
+var unsafe = map[string]bool{
+	fiber.MethodPost:   true,
+	fiber.MethodPut:    true,
+	fiber.MethodPatch:  true,
+	fiber.MethodDelete: true,
+}
+
+// TTL is how long keys are remembered, from IDEMPOTENCY_TTL.
+func TTL() time.Duration {
+	ttl, err := time.ParseDuration(config.ConfigOr("IDEMPOTENCY_TTL", "24h"))
+	if err != nil || ttl <= 0 {
+		return 24 * time.Hour
+	}
+	return ttl
+}
+
+type IdempotencyHandler struct {
+	repository *IdempotencyRepository
+	ttl        time.Duration
+	// lockTimeout is renewed while the request runs, a third of it at a
+	// time, so only requests that stopped lose their key.
+	lockTimeout time.Duration
+}
+
+// Handle serves requests to unsafe methods that carry an Idempotency-Key
+// once per key. Retries get the stored response, with Idempotent-Replayed
+// set; a retry while the first attempt is still served gets 409, and a key
+// reused for another request gets 422. Failures with a 5xx status are not
+// stored, so that they can be retried.
+func (handler *IdempotencyHandler) Handle(c *fiber.Ctx) error {
+	key := c.Get(Header)
+	if key == "" || !unsafe[c.Method()] {
+		return c.Next()
+	}
+	if len(key) > maxKeyLength {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  400,
+			"message": "Idempotency-Key is longer than 255 characters",
+		})
+	}
+
+	sum, cleanup, err := fingerprint(c)
+	if err != nil {
+		c.Context().SetConnectionClose()
+		return c.Status(400).JSON(fiber.Map{
+			"status":  400,
+			"message": "Failed reading the request body",
+			"error":   err.Error(),
+		})
+	}
+	defer cleanup()
+	record, claimed, err := handler.repository.Begin(Record{
+		Key:         auth.Actor(c) + ":" + key,
+		Fingerprint: sum,
+		ExpiresAt:   time.Now().Add(handler.ttl),
+	}, handler.lockTimeout)
+	if err != nil {
+		log.Printf("idempotency: failed claiming key: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"status":  500,
+			"message": "Failed checking Idempotency-Key",
+			"error":   err.Error(),
+		})
+	}
+
+	if !claimed {
+		switch {
+		case record.Fingerprint != sum:
+			return c.Status(422).JSON(fiber.Map{
+				"status":  422,
+				"message": "Idempotency-Key was used for a different request",
+			})
+		case !record.Completed:
+			c.Set(fiber.HeaderRetryAfter, "1")
+			return c.Status(409).JSON(fiber.Map{
+				"status":  409,
+				"message": "A request with this Idempotency-Key is in progress",
+			})
+		}
+		return replay(c, record)
+	}
+
+	release := handler.hold(record)
+	err = c.Next()
+	release()
+	if err != nil {
+		handler.release(record.Key)
+		return err
+	}
+	response := c.Response()
+	if response.StatusCode() >= 500 || response.IsBodyStream() {
+		handler.release(record.Key)
+		return nil
+	}
+
+	headers := map[string]string{}
+	for _, name := range stored {
+		if value := c.GetRespHeader(name); value != "" {
+			headers[name] = value
+		}
+	}
+	encoded, _ := json.Marshal(headers)
+	if err := handler.repository.Complete(record.Key, response.StatusCode(), string(encoded), response.Body()); err != nil {
+		log.Printf("idempotency: failed storing response: %v", err)
+	}
+	return nil
+}
+
+// hold renews the lock on the key of record until the returned function is
+// called, so that a request running longer than the lock timeout is not
+// taken over by a retry and served twice.
+func (handler *IdempotencyHandler) hold(record Record) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(handler.lockTimeout / 3)
+		defer ticker.Stop()
+		lockedAt := record.LockedAt
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				renewed, err := handler.repository.Renew(record.Key, lockedAt)
+				if err != nil {
+					log.Printf("idempotency: failed renewing lock: %v", err)
+					continue
+				}
+				lockedAt = renewed
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+func (handler *IdempotencyHandler) release(key string) {
+	if err := handler.repository.Release(key); err != nil {
+		log.Printf("idempotency: failed releasing key: %v", err)
+	}
+}
+
+func replay(c *fiber.Ctx, record Record) error {
+	headers := map[string]string{}
+	json.Unmarshal([]byte(record.Headers), &headers)
+	for name, value := range headers {
+		c.Set(name, value)
+	}
+	c.Set("Idempotent-Replayed", "true")
+	return c.Status(record.Status).Send(record.Body)
+}
+
+// fingerprint identifies the request a key was first used for, by its
+// method, path and body. A streamed body is read to a temporary file as it
+// is hashed, and the next handlers read the file instead; cleanup removes
+// it.
+func fingerprint(c *fiber.Ctx) (sum string, cleanup func(), err error) {
+	hash := sha256.New()
+	hash.Write([]byte(c.Method() + " " + c.Path() + "\n"))
+	if c.Context().RequestBodyStream() == nil {
+		hash.Write(c.Body())
+		return hex.EncodeToString(hash.Sum(nil)), func() {}, nil
+	}
+
+	file, err := ioutil.TempFile("", "idempotency-")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup = func() {
+		file.Close()
+		os.Remove(file.Name())
+	}
+	if _, err := io.Copy(io.MultiWriter(file, hash), bodylimit.Reader(c)); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	bodylimit.Replace(c, file)
+	return hex.EncodeToString(hash.Sum(nil)), cleanup, nil
+}
+
+func NewIdempotencyHandler(repository *IdempotencyRepository) *IdempotencyHandler {
+	return &IdempotencyHandler{
+		repository:  repository,
+		ttl:         TTL(),
+		lockTimeout: lockTimeout,
+	}
+}
+
+// New returns the middleware that stores the responses to requests with an
+// Idempotency-Key.
+func New(database *gorm.DB) fiber.Handler {
+	database.AutoMigrate(&Record{})
+	idempotencyRepository := NewIdempotencyRepository(database)
+	idempotencyHandler := NewIdempotencyHandler(idempotencyRepository)
+
+	return idempotencyHandler.Handle
+}
+
+// Start sweeps the expired keys every hour, until the context is done.
+func Start(ctx context.Context, database *gorm.DB) {
+	database.AutoMigrate(&Record{})
+	idempotencyRepository := NewIdempotencyRepository(database)
+
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				idempotencyRepository.Sweep()
+			}
+		}
+	}()
+}
@@ -0,0 +1,182 @@
+// idempotency/handlers_test.go
+package idempotency
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/imadbg01/go-todo/bodylimit"
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+)
+
+// serve runs an app streaming request bodies like main does, behind the
+// middleware. /echo answers the body it read, /import reads it as a stream,
+// /slow takes longer than the lock timeout, and /fail fails. Every route
+// counts the requests it served in served.
+func serve(t *testing.T, lockTimeout time.Duration) (string, *int32) {
+	database, err := gorm.Open("sqlite3", filepath.Join(t.TempDir(), "idempotency.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	database.DB().SetMaxOpenConns(1)
+	t.Cleanup(func() { database.Close() })
+	database.AutoMigrate(&Record{})
+	handler := NewIdempotencyHandler(NewIdempotencyRepository(database))
+	handler.lockTimeout = lockTimeout
+
+	app := fiber.New(fiber.Config{
+		StreamRequestBody:            true,
+		DisablePreParseMultipartForm: true,
+		DisableStartupMessage:        true,
+	})
+	app.Use(bodylimit.New(bodylimit.Config{
+		Limit: 1 << 20,
+		Stream: func(c *fiber.Ctx) bool {
+			return c.Path() == "/import"
+		},
+	}))
+	app.Use(handler.Handle)
+
+	var served int32
+	app.Post("/echo", func(c *fiber.Ctx) error {
+		atomic.AddInt32(&served, 1)
+		return c.Status(201).Send(c.Body())
+	})
+	app.Post("/import", func(c *fiber.Ctx) error {
+		atomic.AddInt32(&served, 1)
+		body, err := ioutil.ReadAll(bodylimit.Reader(c))
+		if err != nil {
+			return err
+		}
+		return c.SendString(strconv.Itoa(len(body)) + ":" + string(body))
+	})
+	app.Post("/slow", func(c *fiber.Ctx) error {
+		atomic.AddInt32(&served, 1)
+		time.Sleep(3 * lockTimeout)
+		return c.SendString("done")
+	})
+	app.Post("/fail", func(c *fiber.Ctx) error {
+		atomic.AddInt32(&served, 1)
+		return c.SendStatus(503)
+	})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go app.Listener(listener)
+	t.Cleanup(func() { app.Shutdown() })
+	return "http://" + listener.Addr().String(), &served
+}
+
+// post sends body with the key. A length of -1 sends the body chunked.
+func post(t *testing.T, url, key string, body io.Reader, length int64) (*http.Response, string) {
+	request, err := http.NewRequest("POST", url, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request.ContentLength = length
+	if key != "" {
+		request.Header.Set(Header, key)
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	data, _ := ioutil.ReadAll(response.Body)
+	return response, string(data)
+}
+
+func postString(t *testing.T, url, key, body string) (*http.Response, string) {
+	return post(t, url, key, strings.NewReader(body), int64(len(body)))
+}
+
+func TestReplay(t *testing.T) {
+	base, served := serve(t, time.Minute)
+
+	first, body := postString(t, base+"/echo", "k1", "hello")
+	if first.StatusCode != 201 || body != "hello" || first.Header.Get("Idempotent-Replayed") != "" {
+		t.Fatalf("first attempt = %d %q", first.StatusCode, body)
+	}
+	retry, body := postString(t, base+"/echo", "k1", "hello")
+	if retry.StatusCode != 201 || body != "hello" || retry.Header.Get("Idempotent-Replayed") != "true" {
+		t.Fatalf("retry = %d %q, want the stored response replayed", retry.StatusCode, body)
+	}
+	if other, _ := postString(t, base+"/echo", "k1", "hullo"); other.StatusCode != 422 {
+		t.Errorf("key reused for another body = %d, want 422", other.StatusCode)
+	}
+	if _, body := postString(t, base+"/echo", "", "hello"); body != "hello" {
+		t.Errorf("request without a key answered %q", body)
+	}
+	if atomic.LoadInt32(served) != 2 {
+		t.Errorf("served %d requests, want the keyed one once and the other", atomic.LoadInt32(served))
+	}
+}
+
+func TestFailuresAreNotStored(t *testing.T) {
+	base, served := serve(t, time.Minute)
+	for i := 0; i < 2; i++ {
+		if response, _ := postString(t, base+"/fail", "k1", ""); response.StatusCode != 503 {
+			t.Fatalf("attempt %d = %d, want 503", i, response.StatusCode)
+		}
+	}
+	if atomic.LoadInt32(served) != 2 {
+		t.Errorf("served %d attempts of a failing request, want both", atomic.LoadInt32(served))
+	}
+}
+
+// TestStreamedBodies checks that streamed bodies are told apart by their
+// content, not their length, and still reach the handler whole.
+func TestStreamedBodies(t *testing.T) {
+	base, served := serve(t, time.Minute)
+
+	response, body := postString(t, base+"/import", "k1", "first")
+	if response.StatusCode != 200 || body != "5:first" {
+		t.Fatalf("import = %d %q", response.StatusCode, body)
+	}
+	if response, _ := postString(t, base+"/import", "k1", "other"); response.StatusCode != 422 {
+		t.Errorf("key reused for another body of the same length = %d, want 422", response.StatusCode)
+	}
+	chunked := io.MultiReader(strings.NewReader("fir"), strings.NewReader("st"))
+	response, body = post(t, base+"/import", "k1", chunked, -1)
+	if response.Header.Get("Idempotent-Replayed") != "true" || body != "5:first" {
+		t.Errorf("chunked retry = %d %q, want the stored response", response.StatusCode, body)
+	}
+	if atomic.LoadInt32(served) != 1 {
+		t.Errorf("served %d imports, want 1", atomic.LoadInt32(served))
+	}
+}
+
+// TestLockIsRenewed checks that a retry sent while a request runs past the
+// lock timeout waits for it instead of being served again.
+func TestLockIsRenewed(t *testing.T) {
+	lockTimeout := 300 * time.Millisecond
+	base, served := serve(t, lockTimeout)
+
+	done := make(chan string)
+	go func() {
+		_, body := postString(t, base+"/slow", "k1", "")
+		done <- body
+	}()
+	time.Sleep(2 * lockTimeout)
+	if retry, _ := postString(t, base+"/slow", "k1", ""); retry.StatusCode != 409 {
+		t.Errorf("retry while the first attempt runs = %d, want 409", retry.StatusCode)
+	}
+	if body := <-done; body != "done" {
+		t.Errorf("first attempt answered %q", body)
+	}
+	if atomic.LoadInt32(served) != 1 {
+		t.Errorf("served %d attempts, want 1", atomic.LoadInt32(served))
+	}
+}
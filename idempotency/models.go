@@ -0,0 +1,28 @@
+// idempotency/models.go
+// Package idempotency makes retried writes safe. A client sends the same
+// Idempotency-Key header with every attempt of a request; the response to
+// the first attempt is stored and replayed to the others.
+package idempotency
+
+import "time"
+
+// Record is the stored response to the request made with a key. It is
+// pending, and locked by the request being served, until Completed.
+type Record struct {
+	// Key is the key of the client prefixed with the user who sent it, so
+	// users cannot replay each other's responses.
+	Key string `gorm:"primary_key"`
+	// Fingerprint identifies the method, path and body of the request.
+	Fingerprint string `gorm:"Not Null"`
+	Status      int
+	Headers     string `gorm:"type:text"`
+	Body        []byte
+	Completed   bool
+	LockedAt    time.Time
+	ExpiresAt   time.Time `gorm:"index"`
+	CreatedAt   time.Time
+}
+
+func (Record) TableName() string {
+	return "idempotency_keys"
+}
@@ -0,0 +1,109 @@
+// idempotency/repositories.go
+package idempotency
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// errLockLost is returned by Renew when another request took the key over.
+var errLockLost = errors.New("lock on the key was taken over")
+
+type IdempotencyRepository struct {
+	database *gorm.DB
+}
+
+// Begin claims the key of record for a request. It returns true when the
+// request should be served, and otherwise the record stored for the key.
+// A pending record whose lock is older than lockTimeout was left by a
+// request that never finished, and is claimed again by a request with the
+// same fingerprint.
+func (repository *IdempotencyRepository) Begin(record Record, lockTimeout time.Duration) (Record, bool, error) {
+	// Postgres keeps microseconds, and the lock is compared with what it
+	// stored.
+	now := time.Now().Round(time.Microsecond)
+	result := repository.database.Exec(
+		"INSERT INTO idempotency_keys (key, fingerprint, status, headers, body, completed, locked_at, expires_at, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT (key) DO NOTHING",
+		record.Key, record.Fingerprint, 0, "", []byte{}, false, now, record.ExpiresAt, now,
+	)
+	if result.Error != nil {
+		return record, false, result.Error
+	}
+	if result.RowsAffected == 1 {
+		record.LockedAt = now
+		return record, true, nil
+	}
+
+	var existing Record
+	if err := repository.database.Where("key = ?", record.Key).First(&existing).Error; err != nil {
+		return record, false, err
+	}
+	if !existing.ExpiresAt.After(now) {
+		err := repository.database.Where("key = ? AND expires_at <= ?", record.Key, now).Delete(&Record{}).Error
+		if err != nil {
+			return record, false, err
+		}
+		return repository.Begin(record, lockTimeout)
+	}
+	if !existing.Completed && existing.Fingerprint == record.Fingerprint && now.Sub(existing.LockedAt) > lockTimeout {
+		result := repository.database.Model(&Record{}).
+			Where("key = ? AND completed = ? AND locked_at = ?", existing.Key, false, existing.LockedAt).
+			UpdateColumn("locked_at", now)
+		if result.Error != nil {
+			return record, false, result.Error
+		}
+		if result.RowsAffected == 1 {
+			existing.LockedAt = now
+			return existing, true, nil
+		}
+	}
+	return existing, false, nil
+}
+
+// Renew moves the lock on the pending key taken at lockedAt to now, and
+// returns the new time of the lock.
+func (repository *IdempotencyRepository) Renew(key string, lockedAt time.Time) (time.Time, error) {
+	now := time.Now().Round(time.Microsecond)
+	result := repository.database.Model(&Record{}).
+		Where("key = ? AND completed = ? AND locked_at = ?", key, false, lockedAt).
+		UpdateColumn("locked_at", now)
+	if result.Error != nil {
+		return lockedAt, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return lockedAt, errLockLost
+	}
+	return now, nil
+}
+
+// Complete stores the response to the request that claimed key.
+func (repository *IdempotencyRepository) Complete(key string, status int, headers string, body []byte) error {
+	return repository.database.Model(&Record{}).Where("key = ?", key).UpdateColumns(map[string]interface{}{
+		"status":    status,
+		"headers":   headers,
+		"body":      body,
+		"completed": true,
+	}).Error
+}
+
+// Release forgets a pending key, so that the request can be tried again.
+func (repository *IdempotencyRepository) Release(key string) error {
+	return repository.database.Where("key = ? AND completed = ?", key, false).Delete(&Record{}).Error
+}
+
+// Sweep deletes the records that expired.
+func (repository *IdempotencyRepository) Sweep() {
+	err := repository.database.Where("expires_at <= ?", time.Now()).Delete(&Record{}).Error
+	if err != nil {
+		log.Printf("idempotency: sweep failed: %v", err)
+	}
+}
+
+func NewIdempotencyRepository(database *gorm.DB) *IdempotencyRepository {
+	return &IdempotencyRepository{
+		database: database,
+	}
+}
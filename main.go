@@ -2,22 +2,36 @@
 package main
 
 import (
+	"context"
 	"log"
+	"os"
+	"os/signal"
 
-	"github.com/gofiber/fiber/v2"
-	"github.com/gofiber/fiber/v2/middleware/cors"
+	"github.com/imadbg01/go-todo/attachment"
 	"github.com/imadbg01/go-todo/database"
-	"github.com/imadbg01/go-todo/todo"
+	"github.com/imadbg01/go-todo/idempotency"
+	"github.com/imadbg01/go-todo/openapi"
+	"github.com/imadbg01/go-todo/outbox"
+	"github.com/imadbg01/go-todo/rpc"
+	"github.com/imadbg01/go-todo/server"
 )
 
 func main() {
-	app := fiber.New()
-	app.Use(cors.New())
 	database.ConnectDB()
 	defer database.DB.Close()
 
-	api := app.Group("/api")
-	todo.Register(api, database.DB)
+	outbox.Start(database.DB)
+	app, hub := server.New(database.DB, database.DSN)
+	rpc.Start(database.DB, hub)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	attachment.Start(ctx, database.DB)
+	idempotency.Start(ctx, database.DB)
+
+	for _, err := range openapi.Check(app) {
+		log.Printf("openapi: %v", err)
+	}
 
 	log.Fatal(app.Listen(":5000"))
 }
@@ -0,0 +1,96 @@
+// openapi/describe.go
+package openapi
+
+import (
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Doc describes what a handler reads and answers, beyond the method and
+// path of its route. Body and Result are values whose types are the JSON
+// bodies; raw bodies are given by media type instead.
+type Doc struct {
+	Summary     string
+	Description string
+	Tags        []string
+	Query       []*Parameter
+	Headers     []*Parameter
+	Body        interface{}
+	BodyTypes   []string
+	Result      interface{}
+	ResultTypes []string
+	// Status is the status of a successful response, 200 when zero.
+	Status int
+	// Errors lists the error statuses the handler answers with.
+	Errors []int
+	// Hidden leaves the handler's routes out of the document, for routes
+	// that speak another protocol than JSON over HTTP.
+	Hidden bool
+}
+
+var (
+	docsMu     sync.RWMutex
+	docs       = map[string]Doc{}
+	deprecated = map[string]bool{}
+)
+
+// Describe documents a handler. Every route served by the handler gets the
+// description, so it is written once however often the handler is mounted.
+func Describe(handler fiber.Handler, doc Doc) {
+	docsMu.Lock()
+	defer docsMu.Unlock()
+	docs[handlerName(handler)] = doc
+}
+
+// Deprecate marks the operation of a route deprecated, for routes that are
+// deprecated whatever handler serves them.
+func Deprecate(method, path string) {
+	docsMu.Lock()
+	defer docsMu.Unlock()
+	deprecated[method+" "+templatePath(path)] = true
+}
+
+func isDeprecated(method, path string) bool {
+	docsMu.RLock()
+	defer docsMu.RUnlock()
+	return deprecated[method+" "+templatePath(path)]
+}
+
+func lookup(handler fiber.Handler) (Doc, bool) {
+	docsMu.RLock()
+	defer docsMu.RUnlock()
+	doc, ok := docs[handlerName(handler)]
+	return doc, ok
+}
+
+// handlerName identifies a handler by its function, which is the same for
+// every method value of one method, such as todoHandler.Create.
+func handlerName(handler fiber.Handler) string {
+	return runtime.FuncForPC(reflect.ValueOf(handler).Pointer()).Name()
+}
+
+// operationID makes an ID such as todoCreate from a handler name such as
+// github.com/imadbg01/go-todo/todo.(*TodoHandler).Create-fm.
+func operationID(name string) string {
+	name = strings.TrimSuffix(name[strings.LastIndex(name, "/")+1:], "-fm")
+	parts := strings.Split(name, ".")
+	id := parts[0]
+	if len(parts) > 1 {
+		id += strings.Title(parts[len(parts)-1])
+	}
+	return id
+}
+
+// tag is the package a handler belongs to, the default tag of its
+// operations.
+func tag(name string) string {
+	name = name[strings.LastIndex(name, "/")+1:]
+	if dot := strings.Index(name, "."); dot >= 0 {
+		name = name[:dot]
+	}
+	return name
+}
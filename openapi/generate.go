@@ -0,0 +1,244 @@
+// openapi/generate.go
+package openapi
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+// documented are the methods that appear in the document. Fiber adds a HEAD
+// route for every GET, which is left out.
+var documented = map[string]bool{
+	fiber.MethodGet:    true,
+	fiber.MethodPost:   true,
+	fiber.MethodPut:    true,
+	fiber.MethodPatch:  true,
+	fiber.MethodDelete: true,
+}
+
+var pathParam = regexp.MustCompile(`:([A-Za-z0-9_]+)\??`)
+
+// pathVersion finds the version in a path such as /api/v2/todo.
+var pathVersion = regexp.MustCompile(`/(v[0-9]+)(/|$)`)
+
+type route struct {
+	method  string
+	path    string
+	params  []string
+	handler fiber.Handler
+}
+
+// routes lists the documented routes of the app with the handler that
+// serves each, the last of its handlers.
+func routes(app *fiber.App) []route {
+	var list []route
+	seen := map[string]bool{}
+	for _, stack := range app.Stack() {
+		for _, r := range stack {
+			key := r.Method + " " + r.Path
+			if !documented[r.Method] || seen[key] || len(r.Handlers) == 0 || middleware(r) {
+				continue
+			}
+			seen[key] = true
+			list = append(list, route{
+				method:  r.Method,
+				path:    r.Path,
+				params:  r.Params,
+				handler: r.Handlers[len(r.Handlers)-1],
+			})
+		}
+	}
+	return list
+}
+
+// middleware tells whether r was added with Use. Fiber keeps that in an
+// unexported field, and lists such routes under every method.
+func middleware(r *fiber.Route) bool {
+	use := reflect.ValueOf(r).Elem().FieldByName("use")
+	return use.IsValid() && use.Bool()
+}
+
+func apiVersion(path string) string {
+	if match := pathVersion.FindStringSubmatch(path); match != nil {
+		return match[1]
+	}
+	return ""
+}
+
+// templatePath writes a Fiber path such as /todo/:id/ as /todo/{id}.
+func templatePath(path string) string {
+	if len(path) > 1 {
+		path = strings.TrimRight(path, "/")
+	}
+	return pathParam.ReplaceAllString(path, "{$1}")
+}
+
+// Generate builds the document of the routes registered with app.
+func Generate(app *fiber.App, info Info) *Document {
+	s := newSchemas()
+	s.components["Error"] = errorSchema()
+	document := &Document{
+		OpenAPI:    Version,
+		Info:       info,
+		Paths:      map[string]PathItem{},
+		Components: Components{Schemas: s.components},
+	}
+
+	// A handler serving several routes of one version gets IDs suffixed with
+	// the method, and routes of a version get IDs prefixed with it.
+	list := routes(app)
+	uses := map[string]int{}
+	for _, r := range list {
+		uses[apiVersion(r.path)+handlerName(r.handler)]++
+	}
+
+	for _, r := range list {
+		doc, described := lookup(r.handler)
+		if doc.Hidden {
+			continue
+		}
+		operation := newOperation(s, r, doc, described)
+		if uses[apiVersion(r.path)+handlerName(r.handler)] > 1 {
+			operation.OperationID += strings.Title(strings.ToLower(r.method))
+		}
+		if version := apiVersion(r.path); version != "" {
+			operation.OperationID = version + strings.Title(operation.OperationID)
+		}
+		operation.Deprecated = isDeprecated(r.method, r.path)
+
+		path := templatePath(r.path)
+		if document.Paths[path] == nil {
+			document.Paths[path] = PathItem{}
+		}
+		document.Paths[path][strings.ToLower(r.method)] = operation
+	}
+	return document
+}
+
+func newOperation(s *schemas, r route, doc Doc, described bool) *Operation {
+	name := handlerName(r.handler)
+	operation := &Operation{
+		OperationID: operationID(name),
+		Summary:     doc.Summary,
+		Description: doc.Description,
+		Tags:        doc.Tags,
+		Responses:   map[string]*Response{},
+	}
+	if len(operation.Tags) == 0 {
+		operation.Tags = []string{tag(name)}
+	}
+
+	for _, param := range r.params {
+		if strings.HasPrefix(param, "*") || strings.HasPrefix(param, "+") {
+			continue
+		}
+		schema := Type("string")
+		if param == "id" || strings.HasSuffix(param, "Id") {
+			schema = Type("integer")
+		}
+		operation.Parameters = append(operation.Parameters, &Parameter{Name: param, In: "path", Required: true, Schema: schema})
+	}
+	for _, param := range doc.Query {
+		query := *param
+		query.In = "query"
+		operation.Parameters = append(operation.Parameters, &query)
+	}
+	for _, param := range doc.Headers {
+		header := *param
+		header.In = "header"
+		operation.Parameters = append(operation.Parameters, &header)
+	}
+
+	if (doc.Body != nil || len(doc.BodyTypes) > 0) && r.method != fiber.MethodGet {
+		operation.RequestBody = &RequestBody{Required: true, Content: content(s, doc.Body, doc.BodyTypes)}
+	}
+
+	if !described {
+		operation.Responses["default"] = &Response{Description: "Not described"}
+		return operation
+	}
+	status := doc.Status
+	if status == 0 {
+		status = fiber.StatusOK
+	}
+	success := &Response{Description: utils.StatusMessage(status)}
+	if status != fiber.StatusNoContent {
+		success.Content = content(s, doc.Result, doc.ResultTypes)
+	}
+	operation.Responses[strconv.Itoa(status)] = success
+	for _, code := range doc.Errors {
+		operation.Responses[strconv.Itoa(code)] = &Response{
+			Description: utils.StatusMessage(code),
+			Content: map[string]*MediaType{
+				fiber.MIMEApplicationJSON: {Schema: &Schema{Ref: "#/components/schemas/Error"}},
+			},
+		}
+	}
+	return operation
+}
+
+// content describes a body: JSON of the type of value, or the given media
+// types with no schema.
+func content(s *schemas, value interface{}, mediaTypes []string) map[string]*MediaType {
+	if len(mediaTypes) > 0 {
+		media := map[string]*MediaType{}
+		for _, mediaType := range mediaTypes {
+			media[mediaType] = &MediaType{}
+		}
+		return media
+	}
+	if value == nil {
+		return nil
+	}
+	return map[string]*MediaType{fiber.MIMEApplicationJSON: {Schema: s.of(value)}}
+}
+
+// errorSchema is the body handlers answer errors with.
+func errorSchema() *Schema {
+	return &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"status":  {Description: "The status code, or \"error\""},
+			"message": Type("string"),
+			"error":   {Description: "Details of the error"},
+		},
+	}
+}
+
+// Check compares the routes of app with the descriptions. It reports
+// routes served by a handler that is not described, and descriptions of
+// handlers that serve no route, so the two cannot drift apart unnoticed.
+func Check(app *fiber.App) []error {
+	var problems []string
+	served := map[string]bool{}
+	for _, r := range routes(app) {
+		name := handlerName(r.handler)
+		served[name] = true
+		if _, ok := lookup(r.handler); !ok {
+			problems = append(problems, fmt.Sprintf("%s %s is served by %s, which is not described", r.method, r.path, name))
+		}
+	}
+
+	docsMu.RLock()
+	for name := range docs {
+		if !served[name] {
+			problems = append(problems, fmt.Sprintf("%s is described but serves no route", name))
+		}
+	}
+	docsMu.RUnlock()
+
+	sort.Strings(problems)
+	errs := make([]error, len(problems))
+	for i, problem := range problems {
+		errs[i] = errors.New(problem)
+	}
+	return errs
+}
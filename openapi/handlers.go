@@ -0,0 +1,68 @@
+// openapi/handlers.go
+package openapi
+
+import (
+	_ "embed"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// info heads the document of the API.
+var info = Info{
+	Title:       "go-todo",
+	Version:     "1.0.0",
+	Description: "Todos with comments, attachments, history, webhooks and calendar feeds.",
+}
+
+//go:embed docs.html
+var docsPage []byte
+
+var (
+	generatedMu sync.Mutex
+	generated   = map[*fiber.App]*Document{}
+)
+
+// For returns the document of app. It is generated the first time it is
+// asked for, by which time every route is registered.
+func For(app *fiber.App) *Document {
+	generatedMu.Lock()
+	defer generatedMu.Unlock()
+	document, ok := generated[app]
+	if !ok {
+		document = Generate(app, info)
+		generated[app] = document
+	}
+	return document
+}
+
+type SpecHandler struct {
+	app *fiber.App
+}
+
+func (handler *SpecHandler) Spec(c *fiber.Ctx) error {
+	return c.JSON(For(handler.app))
+}
+
+// Docs serves a page that renders the document.
+func (handler *SpecHandler) Docs(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
+	return c.Send(docsPage)
+}
+
+func NewSpecHandler(app *fiber.App) *SpecHandler {
+	return &SpecHandler{
+		app: app,
+	}
+}
+
+// Register serves the document of app and its docs page.
+func Register(router fiber.Router, app *fiber.App) {
+	specHandler := NewSpecHandler(app)
+
+	Describe(specHandler.Spec, Doc{Summary: "Get the OpenAPI document of the API", Result: Type("object")})
+	Describe(specHandler.Docs, Doc{Summary: "Browse the API documentation", ResultTypes: []string{fiber.MIMETextHTML}})
+
+	router.Get("/openapi.json", specHandler.Spec)
+	router.Get("/docs", specHandler.Docs)
+}
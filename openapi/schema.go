@@ -0,0 +1,167 @@
+// openapi/schema.go
+package openapi
+
+import (
+	"encoding"
+	"encoding/json"
+	"path"
+	"reflect"
+	"strings"
+	"time"
+)
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	marshalerType     = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// schemas turns Go types into schemas the way encoding/json writes them.
+// Named struct types become components referred to by $ref.
+type schemas struct {
+	components map[string]*Schema
+	names      map[reflect.Type]string
+}
+
+func newSchemas() *schemas {
+	return &schemas{
+		components: map[string]*Schema{},
+		names:      map[reflect.Type]string{},
+	}
+}
+
+// of returns the schema of the type of value, or nil for a nil value.
+func (s *schemas) of(value interface{}) *Schema {
+	if value == nil {
+		return nil
+	}
+	if schema, ok := value.(*Schema); ok {
+		return schema
+	}
+	return s.schema(reflect.TypeOf(value))
+}
+
+func (s *schemas) schema(t reflect.Type) *Schema {
+	switch {
+	case t == timeType:
+		return DateTime()
+	case t.Kind() != reflect.Ptr && t.Implements(marshalerType), reflect.PtrTo(t).Implements(marshalerType):
+		return &Schema{}
+	case t.Kind() != reflect.Ptr && t.Implements(textMarshalerType), reflect.PtrTo(t).Implements(textMarshalerType):
+		return Type("string")
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		elem := s.schema(t.Elem())
+		if elem.Ref != "" || elem.Type == "" {
+			return &Schema{AnyOf: []*Schema{elem, Type("null")}}
+		}
+		nullable := *elem
+		nullable.Nullable = true
+		return &nullable
+	case reflect.Bool:
+		return Type("boolean")
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		zero := 0.0
+		return &Schema{Type: "integer", Minimum: &zero}
+	case reflect.Float32, reflect.Float64:
+		return Type("number")
+	case reflect.String:
+		return Type("string")
+	case reflect.Slice, reflect.Array:
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		schema := Type("array")
+		schema.Items = s.schema(t.Elem())
+		if t.Kind() == reflect.Slice {
+			schema.Nullable = true
+		}
+		return schema
+	case reflect.Map:
+		schema := Type("object")
+		schema.AdditionalProperties = s.schema(t.Elem())
+		return schema
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.object(t)
+		}
+		return s.ref(t)
+	}
+	return &Schema{}
+}
+
+// ref registers a named struct type as a component.
+func (s *schemas) ref(t reflect.Type) *Schema {
+	name, ok := s.names[t]
+	if !ok {
+		name = t.Name()
+		if _, taken := s.components[name]; taken {
+			name = strings.Title(path.Base(t.PkgPath())) + name
+		}
+		s.names[t] = name
+		s.components[name] = &Schema{}
+		*s.components[name] = *s.object(t)
+	}
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+func (s *schemas) object(t reflect.Type) *Schema {
+	schema := Type("object")
+	schema.Properties = map[string]*Schema{}
+	s.fields(t, schema)
+	return schema
+}
+
+// fields adds the properties of a struct, promoting the fields of embedded
+// structs without a name as encoding/json does.
+func (s *schemas) fields(t reflect.Type, schema *Schema) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options := tag, ""
+		if comma := strings.Index(tag, ","); comma >= 0 {
+			name, options = tag[:comma], tag[comma+1:]
+		}
+
+		fieldType := field.Type
+		if field.Anonymous && name == "" {
+			if fieldType.Kind() == reflect.Ptr {
+				fieldType = fieldType.Elem()
+			}
+			if fieldType.Kind() == reflect.Struct {
+				s.fields(fieldType, schema)
+				continue
+			}
+		}
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		property := s.schema(fieldType)
+		if hasOption(options, "string") {
+			property = Type("string")
+		}
+		schema.Properties[name] = property
+	}
+}
+
+func hasOption(options, option string) bool {
+	for _, candidate := range strings.Split(options, ",") {
+		if candidate == option {
+			return true
+		}
+	}
+	return false
+}
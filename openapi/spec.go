@@ -0,0 +1,123 @@
+// openapi/spec.go
+
+// Package openapi describes the HTTP API as an OpenAPI 3.1 document. Paths
+// and methods come from the routes registered with Fiber and schemas from
+// the Go types handlers read and write; packages add the rest with Describe.
+package openapi
+
+import "encoding/json"
+
+const Version = "3.1.0"
+
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem holds the operations of a path by lower case method.
+type PathItem map[string]*Operation
+
+type Operation struct {
+	OperationID string               `json:"operationId"`
+	Summary     string               `json:"summary,omitempty"`
+	Description string               `json:"description,omitempty"`
+	Tags        []string             `json:"tags,omitempty"`
+	Parameters  []*Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses"`
+	Deprecated  bool                 `json:"deprecated,omitempty"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                  `json:"required,omitempty"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string                `json:"description"`
+	Headers     map[string]*Header    `json:"headers,omitempty"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+type Header struct {
+	Description string  `json:"description,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas"`
+}
+
+// Schema is the subset of JSON Schema the document uses. Nullable adds
+// "null" to the type, the 3.1 replacement for nullable.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"-"`
+	Nullable             bool               `json:"-"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	AnyOf                []*Schema          `json:"anyOf,omitempty"`
+}
+
+func (schema *Schema) MarshalJSON() ([]byte, error) {
+	type plain Schema
+	var typ interface{}
+	switch {
+	case schema.Type == "":
+	case schema.Nullable:
+		typ = []string{schema.Type, "null"}
+	default:
+		typ = schema.Type
+	}
+	return json.Marshal(struct {
+		Type interface{} `json:"type,omitempty"`
+		*plain
+	}{typ, (*plain)(schema)})
+}
+
+// Type returns a schema of a JSON type: string, integer, number, boolean,
+// array or object.
+func Type(name string) *Schema {
+	return &Schema{Type: name}
+}
+
+// Enum returns a string schema that allows only the given values.
+func Enum(values ...string) *Schema {
+	schema := &Schema{Type: "string"}
+	for _, value := range values {
+		schema.Enum = append(schema.Enum, value)
+	}
+	return schema
+}
+
+// DateTime returns a schema of an RFC 3339 timestamp.
+func DateTime() *Schema {
+	return &Schema{Type: "string", Format: "date-time"}
+}
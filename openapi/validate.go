@@ -0,0 +1,319 @@
+// openapi/validate.go
+package openapi
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// matcher matches request paths against the path of an operation.
+type matcher struct {
+	method    string
+	segments  []string
+	operation *Operation
+}
+
+func (m matcher) match(method, path string) (map[string]string, bool) {
+	if method != m.method {
+		return nil, false
+	}
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	if len(segments) != len(m.segments) {
+		return nil, false
+	}
+	params := map[string]string{}
+	for i, segment := range m.segments {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			params[segment[1:len(segment)-1]] = segments[i]
+		} else if !strings.EqualFold(segment, segments[i]) {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// Validator checks requests against the document of app before they reach
+// their handler: path, query and header parameters, and JSON bodies. The
+// document is generated on the first request, when every route is
+// registered. Requests for paths the document does not know pass through.
+func Validator(app *fiber.App) fiber.Handler {
+	var once sync.Once
+	var document *Document
+	var matchers []matcher
+
+	return func(c *fiber.Ctx) error {
+		once.Do(func() {
+			document = For(app)
+			for path, item := range document.Paths {
+				for method, operation := range item {
+					matchers = append(matchers, matcher{
+						method:    strings.ToUpper(method),
+						segments:  strings.Split(strings.Trim(path, "/"), "/"),
+						operation: operation,
+					})
+				}
+			}
+		})
+
+		for _, m := range matchers {
+			params, ok := m.match(c.Method(), c.Path())
+			if !ok {
+				continue
+			}
+			if err := validateRequest(c, document, m.operation, params); err != nil {
+				return c.Status(400).JSON(fiber.Map{
+					"status":  400,
+					"message": "Request does not match the API specification",
+					"error":   err.Error(),
+				})
+			}
+			break
+		}
+		return c.Next()
+	}
+}
+
+func validateRequest(c *fiber.Ctx, document *Document, operation *Operation, params map[string]string) error {
+	for _, param := range operation.Parameters {
+		var value string
+		switch param.In {
+		case "path":
+			value = params[param.Name]
+		case "query":
+			value = c.Query(param.Name)
+		case "header":
+			value = c.Get(param.Name)
+		}
+		if value == "" {
+			if param.Required {
+				return fmt.Errorf("%s parameter %q is required", param.In, param.Name)
+			}
+			continue
+		}
+		if err := validateParameter(document, param.Schema, value); err != nil {
+			return fmt.Errorf("%s parameter %q %v", param.In, param.Name, err)
+		}
+	}
+
+	// Streamed bodies are read by the handler as they arrive, not here.
+	if operation.RequestBody == nil || !strings.HasPrefix(c.Get(fiber.HeaderContentType), fiber.MIMEApplicationJSON) ||
+		c.Context().RequestBodyStream() != nil {
+		return nil
+	}
+	media, ok := operation.RequestBody.Content[fiber.MIMEApplicationJSON]
+	if !ok || media.Schema == nil {
+		return nil
+	}
+	var body interface{}
+	decoder := json.NewDecoder(bytes.NewReader(c.Body()))
+	decoder.UseNumber()
+	if err := decoder.Decode(&body); err != nil {
+		return fmt.Errorf("body is not valid JSON: %v", err)
+	}
+	return validateValue(document, media.Schema, body, "body")
+}
+
+// ValidateResponse checks a response to a request for method and path
+// against the operation the document has for them: its status has to be
+// documented, and a JSON body has to match the schema of that status. A
+// path such as /todo/search is taken for its own operation rather than for
+// /todo/{id}.
+func ValidateResponse(document *Document, method, path string, status int, contentType string, body []byte) error {
+	var operation *Operation
+	fewest := -1
+	for template, item := range document.Paths {
+		for name, candidate := range item {
+			m := matcher{method: strings.ToUpper(name), segments: strings.Split(strings.Trim(template, "/"), "/")}
+			if params, ok := m.match(method, path); ok && (fewest < 0 || len(params) < fewest) {
+				operation, fewest = candidate, len(params)
+			}
+		}
+	}
+	if operation == nil {
+		return fmt.Errorf("%s %s is not documented", method, path)
+	}
+	response, ok := operation.Responses[strconv.Itoa(status)]
+	if !ok {
+		return fmt.Errorf("%s %s answered %d, which is not documented", method, path, status)
+	}
+	if len(body) == 0 || !strings.HasPrefix(contentType, fiber.MIMEApplicationJSON) {
+		return nil
+	}
+	media, ok := response.Content[fiber.MIMEApplicationJSON]
+	if !ok || media.Schema == nil {
+		return fmt.Errorf("%s %s answered %d with JSON, which is not documented", method, path, status)
+	}
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return fmt.Errorf("%s %s answered invalid JSON: %v", method, path, err)
+	}
+	if err := validateValue(document, media.Schema, value, "body"); err != nil {
+		return fmt.Errorf("%s %s answered %d: %v", method, path, status, err)
+	}
+	return nil
+}
+
+// validateParameter checks the text of a parameter against a scalar schema.
+func validateParameter(document *Document, schema *Schema, value string) error {
+	schema = resolve(document, schema)
+	switch schema.Type {
+	case "integer":
+		if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+			return fmt.Errorf("must be an integer")
+		}
+		return nil
+	case "number":
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return fmt.Errorf("must be a number")
+		}
+		return nil
+	case "boolean":
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("must be true or false")
+		}
+		return nil
+	}
+	return validateString(schema, value)
+}
+
+func validateString(schema *Schema, value string) error {
+	if len(schema.Enum) > 0 {
+		allowed := false
+		for _, candidate := range schema.Enum {
+			if candidate == value {
+				allowed = true
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("must be one of %v", schema.Enum)
+		}
+	}
+	switch schema.Format {
+	case "date-time":
+		if _, err := time.Parse(time.RFC3339, value); err != nil {
+			return fmt.Errorf("must be an RFC 3339 timestamp")
+		}
+	case "byte":
+		if _, err := base64.StdEncoding.DecodeString(value); err != nil {
+			return fmt.Errorf("must be base64")
+		}
+	}
+	return nil
+}
+
+// validateValue checks a decoded JSON value against a schema. at is where
+// the value is in the body, for the error message.
+func validateValue(document *Document, schema *Schema, value interface{}, at string) error {
+	schema = resolve(document, schema)
+	if len(schema.AnyOf) > 0 {
+		var err error
+		for _, option := range schema.AnyOf {
+			if err = validateValue(document, option, value, at); err == nil {
+				return nil
+			}
+		}
+		return err
+	}
+
+	if value == nil {
+		if schema.Type == "" || schema.Type == "null" || schema.Nullable {
+			return nil
+		}
+		return fmt.Errorf("%s must not be null", at)
+	}
+
+	switch schema.Type {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s must be an object", at)
+		}
+		for _, name := range schema.Required {
+			if _, ok := object[name]; !ok {
+				return fmt.Errorf("%s.%s is required", at, name)
+			}
+		}
+		for name, field := range object {
+			property, ok := schema.Properties[name]
+			if !ok {
+				property = schema.AdditionalProperties
+			}
+			if property == nil {
+				continue
+			}
+			if err := validateValue(document, property, field, at+"."+name); err != nil {
+				return err
+			}
+		}
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s must be an array", at)
+		}
+		for i, item := range items {
+			if schema.Items == nil {
+				break
+			}
+			if err := validateValue(document, schema.Items, item, fmt.Sprintf("%s[%d]", at, i)); err != nil {
+				return err
+			}
+		}
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%s must be a string", at)
+		}
+		if err := validateString(schema, s); err != nil {
+			return fmt.Errorf("%s %v", at, err)
+		}
+	case "integer", "number":
+		number, ok := value.(json.Number)
+		if !ok {
+			return fmt.Errorf("%s must be a number", at)
+		}
+		n, err := number.Float64()
+		if err != nil {
+			return fmt.Errorf("%s must be a number", at)
+		}
+		if schema.Type == "integer" {
+			if _, err := number.Int64(); err != nil {
+				return fmt.Errorf("%s must be an integer", at)
+			}
+		}
+		if schema.Minimum != nil && n < *schema.Minimum {
+			return fmt.Errorf("%s must be at least %v", at, *schema.Minimum)
+		}
+		if schema.Maximum != nil && n > *schema.Maximum {
+			return fmt.Errorf("%s must be at most %v", at, *schema.Maximum)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s must be true or false", at)
+		}
+	case "null":
+		return fmt.Errorf("%s must be null", at)
+	}
+	return nil
+}
+
+// resolve follows a $ref to the component it names.
+func resolve(document *Document, schema *Schema) *Schema {
+	for schema.Ref != "" {
+		component, ok := document.Components.Schemas[strings.TrimPrefix(schema.Ref, "#/components/schemas/")]
+		if !ok {
+			return &Schema{}
+		}
+		schema = component
+	}
+	return schema
+}
@@ -0,0 +1,22 @@
+// outbox/models.go
+package outbox
+
+import "time"
+
+// Message is an event written in the same transaction as the change it
+// describes. It stays pending until every sink accepted it.
+type Message struct {
+	ID            uint       `gorm:"primary_key" json:"id"`
+	Topic         string     `gorm:"Not Null;index" json:"topic"`
+	Key           string     `json:"key"`
+	Payload       string     `gorm:"type:text" json:"payload"`
+	Attempts      int        `json:"attempts"`
+	NextAttemptAt time.Time  `gorm:"index" json:"next_attempt_at"`
+	LastError     string     `json:"last_error"`
+	PublishedAt   *time.Time `gorm:"index" json:"published_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+func (Message) TableName() string {
+	return "outbox_messages"
+}
@@ -0,0 +1,122 @@
+// outbox/relay.go
+package outbox
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/imadbg01/go-todo/config"
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	lease    = time.Minute
+	maxRetry = 5 * time.Minute
+)
+
+var wakeup = make(chan struct{}, 1)
+
+// Wake makes the relay look for messages now instead of at its next poll.
+// Call it after committing a transaction that appended messages.
+func Wake() {
+	select {
+	case wakeup <- struct{}{}:
+	default:
+	}
+}
+
+// Relay publishes pending outbox messages to the registered sinks and purges
+// published ones once they are older than the retention. Several replicas
+// can run a relay against the same database.
+type Relay struct {
+	repository *OutboxRepository
+	interval   time.Duration
+	retention  time.Duration
+	batch      int
+}
+
+func (relay *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(relay.interval)
+	defer ticker.Stop()
+	purged := time.Time{}
+
+	for {
+		relay.drain()
+		if time.Since(purged) > time.Hour {
+			if _, err := relay.repository.Purge(time.Now().Add(-relay.retention)); err != nil {
+				log.Printf("outbox: failed purging messages: %v", err)
+			}
+			purged = time.Now()
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-wakeup:
+		case <-ticker.C:
+		}
+	}
+}
+
+func (relay *Relay) drain() {
+	for {
+		messages, err := relay.repository.Claim(relay.batch, lease)
+		if err != nil {
+			log.Printf("outbox: failed claiming messages: %v", err)
+			return
+		}
+		for _, message := range messages {
+			err := publish(message)
+			if err != nil {
+				log.Printf("outbox: failed publishing message %d: %v", message.ID, err)
+			}
+			if err := relay.repository.Complete(message, err, retryDelay(message.Attempts)); err != nil {
+				log.Printf("outbox: failed recording message %d: %v", message.ID, err)
+			}
+		}
+		if len(messages) < relay.batch {
+			return
+		}
+	}
+}
+
+// retryDelay doubles from a second after every failed attempt, up to maxRetry.
+func retryDelay(attempts int) time.Duration {
+	delay := time.Second
+	for i := 0; i < attempts && delay < maxRetry; i++ {
+		delay *= 2
+	}
+	if delay > maxRetry {
+		delay = maxRetry
+	}
+	return delay
+}
+
+func NewRelay(repository *OutboxRepository, interval, retention time.Duration) *Relay {
+	return &Relay{
+		repository: repository,
+		interval:   interval,
+		retention:  retention,
+		batch:      50,
+	}
+}
+
+// Start creates the outbox table and runs a relay in the background. It has
+// to run before the packages that append messages are registered. With
+// OUTBOX_LOG=true every published message is logged.
+func Start(database *gorm.DB) {
+	database.AutoMigrate(&Message{})
+	if config.Config("OUTBOX_LOG") == "true" {
+		AddSink(LogSink{})
+	}
+
+	interval, err := time.ParseDuration(config.ConfigOr("OUTBOX_POLL_INTERVAL", "5s"))
+	if err != nil {
+		interval = 5 * time.Second
+	}
+	retention, err := time.ParseDuration(config.ConfigOr("OUTBOX_RETENTION", "168h"))
+	if err != nil {
+		retention = 168 * time.Hour
+	}
+	go NewRelay(NewOutboxRepository(database), interval, retention).Run(context.Background())
+}
@@ -0,0 +1,149 @@
+// outbox/relay_test.go
+package outbox
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+)
+
+func newTestRepository(t *testing.T) *OutboxRepository {
+	database, err := gorm.Open("sqlite3", filepath.Join(t.TempDir(), "outbox.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	database.DB().SetMaxOpenConns(1)
+	t.Cleanup(func() { database.Close() })
+	database.AutoMigrate(&Message{})
+	return NewOutboxRepository(database)
+}
+
+// useSinks replaces the registered sinks for the rest of the test.
+func useSinks(t *testing.T, replacement ...Sink) {
+	sinksMu.Lock()
+	previous := sinks
+	sinks = replacement
+	sinksMu.Unlock()
+	t.Cleanup(func() {
+		sinksMu.Lock()
+		sinks = previous
+		sinksMu.Unlock()
+	})
+}
+
+func appendMessages(t *testing.T, repository *OutboxRepository, keys ...string) {
+	for _, key := range keys {
+		if err := Append(repository.database, "todo.created", key, map[string]string{"key": key}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRelayDeliversAtLeastOnce(t *testing.T) {
+	repository := newTestRepository(t)
+	appendMessages(t, repository, "1", "2")
+
+	var delivered []string
+	failing := true
+	useSinks(t, SinkFunc(func(message Message) error {
+		delivered = append(delivered, message.Key)
+		return nil
+	}), SinkFunc(func(message Message) error {
+		if failing && message.Key == "2" {
+			return errors.New("broker down")
+		}
+		return nil
+	}))
+
+	relay := NewRelay(repository, time.Hour, time.Hour)
+	relay.drain()
+	if len(delivered) != 2 {
+		t.Fatalf("delivered %v, want both messages", delivered)
+	}
+
+	var failed Message
+	repository.database.Where("key = ?", "2").First(&failed)
+	if failed.PublishedAt != nil || failed.Attempts != 1 || failed.LastError != "broker down" {
+		t.Fatalf("failed message recorded as %+v", failed)
+	}
+	if !failed.NextAttemptAt.After(time.Now()) {
+		t.Fatal("failed message is due again without a delay")
+	}
+
+	// Once due, the failed message is offered to every sink again.
+	failing = false
+	repository.database.Model(&failed).UpdateColumn("next_attempt_at", time.Now())
+	relay.drain()
+	if len(delivered) != 3 || delivered[2] != "2" {
+		t.Fatalf("delivered %v, want the failed message again", delivered)
+	}
+	relay.drain()
+	if len(delivered) != 3 {
+		t.Fatalf("delivered %v after everything was published", delivered)
+	}
+}
+
+func TestClaimLeasesMessages(t *testing.T) {
+	repository := newTestRepository(t)
+	appendMessages(t, repository, "1", "2", "3")
+
+	first, err := repository.Claim(2, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first) != 2 || first[0].Key != "1" || first[1].Key != "2" {
+		t.Fatalf("claimed %+v, want the two oldest messages", first)
+	}
+	second, err := repository.Claim(10, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(second) != 1 || second[0].Key != "3" {
+		t.Fatalf("claimed %+v while the others were leased, want only the third", second)
+	}
+
+	// A relay that died holding a lease leaves the messages to the next
+	// claim once the lease ran out.
+	repository.database.Model(&Message{}).Where("key = ?", "1").UpdateColumn("next_attempt_at", time.Now().Add(-time.Second))
+	again, err := repository.Claim(10, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(again) != 1 || again[0].Key != "1" {
+		t.Fatalf("claimed %+v after the lease ran out, want the first message", again)
+	}
+}
+
+func TestPurge(t *testing.T) {
+	repository := newTestRepository(t)
+	appendMessages(t, repository, "old", "recent", "pending")
+	useSinks(t)
+
+	messages, err := repository.Claim(2, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, message := range messages {
+		if err := repository.Complete(message, nil, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	repository.database.Model(&Message{}).Where("key = ?", "old").UpdateColumn("published_at", time.Now().Add(-48*time.Hour))
+
+	deleted, err := repository.Purge(time.Now().Add(-24 * time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 1 {
+		t.Fatalf("purged %d messages, want 1", deleted)
+	}
+	var keys []string
+	repository.database.Model(&Message{}).Order("id asc").Pluck("key", &keys)
+	if len(keys) != 2 || keys[0] != "recent" || keys[1] != "pending" {
+		t.Fatalf("kept %v, want the recent and the pending message", keys)
+	}
+}
@@ -0,0 +1,82 @@
+// outbox/repositories.go
+package outbox
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Append writes a message to the outbox within tx, so it is committed or
+// rolled back together with the change it describes.
+func Append(tx *gorm.DB, topic, key string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return tx.Create(&Message{
+		Topic:         topic,
+		Key:           key,
+		Payload:       string(body),
+		NextAttemptAt: time.Now(),
+	}).Error
+}
+
+type OutboxRepository struct {
+	database *gorm.DB
+}
+
+// Claim leases up to limit due messages in the order they were written, so
+// that relays of other replicas skip them until the lease ends.
+func (repository *OutboxRepository) Claim(limit int, lease time.Duration) ([]Message, error) {
+	var messages []Message
+	err := repository.database.Transaction(func(tx *gorm.DB) error {
+		query := tx.
+			Where("published_at IS NULL AND next_attempt_at <= ?", time.Now()).
+			Order("id asc").
+			Limit(limit)
+		if tx.Dialect().GetName() == "postgres" {
+			query = query.Set("gorm:query_option", "FOR UPDATE SKIP LOCKED")
+		}
+		if err := query.Find(&messages).Error; err != nil {
+			return err
+		}
+
+		for _, message := range messages {
+			err := tx.Model(&Message{}).Where("id = ?", message.ID).UpdateColumn("next_attempt_at", time.Now().Add(lease)).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return messages, err
+}
+
+// Complete marks the message published, or schedules another attempt after
+// retry when publishing failed.
+func (repository *OutboxRepository) Complete(message Message, failure error, retry time.Duration) error {
+	now := time.Now()
+	columns := map[string]interface{}{"attempts": message.Attempts + 1}
+	if failure == nil {
+		columns["published_at"] = now
+		columns["last_error"] = ""
+	} else {
+		columns["next_attempt_at"] = now.Add(retry)
+		columns["last_error"] = failure.Error()
+	}
+	return repository.database.Model(&Message{}).Where("id = ?", message.ID).UpdateColumns(columns).Error
+}
+
+// Purge deletes messages published before the given time.
+func (repository *OutboxRepository) Purge(before time.Time) (int64, error) {
+	deleted := repository.database.Where("published_at < ?", before).Delete(&Message{})
+	return deleted.RowsAffected, deleted.Error
+}
+
+func NewOutboxRepository(database *gorm.DB) *OutboxRepository {
+	return &OutboxRepository{
+		database: database,
+	}
+}
@@ -0,0 +1,53 @@
+// outbox/sinks.go
+package outbox
+
+import (
+	"log"
+	"sync"
+)
+
+// Sink receives published messages. Delivery is at least once: a message is
+// offered to every sink again when any of them failed, so sinks should
+// tolerate duplicates, for instance by keying on the message ID.
+type Sink interface {
+	Publish(message Message) error
+}
+
+// SinkFunc adapts a function to a Sink.
+type SinkFunc func(message Message) error
+
+func (fn SinkFunc) Publish(message Message) error {
+	return fn(message)
+}
+
+// LogSink notes every message in the standard logger. It leaves out the
+// payload, which holds the todo as it was changed.
+type LogSink struct{}
+
+func (LogSink) Publish(message Message) error {
+	log.Printf("outbox: published %d %s %s", message.ID, message.Topic, message.Key)
+	return nil
+}
+
+var (
+	sinksMu sync.RWMutex
+	sinks   []Sink
+)
+
+// AddSink registers a sink the relay publishes every message to.
+func AddSink(sink Sink) {
+	sinksMu.Lock()
+	defer sinksMu.Unlock()
+	sinks = append(sinks, sink)
+}
+
+func publish(message Message) error {
+	sinksMu.RLock()
+	defer sinksMu.RUnlock()
+	for _, sink := range sinks {
+		if err := sink.Publish(message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
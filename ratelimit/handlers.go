@@ -0,0 +1,125 @@
+// ratelimit/handlers.go
+package ratelimit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/imadbg01/go-todo/auth"
+)
+
+// KeyFunc names the client a request counts against.
+type KeyFunc func(c *fiber.Ctx) string
+
+// ByIP counts requests against the address of the client.
+func ByIP(c *fiber.Ctx) string {
+	return "ip:" + c.IP()
+}
+
+// ByUser counts requests against the user, or the address of anonymous
+// clients. auth.Actor only believes the user from the proxy that
+// authenticates requests; any other client could name a new user per
+// request.
+func ByUser(c *fiber.Ctx) string {
+	if user := auth.Actor(c); user != auth.Anonymous {
+		return "user:" + user
+	}
+	return ByIP(c)
+}
+
+// ByToken counts requests against the bearer token they carry, or else like
+// ByUser. Only a hash of the token is kept. Like the user, the token is only
+// believed from the proxy that checked it.
+func ByToken(c *fiber.Ctx) string {
+	header := c.Get(fiber.HeaderAuthorization)
+	if len(header) > 7 && strings.EqualFold(header[:7], "bearer ") && auth.FromTrustedProxy(c) {
+		sum := sha256.Sum256([]byte(header[7:]))
+		return "token:" + hex.EncodeToString(sum[:16])
+	}
+	return ByUser(c)
+}
+
+// Key returns the key function named ip, user or token, ByToken by default.
+func Key(name string) KeyFunc {
+	switch name {
+	case "ip":
+		return ByIP
+	case "user":
+		return ByUser
+	}
+	return ByToken
+}
+
+// Config is the limit of a group of routes.
+type Config struct {
+	// Name keeps the buckets of the group apart from those of other groups.
+	Name  string
+	Limit Limit
+	Key   KeyFunc
+	// Methods are the methods limited, all when empty.
+	Methods []string
+	Store   Store
+}
+
+// New limits the requests that reach the next handlers. Responses carry the
+// RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers of the
+// limit closest to running out, and requests over a limit are answered with
+// 429 and Retry-After. When the store fails requests are let through.
+func New(config Config) fiber.Handler {
+	limit := config.Limit
+	if limit.Burst < 1 {
+		limit.Burst = limit.Requests
+	}
+	if config.Key == nil {
+		config.Key = ByToken
+	}
+	methods := map[string]bool{}
+	for _, method := range config.Methods {
+		methods[method] = true
+	}
+	policy := strconv.Itoa(limit.Requests) + ";w=" + strconv.Itoa(int(limit.Period.Seconds()))
+	if limit.Burst != limit.Requests {
+		policy += ";burst=" + strconv.Itoa(limit.Burst)
+	}
+
+	return func(c *fiber.Ctx) error {
+		if limit.Off() || (len(methods) > 0 && !methods[c.Method()]) {
+			return c.Next()
+		}
+
+		result, err := config.Store.Take(config.Name+":"+config.Key(c), limit)
+		if err != nil {
+			log.Printf("ratelimit: %s: %v", config.Name, err)
+			return c.Next()
+		}
+
+		remaining := c.GetRespHeader("RateLimit-Remaining")
+		if current, err := strconv.Atoi(remaining); remaining == "" || (err == nil && result.Remaining <= current) {
+			c.Set("RateLimit-Limit", strconv.Itoa(limit.Burst))
+			c.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			c.Set("RateLimit-Reset", ceil(result.Reset))
+			c.Set("RateLimit-Policy", policy)
+		}
+
+		if !result.Allowed {
+			c.Set(fiber.HeaderRetryAfter, ceil(result.RetryAfter))
+			return c.Status(429).JSON(fiber.Map{
+				"status":  429,
+				"message": "Too many requests",
+				"error":   "Rate limit " + config.Name + " of " + limit.String() + " exceeded",
+			})
+		}
+		return c.Next()
+	}
+}
+
+// ceil writes a duration in whole seconds, rounded up.
+func ceil(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
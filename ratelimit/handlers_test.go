@@ -0,0 +1,43 @@
+// ratelimit/handlers_test.go
+package ratelimit
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestKeysTrustOnlyProxies(t *testing.T) {
+	// app.Test sends requests from 0.0.0.0.
+	for _, test := range []struct {
+		name    string
+		config  fiber.Config
+		trusted bool
+	}{
+		{"no proxy check", fiber.Config{}, false},
+		{"other proxy", fiber.Config{EnableTrustedProxyCheck: true, TrustedProxies: []string{"10.0.0.1"}}, false},
+		{"trusted proxy", fiber.Config{EnableTrustedProxyCheck: true, TrustedProxies: []string{"0.0.0.0"}}, true},
+	} {
+		keys := map[string]string{}
+		app := fiber.New(test.config)
+		app.Get("/", func(c *fiber.Ctx) error {
+			keys["user"], keys["token"] = ByUser(c), ByToken(c)
+			return nil
+		})
+		request := httptest.NewRequest("GET", "/", nil)
+		request.Header.Set("X-User", "alice")
+		request.Header.Set("Authorization", "Bearer secret")
+		if _, err := app.Test(request); err != nil {
+			t.Fatal(err)
+		}
+
+		wantUser, wantToken := "ip:0.0.0.0", "ip:0.0.0.0"
+		if test.trusted {
+			wantUser, wantToken = "user:alice", "token:2bb80d537b1da3e38bd30361aa855686"
+		}
+		if keys["user"] != wantUser || keys["token"] != wantToken {
+			t.Errorf("%s: keys = %v, want user %s and token %s", test.name, keys, wantUser, wantToken)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+// ratelimit/models.go
+// Package ratelimit limits how fast clients can call the API with token
+// buckets: each client has a bucket that holds up to Burst tokens and refills
+// at Requests per Period, and every request takes a token.
+package ratelimit
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/imadbg01/go-todo/config"
+)
+
+type Limit struct {
+	Requests int
+	Period   time.Duration
+	Burst    int
+}
+
+// Off reports whether the limit lets every request through.
+func (limit Limit) Off() bool {
+	return limit.Requests <= 0 || limit.Period <= 0
+}
+
+// rate is the number of tokens added to a bucket per second.
+func (limit Limit) rate() float64 {
+	return float64(limit.Requests) / limit.Period.Seconds()
+}
+
+func (limit Limit) String() string {
+	if limit.Off() {
+		return "off"
+	}
+	return fmt.Sprintf("%d/%s:%d", limit.Requests, limit.Period, limit.Burst)
+}
+
+// ParseLimit reads a limit such as 60/1m, or 10/s:20 for a burst larger
+// than the rate. The period is a duration, or s, m, h or d for one of them.
+// "off" and 0 disable the limit.
+func ParseLimit(value string) (Limit, error) {
+	value = strings.TrimSpace(value)
+	if value == "off" || value == "0" {
+		return Limit{}, nil
+	}
+	slash := strings.Index(value, "/")
+	if slash < 0 {
+		return Limit{}, fmt.Errorf("limit %q is not requests/period", value)
+	}
+	requests, err := strconv.Atoi(value[:slash])
+	if err != nil || requests < 0 {
+		return Limit{}, fmt.Errorf("limit %q has no number of requests", value)
+	}
+	period, burst := value[slash+1:], requests
+	if colon := strings.Index(period, ":"); colon >= 0 {
+		burst, err = strconv.Atoi(period[colon+1:])
+		if err != nil || burst < 1 {
+			return Limit{}, fmt.Errorf("limit %q has an invalid burst", value)
+		}
+		period = period[:colon]
+	}
+	limit := Limit{Requests: requests, Burst: burst}
+	switch period {
+	case "s":
+		limit.Period = time.Second
+	case "m":
+		limit.Period = time.Minute
+	case "h":
+		limit.Period = time.Hour
+	case "d":
+		limit.Period = 24 * time.Hour
+	default:
+		limit.Period, err = time.ParseDuration(period)
+		if err != nil || limit.Period <= 0 {
+			return Limit{}, fmt.Errorf("limit %q has an invalid period", value)
+		}
+	}
+	return limit, nil
+}
+
+// Setting reads the limit in the setting key, or fallback when it is not
+// set or invalid.
+func Setting(key string, fallback string) Limit {
+	limit, err := ParseLimit(config.ConfigOr(key, fallback))
+	if err != nil {
+		log.Printf("ratelimit: %s: %v", key, err)
+		limit, _ = ParseLimit(fallback)
+	}
+	return limit
+}
+
+// Bucket is the state of the bucket of one client under one limit.
+type Bucket struct {
+	Key       string `gorm:"primary_key"`
+	Tokens    float64
+	UpdatedAt time.Time `gorm:"index"`
+}
+
+func (Bucket) TableName() string {
+	return "rate_limit_buckets"
+}
+
+// Result is the outcome of taking a token.
+type Result struct {
+	Allowed   bool
+	Remaining int
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is how long until a token is available, when none was.
+	RetryAfter time.Duration
+}
+
+// take refills the bucket for the time since it was last updated and takes
+// a token from it if there is one.
+func (bucket *Bucket) take(limit Limit, now time.Time) Result {
+	elapsed := now.Sub(bucket.UpdatedAt).Seconds()
+	if elapsed > 0 {
+		bucket.Tokens = math.Min(float64(limit.Burst), bucket.Tokens+elapsed*limit.rate())
+	}
+	bucket.UpdatedAt = now
+
+	result := Result{}
+	if bucket.Tokens >= 1 {
+		bucket.Tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = seconds((1 - bucket.Tokens) / limit.rate())
+	}
+	result.Remaining = int(bucket.Tokens)
+	result.Reset = seconds((float64(limit.Burst) - bucket.Tokens) / limit.rate())
+	return result
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,127 @@
+// ratelimit/repositories.go
+package ratelimit
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/imadbg01/go-todo/config"
+	"github.com/jinzhu/gorm"
+)
+
+// Store keeps the buckets of the clients.
+type Store interface {
+	Take(key string, limit Limit) (Result, error)
+}
+
+// MemoryStore keeps buckets in the process, so each replica limits on its
+// own.
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*Bucket
+	lastSweep time.Time
+}
+
+func (store *MemoryStore) Take(key string, limit Limit) (Result, error) {
+	now := time.Now()
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	bucket, ok := store.buckets[key]
+	if !ok {
+		bucket = &Bucket{Key: key, Tokens: float64(limit.Burst), UpdatedAt: now}
+		store.buckets[key] = bucket
+	}
+	result := bucket.take(limit, now)
+
+	if now.Sub(store.lastSweep) > time.Minute {
+		store.sweep(now)
+	}
+	return result, nil
+}
+
+// sweep forgets buckets that were not used for a day, which are full again
+// under any limit shorter than that.
+func (store *MemoryStore) sweep(now time.Time) {
+	store.lastSweep = now
+	for key, bucket := range store.buckets {
+		if now.Sub(bucket.UpdatedAt) > 24*time.Hour {
+			delete(store.buckets, key)
+		}
+	}
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets:   map[string]*Bucket{},
+		lastSweep: time.Now(),
+	}
+}
+
+// DatabaseStore keeps buckets in the database, so that every replica takes
+// from the same buckets. On Postgres the bucket row is locked while a token
+// is taken.
+type DatabaseStore struct {
+	database *gorm.DB
+}
+
+func (store *DatabaseStore) Take(key string, limit Limit) (Result, error) {
+	var result Result
+	err := store.database.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		err := tx.Exec(
+			"INSERT INTO rate_limit_buckets (key, tokens, updated_at) VALUES (?, ?, ?) ON CONFLICT (key) DO NOTHING",
+			key, limit.Burst, now,
+		).Error
+		if err != nil {
+			return err
+		}
+
+		var bucket Bucket
+		query := tx.Where("key = ?", key)
+		if tx.Dialect().GetName() == "postgres" {
+			query = query.Set("gorm:query_option", "FOR UPDATE")
+		}
+		if err := query.First(&bucket).Error; err != nil {
+			return err
+		}
+
+		result = bucket.take(limit, now)
+		return tx.Model(&Bucket{}).Where("key = ?", key).UpdateColumns(map[string]interface{}{
+			"tokens":     bucket.Tokens,
+			"updated_at": bucket.UpdatedAt,
+		}).Error
+	})
+	return result, err
+}
+
+// Sweep deletes buckets that were not used for a day.
+func (store *DatabaseStore) Sweep() {
+	err := store.database.Where("updated_at < ?", time.Now().Add(-24*time.Hour)).Delete(&Bucket{}).Error
+	if err != nil {
+		log.Printf("ratelimit: sweep failed: %v", err)
+	}
+}
+
+func NewDatabaseStore(database *gorm.DB) *DatabaseStore {
+	return &DatabaseStore{
+		database: database,
+	}
+}
+
+// NewStore returns the store chosen by RATE_LIMIT_STORE: memory, or
+// postgres to share limits between replicas through the database.
+func NewStore(database *gorm.DB) Store {
+	if config.ConfigOr("RATE_LIMIT_STORE", "memory") != "postgres" {
+		return NewMemoryStore()
+	}
+	database.AutoMigrate(&Bucket{})
+	store := NewDatabaseStore(database)
+	go func() {
+		for range time.Tick(time.Hour) {
+			store.Sweep()
+		}
+	}()
+	return store
+}
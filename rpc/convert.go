@@ -0,0 +1,142 @@
+// rpc/convert.go
+package rpc
+
+import (
+	"time"
+
+	"github.com/imadbg01/go-todo/feed"
+	"github.com/imadbg01/go-todo/todo"
+	"github.com/imadbg01/go-todo/todopb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var statuses = map[string]todopb.Status{
+	todo.PENDING:  todopb.Status_STATUS_PENDING,
+	todo.PROGRESS: todopb.Status_STATUS_IN_PROGRESS,
+	todo.DONE:     todopb.Status_STATUS_DONE,
+}
+
+var priorities = map[string]todopb.Priority{
+	todo.PriorityNone:   todopb.Priority_PRIORITY_NONE,
+	todo.PriorityLow:    todopb.Priority_PRIORITY_LOW,
+	todo.PriorityMedium: todopb.Priority_PRIORITY_MEDIUM,
+	todo.PriorityHigh:   todopb.Priority_PRIORITY_HIGH,
+	todo.PriorityUrgent: todopb.Priority_PRIORITY_URGENT,
+}
+
+var eventTypes = map[string]todopb.Event_Type{
+	todo.EventCreated:       todopb.Event_TYPE_CREATED,
+	todo.EventUpdated:       todopb.Event_TYPE_UPDATED,
+	todo.EventDeleted:       todopb.Event_TYPE_DELETED,
+	todo.EventRestored:      todopb.Event_TYPE_RESTORED,
+	todo.EventStatusChanged: todopb.Event_TYPE_STATUS_CHANGED,
+	"reset":                 todopb.Event_TYPE_RESET,
+}
+
+func toProto(item todo.Todo) *todopb.Todo {
+	priority := item.Priority
+	if priority == "" {
+		priority = todo.PriorityNone
+	}
+	return &todopb.Todo{
+		Id:          uint64(item.ID),
+		Name:        item.Name,
+		Description: item.Description,
+		Status:      statuses[item.Status],
+		Priority:    priorities[priority],
+		Rank:        item.Rank,
+		Due:         timestamp(item.Due),
+		TimeZone:    item.TimeZone,
+		Recurrence:  item.Recurrence,
+		RepeatFrom:  item.RepeatFrom,
+		SeriesId:    uint64(item.SeriesID),
+		Occurrence:  int32(item.Occurrence),
+		CompletedAt: timestamp(item.CompletedAt),
+		CreatedAt:   timestamp(&item.CreatedAt),
+		UpdatedAt:   timestamp(&item.UpdatedAt),
+		Etag:        item.ETag(),
+	}
+}
+
+func toEvent(message feed.Message) *todopb.Event {
+	event := &todopb.Event{Id: message.ID, Type: eventTypes[message.Type]}
+	if message.Todo.ID != 0 {
+		event.Todo = toProto(message.Todo)
+	}
+	return event
+}
+
+// apply copies the writable fields named in paths from the message onto
+// data. Enums left unspecified keep the value data already has.
+func apply(data *todo.Todo, message *todopb.Todo, paths []string) error {
+	for _, path := range paths {
+		switch path {
+		case "name":
+			data.Name = message.GetName()
+		case "description":
+			data.Description = message.GetDescription()
+		case "status":
+			if message.GetStatus() != todopb.Status_STATUS_UNSPECIFIED {
+				data.Status = statusName(message.GetStatus())
+			}
+		case "priority":
+			if message.GetPriority() != todopb.Priority_PRIORITY_UNSPECIFIED {
+				data.Priority = priorityName(message.GetPriority())
+			}
+		case "due":
+			data.Due = nil
+			if message.GetDue() != nil {
+				due := message.GetDue().AsTime()
+				data.Due = &due
+			}
+		case "time_zone":
+			data.TimeZone = message.GetTimeZone()
+		case "recurrence":
+			data.Recurrence = message.GetRecurrence()
+		case "repeat_from":
+			data.RepeatFrom = message.GetRepeatFrom()
+		default:
+			return status.Errorf(codes.InvalidArgument, "field %q cannot be written", path)
+		}
+	}
+	return nil
+}
+
+// writable lists the fields a client may set, the default update mask.
+var writable = []string{"name", "description", "status", "priority", "due", "time_zone", "recurrence", "repeat_from"}
+
+func statusName(value todopb.Status) string {
+	for name, candidate := range statuses {
+		if candidate == value {
+			return name
+		}
+	}
+	return ""
+}
+
+func priorityName(value todopb.Priority) string {
+	for name, candidate := range priorities {
+		if candidate == value {
+			return name
+		}
+	}
+	return ""
+}
+
+func eventTypeName(value todopb.Event_Type) string {
+	for name, candidate := range eventTypes {
+		if candidate == value {
+			return name
+		}
+	}
+	return ""
+}
+
+func timestamp(at *time.Time) *timestamppb.Timestamp {
+	if at == nil {
+		return nil
+	}
+	return timestamppb.New(*at)
+}
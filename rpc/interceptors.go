@@ -0,0 +1,166 @@
+// rpc/interceptors.go
+package rpc
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/imadbg01/go-todo/auth"
+	"github.com/imadbg01/go-todo/todo"
+	"github.com/imadbg01/go-todo/todopb"
+	"github.com/jinzhu/gorm"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+type contextKey int
+
+const actorKey contextKey = iota
+
+func actorOf(ctx context.Context) todo.Actor {
+	actor, _ := ctx.Value(actorKey).(todo.Actor)
+	return actor
+}
+
+// authenticate checks the bearer token of calls to the todo service and
+// records who the call acts for. The user comes from x-user metadata, which
+// like the X-User header over HTTP is only believed from the trusted
+// proxies; health and reflection are open.
+func authenticate(ctx context.Context, method, token string, proxies auth.Proxies) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	first := func(key string) string {
+		if values := md.Get(key); len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+
+	if token != "" && strings.HasPrefix(method, "/"+todopb.TodoService_ServiceDesc.ServiceName+"/") {
+		given := strings.TrimPrefix(first("authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			return nil, status.Error(codes.Unauthenticated, "missing or invalid token")
+		}
+	}
+
+	actor := todo.Actor{RequestID: first("x-request-id")}
+	if proxies.Trust(peerIP(ctx)) {
+		actor.User = first("x-user")
+	}
+	if actor.User == "" {
+		actor.User = auth.Anonymous
+	}
+	if actor.RequestID == "" {
+		actor.RequestID = utils.UUIDv4()
+	}
+	return context.WithValue(ctx, actorKey, actor), nil
+}
+
+// peerIP returns the IP address the call came from, or nil when it did not
+// come over TCP.
+func peerIP(ctx context.Context) net.IP {
+	if p, ok := peer.FromContext(ctx); ok {
+		if addr, ok := p.Addr.(*net.TCPAddr); ok {
+			return addr.IP
+		}
+	}
+	return nil
+}
+
+func authUnary(token string, proxies auth.Proxies) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := authenticate(ctx, info.FullMethod, token, proxies)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+func authStream(token string, proxies auth.Proxies) grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := authenticate(stream.Context(), info.FullMethod, token, proxies)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &contextStream{ServerStream: stream, ctx: ctx})
+	}
+}
+
+// contextStream replaces the context of a stream.
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (stream *contextStream) Context() context.Context {
+	return stream.ctx
+}
+
+// toStatus maps errors from the todo package to gRPC status codes. Errors
+// that are not expected are logged and reported as internal.
+func toStatus(method string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	var quota *todo.QuotaError
+	switch {
+	case errors.As(err, &quota):
+		return status.Error(codes.ResourceExhausted, "Todo quota reached: "+quota.Error())
+	case err == todo.ErrConflict:
+		return status.Error(codes.Aborted, err.Error())
+	case gorm.IsRecordNotFoundError(err):
+		return status.Error(codes.NotFound, "Todo not found")
+	case err == context.Canceled:
+		return status.Error(codes.Canceled, err.Error())
+	case err == context.DeadlineExceeded:
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	}
+	log.Printf("grpc: %s failed: %v", method, err)
+	return status.Error(codes.Internal, "internal error")
+}
+
+func errorUnary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			resp, err = nil, toStatus(info.FullMethod, fmt.Errorf("panic: %v", r))
+		}
+	}()
+	resp, err = handler(ctx, req)
+	return resp, toStatus(info.FullMethod, err)
+}
+
+func errorStream(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = toStatus(info.FullMethod, fmt.Errorf("panic: %v", r))
+		}
+	}()
+	return toStatus(info.FullMethod, handler(srv, stream))
+}
+
+func logUnary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	log.Printf("grpc: %s %s %s", info.FullMethod, status.Code(err), time.Since(start))
+	return resp, err
+}
+
+func logStream(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	err := handler(srv, stream)
+	log.Printf("grpc: %s %s %s", info.FullMethod, status.Code(err), time.Since(start))
+	return err
+}
@@ -0,0 +1,95 @@
+// rpc/interceptors_test.go
+package rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/imadbg01/go-todo/auth"
+	"github.com/imadbg01/go-todo/todo"
+	"github.com/imadbg01/go-todo/todopb"
+	"github.com/jinzhu/gorm"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestToStatus(t *testing.T) {
+	for _, test := range []struct {
+		err  error
+		code codes.Code
+	}{
+		{nil, codes.OK},
+		{todo.ErrConflict, codes.Aborted},
+		{&todo.QuotaError{Count: 10, Quota: 10}, codes.ResourceExhausted},
+		{fmt.Errorf("scheduling: %w", &todo.QuotaError{Count: 10, Quota: 10}), codes.ResourceExhausted},
+		{gorm.ErrRecordNotFound, codes.NotFound},
+		{context.Canceled, codes.Canceled},
+		{status.Error(codes.InvalidArgument, "bad"), codes.InvalidArgument},
+		{errors.New("disk on fire"), codes.Internal},
+	} {
+		err := toStatus("/test", test.err)
+		if code := status.Code(err); code != test.code {
+			t.Errorf("toStatus(%v) = %v, want %v", test.err, code, test.code)
+		}
+	}
+	if err := toStatus("/test", errors.New("secret detail")); status.Convert(err).Message() != "internal error" {
+		t.Errorf("internal error leaks %q", status.Convert(err).Message())
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	s := newTestServer(t, "secret", nil)
+	conn := s.dial(t)
+	client := todopb.NewTodoServiceClient(conn)
+	ctx := context5s(t)
+
+	if _, err := client.List(ctx, &todopb.ListRequest{}); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("list without a token = %v, want Unauthenticated", err)
+	}
+	wrong := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer guess")
+	if _, err := client.List(wrong, &todopb.ListRequest{}); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("list with a wrong token = %v, want Unauthenticated", err)
+	}
+	stream, err := client.Watch(ctx, &todopb.WatchRequest{})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("watch without a token = %v, want Unauthenticated", err)
+	}
+
+	authorized := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer secret", "x-user", "mallory")
+	created, err := client.Create(authorized, &todopb.CreateRequest{Todo: &todopb.Todo{Name: "Audit"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The call did not come through a trusted proxy, so it cannot name
+	// its user.
+	if history := s.repository.History(int(created.GetId())); history[0].Actor != auth.Anonymous {
+		t.Errorf("call from an untrusted peer acted as %q", history[0].Actor)
+	}
+
+	health, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil || health.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("health check without a token = %v, %v", health, err)
+	}
+}
+
+func TestAuthenticateTrustsProxies(t *testing.T) {
+	s := newTestServer(t, "", auth.Proxies{"127.0.0.0/8"})
+	client := todopb.NewTodoServiceClient(s.dialTCP(t))
+	ctx := metadata.AppendToOutgoingContext(context5s(t), "x-user", "alice", "x-request-id", "req-1")
+
+	created, err := client.Create(ctx, &todopb.CreateRequest{Todo: &todopb.Todo{Name: "Audit"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	history := s.repository.History(int(created.GetId()))
+	if history[0].Actor != "alice" || history[0].RequestID != "req-1" {
+		t.Errorf("call through a trusted proxy recorded as %q in %q", history[0].Actor, history[0].RequestID)
+	}
+}
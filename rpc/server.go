@@ -0,0 +1,274 @@
+// rpc/server.go
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+
+	"github.com/imadbg01/go-todo/auth"
+	"github.com/imadbg01/go-todo/config"
+	"github.com/imadbg01/go-todo/feed"
+	"github.com/imadbg01/go-todo/todo"
+	"github.com/imadbg01/go-todo/todopb"
+	"github.com/jinzhu/gorm"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// TodoServer implements the gRPC todo service on the repository the REST
+// handlers use, so both write the same history and events.
+type TodoServer struct {
+	todopb.UnimplementedTodoServiceServer
+	repository *todo.TodoRepository
+	hub        *feed.Hub
+}
+
+func (server *TodoServer) Get(ctx context.Context, request *todopb.GetRequest) (*todopb.Todo, error) {
+	item, err := server.repository.Find(int(request.GetId()))
+	if err != nil {
+		return nil, status.Error(codes.NotFound, "Todo not found")
+	}
+	return toProto(item), nil
+}
+
+func (server *TodoServer) List(ctx context.Context, request *todopb.ListRequest) (*todopb.ListResponse, error) {
+	size := int(request.GetPageSize())
+	if size == 0 {
+		size = defaultPageSize
+	}
+	if size < 1 || size > maxPageSize {
+		return nil, status.Errorf(codes.InvalidArgument, "page_size must be between 1 and %d", maxPageSize)
+	}
+
+	var filter todo.Filter
+	for _, value := range request.GetStatus() {
+		name := statusName(value)
+		if name == "" {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid status %v", value)
+		}
+		filter.Status = append(filter.Status, name)
+	}
+	for _, value := range request.GetPriority() {
+		name := priorityName(value)
+		if name == "" {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid priority %v", value)
+		}
+		filter.Priority = append(filter.Priority, name)
+	}
+	if request.GetDueBefore() != nil {
+		due := request.GetDueBefore().AsTime()
+		filter.DueBefore = &due
+	}
+
+	var afterRank float64
+	var afterID uint
+	if token := request.GetPageToken(); token != "" {
+		var err error
+		if afterRank, afterID, err = todo.DecodeCursor(token); err != nil {
+			return nil, status.Error(codes.InvalidArgument, "invalid page token")
+		}
+	}
+
+	todos, err := server.repository.FindPage(filter, afterRank, afterID, size+1)
+	if err != nil {
+		return nil, err
+	}
+	total, err := server.repository.Count(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &todopb.ListResponse{TotalSize: int32(total)}
+	if len(todos) > size {
+		todos = todos[:size]
+		response.NextPageToken = todo.EncodeCursor(todos[size-1])
+	}
+	for _, item := range todos {
+		response.Todos = append(response.Todos, toProto(item))
+	}
+	return response, nil
+}
+
+func (server *TodoServer) Create(ctx context.Context, request *todopb.CreateRequest) (*todopb.Todo, error) {
+	if request.GetTodo() == nil {
+		return nil, status.Error(codes.InvalidArgument, "todo is required")
+	}
+	data := todo.Todo{Status: todo.PENDING}
+	if err := apply(&data, request.GetTodo(), writable); err != nil {
+		return nil, err
+	}
+	if err := validate(data); err != nil {
+		return nil, err
+	}
+
+	item, err := server.repository.As(actorOf(ctx)).Create(data)
+	if err != nil {
+		return nil, err
+	}
+	return toProto(item), nil
+}
+
+// Update changes the fields in the update mask. Save schedules the next
+// occurrence when that completes a recurring todo.
+func (server *TodoServer) Update(ctx context.Context, request *todopb.UpdateRequest) (*todopb.Todo, error) {
+	if request.GetTodo() == nil {
+		return nil, status.Error(codes.InvalidArgument, "todo is required")
+	}
+	current, err := server.repository.Find(int(request.GetTodo().GetId()))
+	if err != nil {
+		return nil, status.Error(codes.NotFound, "Todo not found")
+	}
+	if etag := request.GetEtag(); etag != "" && !current.MatchesETag(etag) {
+		return nil, status.Error(codes.FailedPrecondition, "Todo has changed since it was read")
+	}
+
+	paths := request.GetUpdateMask().GetPaths()
+	if len(paths) == 0 {
+		paths = writable
+	}
+	data := current
+	if err := apply(&data, request.GetTodo(), paths); err != nil {
+		return nil, err
+	}
+	if err := validate(data); err != nil {
+		return nil, err
+	}
+
+	repository := server.repository.As(actorOf(ctx))
+	item, err := repository.Save(data)
+	if err != nil {
+		return nil, err
+	}
+	return toProto(item), nil
+}
+
+func (server *TodoServer) Delete(ctx context.Context, request *todopb.DeleteRequest) (*emptypb.Empty, error) {
+	if server.repository.As(actorOf(ctx)).Delete(int(request.GetId())) == 0 {
+		return nil, status.Error(codes.NotFound, "Todo not found")
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// Watch follows the change feed until the client cancels the stream.
+func (server *TodoServer) Watch(request *todopb.WatchRequest, stream todopb.TodoService_WatchServer) error {
+	var filter feed.Filter
+	for _, value := range request.GetTypes() {
+		name := eventTypeName(value)
+		if name == "" {
+			return status.Errorf(codes.InvalidArgument, "invalid event type %v", value)
+		}
+		filter.Types = append(filter.Types, name)
+	}
+	for _, value := range request.GetStatus() {
+		name := statusName(value)
+		if name == "" {
+			return status.Errorf(codes.InvalidArgument, "invalid status %v", value)
+		}
+		filter.Status = append(filter.Status, name)
+	}
+	for _, id := range request.GetIds() {
+		filter.IDs = append(filter.IDs, uint(id))
+	}
+
+	server.hub.Follow(request.GetAfter(), filter, func(message feed.Message) error {
+		return stream.Send(toEvent(message))
+	}, nil, stream.Context().Done())
+	return nil
+}
+
+// validate applies the checks of the REST handlers, plus a name, which
+// update masks could otherwise clear.
+func validate(data todo.Todo) error {
+	if strings.TrimSpace(data.Name) == "" {
+		return status.Error(codes.InvalidArgument, "name must not be empty")
+	}
+	if err := todo.Validate(data); err != nil {
+		return status.Error(codes.InvalidArgument, "Invalid todo: "+err.Error())
+	}
+	return nil
+}
+
+func NewTodoServer(repository *todo.TodoRepository, hub *feed.Hub) *TodoServer {
+	return &TodoServer{
+		repository: repository,
+		hub:        hub,
+	}
+}
+
+// NewServer builds a gRPC server with the todo service, health checks and
+// reflection. A non-empty token is required of every call to the todo
+// service; an empty one leaves it open, which Start only allows on the
+// loopback interface. Calls name their user only through the proxies.
+func NewServer(todoServer *TodoServer, token string, proxies auth.Proxies) *grpc.Server {
+	server := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(logUnary, errorUnary, authUnary(token, proxies)),
+		grpc.ChainStreamInterceptor(logStream, errorStream, authStream(token, proxies)),
+	)
+	todopb.RegisterTodoServiceServer(server, todoServer)
+
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	healthServer.SetServingStatus(todopb.TodoService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(server, healthServer)
+
+	reflection.Register(server)
+	return server
+}
+
+// Start serves gRPC on GRPC_ADDR next to the HTTP API.
+func Start(database *gorm.DB, hub *feed.Hub) {
+	token := config.Config("GRPC_TOKEN")
+	addr, err := listenAddress(config.Config("GRPC_ADDR"), token)
+	if err != nil {
+		log.Fatalf("grpc: %v", err)
+	}
+	if token == "" {
+		log.Printf("grpc: GRPC_TOKEN is not set, serving on %s only", addr)
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Fatalf("grpc: failed to listen: %v", err)
+	}
+	server := NewServer(NewTodoServer(todo.NewTodoRepository(database), hub), token, auth.ProxiesFromConfig())
+	go func() {
+		if err := server.Serve(listener); err != nil {
+			log.Printf("grpc: server stopped: %v", err)
+		}
+	}()
+}
+
+// listenAddress returns the address to serve on. Without a token the todo
+// service is open to any caller, so it is only served on the loopback
+// interface: by default, or when GRPC_ADDR names a loopback host.
+func listenAddress(addr, token string) (string, error) {
+	if token != "" {
+		if addr == "" {
+			return ":50051", nil
+		}
+		return addr, nil
+	}
+	if addr == "" {
+		return "127.0.0.1:50051", nil
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", fmt.Errorf("invalid GRPC_ADDR %q: %v", addr, err)
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return "", fmt.Errorf("GRPC_TOKEN must be set to serve on %s", addr)
+	}
+	return addr, nil
+}
@@ -0,0 +1,288 @@
+// rpc/server_test.go
+package rpc
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/imadbg01/go-todo/auth"
+	"github.com/imadbg01/go-todo/feed"
+	"github.com/imadbg01/go-todo/outbox"
+	"github.com/imadbg01/go-todo/todo"
+	"github.com/imadbg01/go-todo/todopb"
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// testServer is the todo service on a fresh SQLite database.
+type testServer struct {
+	repository *todo.TodoRepository
+	hub        *feed.Hub
+	server     *grpc.Server
+}
+
+func newTestServer(t *testing.T, token string, proxies auth.Proxies) *testServer {
+	database, err := gorm.Open("sqlite3", filepath.Join(t.TempDir(), "rpc.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	database.DB().SetMaxOpenConns(1)
+	t.Cleanup(func() { database.Close() })
+	database.AutoMigrate(&outbox.Message{})
+	todo.Register(fiber.New(), database)
+
+	repository := todo.NewTodoRepository(database)
+	hub := feed.NewHub(16)
+	server := NewServer(NewTodoServer(repository, hub), token, proxies)
+	t.Cleanup(server.Stop)
+	return &testServer{repository: repository, hub: hub, server: server}
+}
+
+// dial connects to the server over an in-memory connection.
+func (s *testServer) dial(t *testing.T) *grpc.ClientConn {
+	listener := bufconn.Listen(1 << 20)
+	go s.server.Serve(listener)
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// dialTCP connects to the server over loopback TCP, for calls whose peer
+// address matters.
+func (s *testServer) dialTCP(t *testing.T) *grpc.ClientConn {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.server.Serve(listener)
+	conn, err := grpc.NewClient(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func context5s(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func TestCRUD(t *testing.T) {
+	s := newTestServer(t, "", nil)
+	client := todopb.NewTodoServiceClient(s.dial(t))
+	ctx := context5s(t)
+
+	created, err := client.Create(ctx, &todopb.CreateRequest{Todo: &todopb.Todo{
+		Name:     "Write report",
+		Priority: todopb.Priority_PRIORITY_HIGH,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.GetId() == 0 || created.GetStatus() != todopb.Status_STATUS_PENDING || created.GetPriority() != todopb.Priority_PRIORITY_HIGH {
+		t.Fatalf("created %v", created)
+	}
+	if _, err := client.Create(ctx, &todopb.CreateRequest{Todo: &todopb.Todo{}}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("create without a name = %v, want InvalidArgument", err)
+	}
+
+	got, err := client.Get(ctx, &todopb.GetRequest{Id: created.GetId()})
+	if err != nil || got.GetName() != "Write report" || got.GetEtag() != created.GetEtag() {
+		t.Fatalf("get = %v, %v", got, err)
+	}
+
+	updated, err := client.Update(ctx, &todopb.UpdateRequest{
+		Todo:       &todopb.Todo{Id: created.GetId(), Name: "ignored", Status: todopb.Status_STATUS_IN_PROGRESS},
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"status"}},
+		Etag:       "W/" + created.GetEtag(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.GetStatus() != todopb.Status_STATUS_IN_PROGRESS || updated.GetName() != "Write report" {
+		t.Errorf("update outside the mask changed %v", updated)
+	}
+	_, err = client.Update(ctx, &todopb.UpdateRequest{Todo: &todopb.Todo{Id: created.GetId(), Name: "Stale"}, Etag: created.GetEtag()})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("update with a stale etag = %v, want FailedPrecondition", err)
+	}
+
+	if _, err := client.Delete(ctx, &todopb.DeleteRequest{Id: created.GetId()}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Get(ctx, &todopb.GetRequest{Id: created.GetId()}); status.Code(err) != codes.NotFound {
+		t.Errorf("get after delete = %v, want NotFound", err)
+	}
+	if _, err := client.Delete(ctx, &todopb.DeleteRequest{Id: created.GetId()}); status.Code(err) != codes.NotFound {
+		t.Errorf("second delete = %v, want NotFound", err)
+	}
+}
+
+func TestUpdateSchedulesNextOccurrence(t *testing.T) {
+	s := newTestServer(t, "", nil)
+	client := todopb.NewTodoServiceClient(s.dial(t))
+	ctx := context5s(t)
+
+	due := time.Date(2024, time.January, 5, 9, 0, 0, 0, time.UTC)
+	created, err := client.Create(ctx, &todopb.CreateRequest{Todo: &todopb.Todo{
+		Name: "Backup", Due: timestamppb.New(due), Recurrence: "FREQ=WEEKLY",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.Update(ctx, &todopb.UpdateRequest{
+		Todo:       &todopb.Todo{Id: created.GetId(), Status: todopb.Status_STATUS_DONE},
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"status"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := client.List(ctx, &todopb.ListRequest{Status: []todopb.Status{todopb.Status_STATUS_PENDING}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.GetTodos()) != 1 || !list.GetTodos()[0].GetDue().AsTime().Equal(due.AddDate(0, 0, 7)) {
+		t.Errorf("pending todos after completing %v, want the next occurrence", list.GetTodos())
+	}
+}
+
+func TestList(t *testing.T) {
+	s := newTestServer(t, "", nil)
+	client := todopb.NewTodoServiceClient(s.dial(t))
+	ctx := context5s(t)
+	for i := 1; i <= 5; i++ {
+		item := todo.Todo{Name: "todo " + strconv.Itoa(i), Status: todo.PENDING}
+		if i%2 == 0 {
+			item.Status = todo.DONE
+		}
+		if _, err := s.repository.Create(item); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var names []string
+	request := &todopb.ListRequest{PageSize: 2}
+	for {
+		page, err := client.List(ctx, request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if page.GetTotalSize() != 5 {
+			t.Errorf("total size %d, want 5", page.GetTotalSize())
+		}
+		for _, item := range page.GetTodos() {
+			names = append(names, item.GetName())
+		}
+		if page.GetNextPageToken() == "" {
+			break
+		}
+		request.PageToken = page.GetNextPageToken()
+	}
+	if len(names) != 5 || names[0] != "todo 1" || names[4] != "todo 5" {
+		t.Errorf("paged through %v, want every todo in order", names)
+	}
+
+	done, err := client.List(ctx, &todopb.ListRequest{Status: []todopb.Status{todopb.Status_STATUS_DONE}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(done.GetTodos()) != 2 || done.GetTotalSize() != 2 {
+		t.Errorf("done todos %v, want 2", done.GetTodos())
+	}
+	for _, request := range []*todopb.ListRequest{{PageSize: 101}, {PageToken: "not a token"}, {Status: []todopb.Status{42}}} {
+		if _, err := client.List(ctx, request); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("list %v = %v, want InvalidArgument", request, err)
+		}
+	}
+}
+
+func TestWatch(t *testing.T) {
+	s := newTestServer(t, "", nil)
+	client := todopb.NewTodoServiceClient(s.dial(t))
+	ctx := context5s(t)
+
+	stream, err := client.Watch(ctx, &todopb.WatchRequest{Ids: []uint64{2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Publish until the stream, which follows the hub once the call has
+	// started, sees an event for the watched todo.
+	go func() {
+		for i := 0; i < 50 && ctx.Err() == nil; i++ {
+			for id := uint(1); id <= 2; id++ {
+				item := todo.Todo{Name: "watched", Status: todo.DONE}
+				item.ID = id
+				s.hub.Publish(todo.Event{ID: strconv.Itoa(i*2 + int(id)), Type: todo.EventStatusChanged, Todo: item})
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+	event, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.GetType() != todopb.Event_TYPE_STATUS_CHANGED || event.GetTodo().GetId() != 2 || event.GetTodo().GetStatus() != todopb.Status_STATUS_DONE {
+		t.Errorf("watched %v, want the status change of todo 2", event)
+	}
+
+	invalid, err := client.Watch(ctx, &todopb.WatchRequest{Types: []todopb.Event_Type{42}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := invalid.Recv(); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("watch with an unknown type = %v, want InvalidArgument", err)
+	}
+}
+
+func TestListenAddress(t *testing.T) {
+	tests := []struct {
+		addr, token string
+		want        string
+		fails       bool
+	}{
+		{"", "secret", ":50051", false},
+		{"0.0.0.0:6000", "secret", "0.0.0.0:6000", false},
+		{"", "", "127.0.0.1:50051", false},
+		{"localhost:6000", "", "localhost:6000", false},
+		{"127.0.0.1:6000", "", "127.0.0.1:6000", false},
+		{"[::1]:6000", "", "[::1]:6000", false},
+		{":50051", "", "", true},
+		{"0.0.0.0:50051", "", "", true},
+		{"example.com:50051", "", "", true},
+		{"50051", "", "", true},
+	}
+	for _, test := range tests {
+		got, err := listenAddress(test.addr, test.token)
+		if test.fails {
+			if err == nil {
+				t.Errorf("listenAddress(%q, %q) = %q, want an error", test.addr, test.token, got)
+			}
+			continue
+		}
+		if err != nil || got != test.want {
+			t.Errorf("listenAddress(%q, %q) = %q, %v, want %q", test.addr, test.token, got, err, test.want)
+		}
+	}
+}
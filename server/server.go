@@ -0,0 +1,121 @@
+// server/server.go
+// Package server builds the HTTP API: its middleware, the routes of every
+// package and the versions they are served under.
+package server
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/cors"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
+	"github.com/imadbg01/go-todo/attachment"
+	"github.com/imadbg01/go-todo/auth"
+	"github.com/imadbg01/go-todo/bodylimit"
+	"github.com/imadbg01/go-todo/caldav"
+	"github.com/imadbg01/go-todo/calendar"
+	"github.com/imadbg01/go-todo/comment"
+	"github.com/imadbg01/go-todo/config"
+	"github.com/imadbg01/go-todo/feed"
+	"github.com/imadbg01/go-todo/graph"
+	"github.com/imadbg01/go-todo/idempotency"
+	"github.com/imadbg01/go-todo/openapi"
+	"github.com/imadbg01/go-todo/ratelimit"
+	"github.com/imadbg01/go-todo/todo"
+	"github.com/imadbg01/go-todo/version"
+	"github.com/imadbg01/go-todo/webhook"
+	"github.com/jinzhu/gorm"
+)
+
+// New builds the app on db, which was opened with dsn. It returns the hub
+// of todo events as well, which the gRPC service shares.
+func New(db *gorm.DB, dsn string) (*fiber.App, *feed.Hub) {
+	// Identities are set by the proxy in front of the API, and only
+	// believed from the addresses in TRUSTED_PROXIES.
+	trustedProxies := auth.ProxiesFromConfig()
+	// Bodies are streamed so that imports are read as they arrive; every
+	// other body is read into memory by bodylimit, up to the limit.
+	bodyLimit := int(attachment.MaxSize()) + 1<<20
+	app := fiber.New(fiber.Config{
+		BodyLimit:                    bodyLimit,
+		StreamRequestBody:            true,
+		DisablePreParseMultipartForm: true,
+		EnableTrustedProxyCheck:      true,
+		TrustedProxies:               trustedProxies,
+	})
+	app.Use(bodylimit.New(bodylimit.Config{
+		Limit: bodyLimit,
+		Stream: func(c *fiber.Ctx) bool {
+			return c.Method() == fiber.MethodPost && strings.HasSuffix(c.Path(), "/todo/import")
+		},
+	}))
+	app.Use(cors.New())
+	app.Use(requestid.New())
+
+	// Every API request counts against the api limit, and writes against
+	// the write limit as well, both keyed by RATE_LIMIT_KEY.
+	limits := ratelimit.NewStore(db)
+	key := ratelimit.Key(config.ConfigOr("RATE_LIMIT_KEY", "token"))
+	app.Use("/api", ratelimit.New(ratelimit.Config{
+		Name:  "api",
+		Limit: ratelimit.Setting("RATE_LIMIT_API", "600/1m"),
+		Key:   key,
+		Store: limits,
+	}))
+	app.Use("/api", ratelimit.New(ratelimit.Config{
+		Name:    "write",
+		Limit:   ratelimit.Setting("RATE_LIMIT_WRITE", "60/1m:20"),
+		Key:     key,
+		Methods: []string{fiber.MethodPost, fiber.MethodPut, fiber.MethodPatch, fiber.MethodDelete},
+		Store:   limits,
+	}))
+	app.Use("/api", idempotency.New(db))
+	if config.ConfigOr("OPENAPI_VALIDATE", "false") == "true" {
+		app.Use(openapi.Validator(app))
+	}
+
+	// The unversioned routes answer like v1. v2 writes the fields every
+	// record shares in snake_case like the others, and leaves out DeletedAt.
+	// Deprecation and sunset dates are announced in the settings.
+	legacy := &version.Version{
+		Name:        "legacy",
+		Prefix:      "/api",
+		Successor:   "/api/v1",
+		Deprecation: version.Date("API_LEGACY_DEPRECATION", ""),
+		Sunset:      version.Date("API_LEGACY_SUNSET", ""),
+	}
+	v1 := &version.Version{
+		Name:        "v1",
+		Prefix:      "/api/v1",
+		Successor:   "/api/v2",
+		Deprecation: version.Date("API_V1_DEPRECATION", ""),
+		Sunset:      version.Date("API_V1_SUNSET", ""),
+	}
+	v2 := &version.Version{
+		Name:   "v2",
+		Prefix: "/api/v2",
+		Mapper: version.Rename(map[string]string{
+			"ID":        "id",
+			"CreatedAt": "created_at",
+			"UpdatedAt": "updated_at",
+			"DeletedAt": "",
+		}),
+	}
+
+	api := app.Group("/api")
+	versioned := version.Router(app, legacy, v1, v2)
+	hub := feed.Register(versioned, db, dsn)
+	todo.Register(versioned, db)
+	comment.Register(versioned, db)
+	attachment.Register(versioned, db)
+	webhook.Register(versioned, db)
+	calendar.Register(versioned, db)
+	caldav.Register(api, db)
+	graph.Register(api, db, hub)
+	openapi.Register(api, app)
+	version.Register(api, legacy, v1, v2)
+
+	app.Server().Handler = caldav.Methods(app.Server().Handler, "/api/caldav", "/.well-known/caldav")
+	app.All("/.well-known/caldav", caldav.WellKnown("/api/caldav/"))
+	return app, hub
+}
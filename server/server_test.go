@@ -0,0 +1,89 @@
+// server/server_test.go
+package server
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/imadbg01/go-todo/openapi"
+	"github.com/imadbg01/go-todo/outbox"
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+)
+
+// TestOpenAPI builds the app on SQLite and checks that every route is
+// described and that what the API answers matches the document.
+func TestOpenAPI(t *testing.T) {
+	db, err := gorm.Open("sqlite3", filepath.Join(t.TempDir(), "api.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.DB().SetMaxOpenConns(1)
+	defer db.Close()
+	db.AutoMigrate(&outbox.Message{})
+
+	app, _ := New(db, "")
+	for _, err := range openapi.Check(app) {
+		t.Error(err)
+	}
+	document := openapi.For(app)
+
+	// The requests exercise each kind of resource on every version, with
+	// the answers that are documented for errors as well. {id} is the id of
+	// the todo created first.
+	requests := []struct {
+		method, path, body string
+		status             int
+	}{
+		{"POST", "/api/v1/todo", `{"name":"Write the report","status":"pending","priority":"high"}`, 200},
+		{"POST", "/api/v1/todo", `{"name":"Plan","priority":"whenever"}`, 400},
+		{"GET", "/api/v1/todo", "", 200},
+		{"GET", "/api/v1/todo/{id}", "", 200},
+		{"GET", "/api/v1/todo/999", "", 404},
+		{"GET", "/api/v1/todo/abc", "", 400},
+		{"PUT", "/api/v1/todo/{id}", `{"name":"Write the report","status":"in_progress","priority":"high"}`, 200},
+		{"GET", "/api/v1/todo/search?q=report", "", 200},
+		{"GET", "/api/v1/todo/{id}/history", "", 200},
+		{"POST", "/api/v1/todo/{id}/comments", `{"body":"Due friday"}`, 201},
+		{"GET", "/api/v1/todo/{id}/comments", "", 200},
+		{"GET", "/api/v1/todo/{id}/attachments", "", 200},
+		{"GET", "/api/v1/webhooks", "", 200},
+		{"POST", "/api/v1/webhooks", `{"url":"http://127.0.0.1/hook","events":"todo.created"}`, 400},
+		{"GET", "/api/todo/{id}", "", 200},
+		{"GET", "/api/v2/todo", "", 200},
+		{"GET", "/api/v2/todo/{id}", "", 200},
+		{"GET", "/api/versions", "", 200},
+		{"DELETE", "/api/v1/todo/{id}", "", 204},
+		{"POST", "/api/v1/todo/{id}/restore", "", 200},
+	}
+	id := ""
+	for _, r := range requests {
+		path := strings.Replace(r.path, "{id}", id, 1)
+		request := httptest.NewRequest(r.method, path, strings.NewReader(r.body))
+		if r.body != "" {
+			request.Header.Set("Content-Type", "application/json")
+		}
+		response, err := app.Test(request, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := ioutil.ReadAll(response.Body)
+		if response.StatusCode != r.status {
+			t.Errorf("%s %s = %d, want %d\n%s", r.method, path, response.StatusCode, r.status, body)
+			continue
+		}
+		if id == "" && r.method == "POST" {
+			var created struct{ ID uint }
+			json.Unmarshal(body, &created)
+			id = strconv.FormatUint(uint64(created.ID), 10)
+		}
+		if err := openapi.ValidateResponse(document, r.method, request.URL.Path, response.StatusCode, response.Header.Get("Content-Type"), body); err != nil {
+			t.Errorf("%v\n%s", err, body)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+// todo/cursor.go
+package todo
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// EncodeCursor makes the opaque cursor that resumes a listing after a todo,
+// from its list position. Every API that pages through todos uses it, so
+// they all page the same way.
+func EncodeCursor(item Todo) string {
+	position := strconv.FormatFloat(item.Rank, 'g', -1, 64) + ":" + strconv.FormatUint(uint64(item.ID), 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(position))
+}
+
+// DecodeCursor returns the rank and id of the todo a cursor made by
+// EncodeCursor resumes after.
+func DecodeCursor(cursor string) (float64, uint, error) {
+	invalid := fmt.Errorf("invalid cursor %q", cursor)
+	position, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, 0, invalid
+	}
+	parts := strings.SplitN(string(position), ":", 2)
+	if len(parts) != 2 {
+		return 0, 0, invalid
+	}
+	rank, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil {
+		return 0, 0, invalid
+	}
+	id, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil || id == 0 {
+		return 0, 0, invalid
+	}
+	return rank, uint(id), nil
+}
@@ -0,0 +1,90 @@
+// todo/events.go
+package todo
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/imadbg01/go-todo/outbox"
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	EventCreated  = "todo.created"
+	EventUpdated  = "todo.updated"
+	EventDeleted  = "todo.deleted"
+	EventRestored = "todo.restored"
+
+	EventStatusChanged = "todo.status_changed"
+)
+
+// Event describes a change made to a todo. Events are written to the outbox
+// with the change and reach listeners once the outbox relay publishes them,
+// with the ID of the outbox message, which is the same on every replica.
+type Event struct {
+	ID       string `json:"id,omitempty"`
+	Type     string `json:"type"`
+	Todo     Todo   `json:"todo"`
+	Previous *Todo  `json:"previous,omitempty"`
+}
+
+var (
+	listenersMu sync.RWMutex
+	listeners   []func(Event)
+)
+
+// Subscribe registers a listener that is called synchronously for every event.
+func Subscribe(listener func(Event)) {
+	listenersMu.Lock()
+	defer listenersMu.Unlock()
+	listeners = append(listeners, listener)
+}
+
+func publish(event Event) {
+	listenersMu.RLock()
+	defer listenersMu.RUnlock()
+	for _, listener := range listeners {
+		listener(event)
+	}
+}
+
+// emit appends the events of a recorded change to the outbox.
+func emit(tx *gorm.DB, action string, before, after *Todo) error {
+	var events []Event
+	switch action {
+	case ActionCreate:
+		events = append(events, Event{Type: EventCreated, Todo: *after})
+	case ActionUpdate:
+		events = append(events, Event{Type: EventUpdated, Todo: *after, Previous: before})
+		if before.Status != after.Status {
+			events = append(events, Event{Type: EventStatusChanged, Todo: *after, Previous: before})
+		}
+	case ActionDelete:
+		events = append(events, Event{Type: EventDeleted, Todo: *after})
+	case ActionRestore:
+		events = append(events, Event{Type: EventRestored, Todo: *after})
+	}
+
+	for _, event := range events {
+		if err := outbox.Append(tx, event.Type, fmt.Sprint(event.Todo.ID), event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dispatch is the outbox sink that hands todo events to the listeners.
+func dispatch(message outbox.Message) error {
+	if !strings.HasPrefix(message.Topic, "todo.") {
+		return nil
+	}
+	var event Event
+	if err := json.Unmarshal([]byte(message.Payload), &event); err != nil {
+		return err
+	}
+	event.ID = fmt.Sprint(message.ID)
+	publish(event)
+	return nil
+}
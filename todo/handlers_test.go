@@ -0,0 +1,95 @@
+// todo/handlers_test.go
+package todo
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestGetAllPages(t *testing.T) {
+	repository := newTestRepository(t)
+	app := fiber.New()
+	app.Get("/todo", NewTodoHandler(repository).GetAll)
+
+	for _, item := range []Todo{
+		{Name: "one", Status: PENDING, Priority: "high"},
+		{Name: "two", Status: DONE, Priority: "low"},
+		{Name: "three", Status: PENDING, Priority: "low"},
+		{Name: "four", Status: PENDING, Priority: "high"},
+		{Name: "five", Status: DONE, Priority: "high"},
+	} {
+		if _, err := repository.Create(item); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// get answers the names of the todos on the page at path, the total
+	// and the next page from the Link header.
+	next := regexp.MustCompile(`^<(.+)>; rel="next"$`)
+	get := func(path string) (names []string, total, link string) {
+		t.Helper()
+		resp, err := app.Test(httptest.NewRequest("GET", path, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != 200 {
+			t.Fatalf("GET %s answered %d", path, resp.StatusCode)
+		}
+		var todos []Todo
+		json.NewDecoder(resp.Body).Decode(&todos)
+		for _, item := range todos {
+			names = append(names, item.Name)
+		}
+		if match := next.FindStringSubmatch(resp.Header.Get(fiber.HeaderLink)); match != nil {
+			link = match[1]
+		}
+		return names, resp.Header.Get("X-Total-Count"), link
+	}
+
+	if names, total, link := get("/todo"); len(names) != 5 || total != "" || link != "" {
+		t.Errorf("unpaged list = %v, total %q, link %q", names, total, link)
+	}
+
+	var names []string
+	path := "/todo?limit=2"
+	for pages := 0; path != ""; pages++ {
+		if pages == 3 {
+			t.Fatalf("still paging after %v", names)
+		}
+		page, total, link := get(path)
+		if total != "5" {
+			t.Errorf("X-Total-Count of %s = %q, want 5", path, total)
+		}
+		names = append(names, page...)
+		path = link
+	}
+	if len(names) != 5 || names[0] != "one" || names[4] != "five" {
+		t.Errorf("pages = %v, want every todo once in order", names)
+	}
+
+	// The filters carry over to the next page.
+	page, total, link := get("/todo?status=pending&priority=high,low&limit=2")
+	if total != "3" || len(page) != 2 || link == "" {
+		t.Fatalf("filtered page = %v, total %q, link %q", page, total, link)
+	}
+	if rest, _, link := get(link); len(rest) != 1 || rest[0] != "four" || link != "" {
+		t.Errorf("next filtered page = %v, link %q", rest, link)
+	}
+	if page, total, _ := get("/todo?priority=high&status=done"); total != "1" || len(page) != 1 || page[0] != "five" {
+		t.Errorf("done and high = %v, total %q", page, total)
+	}
+
+	for _, path := range []string{"/todo?limit=0", "/todo?limit=501", "/todo?limit=many", "/todo?after=not-a-cursor"} {
+		resp, err := app.Test(httptest.NewRequest("GET", path, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != 400 {
+			t.Errorf("GET %s answered %d, want 400", path, resp.StatusCode)
+		}
+	}
+}
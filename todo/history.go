@@ -0,0 +1,269 @@
+// todo/history.go
+package todo
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	ActionCreate  = "create"
+	ActionUpdate  = "update"
+	ActionDelete  = "delete"
+	ActionRestore = "restore"
+)
+
+// Actor identifies who made a change and in which request.
+type Actor struct {
+	User      string
+	RequestID string
+}
+
+type FieldChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// History is an append-only record of one change to a todo. Entries are
+// only ever inserted, in the transaction that made the change.
+type History struct {
+	ID        uint                   `gorm:"primary_key" json:"id"`
+	TodoID    uint                   `gorm:"Not Null;index" json:"todo_id"`
+	Action    string                 `gorm:"Not Null" json:"action"`
+	Actor     string                 `json:"actor"`
+	RequestID string                 `json:"request_id"`
+	Diff      string                 `gorm:"type:text" json:"-"`
+	Changes   map[string]FieldChange `gorm:"-" json:"changes"`
+	Position  uint64                 `gorm:"index" json:"-"`
+	CreatedAt time.Time              `gorm:"index" json:"created_at"`
+}
+
+func (History) TableName() string {
+	return "todo_histories"
+}
+
+func (history *History) AfterFind() error {
+	return json.Unmarshal([]byte(history.Diff), &history.Changes)
+}
+
+// diffFields lists the fields whose values differ between before and after,
+// keyed by their JSON name. A nil before records every field of after.
+func diffFields(before, after *Todo) (map[string]FieldChange, error) {
+	old, err := fieldValues(before)
+	if err != nil {
+		return nil, err
+	}
+	current, err := fieldValues(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := map[string]FieldChange{}
+	for name, value := range current {
+		if previous, ok := old[name]; before == nil || !ok || !reflect.DeepEqual(previous, value) {
+			changes[name] = FieldChange{Before: old[name], After: value}
+		}
+	}
+	for name, value := range old {
+		if _, ok := current[name]; !ok {
+			changes[name] = FieldChange{Before: value}
+		}
+	}
+	return changes, nil
+}
+
+func fieldValues(todo *Todo) (map[string]interface{}, error) {
+	values := map[string]interface{}{}
+	if todo == nil {
+		return values, nil
+	}
+	data, err := json.Marshal(todo)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil, err
+	}
+	delete(values, "ID")
+	delete(values, "CreatedAt")
+	delete(values, "UpdatedAt")
+	return values, nil
+}
+
+func (repository *TodoRepository) record(tx *gorm.DB, action string, before, after *Todo) error {
+	history, err := repository.entry(action, before, after)
+	if err != nil {
+		return err
+	}
+	if err := createHistory(tx, &history); err != nil {
+		return err
+	}
+	return emit(tx, action, before, after)
+}
+
+func (repository *TodoRepository) entry(action string, before, after *Todo) (History, error) {
+	changes, err := diffFields(before, after)
+	if err != nil {
+		return History{}, err
+	}
+	diff, err := json.Marshal(changes)
+	if err != nil {
+		return History{}, err
+	}
+
+	id := after
+	if id == nil {
+		id = before
+	}
+	return History{
+		TodoID:    id.ID,
+		Action:    action,
+		Actor:     repository.actor.User,
+		RequestID: repository.actor.RequestID,
+		Diff:      string(diff),
+	}, nil
+}
+
+// backfillHistory gives todos created before history was kept a creation
+// entry with their current fields, dated when the todo was created, so
+// reading history back to the start finds them.
+func (repository *TodoRepository) backfillHistory() error {
+	var todos []Todo
+	err := repository.database.Unscoped().
+		Where("NOT EXISTS (SELECT 1 FROM todo_histories WHERE todo_histories.todo_id = todos.id)").
+		Order("id asc").
+		Find(&todos).Error
+	if err != nil {
+		return err
+	}
+	for _, todo := range todos {
+		history, err := repository.entry(ActionCreate, nil, &todo)
+		if err != nil {
+			return err
+		}
+		history.CreatedAt = todo.CreatedAt
+		if err := createHistory(repository.database, &history); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// backfillPositions puts history recorded before sync positions existed at
+// the start, so a client's first sync still finds those todos.
+func (repository *TodoRepository) backfillPositions() error {
+	return repository.database.Model(&History{}).Where("position IS NULL").UpdateColumn("position", 0).Error
+}
+
+// createHistory inserts a history entry at its sync position. On Postgres
+// that is the ID of the writing transaction, which HistoryPosition does not
+// pass until the transaction has ended, so an entry that commits after a
+// client synced is never behind the token the client was given. SQLite runs
+// one writer at a time, so there entries commit in ID order.
+func createHistory(tx *gorm.DB, history *History) error {
+	if tx.Dialect().GetName() == "postgres" {
+		if err := tx.Raw("SELECT txid_current()").Row().Scan(&history.Position); err != nil {
+			return err
+		}
+		return tx.Create(history).Error
+	}
+	if err := tx.Create(history).Error; err != nil {
+		return err
+	}
+	history.Position = uint64(history.ID)
+	return tx.Model(history).UpdateColumn("position", history.Position).Error
+}
+
+// As returns a repository that attributes the changes it makes to actor.
+func (repository *TodoRepository) As(actor Actor) *TodoRepository {
+	scoped := *repository
+	scoped.actor = actor
+	return &scoped
+}
+
+// ChangedSince returns the todos with history entries at or after the given
+// position, soft-deleted ones included, and the position to resume from.
+// It lets sync clients catch up; a change may be returned twice, but none
+// is skipped.
+func (repository *TodoRepository) ChangedSince(position uint64) ([]Todo, uint64, error) {
+	next := repository.HistoryPosition()
+	var ids []uint
+	err := repository.database.Model(&History{}).Where("position >= ?", position).Pluck("DISTINCT todo_id", &ids).Error
+	if err != nil {
+		return nil, next, err
+	}
+	todos := []Todo{}
+	if len(ids) > 0 {
+		err = repository.database.Unscoped().Where("id IN (?)", ids).Order("id asc").Find(&todos).Error
+	}
+	return todos, next, err
+}
+
+// HistoryPosition returns the position below which every history entry is
+// committed: on Postgres the oldest transaction still running, elsewhere
+// the position after the latest entry.
+func (repository *TodoRepository) HistoryPosition() uint64 {
+	var position uint64
+	if repository.database.Dialect().GetName() == "postgres" {
+		repository.database.Raw("SELECT txid_snapshot_xmin(txid_current_snapshot())").Row().Scan(&position)
+		return position
+	}
+	repository.database.Model(&History{}).Select("COALESCE(MAX(position), 0) + 1").Row().Scan(&position)
+	return position
+}
+
+func (repository *TodoRepository) History(id int) []History {
+	histories := []History{}
+	repository.database.Where("todo_id = ?", id).Order("id asc").Find(&histories)
+	return histories
+}
+
+// AsOf reconstructs a todo as it was at the given time by replaying its
+// history.
+func (repository *TodoRepository) AsOf(id int, at time.Time) (Todo, error) {
+	var histories []History
+	repository.database.Where("todo_id = ? AND created_at <= ?", id, at).Order("id asc").Find(&histories)
+	return repository.replay(id, histories)
+}
+
+// Revision reconstructs a todo as it was right after the given history entry.
+func (repository *TodoRepository) Revision(id int, revision uint) (Todo, error) {
+	var histories []History
+	repository.database.Where("todo_id = ? AND id <= ?", id, revision).Order("id asc").Find(&histories)
+	if len(histories) == 0 || histories[len(histories)-1].ID != revision {
+		return Todo{}, errors.New("Revision not found")
+	}
+	return repository.replay(id, histories)
+}
+
+func (repository *TodoRepository) replay(id int, histories []History) (Todo, error) {
+	var todo Todo
+	if len(histories) == 0 {
+		return todo, errors.New("Todo not found")
+	}
+
+	values := map[string]interface{}{}
+	for _, history := range histories {
+		for name, change := range history.Changes {
+			values[name] = change.After
+		}
+	}
+	data, err := json.Marshal(values)
+	if err != nil {
+		return todo, err
+	}
+	if err := json.Unmarshal(data, &todo); err != nil {
+		return todo, err
+	}
+
+	var current Todo
+	repository.database.Unscoped().Select("created_at").First(&current, id)
+	todo.ID = uint(id)
+	todo.CreatedAt = current.CreatedAt
+	todo.UpdatedAt = histories[len(histories)-1].CreatedAt
+	return todo, nil
+}
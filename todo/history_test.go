@@ -0,0 +1,274 @@
+// todo/history_test.go
+package todo
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jinzhu/gorm"
+)
+
+func TestHistoryRecordsEveryChange(t *testing.T) {
+	repository := newTestRepository(t).As(Actor{User: "alice", RequestID: "req-1"})
+	todo, err := repository.Create(Todo{Name: "Write report", Status: PENDING})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	todo.Status = PROGRESS
+	if todo, err = repository.Save(todo); err != nil {
+		t.Fatal(err)
+	}
+	if repository.Delete(int(todo.ID)) != 1 {
+		t.Fatal("todo not deleted")
+	}
+	if _, err := repository.Restore(int(todo.ID)); err != nil {
+		t.Fatal(err)
+	}
+
+	histories := repository.History(int(todo.ID))
+	actions := []string{ActionCreate, ActionUpdate, ActionDelete, ActionRestore}
+	if len(histories) != len(actions) {
+		t.Fatalf("%d history entries, want %d", len(histories), len(actions))
+	}
+	for i, history := range histories {
+		if history.Action != actions[i] {
+			t.Errorf("entry %d is %q, want %q", i, history.Action, actions[i])
+		}
+		if history.Actor != "alice" || history.RequestID != "req-1" {
+			t.Errorf("entry %d attributed to %q in %q", i, history.Actor, history.RequestID)
+		}
+	}
+
+	if name := histories[0].Changes["name"]; name.Before != nil || name.After != "Write report" {
+		t.Errorf("creation recorded name %+v", name)
+	}
+	update := histories[1].Changes
+	if len(update) != 1 || update["status"].Before != PENDING || update["status"].After != PROGRESS {
+		t.Errorf("update recorded %+v, want only the status change", update)
+	}
+	if _, ok := histories[2].Changes["DeletedAt"]; !ok {
+		t.Errorf("deletion recorded %+v, want DeletedAt", histories[2].Changes)
+	}
+}
+
+func TestHistoryRollsBackWithTheChange(t *testing.T) {
+	repository := newTestRepository(t)
+	todo, err := repository.Create(Todo{Name: "Pay rent", Status: PENDING, Recurrence: "FREQ=HOURLY"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The next occurrence of an unsupported rule cannot be created, so
+	// completing fails and neither the change nor its history is kept.
+	todo.Status = DONE
+	if _, err := repository.Save(todo); err == nil {
+		t.Fatal("completing with an invalid rule succeeded")
+	}
+	if histories := repository.History(int(todo.ID)); len(histories) != 1 {
+		t.Fatalf("%d history entries after a failed save, want 1", len(histories))
+	}
+}
+
+func TestBackfillHistory(t *testing.T) {
+	repository := newTestRepository(t)
+	created := time.Date(2020, time.March, 1, 12, 0, 0, 0, time.UTC)
+	legacy := Todo{Name: "From before history", Status: PENDING, Priority: PriorityNone}
+	legacy.CreatedAt = created
+	if err := repository.database.Create(&legacy).Error; err != nil {
+		t.Fatal(err)
+	}
+	recorded, err := repository.Create(Todo{Name: "Already recorded", Status: PENDING})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := repository.backfillHistory(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	histories := repository.History(int(legacy.ID))
+	if len(histories) != 1 || histories[0].Action != ActionCreate {
+		t.Fatalf("legacy todo history %+v, want a single creation", histories)
+	}
+	if !histories[0].CreatedAt.Equal(created) {
+		t.Errorf("backfilled entry dated %v, want %v", histories[0].CreatedAt, created)
+	}
+	if histories[0].Changes["name"].After != "From before history" {
+		t.Errorf("backfilled entry recorded %+v", histories[0].Changes)
+	}
+	if histories := repository.History(int(recorded.ID)); len(histories) != 1 {
+		t.Errorf("%d entries for a todo with history, want 1", len(histories))
+	}
+}
+
+func TestAsOf(t *testing.T) {
+	repository := newTestRepository(t)
+	todo, err := repository.Create(Todo{Name: "Draft", Status: PENDING})
+	if err != nil {
+		t.Fatal(err)
+	}
+	todo.Name = "Final"
+	todo.Status = DONE
+	if _, err := repository.Save(todo); err != nil {
+		t.Fatal(err)
+	}
+
+	// Date the entries apart so the reads do not depend on the clock.
+	created := time.Date(2024, time.May, 1, 9, 0, 0, 0, time.UTC)
+	histories := repository.History(int(todo.ID))
+	for i, history := range histories {
+		repository.database.Model(&history).UpdateColumn("created_at", created.Add(time.Duration(i)*time.Hour))
+	}
+
+	if _, err := repository.AsOf(int(todo.ID), created.Add(-time.Minute)); err == nil {
+		t.Error("read a todo before it was created")
+	}
+	draft, err := repository.AsOf(int(todo.ID), created.Add(30*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if draft.ID != todo.ID || draft.Name != "Draft" || draft.Status != PENDING {
+		t.Errorf("as of the creation got %+v", draft)
+	}
+	final, err := repository.AsOf(int(todo.ID), created.Add(2*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if final.Name != "Final" || final.Status != DONE {
+		t.Errorf("as of the update got %+v", final)
+	}
+}
+
+func TestRevision(t *testing.T) {
+	repository := newTestRepository(t)
+	todo, err := repository.Create(Todo{Name: "Draft", Status: PENDING})
+	if err != nil {
+		t.Fatal(err)
+	}
+	todo.Name = "Final"
+	if _, err := repository.Save(todo); err != nil {
+		t.Fatal(err)
+	}
+	other, err := repository.Create(Todo{Name: "Other", Status: PENDING})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	histories := repository.History(int(todo.ID))
+	revision, err := repository.Revision(int(todo.ID), histories[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if revision.Name != "Draft" {
+		t.Errorf("first revision named %q, want Draft", revision.Name)
+	}
+	if _, err := repository.Revision(int(todo.ID), repository.History(int(other.ID))[0].ID); err == nil {
+		t.Error("read the revision of another todo")
+	}
+}
+
+func TestRevertSchedulesNextOccurrence(t *testing.T) {
+	repository := newTestRepository(t)
+	app := fiber.New()
+	app.Post("/todo/:id/revert", NewTodoHandler(repository).Revert)
+
+	due := time.Date(2024, time.January, 5, 9, 0, 0, 0, time.UTC)
+	todo, err := repository.Create(Todo{Name: "Backup", Status: PENDING, Due: &due, Recurrence: "FREQ=WEEKLY"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	todo.Status = DONE
+	if todo, err = repository.Save(todo); err != nil {
+		t.Fatal(err)
+	}
+	done := repository.History(int(todo.ID))[1]
+	todo.Status = PENDING
+	if _, err := repository.Save(todo); err != nil {
+		t.Fatal(err)
+	}
+
+	body := strings.NewReader(fmt.Sprintf(`{"revision":%d}`, done.ID))
+	req := httptest.NewRequest("POST", fmt.Sprintf("/todo/%d/revert", todo.ID), body)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 200 {
+		t.Fatalf("revert answered %d", resp.StatusCode)
+	}
+
+	reverted, _ := repository.Find(int(todo.ID))
+	if reverted.Status != DONE {
+		t.Errorf("status after the revert = %q, want done", reverted.Status)
+	}
+	if todos := repository.FindAll(); len(todos) != 3 {
+		t.Errorf("%d todos after completing twice, want an occurrence per completion", len(todos))
+	}
+}
+
+func TestChangedSince(t *testing.T) {
+	repository := newTestRepository(t)
+	first, err := repository.Create(Todo{Name: "First", Status: PENDING})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := repository.Create(Todo{Name: "Second", Status: PENDING})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	all, position, err := repository.ChangedSince(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 2 {
+		t.Fatalf("initial sync returned %d todos, want 2", len(all))
+	}
+	if changed, _, _ := repository.ChangedSince(position); len(changed) != 0 {
+		t.Fatalf("%d todos changed without a change", len(changed))
+	}
+
+	second.Name = "Second again"
+	if _, err := repository.Save(second); err != nil {
+		t.Fatal(err)
+	}
+	repository.Delete(int(first.ID))
+	changed, next, err := repository.ChangedSince(position)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changed) != 2 || changed[0].DeletedAt == nil || changed[1].Name != "Second again" {
+		t.Fatalf("changes %+v, want the deletion and the update", changed)
+	}
+	if next <= position {
+		t.Errorf("position %d did not move past %d", next, position)
+	}
+}
+
+func TestBackfillPositions(t *testing.T) {
+	repository := newTestRepository(t)
+	legacy, err := repository.Create(Todo{Name: "Synced before positions", Status: PENDING})
+	if err != nil {
+		t.Fatal(err)
+	}
+	repository.database.Model(&History{}).UpdateColumn("position", gorm.Expr("NULL"))
+
+	if err := repository.backfillPositions(); err != nil {
+		t.Fatal(err)
+	}
+	changed, _, err := repository.ChangedSince(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changed) != 1 || changed[0].ID != legacy.ID {
+		t.Fatalf("initial sync returned %+v, want the todo with legacy history", changed)
+	}
+}
@@ -0,0 +1,214 @@
+// todo/ical.go
+package todo
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/imadbg01/go-todo/ical"
+)
+
+var icalStatuses = map[string]string{
+	PENDING:  "NEEDS-ACTION",
+	PROGRESS: "IN-PROCESS",
+	DONE:     "COMPLETED",
+}
+
+// icalPriorities follow RFC 5545, where 1 is the highest priority and 0
+// leaves it undefined.
+var icalPriorities = map[string]string{
+	PriorityUrgent: "1",
+	PriorityHigh:   "3",
+	PriorityMedium: "5",
+	PriorityLow:    "7",
+}
+
+// CalendarUID identifies the todo in calendar clients. Todos created by a
+// client keep the UID it gave them.
+func (todo Todo) CalendarUID() string {
+	if todo.UID != "" {
+		return todo.UID
+	}
+	return fmt.Sprintf("todo-%d@go-todo", todo.ID)
+}
+
+// ToVTODO maps a todo onto a VTODO component. Due dates of todos with a
+// time zone are written as wall-clock time in that zone, so that their
+// recurrence follows daylight saving time.
+func ToVTODO(todo Todo) ical.Component {
+	vtodo := ical.Component{Name: "VTODO"}
+	vtodo.AddText("UID", todo.CalendarUID())
+	vtodo.AddTime("DTSTAMP", todo.UpdatedAt)
+	vtodo.AddTime("CREATED", todo.CreatedAt)
+	vtodo.AddTime("LAST-MODIFIED", todo.UpdatedAt)
+	vtodo.AddText("SUMMARY", todo.Name)
+	if todo.Description != "" {
+		vtodo.AddText("DESCRIPTION", todo.Description)
+	}
+	if status, ok := icalStatuses[todo.Status]; ok {
+		vtodo.Add("STATUS", status)
+	}
+	if todo.CompletedAt != nil {
+		vtodo.AddTime("COMPLETED", *todo.CompletedAt)
+	}
+	if priority, ok := icalPriorities[todo.Priority]; ok {
+		vtodo.Add("PRIORITY", priority)
+	}
+	if todo.Due != nil {
+		location, err := todo.Location()
+		if err != nil {
+			location = time.UTC
+		}
+		vtodo.AddLocalTime("DUE", *todo.Due, location)
+	}
+	if todo.Recurrence != "" {
+		vtodo.Add("RRULE", strings.TrimPrefix(strings.TrimSpace(todo.Recurrence), "RRULE:"))
+	}
+	return vtodo
+}
+
+// FromVTODO reads the fields a VTODO shares with a todo. Properties with no
+// field of their own are dropped.
+func FromVTODO(vtodo ical.Component) (Todo, error) {
+	todo := Todo{Status: PENDING, Priority: PriorityNone}
+	if uid, ok := vtodo.Get("UID"); ok {
+		todo.UID = uid.Text()
+	}
+	if summary, ok := vtodo.Get("SUMMARY"); ok {
+		todo.Name = summary.Text()
+	}
+	if strings.TrimSpace(todo.Name) == "" {
+		todo.Name = "Untitled"
+	}
+	if description, ok := vtodo.Get("DESCRIPTION"); ok {
+		todo.Description = description.Text()
+	}
+	if status, ok := vtodo.Get("STATUS"); ok {
+		for key, value := range icalStatuses {
+			if strings.EqualFold(status.Value, value) {
+				todo.Status = key
+			}
+		}
+	}
+	if priority, ok := vtodo.Get("PRIORITY"); ok {
+		switch level, _ := strconv.Atoi(priority.Value); {
+		case level == 1:
+			todo.Priority = PriorityUrgent
+		case level >= 2 && level <= 4:
+			todo.Priority = PriorityHigh
+		case level == 5:
+			todo.Priority = PriorityMedium
+		case level >= 6 && level <= 9:
+			todo.Priority = PriorityLow
+		}
+	}
+	if due, ok := vtodo.Get("DUE"); ok {
+		at, location, err := due.Time()
+		if err != nil {
+			return todo, fmt.Errorf("invalid DUE: %v", err)
+		}
+		todo.Due = &at
+		if location != nil {
+			todo.TimeZone = location.String()
+		}
+	}
+	if completed, ok := vtodo.Get("COMPLETED"); ok && todo.Status == DONE {
+		at, _, err := completed.Time()
+		if err != nil {
+			return todo, fmt.Errorf("invalid COMPLETED: %v", err)
+		}
+		todo.CompletedAt = &at
+	}
+	if rrule, ok := vtodo.Get("RRULE"); ok {
+		todo.Recurrence = rrule.Value
+	}
+	return todo, nil
+}
+
+// CalendarQuery selects todos the way a CalDAV calendar-query does.
+type CalendarQuery struct {
+	// Completed selects the todos with (true) or without (false) a
+	// COMPLETED property; nil selects both.
+	Completed *bool
+	// Start and End bound the time range; nil leaves that side open.
+	Start, End *time.Time
+}
+
+// FindForCalendar returns the todos a calendar query selects. Todos have no
+// DTSTART or DURATION, so RFC 4791 matches the time range against DUE when
+// there is one, and against CREATED and COMPLETED otherwise.
+func (repository *TodoRepository) FindForCalendar(query CalendarQuery) []Todo {
+	database := repository.database
+	if query.Completed != nil && *query.Completed {
+		database = database.Where("completed_at IS NOT NULL")
+	} else if query.Completed != nil {
+		database = database.Where("completed_at IS NULL")
+	}
+	if query.Start != nil || query.End != nil {
+		start, end := time.Time{}, time.Date(9999, time.December, 31, 0, 0, 0, 0, time.UTC)
+		if query.Start != nil {
+			start = query.Start.UTC()
+		}
+		if query.End != nil {
+			end = query.End.UTC()
+		}
+		database = database.Where("(due IS NOT NULL AND due > ? AND due <= ?)"+
+			" OR (due IS NULL AND completed_at IS NOT NULL AND (created_at >= ? OR completed_at >= ?) AND (created_at <= ? OR completed_at <= ?))"+
+			" OR (due IS NULL AND completed_at IS NULL AND created_at < ?)",
+			start, end, start, start, end, end, end)
+	}
+	var todos []Todo
+	database.Order("rank asc, id asc").Find(&todos)
+	return todos
+}
+
+// WriteCalendar streams every todo to w as a VCALENDAR of VTODO components,
+// preceded by the VTIMEZONE components their due dates refer to.
+func (repository *TodoRepository) WriteCalendar(w io.Writer, name string) error {
+	writer := beginCalendar(w)
+	writer.Property(ical.Property{Name: "X-WR-CALNAME", Value: ical.EscapeText(name)})
+	for _, zone := range repository.TimeZones() {
+		writeTimezone(writer, zone)
+	}
+
+	err := repository.Each(500, func(todo Todo) error {
+		return writer.Component(ToVTODO(todo))
+	})
+	if err != nil {
+		return err
+	}
+	return writer.End("VCALENDAR")
+}
+
+// WriteVTODO writes a todo as a calendar object of its own, the way CalDAV
+// serves each todo.
+func WriteVTODO(w io.Writer, todo Todo) error {
+	writer := beginCalendar(w)
+	writeTimezone(writer, todo.TimeZone)
+	writer.Component(ToVTODO(todo))
+	return writer.End("VCALENDAR")
+}
+
+func beginCalendar(w io.Writer) *ical.Writer {
+	writer := ical.NewWriter(w)
+	writer.Begin("VCALENDAR")
+	writer.Property(ical.Property{Name: "VERSION", Value: "2.0"})
+	writer.Property(ical.Property{Name: "PRODID", Value: "-//go-todo//todo//EN"})
+	writer.Property(ical.Property{Name: "CALSCALE", Value: "GREGORIAN"})
+	return writer
+}
+
+// writeTimezone writes the VTIMEZONE of a zone with its offset changes from
+// the start of last year over the next six years.
+func writeTimezone(writer *ical.Writer, zone string) {
+	location, err := time.LoadLocation(zone)
+	if zone == "" || err != nil || location == time.UTC {
+		return
+	}
+	now := time.Now()
+	from := time.Date(now.Year()-1, 1, 1, 0, 0, 0, 0, time.UTC)
+	writer.Component(ical.Timezone(location, from, from.AddDate(6, 0, 0)))
+}
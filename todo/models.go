@@ -1,7 +1,13 @@
 // todo/models.go
 package todo
 
-import "github.com/jinzhu/gorm"
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
 
 const (
 	PENDING  = "pending"
@@ -9,9 +15,68 @@ const (
 	DONE     = "done"
 )
 
+const (
+	PriorityNone   = "none"
+	PriorityLow    = "low"
+	PriorityMedium = "medium"
+	PriorityHigh   = "high"
+	PriorityUrgent = "urgent"
+)
+
 type Todo struct {
 	gorm.Model
-	Name        string `gorm:"Not Null" json:"name"`
-	Description string `json:"description"`
-	Status      string `gorm:"Not Null" json:"status"`
+	ExternalID  string     `gorm:"index" json:"external_id"`
+	UID         string     `gorm:"index" json:"uid"`
+	Name        string     `gorm:"Not Null" json:"name"`
+	Description string     `json:"description"`
+	Status      string     `gorm:"Not Null" json:"status"`
+	Priority    string     `gorm:"Not Null;Default:'none'" json:"priority"`
+	Rank        float64    `gorm:"index" json:"rank"`
+	Due         *time.Time `json:"due"`
+	TimeZone    string     `json:"time_zone"`
+	Recurrence  string     `json:"recurrence"`
+	RepeatFrom  string     `json:"repeat_from"`
+	SeriesID    uint       `gorm:"index" json:"series_id"`
+	Occurrence  int        `json:"occurrence"`
+	CompletedAt *time.Time `json:"completed_at"`
+	// TxtDates records which dates the todo.txt line the todo was imported
+	// from carried, so that exports write the same ones. It is empty for
+	// todos from anywhere else, which are written with all their dates.
+	TxtDates string `json:"-"`
+}
+
+// Location returns the time zone used to expand the todo's recurrence rule.
+func (todo Todo) Location() (*time.Location, error) {
+	if todo.TimeZone == "" {
+		return time.UTC, nil
+	}
+	return time.LoadLocation(todo.TimeZone)
+}
+
+// ETag identifies the stored version of the todo for conditional requests.
+func (todo Todo) ETag() string {
+	return fmt.Sprintf("\"%d-%d\"", todo.ID, todo.UpdatedAt.Round(time.Microsecond).UnixNano()/int64(time.Microsecond))
+}
+
+// MatchesETag reports whether an If-Match or If-None-Match header lists the
+// todo's ETag or is "*". Tags are compared weakly, since proxies that
+// compress responses pass ETags on marked as weak.
+func (todo Todo) MatchesETag(header string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == todo.ETag() {
+			return true
+		}
+	}
+	return false
+}
+
+// complete keeps CompletedAt in step with the status.
+func (todo *Todo) complete() {
+	if todo.Status != DONE {
+		todo.CompletedAt = nil
+	} else if todo.CompletedAt == nil {
+		now := time.Now()
+		todo.CompletedAt = &now
+	}
 }
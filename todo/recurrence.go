@@ -0,0 +1,478 @@
+// todo/recurrence.go
+package todo
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata"
+)
+
+const (
+	RepeatFromDue        = "due"
+	RepeatFromCompletion = "completion"
+)
+
+// maxPeriods bounds the search for instances of rules that rarely or never match.
+const maxPeriods = 10000
+
+var weekdays = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+type weekdayNum struct {
+	N   int
+	Day time.Weekday
+}
+
+// Rule is the subset of an RFC 5545 RRULE supported for recurring todos.
+type Rule struct {
+	Freq       string
+	Interval   int
+	Count      int
+	Until      time.Time
+	ByDay      []weekdayNum
+	ByMonthDay []int
+	ByMonth    []int
+	BySetPos   []int
+	WeekStart  time.Weekday
+
+	untilFloating bool
+}
+
+func ParseRule(value string) (Rule, error) {
+	rule := Rule{Interval: 1, WeekStart: time.Monday}
+	value = strings.TrimPrefix(strings.TrimSpace(value), "RRULE:")
+
+	for _, part := range strings.Split(value, ";") {
+		if part == "" {
+			continue
+		}
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return rule, fmt.Errorf("invalid rule part %q", part)
+		}
+		key, val := strings.ToUpper(kv[0]), strings.ToUpper(kv[1])
+
+		var err error
+		switch key {
+		case "FREQ":
+			switch val {
+			case "DAILY", "WEEKLY", "MONTHLY", "YEARLY":
+				rule.Freq = val
+			default:
+				err = fmt.Errorf("unsupported FREQ %q", val)
+			}
+		case "INTERVAL":
+			rule.Interval, err = parseInt(val, 1, 1<<16)
+		case "COUNT":
+			rule.Count, err = parseInt(val, 1, 1<<16)
+		case "UNTIL":
+			rule.Until, rule.untilFloating, err = parseUntil(val)
+		case "BYDAY":
+			for _, day := range strings.Split(val, ",") {
+				var wd weekdayNum
+				if wd, err = parseWeekdayNum(day); err != nil {
+					break
+				}
+				rule.ByDay = append(rule.ByDay, wd)
+			}
+		case "BYMONTHDAY":
+			rule.ByMonthDay, err = parseIntList(val, -31, 31)
+		case "BYMONTH":
+			rule.ByMonth, err = parseIntList(val, 1, 12)
+		case "BYSETPOS":
+			rule.BySetPos, err = parseIntList(val, -366, 366)
+		case "WKST":
+			day, ok := weekdays[val]
+			if !ok {
+				err = fmt.Errorf("invalid WKST %q", val)
+			}
+			rule.WeekStart = day
+		default:
+			err = fmt.Errorf("unsupported rule part %s", key)
+		}
+		if err != nil {
+			return rule, err
+		}
+	}
+
+	if rule.Freq == "" {
+		return rule, errors.New("FREQ is required")
+	}
+	if rule.Count > 0 && !rule.Until.IsZero() {
+		return rule, errors.New("COUNT and UNTIL cannot be combined")
+	}
+	for _, day := range rule.ByDay {
+		if day.N != 0 && rule.Freq != "MONTHLY" && rule.Freq != "YEARLY" {
+			return rule, errors.New("numbered BYDAY is only allowed with MONTHLY or YEARLY")
+		}
+	}
+	if len(rule.ByMonthDay) > 0 && rule.Freq == "WEEKLY" {
+		return rule, errors.New("BYMONTHDAY is not allowed with WEEKLY")
+	}
+	return rule, nil
+}
+
+// Occurrences returns up to limit instances of the rule, anchored at dtstart,
+// that fall strictly after the given time. Instances keep the wall-clock time
+// of dtstart in its location, so they do not drift across DST changes.
+func (rule Rule) Occurrences(dtstart, after time.Time, limit int) []time.Time {
+	var out []time.Time
+	loc := dtstart.Location()
+	until := rule.Until
+	if rule.untilFloating {
+		until = time.Date(until.Year(), until.Month(), until.Day(), until.Hour(), until.Minute(), until.Second(), 0, loc)
+	}
+	start := civilDate(dtstart)
+
+	emitted := 0
+	for i := 0; i < maxPeriods && len(out) < limit; i++ {
+		for _, day := range rule.expand(start, i) {
+			instance := time.Date(day.Year(), day.Month(), day.Day(), dtstart.Hour(), dtstart.Minute(), dtstart.Second(), 0, loc)
+			if instance.Before(dtstart) {
+				continue
+			}
+			if !until.IsZero() && instance.After(until) {
+				return out
+			}
+			emitted++
+			if rule.Count > 0 && emitted > rule.Count {
+				return out
+			}
+			if instance.After(after) {
+				out = append(out, instance)
+				if len(out) == limit {
+					return out
+				}
+			}
+		}
+	}
+	return out
+}
+
+// expand returns the sorted calendar days of the i-th period of the rule.
+func (rule Rule) expand(start time.Time, i int) []time.Time {
+	var days []time.Time
+	step := i * rule.Interval
+
+	switch rule.Freq {
+	case "DAILY":
+		days = []time.Time{start.AddDate(0, 0, step)}
+	case "WEEKLY":
+		offset := (int(start.Weekday()) - int(rule.WeekStart) + 7) % 7
+		first := start.AddDate(0, 0, step*7-offset)
+		for k := 0; k < 7; k++ {
+			day := first.AddDate(0, 0, k)
+			if len(rule.ByDay) == 0 && day.Weekday() != start.Weekday() {
+				continue
+			}
+			days = append(days, day)
+		}
+	case "MONTHLY":
+		first := time.Date(start.Year(), start.Month()+time.Month(step), 1, 0, 0, 0, 0, time.UTC)
+		days = rule.monthDays(first, start)
+	case "YEARLY":
+		year := start.Year() + step
+		if len(rule.ByMonth) == 0 && len(rule.ByMonthDay) == 0 && len(rule.ByDay) > 0 {
+			days = rule.yearDays(year)
+			break
+		}
+		// Without BYMONTH, BYMONTHDAY picks days in every month of the
+		// year, and the rule only repeats in the start month otherwise.
+		months := rule.ByMonth
+		if len(months) == 0 && len(rule.ByMonthDay) > 0 {
+			months = []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}
+		}
+		if len(months) == 0 {
+			months = []int{int(start.Month())}
+		}
+		for _, month := range months {
+			first := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+			days = append(days, rule.monthDays(first, start)...)
+		}
+	}
+
+	filtered := days[:0]
+	for _, day := range days {
+		if len(rule.ByMonth) > 0 && !containsInt(rule.ByMonth, int(day.Month())) {
+			continue
+		}
+		if rule.Freq == "DAILY" || rule.Freq == "WEEKLY" {
+			if len(rule.ByDay) > 0 && !matchWeekday(rule.ByDay, day) {
+				continue
+			}
+			if len(rule.ByMonthDay) > 0 && !matchMonthDay(rule.ByMonthDay, day.Day(), daysIn(day)) {
+				continue
+			}
+		}
+		filtered = append(filtered, day)
+	}
+	sort.Slice(filtered, func(a, b int) bool { return filtered[a].Before(filtered[b]) })
+
+	if len(rule.BySetPos) == 0 {
+		return filtered
+	}
+	var selected []time.Time
+	for k, day := range filtered {
+		for _, pos := range rule.BySetPos {
+			if pos == k+1 || pos == k-len(filtered) {
+				selected = append(selected, day)
+				break
+			}
+		}
+	}
+	return selected
+}
+
+func (rule Rule) monthDays(first, start time.Time) []time.Time {
+	var days []time.Time
+	n := daysIn(first)
+	for d := 1; d <= n; d++ {
+		day := first.AddDate(0, 0, d-1)
+		if len(rule.ByMonthDay) == 0 && len(rule.ByDay) == 0 && d != start.Day() {
+			continue
+		}
+		if len(rule.ByMonthDay) > 0 && !matchMonthDay(rule.ByMonthDay, d, n) {
+			continue
+		}
+		if len(rule.ByDay) > 0 && !matchNumberedWeekday(rule.ByDay, day, d, n) {
+			continue
+		}
+		days = append(days, day)
+	}
+	return days
+}
+
+func (rule Rule) yearDays(year int) []time.Time {
+	var days []time.Time
+	first := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	n := first.AddDate(1, 0, -1).YearDay()
+	for d := 1; d <= n; d++ {
+		day := first.AddDate(0, 0, d-1)
+		if matchNumberedWeekday(rule.ByDay, day, d, n) {
+			days = append(days, day)
+		}
+	}
+	return days
+}
+
+// NextOccurrence builds the todo that follows a completed occurrence of a
+// recurring todo. It reports false once the series has run out.
+func NextOccurrence(todo Todo, completed time.Time) (Todo, bool, error) {
+	rule, err := ParseRule(todo.Recurrence)
+	if err != nil {
+		return Todo{}, false, err
+	}
+	loc, err := todo.Location()
+	if err != nil {
+		return Todo{}, false, err
+	}
+
+	occurrence := todo.Occurrence
+	if occurrence == 0 {
+		occurrence = 1
+	}
+	if rule.Count > 0 && occurrence >= rule.Count {
+		return Todo{}, false, nil
+	}
+	rule.Count = 0
+
+	completed = completed.In(loc)
+	clock := completed
+	if todo.Due != nil {
+		clock = todo.Due.In(loc)
+	}
+
+	var dtstart time.Time
+	if todo.RepeatFrom == RepeatFromCompletion || todo.Due == nil {
+		dtstart = time.Date(completed.Year(), completed.Month(), completed.Day(), clock.Hour(), clock.Minute(), clock.Second(), 0, loc)
+	} else {
+		dtstart = clock
+	}
+
+	next := rule.Occurrences(dtstart, dtstart, 1)
+	if len(next) == 0 {
+		return Todo{}, false, nil
+	}
+
+	seriesID := todo.SeriesID
+	if seriesID == 0 {
+		seriesID = todo.ID
+	}
+	return Todo{
+		Name:        todo.Name,
+		Description: todo.Description,
+		Status:      PENDING,
+		Due:         &next[0],
+		TimeZone:    todo.TimeZone,
+		Recurrence:  todo.Recurrence,
+		RepeatFrom:  todo.RepeatFrom,
+		SeriesID:    seriesID,
+		Occurrence:  occurrence + 1,
+	}, true, nil
+}
+
+// UpcomingOccurrences previews the due dates of the next n occurrences after
+// the todo's current one.
+func UpcomingOccurrences(todo Todo, n int) ([]time.Time, error) {
+	rule, err := ParseRule(todo.Recurrence)
+	if err != nil {
+		return nil, err
+	}
+	loc, err := todo.Location()
+	if err != nil {
+		return nil, err
+	}
+
+	dtstart := time.Now().In(loc)
+	if todo.Due != nil {
+		dtstart = todo.Due.In(loc)
+	}
+	if rule.Count > 0 {
+		occurrence := todo.Occurrence
+		if occurrence == 0 {
+			occurrence = 1
+		}
+		remaining := rule.Count - occurrence
+		if remaining < n {
+			n = remaining
+		}
+		rule.Count = 0
+	}
+	if n <= 0 {
+		return []time.Time{}, nil
+	}
+	return rule.Occurrences(dtstart, dtstart, n), nil
+}
+
+func validateRecurrence(todo Todo) error {
+	if _, err := todo.Location(); err != nil {
+		return err
+	}
+	switch todo.RepeatFrom {
+	case "", RepeatFromDue, RepeatFromCompletion:
+	default:
+		return fmt.Errorf("repeat_from must be %q or %q", RepeatFromDue, RepeatFromCompletion)
+	}
+	if todo.Recurrence == "" {
+		return nil
+	}
+	_, err := ParseRule(todo.Recurrence)
+	return err
+}
+
+func parseInt(value string, min, max int) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < min || n > max || n == 0 {
+		return 0, fmt.Errorf("invalid number %q", value)
+	}
+	return n, nil
+}
+
+func parseIntList(value string, min, max int) ([]int, error) {
+	var list []int
+	for _, item := range strings.Split(value, ",") {
+		n, err := parseInt(item, min, max)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, n)
+	}
+	return list, nil
+}
+
+func parseWeekdayNum(value string) (weekdayNum, error) {
+	if len(value) < 2 {
+		return weekdayNum{}, fmt.Errorf("invalid BYDAY %q", value)
+	}
+	day, ok := weekdays[value[len(value)-2:]]
+	if !ok {
+		return weekdayNum{}, fmt.Errorf("invalid BYDAY %q", value)
+	}
+	wd := weekdayNum{Day: day}
+	if prefix := strings.TrimPrefix(value[:len(value)-2], "+"); prefix != "" {
+		n, err := parseInt(prefix, -53, 53)
+		if err != nil {
+			return weekdayNum{}, fmt.Errorf("invalid BYDAY %q", value)
+		}
+		wd.N = n
+	}
+	return wd, nil
+}
+
+func parseUntil(value string) (time.Time, bool, error) {
+	if t, err := time.Parse("20060102T150405Z", value); err == nil {
+		return t, false, nil
+	}
+	if t, err := time.Parse("20060102T150405", value); err == nil {
+		return t, true, nil
+	}
+	if t, err := time.Parse("20060102", value); err == nil {
+		return t.Add(24*time.Hour - time.Second), true, nil
+	}
+	return time.Time{}, false, fmt.Errorf("invalid UNTIL %q", value)
+}
+
+func civilDate(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func daysIn(t time.Time) int {
+	return time.Date(t.Year(), t.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+func containsInt(list []int, n int) bool {
+	for _, item := range list {
+		if item == n {
+			return true
+		}
+	}
+	return false
+}
+
+func matchWeekday(days []weekdayNum, day time.Time) bool {
+	for _, wd := range days {
+		if wd.Day == day.Weekday() {
+			return true
+		}
+	}
+	return false
+}
+
+func matchMonthDay(monthDays []int, d, n int) bool {
+	for _, md := range monthDays {
+		if md == d || md == d-n-1 {
+			return true
+		}
+	}
+	return false
+}
+
+// matchNumberedWeekday checks day, the d-th of n days in its month or year,
+// against BYDAY entries such as MO, 2TU or -1FR.
+func matchNumberedWeekday(days []weekdayNum, day time.Time, d, n int) bool {
+	for _, wd := range days {
+		if wd.Day != day.Weekday() {
+			continue
+		}
+		switch {
+		case wd.N == 0:
+			return true
+		case wd.N > 0 && (d-1)/7+1 == wd.N:
+			return true
+		case wd.N < 0 && (n-d)/7+1 == -wd.N:
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,144 @@
+// todo/recurrence_test.go
+package todo
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOccurrences(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	utc := func(year int, month time.Month, day, hour int) time.Time {
+		return time.Date(year, month, day, hour, 0, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name    string
+		rule    string
+		dtstart time.Time
+		want    []time.Time
+		// ends marks rules whose series ends after want.
+		ends bool
+	}{
+		{
+			name:    "every weekday",
+			rule:    "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR",
+			dtstart: utc(2024, time.January, 5, 9),
+			want:    []time.Time{utc(2024, time.January, 5, 9), utc(2024, time.January, 8, 9), utc(2024, time.January, 9, 9), utc(2024, time.January, 10, 9)},
+		},
+		{
+			name:    "last Friday of the month",
+			rule:    "FREQ=MONTHLY;BYDAY=-1FR",
+			dtstart: utc(2024, time.January, 26, 9),
+			want:    []time.Time{utc(2024, time.January, 26, 9), utc(2024, time.February, 23, 9), utc(2024, time.March, 29, 9), utc(2024, time.April, 26, 9)},
+		},
+		{
+			name:    "last weekday of the month",
+			rule:    "FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1",
+			dtstart: utc(2024, time.January, 31, 9),
+			want:    []time.Time{utc(2024, time.January, 31, 9), utc(2024, time.February, 29, 9), utc(2024, time.March, 29, 9), utc(2024, time.April, 30, 9)},
+		},
+		{
+			name:    "second Tuesday every other month",
+			rule:    "FREQ=MONTHLY;INTERVAL=2;BYDAY=TU;BYSETPOS=2",
+			dtstart: utc(2024, time.January, 9, 9),
+			want:    []time.Time{utc(2024, time.January, 9, 9), utc(2024, time.March, 12, 9), utc(2024, time.May, 14, 9), utc(2024, time.July, 9, 9)},
+		},
+		{
+			name:    "count",
+			rule:    "FREQ=DAILY;COUNT=3",
+			dtstart: utc(2024, time.January, 1, 9),
+			want:    []time.Time{utc(2024, time.January, 1, 9), utc(2024, time.January, 2, 9), utc(2024, time.January, 3, 9)},
+			ends:    true,
+		},
+		{
+			name:    "until",
+			rule:    "FREQ=WEEKLY;UNTIL=20240115T090000Z",
+			dtstart: utc(2024, time.January, 1, 9),
+			want:    []time.Time{utc(2024, time.January, 1, 9), utc(2024, time.January, 8, 9), utc(2024, time.January, 15, 9)},
+			ends:    true,
+		},
+		{
+			name:    "until a floating date",
+			rule:    "FREQ=DAILY;UNTIL=20240102",
+			dtstart: time.Date(2024, time.January, 1, 23, 0, 0, 0, newYork),
+			want:    []time.Time{time.Date(2024, time.January, 1, 23, 0, 0, 0, newYork), time.Date(2024, time.January, 2, 23, 0, 0, 0, newYork)},
+			ends:    true,
+		},
+		{
+			name:    "across the start of DST",
+			rule:    "FREQ=DAILY",
+			dtstart: time.Date(2024, time.March, 9, 9, 0, 0, 0, newYork),
+			want:    []time.Time{time.Date(2024, time.March, 9, 9, 0, 0, 0, newYork), time.Date(2024, time.March, 10, 9, 0, 0, 0, newYork), time.Date(2024, time.March, 11, 9, 0, 0, 0, newYork)},
+		},
+		{
+			name:    "yearly on the first of every month",
+			rule:    "FREQ=YEARLY;BYMONTHDAY=1",
+			dtstart: utc(2024, time.January, 1, 9),
+			want:    []time.Time{utc(2024, time.January, 1, 9), utc(2024, time.February, 1, 9), utc(2024, time.March, 1, 9), utc(2024, time.April, 1, 9)},
+		},
+		{
+			name:    "yearly on a day of the start month",
+			rule:    "FREQ=YEARLY",
+			dtstart: utc(2024, time.February, 29, 9),
+			want:    []time.Time{utc(2024, time.February, 29, 9), utc(2028, time.February, 29, 9)},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rule, err := ParseRule(test.rule)
+			if err != nil {
+				t.Fatal(err)
+			}
+			limit := len(test.want)
+			if test.ends {
+				limit++
+			}
+			got := rule.Occurrences(test.dtstart, test.dtstart.Add(-time.Second), limit)
+			if len(got) != len(test.want) {
+				t.Fatalf("got %v, want %v", got, test.want)
+			}
+			for i := range got {
+				if !got[i].Equal(test.want[i]) {
+					t.Errorf("occurrence %d = %v, want %v", i, got[i], test.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestNextOccurrence(t *testing.T) {
+	due := time.Date(2024, time.January, 31, 9, 0, 0, 0, time.UTC)
+	completed := time.Date(2024, time.February, 3, 17, 30, 0, 0, time.UTC)
+	todo := Todo{Name: "Pay rent", Status: DONE, Due: &due, Recurrence: "FREQ=MONTHLY;BYMONTHDAY=-1;COUNT=3"}
+	todo.ID = 7
+
+	next, ok, err := NextOccurrence(todo, completed)
+	if err != nil || !ok {
+		t.Fatalf("next = %v, %v", ok, err)
+	}
+	if want := time.Date(2024, time.February, 29, 9, 0, 0, 0, time.UTC); !next.Due.Equal(want) || next.Status != PENDING || next.SeriesID != 7 || next.Occurrence != 2 {
+		t.Errorf("next = %+v, want pending occurrence 2 of series 7 due %v", next, want)
+	}
+
+	todo.RepeatFrom = RepeatFromCompletion
+	next, _, _ = NextOccurrence(todo, completed)
+	if want := time.Date(2024, time.February, 29, 9, 0, 0, 0, time.UTC); !next.Due.Equal(want) {
+		t.Errorf("next from completion due %v, want %v", next.Due, want)
+	}
+	todo.Recurrence = "FREQ=DAILY"
+	next, _, _ = NextOccurrence(todo, completed)
+	if want := time.Date(2024, time.February, 4, 9, 0, 0, 0, time.UTC); !next.Due.Equal(want) {
+		t.Errorf("next daily from completion due %v, want %v", next.Due, want)
+	}
+
+	todo.Recurrence = "FREQ=MONTHLY;COUNT=3"
+	todo.Occurrence = 3
+	if _, ok, err := NextOccurrence(todo, completed); ok || err != nil {
+		t.Errorf("next after the last of COUNT = %v, %v, want none", ok, err)
+	}
+}
@@ -3,17 +3,72 @@ package todo
 
 import (
 	"errors"
+	"fmt"
+	"strconv"
+	"time"
 
+	"github.com/imadbg01/go-todo/config"
+	"github.com/imadbg01/go-todo/outbox"
 	"github.com/jinzhu/gorm"
 )
 
+// rankStep is the gap left between neighbouring todos when ranks are
+// assigned or rebalanced; minRankGap is the smallest gap still split.
+const (
+	rankStep   = 1024.0
+	minRankGap = 1e-9
+)
+
+var errRankExhausted = errors.New("rank precision exhausted")
+
+// rankLock is the key of the Postgres advisory lock that orders the rank
+// reads of concurrent transactions that place todos.
+const rankLock = 0x72616e6b
+
+// ErrConflict is returned by Save when the todo changed after it was read.
+var ErrConflict = errors.New("Todo was modified concurrently")
+
+// ErrUIDTaken is returned by Create and Save when another todo, deleted or
+// not, already has the client UID of the todo.
+var ErrUIDTaken = errors.New("another todo has this uid")
+
+// QuotaError is returned when another todo would take the workspace past
+// its quota, by Create and Restore, and by Save when the next occurrence of
+// a completed todo would.
+type QuotaError struct {
+	Count int
+	Quota int
+}
+
+func (err *QuotaError) Error() string {
+	return fmt.Sprintf("the workspace holds %d of at most %d todos", err.Count, err.Quota)
+}
+
+// quotaLock is the key of the Postgres advisory lock that orders the quota
+// checks of concurrent transactions.
+const quotaLock = 0x746f646f
+
+// MaxTodos is the most todos the workspace may hold, from TODO_QUOTA, or 0
+// for no limit. Todos are shared by every user, so the workspace is the
+// whole server.
+func MaxTodos() int {
+	quota, err := strconv.Atoi(config.ConfigOr("TODO_QUOTA", "0"))
+	if err != nil || quota < 0 {
+		return 0
+	}
+	return quota
+}
+
 type TodoRepository struct {
 	database *gorm.DB
+	searcher Searcher
+	actor    Actor
+	quota    int
 }
 
 func (repository *TodoRepository) FindAll() []Todo {
 	var todos []Todo
-	repository.database.Find(&todos)
+	repository.database.Order("rank asc, id asc").Find(&todos)
 	return todos
 }
 
@@ -26,8 +81,100 @@ func (repository *TodoRepository) Find(id int) (Todo, error) {
 	return todo, err
 }
 
+// FindByExternalID returns the todo imported under the given external ID.
+func (repository *TodoRepository) FindByExternalID(externalID string) (Todo, error) {
+	var todo Todo
+	err := repository.database.Where("external_id = ?", externalID).First(&todo).Error
+	return todo, err
+}
+
+// Each calls fn for every todo in ID order, reading them in batches so that
+// large tables are never loaded at once.
+func (repository *TodoRepository) Each(batch int, fn func(Todo) error) error {
+	var last uint
+	for {
+		var todos []Todo
+		if err := repository.database.Where("id > ?", last).Order("id asc").Limit(batch).Find(&todos).Error; err != nil {
+			return err
+		}
+		for _, todo := range todos {
+			if err := fn(todo); err != nil {
+				return err
+			}
+			last = todo.ID
+		}
+		if len(todos) < batch {
+			return nil
+		}
+	}
+}
+
+// Filter narrows a listing of todos. Empty fields match every todo.
+type Filter struct {
+	Status    []string
+	Priority  []string
+	DueBefore *time.Time
+}
+
+func (filter Filter) apply(query *gorm.DB) *gorm.DB {
+	if len(filter.Status) > 0 {
+		query = query.Where("status IN (?)", filter.Status)
+	}
+	if len(filter.Priority) > 0 {
+		query = query.Where("priority IN (?)", filter.Priority)
+	}
+	if filter.DueBefore != nil {
+		query = query.Where("due < ?", *filter.DueBefore)
+	}
+	return query
+}
+
+// FindPage returns up to limit todos in list order that come after the
+// position of another todo, given by its rank and ID. An afterID of zero
+// starts at the top of the list.
+func (repository *TodoRepository) FindPage(filter Filter, afterRank float64, afterID uint, limit int) ([]Todo, error) {
+	todos := []Todo{}
+	query := filter.apply(repository.database)
+	if afterID != 0 {
+		query = query.Where("rank > ? OR (rank = ? AND id > ?)", afterRank, afterRank, afterID)
+	}
+	err := query.Order("rank asc, id asc").Limit(limit).Find(&todos).Error
+	return todos, err
+}
+
+func (repository *TodoRepository) Count(filter Filter) (int, error) {
+	var count int
+	err := filter.apply(repository.database.Model(&Todo{})).Count(&count).Error
+	return count, err
+}
+
+// TimeZones returns the distinct time zones todos are due in.
+func (repository *TodoRepository) TimeZones() []string {
+	var zones []string
+	repository.database.Model(&Todo{}).Where("time_zone <> ''").Order("time_zone").Pluck("DISTINCT time_zone", &zones)
+	return zones
+}
+
 func (repository *TodoRepository) Create(todo Todo) (Todo, error) {
-	err := repository.database.Create(&todo).Error
+	return repository.CreateWith(todo, nil)
+}
+
+// CreateWith creates the todo and calls fn with it in the same transaction,
+// so that what fn writes about the new todo is committed with it or not at
+// all.
+func (repository *TodoRepository) CreateWith(todo Todo, fn func(tx *gorm.DB, todo Todo) error) (Todo, error) {
+	err := repository.transaction(func(tx *gorm.DB) error {
+		if err := repository.insert(tx, &todo); err != nil {
+			return err
+		}
+		if fn != nil {
+			return fn(tx, todo)
+		}
+		return nil
+	})
+	if repository.uidTaken(err, todo) {
+		return todo, ErrUIDTaken
+	}
 	if err != nil {
 		return todo, err
 	}
@@ -35,18 +182,379 @@ func (repository *TodoRepository) Create(todo Todo) (Todo, error) {
 	return todo, nil
 }
 
+// insert writes a new todo in tx, at the end of the list unless it was
+// given a rank.
+func (repository *TodoRepository) insert(tx *gorm.DB, todo *Todo) error {
+	if err := repository.checkQuota(tx); err != nil {
+		return err
+	}
+	if todo.Priority == "" {
+		todo.Priority = PriorityNone
+	}
+	todo.complete()
+	if todo.Rank == 0 {
+		if err := lockRanks(tx); err != nil {
+			return err
+		}
+		todo.Rank = lastRank(tx) + rankStep
+	}
+	if err := tx.Create(todo).Error; err != nil {
+		return err
+	}
+	if err := repository.record(tx, ActionCreate, nil, todo); err != nil {
+		return err
+	}
+	return repository.searcher.Index(tx, *todo)
+}
+
+// Save updates the todo. A recurring todo that the update completes gets
+// its next occurrence in the same transaction, whichever handler completed
+// it, so the series never stops or repeats because half the change failed.
 func (repository *TodoRepository) Save(user Todo) (Todo, error) {
-	err := repository.database.Save(user).Error
+	if user.Priority == "" {
+		user.Priority = PriorityNone
+	}
+	user.complete()
+	err := repository.transaction(func(tx *gorm.DB) error {
+		var before Todo
+		if err := forUpdate(tx).First(&before, user.ID).Error; err != nil {
+			return err
+		}
+		if !before.UpdatedAt.Equal(user.UpdatedAt) {
+			return ErrConflict
+		}
+		if err := tx.Save(&user).Error; err != nil {
+			return err
+		}
+		if err := repository.record(tx, ActionUpdate, &before, &user); err != nil {
+			return err
+		}
+		if err := repository.searcher.Index(tx, user); err != nil {
+			return err
+		}
+		return repository.scheduleNext(tx, before, user)
+	})
+	if repository.uidTaken(err, user) {
+		return user, ErrUIDTaken
+	}
 	return user, err
 }
 
+// scheduleNext creates the next occurrence of a recurring todo when the
+// change from before to after completes it.
+func (repository *TodoRepository) scheduleNext(tx *gorm.DB, before, after Todo) error {
+	if before.Status == DONE || after.Status != DONE || after.Recurrence == "" {
+		return nil
+	}
+	next, ok, err := NextOccurrence(after, time.Now())
+	if err != nil || !ok {
+		return err
+	}
+	return repository.insert(tx, &next)
+}
+
+// uidTaken tells whether a write of todo that failed with err was refused
+// because another todo holds its UID. The unique index on uid is what
+// refuses it, and the index error differs by database, so the other todo
+// is looked up instead.
+func (repository *TodoRepository) uidTaken(err error, todo Todo) bool {
+	if err == nil || todo.UID == "" {
+		return false
+	}
+	other, findErr := repository.FindByUID(todo.UID)
+	return findErr == nil && other.ID != todo.ID
+}
+
+// uniqueUIDs lets at most one todo, deleted or not, hold each client UID, so
+// that a sync create retried while the first attempt is still running
+// cannot make the todo twice.
+func (repository *TodoRepository) uniqueUIDs() error {
+	return repository.database.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_todos_uid_unique ON todos (uid) WHERE uid <> ''").Error
+}
+
+func (repository *TodoRepository) Search(query string, limit int) ([]SearchResult, error) {
+	return repository.searcher.Search(repository.database, query, limit)
+}
+
+// Reindex refreshes the search index of a todo after data it is indexed
+// with, such as its comments, has changed.
+func (repository *TodoRepository) Reindex(id uint) error {
+	var todo Todo
+	if err := repository.database.First(&todo, id).Error; err != nil {
+		return err
+	}
+	return repository.searcher.Index(repository.database, todo)
+}
+
 func (repository *TodoRepository) Delete(id int) int64 {
-	count := repository.database.Delete(&Todo{}, id).RowsAffected
+	var count int64
+	repository.transaction(func(tx *gorm.DB) error {
+		var before Todo
+		if err := tx.First(&before, id).Error; err != nil {
+			return err
+		}
+		deleted := tx.Delete(&before)
+		if deleted.Error != nil {
+			return deleted.Error
+		}
+		count = deleted.RowsAffected
+
+		var after Todo
+		if err := tx.Unscoped().First(&after, id).Error; err != nil {
+			return err
+		}
+		return repository.record(tx, ActionDelete, &before, &after)
+	})
 	return count
 }
 
+// Restore undoes the soft deletion of a todo.
+func (repository *TodoRepository) Restore(id int) (Todo, error) {
+	var todo Todo
+	err := repository.transaction(func(tx *gorm.DB) error {
+		var before Todo
+		if err := tx.Unscoped().Where("deleted_at IS NOT NULL").First(&before, id).Error; err != nil {
+			return errors.New("Deleted todo not found")
+		}
+		if err := repository.checkQuota(tx); err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Model(&Todo{}).Where("id = ?", id).Update("deleted_at", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.First(&todo, id).Error; err != nil {
+			return err
+		}
+		if err := repository.record(tx, ActionRestore, &before, &todo); err != nil {
+			return err
+		}
+		return repository.searcher.Index(tx, todo)
+	})
+	return todo, err
+}
+
+// Move gives the todo a rank between the after and before anchors, either of
+// which may be nil. Only the moved row is written unless the ranks around it
+// have to be rebalanced first.
+// The anchors are read in the transaction that writes the rank, under the
+// rank lock, so a concurrent move or create cannot take the same place.
+func (repository *TodoRepository) Move(id int, before, after *int) (Todo, error) {
+	var todo Todo
+	err := repository.transaction(func(tx *gorm.DB) error {
+		if err := lockRanks(tx); err != nil {
+			return err
+		}
+		if err := tx.First(&todo, id).Error; err != nil {
+			return err
+		}
+
+		rank, err := rankBetween(tx, id, before, after)
+		if err == errRankExhausted {
+			if err = repository.rebalance(tx); err != nil {
+				return err
+			}
+			rank, err = rankBetween(tx, id, before, after)
+		}
+		if err != nil {
+			return err
+		}
+		previous := todo
+		if err := tx.Model(&todo).Update("rank", rank).Error; err != nil {
+			return err
+		}
+		return repository.record(tx, ActionUpdate, &previous, &todo)
+	})
+	return todo, err
+}
+
+// Rebalance spreads the ranks of all todos evenly, keeping their order.
+// Each live todo it moves gets an update in its history, as a move does, so
+// that sync clients pick up the new ranks.
+func (repository *TodoRepository) Rebalance() error {
+	return repository.transaction(func(tx *gorm.DB) error {
+		if err := lockRanks(tx); err != nil {
+			return err
+		}
+		return repository.rebalance(tx)
+	})
+}
+
+func (repository *TodoRepository) rebalance(tx *gorm.DB) error {
+	var todos []Todo
+	if err := tx.Unscoped().Order("rank asc, id asc").Find(&todos).Error; err != nil {
+		return err
+	}
+	for i, todo := range todos {
+		rank := float64(i+1) * rankStep
+		if todo.Rank == rank {
+			continue
+		}
+		before := todo
+		if err := tx.Unscoped().Model(&todo).Update("rank", rank).Error; err != nil {
+			return err
+		}
+		if todo.DeletedAt != nil {
+			continue
+		}
+		if err := repository.record(tx, ActionUpdate, &before, &todo); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// backfillRanks gives rows created before ranking existed a place in the order.
+func (repository *TodoRepository) backfillRanks() error {
+	var count int
+	repository.database.Unscoped().Model(&Todo{}).Where("rank IS NULL").Count(&count)
+	if count == 0 {
+		return nil
+	}
+	return repository.Rebalance()
+}
+
+// backfillIndex creates the search index and indexes every todo, so rows
+// written before search existed can be found.
+func (repository *TodoRepository) backfillIndex() error {
+	if repository.database.HasTable(&SearchTerm{}) || repository.hasSearchVector() {
+		return nil
+	}
+	if err := repository.searcher.Migrate(repository.database); err != nil {
+		return err
+	}
+	var todos []Todo
+	repository.database.Find(&todos)
+	for _, todo := range todos {
+		if err := repository.searcher.Index(repository.database, todo); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (repository *TodoRepository) hasSearchVector() bool {
+	return repository.database.Dialect().HasColumn("todos", "search_vector")
+}
+
+func rankBetween(tx *gorm.DB, id int, before, after *int) (float64, error) {
+	if before == nil && after == nil {
+		return 0, errors.New("before or after is required")
+	}
+
+	var low, high *Todo
+	if after != nil {
+		anchor, err := findAnchor(tx, id, *after)
+		if err != nil {
+			return 0, err
+		}
+		low = &anchor
+	}
+	if before != nil {
+		anchor, err := findAnchor(tx, id, *before)
+		if err != nil {
+			return 0, err
+		}
+		high = &anchor
+	}
+
+	if low == nil {
+		var prev Todo
+		if !tx.Where("rank < ? AND id <> ?", high.Rank, id).Order("rank desc").First(&prev).RecordNotFound() {
+			low = &prev
+		}
+	}
+	if high == nil {
+		var next Todo
+		if !tx.Where("rank > ? AND id <> ?", low.Rank, id).Order("rank asc").First(&next).RecordNotFound() {
+			high = &next
+		}
+	}
+
+	switch {
+	case low == nil:
+		return high.Rank - rankStep, nil
+	case high == nil:
+		return low.Rank + rankStep, nil
+	case low.Rank > high.Rank:
+		return 0, errors.New("after must be ranked before before")
+	case high.Rank-low.Rank < minRankGap:
+		return 0, errRankExhausted
+	}
+	return low.Rank + (high.Rank-low.Rank)/2, nil
+}
+
+func findAnchor(tx *gorm.DB, id int, anchorID int) (Todo, error) {
+	if anchorID == id {
+		return Todo{}, errors.New("A todo cannot be moved relative to itself")
+	}
+	var anchor Todo
+	if err := tx.First(&anchor, anchorID).Error; err != nil {
+		return anchor, errors.New("Anchor todo not found")
+	}
+	return anchor, nil
+}
+
+func lastRank(tx *gorm.DB) float64 {
+	var last Todo
+	tx.Select("rank").Order("rank desc").First(&last)
+	return last.Rank
+}
+
+// lockRanks makes transactions that place todos read the ranks one after
+// the other on Postgres, so that two creates cannot both take the place
+// after the same last todo. SQLite runs one writer at a time anyway.
+func lockRanks(tx *gorm.DB) error {
+	if tx.Dialect().GetName() != "postgres" {
+		return nil
+	}
+	return tx.Exec("SELECT pg_advisory_xact_lock(?)", rankLock).Error
+}
+
+// transaction runs fn in a transaction and wakes the outbox relay once the
+// events recorded by fn are committed.
+func (repository *TodoRepository) transaction(fn func(tx *gorm.DB) error) error {
+	if err := repository.database.Transaction(fn); err != nil {
+		return err
+	}
+	outbox.Wake()
+	return nil
+}
+
+// checkQuota refuses to add a todo to a workspace that is at its quota. On
+// Postgres the count is taken under a transaction-scoped advisory lock, so
+// concurrent creates are counted one after the other instead of all seeing
+// room for one more; SQLite runs one writer at a time anyway.
+func (repository *TodoRepository) checkQuota(tx *gorm.DB) error {
+	if repository.quota == 0 {
+		return nil
+	}
+	if tx.Dialect().GetName() == "postgres" {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", quotaLock).Error; err != nil {
+			return err
+		}
+	}
+	var count int
+	if err := tx.Model(&Todo{}).Count(&count).Error; err != nil {
+		return err
+	}
+	if count >= repository.quota {
+		return &QuotaError{Count: count, Quota: repository.quota}
+	}
+	return nil
+}
+
+// forUpdate locks the rows read in a transaction where the database supports it.
+func forUpdate(tx *gorm.DB) *gorm.DB {
+	if tx.Dialect().GetName() == "postgres" {
+		return tx.Set("gorm:query_option", "FOR UPDATE")
+	}
+	return tx
+}
+
 func NewTodoRepository(database *gorm.DB) *TodoRepository {
 	return &TodoRepository{
 		database: database,
+		searcher: newSearcher(database),
+		quota:    MaxTodos(),
 	}
 }
@@ -0,0 +1,198 @@
+// todo/repositories_test.go
+package todo
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/imadbg01/go-todo/outbox"
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+)
+
+// newTestRepository returns a repository on a fresh SQLite database.
+func newTestRepository(t *testing.T) *TodoRepository {
+	database, err := gorm.Open("sqlite3", filepath.Join(t.TempDir(), "todo.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	database.DB().SetMaxOpenConns(1)
+	t.Cleanup(func() { database.Close() })
+	database.AutoMigrate(&Todo{}, &History{}, &outbox.Message{})
+	repository := NewTodoRepository(database)
+	if err := repository.uniqueUIDs(); err != nil {
+		t.Fatal(err)
+	}
+	if err := repository.backfillIndex(); err != nil {
+		t.Fatal(err)
+	}
+	return repository
+}
+
+func TestSaveSchedulesNextOccurrence(t *testing.T) {
+	repository := newTestRepository(t)
+	due := time.Date(2024, time.January, 5, 9, 0, 0, 0, time.UTC)
+	todo, err := repository.Create(Todo{Name: "Stand-up", Status: PENDING, Due: &due, Recurrence: "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	todo.Status = DONE
+	if todo, err = repository.Save(todo); err != nil {
+		t.Fatal(err)
+	}
+	todos := repository.FindAll()
+	if len(todos) != 2 {
+		t.Fatalf("%d todos after completing a recurring one, want 2", len(todos))
+	}
+	next := todos[1]
+	if next.Rank <= todo.Rank {
+		t.Errorf("next occurrence ranked %v, want after %v", next.Rank, todo.Rank)
+	}
+	if want := time.Date(2024, time.January, 8, 9, 0, 0, 0, time.UTC); next.Status != PENDING || next.Due == nil || !next.Due.Equal(want) || next.SeriesID != todo.ID {
+		t.Errorf("next occurrence %+v, want pending in series %d due %v", next, todo.ID, want)
+	}
+
+	// Saving a todo that was done already schedules nothing more.
+	todo.Name = "Daily stand-up"
+	if _, err := repository.Save(todo); err != nil {
+		t.Fatal(err)
+	}
+	if todos := repository.FindAll(); len(todos) != 2 {
+		t.Errorf("%d todos after editing a done todo, want 2", len(todos))
+	}
+}
+
+func TestSaveRollsBackOnBadRecurrence(t *testing.T) {
+	repository := newTestRepository(t)
+	todo, err := repository.Create(Todo{Name: "Broken", Status: PENDING, Recurrence: "FREQ=HOURLY"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	todo.Status = DONE
+	if _, err := repository.Save(todo); err == nil {
+		t.Fatal("completing a todo with an invalid rule succeeded")
+	}
+	stored, _ := repository.Find(int(todo.ID))
+	if stored.Status != PENDING {
+		t.Errorf("status after the failed save = %q, want it unchanged", stored.Status)
+	}
+}
+
+func TestMove(t *testing.T) {
+	repository := newTestRepository(t)
+	var ids []int
+	for _, name := range []string{"one", "two", "three"} {
+		todo, err := repository.Create(Todo{Name: name, Status: PENDING})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, int(todo.ID))
+	}
+	order := func() string {
+		var names []string
+		for _, todo := range repository.FindAll() {
+			names = append(names, todo.Name)
+		}
+		return strings.Join(names, ",")
+	}
+	if got := order(); got != "one,two,three" {
+		t.Fatalf("created in order %s", got)
+	}
+
+	moved, err := repository.Move(ids[2], &ids[0], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := order(); got != "three,one,two" || moved.Rank >= rankStep {
+		t.Errorf("after moving three before one: %s, rank %v", got, moved.Rank)
+	}
+	if _, err := repository.Move(ids[2], nil, &ids[0]); err != nil {
+		t.Fatal(err)
+	}
+	if got := order(); got != "one,three,two" {
+		t.Errorf("after moving three after one: %s", got)
+	}
+	if _, err := repository.Move(ids[0], &ids[0], nil); err == nil {
+		t.Error("moving a todo relative to itself succeeded")
+	}
+	if _, err := repository.Move(ids[0], nil, nil); err == nil {
+		t.Error("moving a todo without anchors succeeded")
+	}
+}
+
+func TestMoveRebalancesWhenRanksRunOut(t *testing.T) {
+	repository := newTestRepository(t)
+	first, _ := repository.Create(Todo{Name: "first", Status: PENDING, Rank: 1})
+	second, _ := repository.Create(Todo{Name: "second", Status: PENDING, Rank: 1 + minRankGap/2})
+	last, _ := repository.Create(Todo{Name: "last", Status: PENDING})
+
+	after, before := int(first.ID), int(second.ID)
+	moved, err := repository.Move(int(last.ID), &before, &after)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, todo := range repository.FindAll() {
+		names = append(names, todo.Name)
+	}
+	if strings.Join(names, ",") != "first,last,second" {
+		t.Errorf("order after the move %v", names)
+	}
+	if moved.Rank <= rankStep || moved.Rank >= 2*rankStep {
+		t.Errorf("moved to rank %v, want between the rebalanced ranks", moved.Rank)
+	}
+}
+
+func TestQuota(t *testing.T) {
+	repository := newTestRepository(t)
+	repository.quota = 2
+
+	first, err := repository.Create(Todo{Name: "first", Status: PENDING})
+	if err != nil {
+		t.Fatal(err)
+	}
+	due := time.Date(2024, time.January, 5, 9, 0, 0, 0, time.UTC)
+	recurring, err := repository.Create(Todo{Name: "second", Status: PENDING, Due: &due, Recurrence: "FREQ=WEEKLY"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var quota *QuotaError
+	if _, err := repository.Create(Todo{Name: "third", Status: PENDING}); !errors.As(err, &quota) || quota.Count != 2 || quota.Quota != 2 {
+		t.Fatalf("Create past the quota = %v, want a QuotaError", err)
+	}
+
+	// The next occurrence would take the workspace past the quota, so the
+	// todo is not completed either.
+	recurring.Status = DONE
+	if _, err := repository.Save(recurring); !errors.As(err, &quota) {
+		t.Fatalf("completing a recurring todo past the quota = %v, want a QuotaError", err)
+	}
+	if stored, _ := repository.Find(int(recurring.ID)); stored.Status != PENDING {
+		t.Errorf("status after the refused completion = %q, want it unchanged", stored.Status)
+	}
+
+	repository.Delete(int(first.ID))
+	if _, err := repository.Create(Todo{Name: "third", Status: PENDING}); err != nil {
+		t.Fatalf("Create after a delete = %v", err)
+	}
+	if _, err := repository.Restore(int(first.ID)); !errors.As(err, &quota) {
+		t.Fatalf("Restore past the quota = %v, want a QuotaError", err)
+	}
+
+	report, err := repository.Import(FormatNDJSON, strings.NewReader(`{"name":"imported","status":"pending"}`), false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Created != 0 || report.Failed != 1 {
+		t.Errorf("import past the quota = %+v", report)
+	}
+
+	results := repository.Sync([]SyncMutation{{Op: SyncCreate, UID: "offline-1", Todo: Todo{Name: "offline", Status: PENDING}}})
+	if results[0].Status != SyncError || !strings.Contains(results[0].Error, "at most 2 todos") {
+		t.Errorf("sync create past the quota = %+v, want an error", results[0])
+	}
+}
@@ -0,0 +1,260 @@
+// todo/search.go
+package todo
+
+import (
+	"html"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/jinzhu/gorm"
+)
+
+type SearchResult struct {
+	Todo    Todo    `json:"todo"`
+	Rank    float64 `json:"rank"`
+	Snippet string  `json:"snippet"`
+}
+
+// Searcher keeps the full-text index of todos up to date and queries it.
+// Index runs on the same connection or transaction that wrote the todo.
+type Searcher interface {
+	Migrate(database *gorm.DB) error
+	Index(database *gorm.DB, todo Todo) error
+	Search(database *gorm.DB, query string, limit int) ([]SearchResult, error)
+}
+
+func newSearcher(database *gorm.DB) Searcher {
+	if database.Dialect().GetName() == "postgres" {
+		return postgresSearcher{}
+	}
+	return portableSearcher{}
+}
+
+// postgresSearcher ranks todos with a weighted tsvector: name (A),
+// description (B) and comments (C).
+type postgresSearcher struct{}
+
+func (postgresSearcher) Migrate(database *gorm.DB) error {
+	if err := database.Exec("ALTER TABLE todos ADD COLUMN IF NOT EXISTS search_vector tsvector").Error; err != nil {
+		return err
+	}
+	return database.Exec("CREATE INDEX IF NOT EXISTS idx_todos_search_vector ON todos USING GIN (search_vector)").Error
+}
+
+func (postgresSearcher) Index(database *gorm.DB, todo Todo) error {
+	return database.Exec(`UPDATE todos SET search_vector =
+		setweight(to_tsvector('english', coalesce(name, '')), 'A') ||
+		setweight(to_tsvector('english', coalesce(description, '')), 'B') ||
+		setweight(to_tsvector('english', ?), 'C')
+		WHERE id = ?`, commentText(database, todo.ID), todo.ID).Error
+}
+
+func (postgresSearcher) Search(database *gorm.DB, query string, limit int) ([]SearchResult, error) {
+	rows, err := database.Raw(`SELECT todos.*,
+		ts_rank(search_vector, query) AS search_rank,
+		ts_headline('english', translate(name || ' ' || coalesce(description, ''), chr(2) || chr(3), ''), query,
+			'StartSel=' || chr(2) || ', StopSel=' || chr(3) || ', MaxFragments=2') AS snippet
+		FROM todos, websearch_to_tsquery('english', ?) query
+		WHERE deleted_at IS NULL AND search_vector @@ query
+		ORDER BY search_rank DESC, id ASC
+		LIMIT ?`, query, limit).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []SearchResult{}
+	for rows.Next() {
+		var row struct {
+			Todo
+			SearchRank float64
+			Snippet    string
+		}
+		if err := database.ScanRows(rows, &row); err != nil {
+			return nil, err
+		}
+		results = append(results, SearchResult{Todo: row.Todo, Rank: row.SearchRank, Snippet: markSnippet(row.Snippet)})
+	}
+	return results, rows.Err()
+}
+
+// headlineMarks are what ts_headline is asked to put around matches. They
+// are removed from the text first, so the snippet can be escaped
+// and only they turn into markup.
+var headlineMarks = strings.NewReplacer("\x02", "<mark>", "\x03", "</mark>")
+
+// markSnippet escapes a ts_headline snippet like highlight does, and marks
+// the matches in it.
+func markSnippet(snippet string) string {
+	return headlineMarks.Replace(html.EscapeString(snippet))
+}
+
+// SearchTerm is a row of the portable index used when the database has no
+// native full-text search.
+type SearchTerm struct {
+	ID     uint    `gorm:"primary_key"`
+	TodoID uint    `gorm:"Not Null;index"`
+	Term   string  `gorm:"Not Null;index"`
+	Weight float64 `gorm:"Not Null"`
+}
+
+// Field weights of the portable index, matching ts_rank's defaults.
+const (
+	nameWeight        = 1.0
+	descriptionWeight = 0.4
+	commentWeight     = 0.2
+)
+
+type portableSearcher struct{}
+
+func (portableSearcher) Migrate(database *gorm.DB) error {
+	return database.AutoMigrate(&SearchTerm{}).Error
+}
+
+func (portableSearcher) Index(database *gorm.DB, todo Todo) error {
+	if err := database.Where("todo_id = ?", todo.ID).Delete(&SearchTerm{}).Error; err != nil {
+		return err
+	}
+
+	weights := map[string]float64{}
+	for _, term := range tokenize(todo.Name) {
+		weights[term] += nameWeight
+	}
+	for _, term := range tokenize(todo.Description) {
+		weights[term] += descriptionWeight
+	}
+	for _, term := range tokenize(commentText(database, todo.ID)) {
+		weights[term] += commentWeight
+	}
+
+	for term, weight := range weights {
+		if err := database.Create(&SearchTerm{TodoID: todo.ID, Term: term, Weight: weight}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Search matches todos containing every query term as a word prefix.
+func (portableSearcher) Search(database *gorm.DB, query string, limit int) ([]SearchResult, error) {
+	terms := tokenize(query)
+	if len(terms) == 0 {
+		return []SearchResult{}, nil
+	}
+
+	var scores map[uint]float64
+	for _, term := range terms {
+		var matches []SearchTerm
+		err := database.Where("term LIKE ?", term+"%").Find(&matches).Error
+		if err != nil {
+			return nil, err
+		}
+		termScores := map[uint]float64{}
+		for _, match := range matches {
+			termScores[match.TodoID] += match.Weight
+		}
+		if scores == nil {
+			scores = termScores
+			continue
+		}
+		for id := range scores {
+			if _, ok := termScores[id]; !ok {
+				delete(scores, id)
+				continue
+			}
+			scores[id] += termScores[id]
+		}
+	}
+
+	ids := make([]uint, 0, len(scores))
+	for id := range scores {
+		ids = append(ids, id)
+	}
+	var todos []Todo
+	if err := database.Where("id IN (?)", ids).Find(&todos).Error; err != nil {
+		return nil, err
+	}
+
+	results := []SearchResult{}
+	for _, todo := range todos {
+		results = append(results, SearchResult{
+			Todo:    todo,
+			Rank:    scores[todo.ID],
+			Snippet: highlight(todo.Name+" "+todo.Description, terms),
+		})
+	}
+	sort.SliceStable(results, func(a, b int) bool {
+		if results[a].Rank != results[b].Rank {
+			return results[a].Rank > results[b].Rank
+		}
+		return results[a].Todo.ID < results[b].Todo.ID
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// commentText joins the live comments of a todo for indexing.
+func commentText(database *gorm.DB, id uint) string {
+	if !database.HasTable("comments") {
+		return ""
+	}
+	var bodies []string
+	database.Table("comments").Where("todo_id = ? AND deleted_at IS NULL", id).Pluck("body", &bodies)
+	return strings.Join(bodies, " ")
+}
+
+func tokenize(text string) []string {
+	var terms []string
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), isSeparator) {
+		if len([]rune(word)) > 1 {
+			terms = append(terms, word)
+		}
+	}
+	return terms
+}
+
+func isSeparator(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+// highlight wraps words starting with a query term in <mark> and trims the
+// text to a window around the first match.
+func highlight(text string, terms []string) string {
+	const window = 12
+
+	words := strings.Fields(text)
+	first := -1
+	for i, word := range words {
+		lower := strings.ToLower(strings.TrimFunc(word, isSeparator))
+		words[i] = html.EscapeString(word)
+		for _, term := range terms {
+			if strings.HasPrefix(lower, term) {
+				words[i] = "<mark>" + words[i] + "</mark>"
+				if first < 0 {
+					first = i
+				}
+				break
+			}
+		}
+	}
+
+	start := first - window/2
+	if start < 0 {
+		start = 0
+	}
+	end := start + window
+	if end > len(words) {
+		end = len(words)
+	}
+	snippet := strings.Join(words[start:end], " ")
+	if start > 0 {
+		snippet = "… " + snippet
+	}
+	if end < len(words) {
+		snippet += " …"
+	}
+	return snippet
+}
@@ -0,0 +1,16 @@
+// todo/search_test.go
+package todo
+
+import "testing"
+
+func TestSnippetsAreEscaped(t *testing.T) {
+	headline := markSnippet("fix \x02<script>\x03alert(1)</script> & more")
+	if want := "fix <mark>&lt;script&gt;</mark>alert(1)&lt;/script&gt; &amp; more"; headline != want {
+		t.Errorf("markSnippet = %q, want %q", headline, want)
+	}
+
+	portable := highlight("fix <script>alert(1)</script>", []string{"fix"})
+	if want := "<mark>fix</mark> &lt;script&gt;alert(1)&lt;/script&gt;"; portable != want {
+		t.Errorf("highlight = %q, want %q", portable, want)
+	}
+}
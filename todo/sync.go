@@ -0,0 +1,309 @@
+// todo/sync.go
+package todo
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	SyncCreate = "create"
+	SyncUpdate = "update"
+	SyncDelete = "delete"
+)
+
+const (
+	SyncApplied  = "applied"
+	SyncConflict = "conflict"
+	SyncError    = "error"
+)
+
+// MaxSyncMutations is the most mutations one sync request may carry.
+const MaxSyncMutations = 500
+
+const syncTokenPrefix = "history:"
+
+// SyncChanges is what changed since a sync token. A full response holds
+// every todo, and the client replaces what it has instead of merging.
+type SyncChanges struct {
+	Token   string      `json:"token"`
+	Full    bool        `json:"full"`
+	Changed []Todo      `json:"changed"`
+	Deleted []Tombstone `json:"deleted"`
+}
+
+// Tombstone stands for a todo deleted since the sync token.
+type Tombstone struct {
+	ID        uint      `json:"id"`
+	UID       string    `json:"uid"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+// SyncMutation is one change a client made while offline. Todos created by
+// the client are named by the UID it generated for them, which later
+// mutations in the same or another batch can refer to before the client
+// learns the server ID. BaseUpdatedAt is the version the change was made
+// to; without it the change is applied whatever the server holds.
+type SyncMutation struct {
+	Op            string     `json:"op"`
+	ID            uint       `json:"id,omitempty"`
+	UID           string     `json:"uid,omitempty"`
+	BaseUpdatedAt *time.Time `json:"base_updated_at,omitempty"`
+	Todo          Todo       `json:"todo"`
+}
+
+// SyncResult is the outcome of one mutation, at the same index as the
+// mutation. Todo is the server state after an applied mutation, or the one
+// that conflicted with it; Deleted tells whether that todo is deleted.
+type SyncResult struct {
+	Op      string `json:"op"`
+	ID      uint   `json:"id,omitempty"`
+	UID     string `json:"uid,omitempty"`
+	Status  string `json:"status"`
+	Todo    *Todo  `json:"todo,omitempty"`
+	Deleted bool   `json:"deleted"`
+	Error   string `json:"error,omitempty"`
+}
+
+func encodeSyncToken(position uint64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(syncTokenPrefix + strconv.FormatUint(position, 10)))
+}
+
+func decodeSyncToken(token string) (uint64, error) {
+	invalid := fmt.Errorf("invalid sync token %q", token)
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || !strings.HasPrefix(string(data), syncTokenPrefix) {
+		return 0, invalid
+	}
+	position, err := strconv.ParseUint(strings.TrimPrefix(string(data), syncTokenPrefix), 10, 64)
+	if err != nil {
+		return 0, invalid
+	}
+	return position, nil
+}
+
+// Changes returns the todos changed and deleted since the token, or every
+// todo when the token is empty or from a history this server does not
+// have.
+func (repository *TodoRepository) Changes(token string) (SyncChanges, error) {
+	changes := SyncChanges{Changed: []Todo{}, Deleted: []Tombstone{}}
+	since := uint64(0)
+	if token != "" {
+		position, err := decodeSyncToken(token)
+		if err != nil {
+			return changes, err
+		}
+		since = position
+	}
+
+	position := repository.HistoryPosition()
+	if token == "" || since > position {
+		changes.Full = true
+		changes.Token = encodeSyncToken(position)
+		changes.Changed = repository.FindAll()
+		if changes.Changed == nil {
+			changes.Changed = []Todo{}
+		}
+		return changes, nil
+	}
+
+	todos, next, err := repository.ChangedSince(since)
+	if err != nil {
+		return changes, err
+	}
+	changes.Token = encodeSyncToken(next)
+	for _, todo := range todos {
+		if todo.DeletedAt != nil {
+			changes.Deleted = append(changes.Deleted, Tombstone{ID: todo.ID, UID: todo.UID, DeletedAt: *todo.DeletedAt})
+		} else {
+			changes.Changed = append(changes.Changed, todo)
+		}
+	}
+	return changes, nil
+}
+
+// FindByUID returns the todo with the given UID, deleted or not.
+func (repository *TodoRepository) FindByUID(uid string) (Todo, error) {
+	var todo Todo
+	err := repository.database.Unscoped().Where("uid = ?", uid).Order("id asc").First(&todo).Error
+	return todo, err
+}
+
+// Sync applies the mutations in order, each in its own transaction, so
+// that one failing leaves the others applied.
+func (repository *TodoRepository) Sync(mutations []SyncMutation) []SyncResult {
+	results := make([]SyncResult, len(mutations))
+	for i, mutation := range mutations {
+		results[i] = repository.apply(mutation)
+	}
+	return results
+}
+
+func (repository *TodoRepository) apply(mutation SyncMutation) SyncResult {
+	result := SyncResult{Op: mutation.Op, ID: mutation.ID, UID: mutation.UID}
+	fail := func(err error) SyncResult {
+		result.Status = SyncError
+		result.Error = err.Error()
+		return result
+	}
+
+	if mutation.Op == SyncCreate {
+		if mutation.UID == "" {
+			return fail(errors.New("create needs the uid the client generated"))
+		}
+		// A create seen before is a retry, answered with what it made.
+		retried := func(existing Todo) SyncResult {
+			if existing.DeletedAt != nil {
+				return settled(result, SyncConflict, existing)
+			}
+			return settled(result, SyncApplied, existing)
+		}
+		if existing, err := repository.FindByUID(mutation.UID); err == nil {
+			return retried(existing)
+		}
+		if err := Validate(mutation.Todo); err != nil {
+			return fail(err)
+		}
+		data := mutation.Todo
+		data.Model = gorm.Model{}
+		data.UID = mutation.UID
+		todo, err := repository.Create(data)
+		if err == ErrUIDTaken {
+			// The first attempt committed while this one was running.
+			existing, findErr := repository.FindByUID(mutation.UID)
+			if findErr != nil {
+				return fail(findErr)
+			}
+			return retried(existing)
+		}
+		if err != nil {
+			return fail(err)
+		}
+		return settled(result, SyncApplied, todo)
+	}
+
+	if mutation.Op != SyncUpdate && mutation.Op != SyncDelete {
+		return fail(fmt.Errorf("unknown op %q", mutation.Op))
+	}
+	stored, err := repository.target(mutation)
+	if err != nil {
+		return fail(err)
+	}
+	if mutation.Op == SyncDelete && stored.DeletedAt != nil {
+		return settled(result, SyncApplied, stored)
+	}
+	if stored.DeletedAt != nil || !unchanged(stored, mutation.BaseUpdatedAt) {
+		return settled(result, SyncConflict, stored)
+	}
+
+	if mutation.Op == SyncDelete {
+		todo, err := repository.deleteUnchanged(stored.ID, stored.UpdatedAt)
+		if err == ErrConflict {
+			return repository.conflict(result, stored.ID)
+		}
+		if err != nil {
+			return fail(err)
+		}
+		return settled(result, SyncApplied, todo)
+	}
+
+	if err := Validate(mutation.Todo); err != nil {
+		return fail(err)
+	}
+	todo := stored
+	todo.Name = mutation.Todo.Name
+	todo.Description = mutation.Todo.Description
+	todo.Status = mutation.Todo.Status
+	todo.Priority = mutation.Todo.Priority
+	todo.Due = mutation.Todo.Due
+	todo.TimeZone = mutation.Todo.TimeZone
+	todo.Recurrence = mutation.Todo.Recurrence
+	todo.RepeatFrom = mutation.Todo.RepeatFrom
+	todo, err = repository.Save(todo)
+	if err == ErrConflict {
+		return repository.conflict(result, stored.ID)
+	}
+	if err != nil {
+		return fail(err)
+	}
+	return settled(result, SyncApplied, todo)
+}
+
+// target finds the todo a mutation refers to by ID or, for todos the
+// client created, by UID.
+func (repository *TodoRepository) target(mutation SyncMutation) (Todo, error) {
+	var todo Todo
+	var err error
+	switch {
+	case mutation.ID != 0:
+		err = repository.database.Unscoped().First(&todo, mutation.ID).Error
+	case mutation.UID != "":
+		todo, err = repository.FindByUID(mutation.UID)
+	default:
+		return todo, errors.New("mutation needs an id or uid")
+	}
+	if gorm.IsRecordNotFoundError(err) {
+		return todo, errors.New("Todo not found")
+	}
+	return todo, err
+}
+
+// conflict answers a mutation that lost a race with another writer, with
+// the todo as that writer left it.
+func (repository *TodoRepository) conflict(result SyncResult, id uint) SyncResult {
+	var todo Todo
+	if err := repository.database.Unscoped().First(&todo, id).Error; err != nil {
+		result.Status = SyncError
+		result.Error = err.Error()
+		return result
+	}
+	return settled(result, SyncConflict, todo)
+}
+
+// settled completes the result of a mutation with the todo it left.
+func settled(result SyncResult, status string, todo Todo) SyncResult {
+	result.Status = status
+	result.ID = todo.ID
+	result.UID = todo.UID
+	result.Todo = &todo
+	result.Deleted = todo.DeletedAt != nil
+	return result
+}
+
+// unchanged tells whether the todo is still at the version the client based
+// its change on. Both sides are compared at the microsecond precision the
+// databases store, which is what clients were sent.
+func unchanged(todo Todo, base *time.Time) bool {
+	return base == nil || todo.UpdatedAt.Round(time.Microsecond).Equal(base.Round(time.Microsecond))
+}
+
+// deleteUnchanged soft-deletes the todo unless it changed after updatedAt.
+func (repository *TodoRepository) deleteUnchanged(id uint, updatedAt time.Time) (Todo, error) {
+	var after Todo
+	err := repository.transaction(func(tx *gorm.DB) error {
+		var before Todo
+		if err := forUpdate(tx).First(&before, id).Error; err != nil {
+			if gorm.IsRecordNotFoundError(err) {
+				return ErrConflict
+			}
+			return err
+		}
+		if !before.UpdatedAt.Equal(updatedAt) {
+			return ErrConflict
+		}
+		if err := tx.Delete(&before).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().First(&after, id).Error; err != nil {
+			return err
+		}
+		return repository.record(tx, ActionDelete, &before, &after)
+	})
+	return after, err
+}
@@ -0,0 +1,135 @@
+// todo/sync_test.go
+package todo
+
+import (
+	"testing"
+	"time"
+)
+
+func TestChangesSinceToken(t *testing.T) {
+	repository := newTestRepository(t)
+	first, err := repository.Create(Todo{Name: "first", Status: PENDING})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repository.Create(Todo{Name: "second", Status: PENDING}); err != nil {
+		t.Fatal(err)
+	}
+
+	full, err := repository.Changes("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !full.Full || len(full.Changed) != 2 {
+		t.Fatalf("full changes = %+v", full)
+	}
+
+	first.Status = DONE
+	if _, err := repository.Save(first); err != nil {
+		t.Fatal(err)
+	}
+	changes, err := repository.Changes(full.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changes.Full || len(changes.Changed) != 1 || changes.Changed[0].ID != first.ID || changes.Token == full.Token {
+		t.Errorf("changes since the full sync = %+v", changes)
+	}
+
+	for _, history := range repository.History(int(first.ID)) {
+		if history.Position == 0 || history.Position >= repository.HistoryPosition() {
+			t.Errorf("history %d has position %d of %d", history.ID, history.Position, repository.HistoryPosition())
+		}
+	}
+}
+
+func TestRebalanceIsSynced(t *testing.T) {
+	repository := newTestRepository(t)
+	var ids []uint
+	for _, name := range []string{"one", "two", "three"} {
+		created, err := repository.Create(Todo{Name: name, Status: PENDING})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, created.ID)
+	}
+	for i, id := range ids {
+		repository.database.Model(&Todo{}).Where("id = ?", id).UpdateColumn("rank", float64(i+1)*minRankGap)
+	}
+
+	full, err := repository.Changes("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repository.Rebalance(); err != nil {
+		t.Fatal(err)
+	}
+	changes, err := repository.Changes(full.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes.Changed) != 3 {
+		t.Fatalf("changes after a rebalance = %+v", changes)
+	}
+	for i, todo := range changes.Changed {
+		if todo.Rank != float64(i+1)*rankStep {
+			t.Errorf("todo %d synced with rank %v", todo.ID, todo.Rank)
+		}
+	}
+}
+
+func TestUniqueUID(t *testing.T) {
+	repository := newTestRepository(t)
+	first, err := repository.Create(Todo{Name: "first", Status: PENDING, UID: "offline-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repository.Create(Todo{Name: "again", Status: PENDING, UID: "offline-1"}); err != ErrUIDTaken {
+		t.Errorf("create with a taken uid = %v, want ErrUIDTaken", err)
+	}
+	second, err := repository.Create(Todo{Name: "second", Status: PENDING})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second.UID = "offline-1"
+	if _, err := repository.Save(second); err != ErrUIDTaken {
+		t.Errorf("save with a taken uid = %v, want ErrUIDTaken", err)
+	}
+
+	results := repository.Sync([]SyncMutation{{Op: SyncCreate, UID: "offline-1", Todo: Todo{Name: "retried", Status: PENDING}}})
+	if len(results) != 1 || results[0].Status != SyncApplied || results[0].ID != first.ID {
+		t.Errorf("retried create = %+v, want todo %d", results, first.ID)
+	}
+}
+
+func TestSyncUpdate(t *testing.T) {
+	repository := newTestRepository(t)
+	due := time.Date(2024, time.January, 5, 9, 0, 0, 0, time.UTC)
+	stored, err := repository.Create(Todo{Name: "Backup", Status: PENDING, Due: &due, Recurrence: "FREQ=WEEKLY"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Clients are sent UpdatedAt to the microsecond, so a base version that
+	// only differs below it is the stored one.
+	base := stored.UpdatedAt.Round(time.Microsecond).Add(400 * time.Nanosecond)
+	stale := stored.UpdatedAt.Add(-time.Second)
+	completed := stored
+	completed.Status = DONE
+	results := repository.Sync([]SyncMutation{
+		{Op: SyncUpdate, ID: stored.ID, BaseUpdatedAt: &stale, Todo: completed},
+		{Op: SyncUpdate, ID: stored.ID, BaseUpdatedAt: &base, Todo: completed},
+	})
+	if results[0].Status != SyncConflict || results[0].Todo.Status != PENDING {
+		t.Errorf("update of a stale version = %+v, want a conflict", results[0])
+	}
+	if results[1].Status != SyncApplied || results[1].Todo.Status != DONE {
+		t.Fatalf("update of the stored version = %+v, want it applied", results[1])
+	}
+
+	// Completing a recurring todo schedules its next occurrence once, as
+	// any other save does.
+	if todos := repository.FindAll(); len(todos) != 2 || todos[1].Due == nil || !todos[1].Due.Equal(due.AddDate(0, 0, 7)) {
+		t.Errorf("todos after completing offline = %+v, want the next occurrence", todos)
+	}
+}
@@ -0,0 +1,119 @@
+// todo/todotxt.go
+package todo
+
+import (
+	"strings"
+	"time"
+
+	"github.com/imadbg01/go-todo/todotxt"
+)
+
+// todo.txt priorities A to D map onto the priority levels. Other letters are
+// kept as a pri: extension so that they survive a round trip.
+var txtPriorities = map[string]string{
+	"A": PriorityUrgent,
+	"B": PriorityHigh,
+	"C": PriorityMedium,
+	"D": PriorityLow,
+}
+
+// Values of TxtDates, naming the dates a todo.txt line had.
+const (
+	txtNoDates        = "none"
+	txtCreationDate   = "created"
+	txtCompletionDate = "completed"
+	txtBothDates      = "both"
+)
+
+// FromTask maps a todo.txt task onto a todo. The description becomes the
+// name unchanged, so projects, contexts and extensions are all kept; due: and
+// status:in_progress also set the due date and status.
+func FromTask(task todotxt.Task) (Todo, error) {
+	todo := Todo{Status: PENDING, Priority: PriorityNone}
+	if task.Completed {
+		todo.Status = DONE
+		todo.CompletedAt = task.CompletionDate
+	}
+	if task.CreationDate != nil {
+		todo.CreatedAt = *task.CreationDate
+	}
+	switch completion := task.Completed && task.CompletionDate != nil; {
+	case task.CreationDate != nil && completion:
+		todo.TxtDates = txtBothDates
+	case task.CreationDate != nil:
+		todo.TxtDates = txtCreationDate
+	case completion:
+		todo.TxtDates = txtCompletionDate
+	default:
+		todo.TxtDates = txtNoDates
+	}
+
+	if priority, ok := txtPriorities[task.Priority]; ok {
+		todo.Priority = priority
+	} else if task.Priority != "" {
+		task.SetExtension("pri", task.Priority)
+	}
+
+	if due, ok := task.Extension("due"); ok {
+		at, err := time.Parse("2006-01-02", due)
+		if err != nil {
+			return todo, err
+		}
+		todo.Due = &at
+	}
+	if status, _ := task.Extension("status"); status == PROGRESS && !task.Completed {
+		todo.Status = PROGRESS
+	}
+
+	todo.Name = task.Description
+	return todo, nil
+}
+
+// ToTask formats a todo as a todo.txt task, bringing the due: and status:
+// extensions in its name in line with its fields. A todo imported from
+// todo.txt is written with the dates its line had.
+func ToTask(todo Todo) todotxt.Task {
+	task := todotxt.Task{
+		Completed:   todo.Status == DONE,
+		Description: strings.TrimSpace(todo.Name),
+	}
+	if todo.TxtDates == "" || todo.TxtDates == txtCreationDate || todo.TxtDates == txtBothDates {
+		created := todo.CreatedAt
+		task.CreationDate = &created
+	}
+	// A single date on a completed task is its completion date, so a
+	// creation date needs one in front of it.
+	withCompletion := todo.TxtDates == "" || todo.TxtDates == txtCompletionDate || todo.TxtDates == txtBothDates
+	if task.Completed && (withCompletion || task.CreationDate != nil) {
+		task.CompletionDate = todo.CompletedAt
+	}
+
+	for letter, priority := range txtPriorities {
+		if todo.Priority == priority {
+			task.Priority = letter
+		}
+	}
+	if letter, ok := task.Extension("pri"); ok && len(letter) == 1 && letter[0] >= 'A' && letter[0] <= 'Z' {
+		task.SetExtension("pri", "")
+		if task.Priority == "" {
+			task.Priority = letter
+		}
+	}
+
+	due := ""
+	if todo.Due != nil {
+		location, err := todo.Location()
+		if err != nil {
+			location = time.UTC
+		}
+		due = todo.Due.In(location).Format("2006-01-02")
+	}
+	task.SetExtension("due", due)
+
+	if todo.Status == PROGRESS {
+		task.SetExtension("status", PROGRESS)
+	} else if status, _ := task.Extension("status"); status == PROGRESS {
+		task.SetExtension("status", "")
+	}
+	return task
+}
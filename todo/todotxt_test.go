@@ -0,0 +1,67 @@
+// todo/todotxt_test.go
+package todo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/imadbg01/go-todo/todotxt"
+)
+
+// TestTaskRoundTrip imports lines the way Import does and exports them
+// again, with the dates the server fills in for the ones a line lacks.
+func TestTaskRoundTrip(t *testing.T) {
+	now := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	lines := []string{
+		"Call mom",
+		"2024-01-02 Call mom",
+		"x Call mom",
+		"x 2024-01-03 Call mom",
+		"x 2024-01-03 2024-01-02 Call mom",
+		"(A) Call mom +family due:2024-02-01",
+		"(F) Call mom",
+		"Call mom status:in_progress",
+	}
+	for _, line := range lines {
+		task, err := todotxt.Parse(line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		todo, err := FromTask(task)
+		if err != nil {
+			t.Fatalf("FromTask(%q): %v", line, err)
+		}
+		todo = importable(todo)
+		if todo.CreatedAt.IsZero() {
+			todo.CreatedAt = now
+		}
+		todo.complete()
+		if got := ToTask(todo).String(); got != line {
+			t.Errorf("%q came back as %q", line, got)
+		}
+	}
+}
+
+func TestToTaskDates(t *testing.T) {
+	created := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	completed := time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC)
+	todo := Todo{Name: "Call mom", Status: DONE, CompletedAt: &completed}
+	todo.CreatedAt = created
+
+	tests := []struct {
+		dates string
+		want  string
+	}{
+		{"", "x 2024-01-03 2024-01-02 Call mom"},
+		{txtNoDates, "x Call mom"},
+		{txtCompletionDate, "x 2024-01-03 Call mom"},
+		// A lone date would be read as the completion date.
+		{txtCreationDate, "x 2024-01-03 2024-01-02 Call mom"},
+	}
+	for _, test := range tests {
+		todo.TxtDates = test.dates
+		if got := ToTask(todo).String(); got != test.want {
+			t.Errorf("TxtDates %q: %q, want %q", test.dates, got, test.want)
+		}
+	}
+}
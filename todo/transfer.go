@@ -0,0 +1,374 @@
+// todo/transfer.go
+package todo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/imadbg01/go-todo/todotxt"
+)
+
+const (
+	FormatJSON    = "json"
+	FormatNDJSON  = "ndjson"
+	FormatCSV     = "csv"
+	FormatTodoTxt = "todotxt"
+	FormatICS     = "ics"
+)
+
+// csvColumns are the columns of a CSV export. Imports accept any subset in
+// any order, as named by the header row.
+var csvColumns = []string{
+	"id", "external_id", "name", "description", "status", "priority", "rank",
+	"due", "time_zone", "recurrence", "repeat_from", "series_id", "occurrence",
+	"completed_at", "created_at", "updated_at",
+}
+
+// ImportReport summarises an import. Rows are numbered from 1, not counting
+// a CSV header; todo.txt rows are line numbers.
+type ImportReport struct {
+	DryRun  bool          `json:"dry_run"`
+	Created int           `json:"created"`
+	Updated int           `json:"updated"`
+	Failed  int           `json:"failed"`
+	Errors  []ImportError `json:"errors"`
+}
+
+type ImportError struct {
+	Row        int    `json:"row"`
+	ExternalID string `json:"external_id,omitempty"`
+	Error      string `json:"error"`
+}
+
+// exporter writes todos one at a time in one of the export formats.
+type exporter struct {
+	format string
+	w      *bufio.Writer
+	csv    *csv.Writer
+	count  int
+	// marshal encodes the todos of the JSON formats.
+	marshal func(value interface{}) ([]byte, error)
+}
+
+func newExporter(format string, w *bufio.Writer, marshal func(value interface{}) ([]byte, error)) (*exporter, error) {
+	exporter := &exporter{format: format, w: w, marshal: marshal}
+	switch format {
+	case FormatJSON:
+		w.WriteString("[")
+	case FormatNDJSON, FormatTodoTxt:
+	case FormatCSV:
+		exporter.csv = csv.NewWriter(w)
+		exporter.csv.Write(csvColumns)
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+	return exporter, nil
+}
+
+func (exporter *exporter) Write(todo Todo) error {
+	defer func() { exporter.count++ }()
+	if exporter.csv != nil {
+		return exporter.csv.Write(csvRecord(todo))
+	}
+	if exporter.format == FormatTodoTxt {
+		return todotxt.Write(exporter.w, ToTask(todo))
+	}
+
+	body, err := exporter.marshal(todo)
+	if err != nil {
+		return err
+	}
+	if exporter.format == FormatJSON && exporter.count > 0 {
+		exporter.w.WriteString(",")
+	}
+	exporter.w.Write(body)
+	if exporter.format == FormatNDJSON {
+		exporter.w.WriteString("\n")
+	}
+	return nil
+}
+
+func (exporter *exporter) Close() error {
+	if exporter.csv != nil {
+		exporter.csv.Flush()
+		return exporter.csv.Error()
+	}
+	if exporter.format == FormatJSON {
+		exporter.w.WriteString("]")
+	}
+	return nil
+}
+
+func formatTime(at *time.Time) string {
+	if at == nil {
+		return ""
+	}
+	return at.Format(time.RFC3339)
+}
+
+func csvRecord(todo Todo) []string {
+	return []string{
+		strconv.FormatUint(uint64(todo.ID), 10),
+		todo.ExternalID,
+		todo.Name,
+		todo.Description,
+		todo.Status,
+		todo.Priority,
+		strconv.FormatFloat(todo.Rank, 'g', -1, 64),
+		formatTime(todo.Due),
+		todo.TimeZone,
+		todo.Recurrence,
+		todo.RepeatFrom,
+		strconv.FormatUint(uint64(todo.SeriesID), 10),
+		strconv.Itoa(todo.Occurrence),
+		formatTime(todo.CompletedAt),
+		todo.CreatedAt.Format(time.RFC3339Nano),
+		todo.UpdatedAt.Format(time.RFC3339Nano),
+	}
+}
+
+// readTodos decodes the todos in r, calling fn for every row with the todo or
+// the error that row has. A JSON array that is not well formed stops reading;
+// bad NDJSON lines and CSV records are reported and skipped.
+func readTodos(format string, r io.Reader, fn func(row int, todo Todo, err error) error) error {
+	switch format {
+	case FormatJSON:
+		decoder := json.NewDecoder(r)
+		if token, err := decoder.Token(); err != nil || token != json.Delim('[') {
+			return errors.New("expected a JSON array of todos")
+		}
+		for row := 1; decoder.More(); row++ {
+			var todo Todo
+			if err := decoder.Decode(&todo); err != nil {
+				if _, ok := err.(*json.UnmarshalTypeError); !ok {
+					return err
+				}
+				if err := fn(row, todo, err); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := fn(row, todo, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case FormatNDJSON:
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64<<10), 1<<20)
+		row := 0
+		for scanner.Scan() {
+			line := bytes.TrimSpace(scanner.Bytes())
+			if len(line) == 0 {
+				continue
+			}
+			row++
+			var todo Todo
+			err := json.Unmarshal(line, &todo)
+			if err := fn(row, todo, err); err != nil {
+				return err
+			}
+		}
+		return scanner.Err()
+
+	case FormatTodoTxt:
+		return todotxt.Read(r, func(line int, task todotxt.Task, err error) error {
+			var todo Todo
+			if err == nil {
+				todo, err = FromTask(task)
+			}
+			return fn(line, todo, err)
+		})
+
+	case FormatCSV:
+		reader := csv.NewReader(r)
+		reader.FieldsPerRecord = -1
+		header, err := reader.Read()
+		if err != nil {
+			return errors.New("expected a CSV header row")
+		}
+		columns := map[string]int{}
+		for i, name := range header {
+			columns[strings.TrimSpace(strings.ToLower(name))] = i
+		}
+		if _, ok := columns["name"]; !ok {
+			return errors.New("CSV header has no name column")
+		}
+		for row := 1; ; row++ {
+			record, err := reader.Read()
+			if err == io.EOF {
+				return nil
+			}
+			var todo Todo
+			if err == nil {
+				todo, err = fromCSV(columns, record)
+			}
+			if err := fn(row, todo, err); err != nil {
+				return err
+			}
+		}
+	}
+	return fmt.Errorf("unknown format %q", format)
+}
+
+func fromCSV(columns map[string]int, record []string) (Todo, error) {
+	field := func(name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return record[i]
+		}
+		return ""
+	}
+
+	todo := Todo{
+		ExternalID:  field("external_id"),
+		Name:        field("name"),
+		Description: field("description"),
+		Status:      field("status"),
+		Priority:    field("priority"),
+		TimeZone:    field("time_zone"),
+		Recurrence:  field("recurrence"),
+		RepeatFrom:  field("repeat_from"),
+	}
+	var err error
+	if todo.Due, err = parseTime(field("due")); err != nil {
+		return todo, errors.New("due must be an RFC 3339 timestamp")
+	}
+	if todo.CompletedAt, err = parseTime(field("completed_at")); err != nil {
+		return todo, errors.New("completed_at must be an RFC 3339 timestamp")
+	}
+	return todo, nil
+}
+
+func parseTime(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	at, err := time.Parse(time.RFC3339, value)
+	return &at, err
+}
+
+// importable keeps only the fields an import may set; IDs, ranks and update
+// times are assigned by this server. Rows without a status are pending.
+func importable(todo Todo) Todo {
+	if todo.Status == "" {
+		todo.Status = PENDING
+	}
+	imported := Todo{
+		ExternalID:  strings.TrimSpace(todo.ExternalID),
+		Name:        todo.Name,
+		Description: todo.Description,
+		Status:      todo.Status,
+		Priority:    todo.Priority,
+		Due:         todo.Due,
+		TimeZone:    todo.TimeZone,
+		Recurrence:  todo.Recurrence,
+		RepeatFrom:  todo.RepeatFrom,
+		CompletedAt: todo.CompletedAt,
+		TxtDates:    todo.TxtDates,
+	}
+	imported.CreatedAt = todo.CreatedAt
+	return imported
+}
+
+// Import writes the todos read from r. With upsert a row whose external ID
+// matches an existing todo, or an earlier row of the file, updates it;
+// otherwise every row is inserted, as are todo.txt tasks, which carry no ID.
+// A dry run validates and counts without writing, and reports what the
+// import would.
+func (repository *TodoRepository) Import(format string, r io.Reader, upsert, dryRun bool) (ImportReport, error) {
+	report := ImportReport{DryRun: dryRun, Errors: []ImportError{}}
+	// imported holds the external IDs of the rows the dry run would have
+	// created, which later rows of the file would update.
+	imported := map[string]bool{}
+	// count is how many todos the dry run would leave, for the rows the
+	// import would refuse for the quota.
+	var count int
+	if dryRun && repository.quota > 0 {
+		if err := repository.database.Model(&Todo{}).Count(&count).Error; err != nil {
+			return report, err
+		}
+	}
+	err := readTodos(format, r, func(row int, todo Todo, err error) error {
+		todo = importable(todo)
+		if err == nil {
+			err = validateImport(todo)
+		}
+		if err == nil && dryRun && upsert && imported[todo.ExternalID] {
+			report.Updated++
+			return nil
+		}
+		if err == nil {
+			var created bool
+			created, err = repository.importOne(todo, upsert, dryRun)
+			if created && dryRun && repository.quota > 0 {
+				if count >= repository.quota {
+					created, err = false, &QuotaError{Count: count, Quota: repository.quota}
+				} else {
+					count++
+				}
+			}
+			if created && dryRun && todo.ExternalID != "" {
+				imported[todo.ExternalID] = true
+			}
+			if created {
+				report.Created++
+			} else if err == nil {
+				report.Updated++
+			}
+		}
+		if err != nil {
+			report.Failed++
+			report.Errors = append(report.Errors, ImportError{Row: row, ExternalID: todo.ExternalID, Error: err.Error()})
+		}
+		return nil
+	})
+	return report, err
+}
+
+func (repository *TodoRepository) importOne(todo Todo, upsert, dryRun bool) (bool, error) {
+	if upsert && todo.ExternalID != "" {
+		existing, err := repository.FindByExternalID(todo.ExternalID)
+		if err == nil {
+			if dryRun {
+				return false, nil
+			}
+			existing.Name = todo.Name
+			existing.Description = todo.Description
+			existing.Status = todo.Status
+			existing.Priority = todo.Priority
+			existing.Due = todo.Due
+			existing.TimeZone = todo.TimeZone
+			existing.Recurrence = todo.Recurrence
+			existing.RepeatFrom = todo.RepeatFrom
+			existing.CompletedAt = todo.CompletedAt
+			_, err = repository.Save(existing)
+			return false, err
+		}
+	}
+	if dryRun {
+		return true, nil
+	}
+	_, err := repository.Create(todo)
+	return err == nil, err
+}
+
+func validateImport(todo Todo) error {
+	if strings.TrimSpace(todo.Name) == "" {
+		return errors.New("name is required")
+	}
+	switch todo.Status {
+	case PENDING, PROGRESS, DONE:
+	default:
+		return fmt.Errorf("invalid status %q", todo.Status)
+	}
+	return Validate(todo)
+}
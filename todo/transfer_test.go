@@ -0,0 +1,87 @@
+// todo/transfer_test.go
+package todo
+
+import (
+	"strings"
+	"testing"
+)
+
+const importFile = `{"external_id":"a","name":"First","status":"pending"}
+{"external_id":"b","name":"Second","status":"archived"}
+{"external_id":"a","name":"First again","status":"done"}
+{"name":"No status"}
+{"external_id":"c","name":"","status":"pending"}
+`
+
+func TestImportValidatesStatus(t *testing.T) {
+	repository := newTestRepository(t)
+	report, err := repository.Import(FormatNDJSON, strings.NewReader(importFile), false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Created != 3 || report.Failed != 2 {
+		t.Fatalf("report %+v, want 3 created and 2 failed", report)
+	}
+	if report.Errors[0].Row != 2 || report.Errors[0].Error != `invalid status "archived"` {
+		t.Errorf("first error %+v, want the unknown status of row 2", report.Errors[0])
+	}
+	for _, todo := range repository.FindAll() {
+		if todo.Name == "No status" && todo.Status != PENDING {
+			t.Errorf("row without a status imported as %q, want pending", todo.Status)
+		}
+	}
+}
+
+func TestImportDryRunMatchesImport(t *testing.T) {
+	for _, upsert := range []bool{false, true} {
+		repository := newTestRepository(t)
+		if _, err := repository.Create(Todo{ExternalID: "b", Name: "Existing", Status: PENDING}); err != nil {
+			t.Fatal(err)
+		}
+		file := importFile + `{"external_id":"b","name":"Existing again","status":"done"}` + "\n"
+
+		dryRun, err := repository.Import(FormatNDJSON, strings.NewReader(file), upsert, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if todos := repository.FindAll(); len(todos) != 1 {
+			t.Fatalf("dry run wrote %d todos", len(todos)-1)
+		}
+		report, err := repository.Import(FormatNDJSON, strings.NewReader(file), upsert, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if dryRun.Created != report.Created || dryRun.Updated != report.Updated || dryRun.Failed != report.Failed {
+			t.Errorf("upsert %v: dry run reported %+v, the import %+v", upsert, dryRun, report)
+		}
+		if upsert && (report.Created != 2 || report.Updated != 2) {
+			t.Errorf("upsert reported %+v, want the repeated external IDs updated", report)
+		}
+	}
+}
+
+func TestImportDryRunCountsQuota(t *testing.T) {
+	repository := newTestRepository(t)
+	repository.quota = 3
+	if _, err := repository.Create(Todo{Name: "Existing", Status: PENDING}); err != nil {
+		t.Fatal(err)
+	}
+
+	dryRun, err := repository.Import(FormatNDJSON, strings.NewReader(importFile), false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := repository.Import(FormatNDJSON, strings.NewReader(importFile), false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dryRun.Created != 2 || dryRun.Failed != report.Failed || report.Created != 2 {
+		t.Fatalf("dry run reported %+v, the import %+v, want both to create 2", dryRun, report)
+	}
+	for i := range report.Errors {
+		if dryRun.Errors[i] != report.Errors[i] {
+			t.Errorf("dry run error %+v, the import %+v", dryRun.Errors[i], report.Errors[i])
+		}
+	}
+}
@@ -0,0 +1,14 @@
+// todo/validation.go
+package todo
+
+import "fmt"
+
+// Validate checks the user-supplied fields of a todo before it is written.
+func Validate(todo Todo) error {
+	switch todo.Priority {
+	case "", PriorityNone, PriorityLow, PriorityMedium, PriorityHigh, PriorityUrgent:
+	default:
+		return fmt.Errorf("invalid priority %q", todo.Priority)
+	}
+	return validateRecurrence(todo)
+}
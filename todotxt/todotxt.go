@@ -0,0 +1,201 @@
+// todotxt/todotxt.go
+
+// Package todotxt reads and writes the todo.txt format described at
+// https://github.com/todotxt/todo.txt. Parse and String round trip a line
+// exactly: the parts in front of the description are each followed by one
+// space, as the format has them, and everything after that, whitespace
+// included, is the description, where projects, contexts and key:value
+// extensions stay where they are.
+package todotxt
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+	"time"
+)
+
+const dateLayout = "2006-01-02"
+
+type Task struct {
+	Completed      bool
+	Priority       string
+	CompletionDate *time.Time
+	CreationDate   *time.Time
+	Description    string
+}
+
+// Extension is a key:value pair from the description.
+type Extension struct {
+	Key   string
+	Value string
+}
+
+// Parse reads one todo.txt line.
+func Parse(line string) (Task, error) {
+	var task Task
+	rest := strings.TrimRight(line, "\r\n")
+	if strings.TrimSpace(rest) == "" {
+		return task, errors.New("empty task")
+	}
+
+	if strings.HasPrefix(rest, "x ") {
+		task.Completed = true
+		rest = rest[2:]
+	}
+	if len(rest) >= 4 && rest[0] == '(' && rest[1] >= 'A' && rest[1] <= 'Z' && rest[2] == ')' && rest[3] == ' ' {
+		task.Priority = rest[1:2]
+		rest = rest[4:]
+	}
+
+	first, ok := leadingDate(&rest)
+	if ok {
+		if second, ok := leadingDate(&rest); ok {
+			task.CompletionDate, task.CreationDate = &first, &second
+		} else if task.Completed {
+			task.CompletionDate = &first
+		} else {
+			task.CreationDate = &first
+		}
+	}
+
+	task.Description = rest
+	if strings.TrimSpace(task.Description) == "" {
+		return task, errors.New("task has no description")
+	}
+	return task, nil
+}
+
+func leadingDate(rest *string) (time.Time, bool) {
+	if len(*rest) < len(dateLayout) || (len(*rest) > len(dateLayout) && (*rest)[len(dateLayout)] != ' ') {
+		return time.Time{}, false
+	}
+	date, err := time.Parse(dateLayout, (*rest)[:len(dateLayout)])
+	if err != nil {
+		return time.Time{}, false
+	}
+	*rest = strings.TrimPrefix((*rest)[len(dateLayout):], " ")
+	return date, true
+}
+
+// String formats the task as a todo.txt line.
+func (task Task) String() string {
+	var parts []string
+	if task.Completed {
+		parts = append(parts, "x")
+	}
+	if task.Priority != "" {
+		parts = append(parts, "("+task.Priority+")")
+	}
+	if task.CompletionDate != nil && (task.Completed || task.CreationDate != nil) {
+		parts = append(parts, task.CompletionDate.Format(dateLayout))
+	}
+	if task.CreationDate != nil {
+		parts = append(parts, task.CreationDate.Format(dateLayout))
+	}
+	parts = append(parts, task.Description)
+	return strings.Join(parts, " ")
+}
+
+// Projects returns the +project tags in the description.
+func (task Task) Projects() []string {
+	return task.tagged('+')
+}
+
+// Contexts returns the @context tags in the description.
+func (task Task) Contexts() []string {
+	return task.tagged('@')
+}
+
+func (task Task) tagged(sigil byte) []string {
+	var tags []string
+	for _, word := range strings.Fields(task.Description) {
+		if len(word) > 1 && word[0] == sigil {
+			tags = append(tags, word[1:])
+		}
+	}
+	return tags
+}
+
+// Extensions returns the key:value pairs in the description in order.
+func (task Task) Extensions() []Extension {
+	var extensions []Extension
+	for _, word := range strings.Fields(task.Description) {
+		if extension, ok := parseExtension(word); ok {
+			extensions = append(extensions, extension)
+		}
+	}
+	return extensions
+}
+
+// Extension returns the value of the first extension with the given key.
+func (task Task) Extension(key string) (string, bool) {
+	for _, extension := range task.Extensions() {
+		if extension.Key == key {
+			return extension.Value, true
+		}
+	}
+	return "", false
+}
+
+// SetExtension replaces the value of the extension with the given key, or
+// appends it to the description. An empty value removes the extension.
+func (task *Task) SetExtension(key, value string) {
+	words := strings.Split(task.Description, " ")
+	kept := words[:0]
+	found := false
+	for _, word := range words {
+		if extension, ok := parseExtension(word); ok && extension.Key == key {
+			if found || value == "" {
+				continue
+			}
+			word, found = key+":"+value, true
+		}
+		kept = append(kept, word)
+	}
+	if !found && value != "" {
+		kept = append(kept, key+":"+value)
+	}
+	task.Description = strings.TrimSpace(strings.Join(kept, " "))
+}
+
+// parseExtension recognises key:value words, leaving out URLs such as
+// https://example.com.
+func parseExtension(word string) (Extension, bool) {
+	i := strings.IndexByte(word, ':')
+	if i <= 0 || i == len(word)-1 {
+		return Extension{}, false
+	}
+	key, value := word[:i], word[i+1:]
+	if strings.ContainsAny(value, ":") || strings.HasPrefix(value, "//") || key[0] == '+' || key[0] == '@' {
+		return Extension{}, false
+	}
+	return Extension{Key: key, Value: value}, true
+}
+
+// Read parses the lines of r, calling fn with the 1-based line number and the
+// task or the error of every line that is not blank.
+func Read(r io.Reader, fn func(line int, task Task, err error) error) error {
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		task, err := Parse(scanner.Text())
+		if err := fn(line, task, err); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// Write writes the tasks to w, one per line.
+func Write(w io.Writer, tasks ...Task) error {
+	for _, task := range tasks {
+		if _, err := io.WriteString(w, task.String()+"\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+// todotxt/todotxt_test.go
+package todotxt
+
+import "testing"
+
+func TestRoundTrip(t *testing.T) {
+	lines := []string{
+		"Call mom",
+		"(A) Call mom +family @phone",
+		"2024-01-02 Call mom",
+		"(B) 2024-01-02 Call mom due:2024-02-01",
+		"x Call mom",
+		"x 2024-01-03 Call mom",
+		"x 2024-01-03 2024-01-02 Call mom",
+		"x (C) 2024-01-03 2024-01-02 Call mom",
+		"2024-01-03 2024-01-02 Call mom",
+		"x  two spaces",
+		"(A)  Call  mom  ",
+		"  indented",
+		"(a) lower case is no priority",
+		"x2024-01-02 not completed",
+		"2024-13-01 not a date",
+		"see https://example.com key:value",
+	}
+	for _, line := range lines {
+		task, err := Parse(line)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", line, err)
+			continue
+		}
+		if got := task.String(); got != line {
+			t.Errorf("Parse(%q).String() = %q", line, got)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	task, err := Parse("x (A) 2024-01-03 2024-01-02 Call mom +family @phone due:2024-02-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !task.Completed || task.Priority != "A" || task.Description != "Call mom +family @phone due:2024-02-01" {
+		t.Errorf("Parse = %+v", task)
+	}
+	if task.CompletionDate == nil || task.CompletionDate.Format(dateLayout) != "2024-01-03" ||
+		task.CreationDate == nil || task.CreationDate.Format(dateLayout) != "2024-01-02" {
+		t.Errorf("dates = %v, %v", task.CompletionDate, task.CreationDate)
+	}
+	if due, _ := task.Extension("due"); due != "2024-02-01" {
+		t.Errorf("due = %q", due)
+	}
+	if projects, contexts := task.Projects(), task.Contexts(); len(projects) != 1 || projects[0] != "family" || len(contexts) != 1 || contexts[0] != "phone" {
+		t.Errorf("projects %v, contexts %v", projects, contexts)
+	}
+
+	for _, line := range []string{"", "   ", "x ", "(A) 2024-01-02 "} {
+		if _, err := Parse(line); err == nil {
+			t.Errorf("Parse(%q) succeeded", line)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+// version/handlers.go
+package version
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/imadbg01/go-todo/openapi"
+)
+
+type VersionHandler struct {
+	versions []*Version
+}
+
+// GetAll lists the versions with their deprecation dates and use.
+func (handler *VersionHandler) GetAll(c *fiber.Ctx) error {
+	statuses := make([]Status, len(handler.versions))
+	for i, v := range handler.versions {
+		statuses[i] = v.Status()
+	}
+	return c.JSON(statuses)
+}
+
+func NewVersionHandler(versions []*Version) *VersionHandler {
+	return &VersionHandler{
+		versions: versions,
+	}
+}
+
+func Register(router fiber.Router, versions ...*Version) {
+	versionHandler := NewVersionHandler(versions)
+
+	openapi.Describe(versionHandler.GetAll, openapi.Doc{Summary: "List the API versions and their use", Result: []Status{}})
+
+	router.Get("/versions", versionHandler.GetAll)
+}
@@ -0,0 +1,31 @@
+// version/mapper.go
+package version
+
+// Rename returns a mapper that renames the keys of every object of a body,
+// at any depth. Keys renamed to "" are dropped.
+func Rename(names map[string]string) Mapper {
+	var walk Mapper
+	walk = func(body interface{}) interface{} {
+		switch body := body.(type) {
+		case map[string]interface{}:
+			mapped := make(map[string]interface{}, len(body))
+			for key, value := range body {
+				if name, ok := names[key]; ok {
+					if name == "" {
+						continue
+					}
+					key = name
+				}
+				mapped[key] = walk(value)
+			}
+			return mapped
+		case []interface{}:
+			for i, value := range body {
+				body[i] = walk(value)
+			}
+			return body
+		}
+		return body
+	}
+	return walk
+}
@@ -0,0 +1,37 @@
+// version/mapper_test.go
+package version
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestRename(t *testing.T) {
+	mapper := Rename(map[string]string{"ID": "id", "DeletedAt": ""})
+	for _, test := range []struct {
+		name       string
+		body, want string
+	}{
+		{"scalar", `3`, `3`},
+		{"object", `{"ID":1,"DeletedAt":null,"name":"Report"}`, `{"id":1,"name":"Report"}`},
+		{"array", `[{"ID":1},{"ID":2,"DeletedAt":"2024-01-01T00:00:00Z"}]`, `[{"id":1},{"id":2}]`},
+		{"nested object", `{"todo":{"ID":1,"parent":{"ID":2}}}`, `{"todo":{"id":1,"parent":{"id":2}}}`},
+		{"object in array in object", `{"items":[{"ID":1,"tags":[{"ID":3}]}]}`, `{"items":[{"id":1,"tags":[{"id":3}]}]}`},
+		{"arrays of arrays", `[[{"ID":1}],[]]`, `[[{"id":1}],[]]`},
+		{"values are left alone", `{"name":"ID","ids":["ID","DeletedAt"]}`, `{"name":"ID","ids":["ID","DeletedAt"]}`},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var body, want interface{}
+			if err := json.Unmarshal([]byte(test.body), &body); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal([]byte(test.want), &want); err != nil {
+				t.Fatal(err)
+			}
+			if got := mapper(body); !reflect.DeepEqual(got, want) {
+				t.Errorf("mapped %s to %v, want %s", test.body, got, test.want)
+			}
+		})
+	}
+}
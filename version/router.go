@@ -0,0 +1,136 @@
+// version/router.go
+package version
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/imadbg01/go-todo/openapi"
+)
+
+// router mounts every route it is given on the prefix of each version, in
+// front of the version's middleware. Packages register their routes once,
+// so migrations and subscriptions in their Register functions run once.
+type router struct {
+	versions []*Version
+	groups   []fiber.Router
+	// prefix is the path of the router below the prefixes of the versions.
+	prefix string
+}
+
+// Router returns a router that serves its routes under every version.
+func Router(app *fiber.App, versions ...*Version) fiber.Router {
+	r := &router{versions: versions}
+	for _, v := range versions {
+		r.groups = append(r.groups, app.Group(v.Prefix))
+	}
+	return r
+}
+
+func (r *router) Add(method, path string, handlers ...fiber.Handler) fiber.Router {
+	for i, group := range r.groups {
+		v := r.versions[i]
+		group.Add(method, path, append([]fiber.Handler{v.handle}, handlers...)...)
+		if !v.Deprecation.IsZero() {
+			openapi.Deprecate(method, join(v.Prefix+r.prefix, path))
+		}
+	}
+	return r
+}
+
+// Get also serves HEAD requests, as Fiber's own routers do.
+func (r *router) Get(path string, handlers ...fiber.Handler) fiber.Router {
+	r.Add(fiber.MethodHead, path, handlers...)
+	return r.Add(fiber.MethodGet, path, handlers...)
+}
+
+func (r *router) Head(path string, handlers ...fiber.Handler) fiber.Router {
+	return r.Add(fiber.MethodHead, path, handlers...)
+}
+
+func (r *router) Post(path string, handlers ...fiber.Handler) fiber.Router {
+	return r.Add(fiber.MethodPost, path, handlers...)
+}
+
+func (r *router) Put(path string, handlers ...fiber.Handler) fiber.Router {
+	return r.Add(fiber.MethodPut, path, handlers...)
+}
+
+func (r *router) Delete(path string, handlers ...fiber.Handler) fiber.Router {
+	return r.Add(fiber.MethodDelete, path, handlers...)
+}
+
+func (r *router) Connect(path string, handlers ...fiber.Handler) fiber.Router {
+	return r.Add(fiber.MethodConnect, path, handlers...)
+}
+
+func (r *router) Options(path string, handlers ...fiber.Handler) fiber.Router {
+	return r.Add(fiber.MethodOptions, path, handlers...)
+}
+
+func (r *router) Trace(path string, handlers ...fiber.Handler) fiber.Router {
+	return r.Add(fiber.MethodTrace, path, handlers...)
+}
+
+func (r *router) Patch(path string, handlers ...fiber.Handler) fiber.Router {
+	return r.Add(fiber.MethodPatch, path, handlers...)
+}
+
+func (r *router) All(path string, handlers ...fiber.Handler) fiber.Router {
+	for _, method := range []string{
+		fiber.MethodGet, fiber.MethodHead, fiber.MethodPost, fiber.MethodPut, fiber.MethodDelete,
+		fiber.MethodConnect, fiber.MethodOptions, fiber.MethodTrace, fiber.MethodPatch,
+	} {
+		r.Add(method, path, handlers...)
+	}
+	return r
+}
+
+// Use adds middleware to the prefix of every version. The prefix of one
+// version can contain another's, so it is best kept to groups.
+func (r *router) Use(args ...interface{}) fiber.Router {
+	for _, group := range r.groups {
+		group.Use(args...)
+	}
+	return r
+}
+
+func (r *router) Group(prefix string, handlers ...fiber.Handler) fiber.Router {
+	sub := &router{versions: r.versions, prefix: join(r.prefix, prefix)}
+	for _, group := range r.groups {
+		sub.groups = append(sub.groups, group.Group(prefix, handlers...))
+	}
+	return sub
+}
+
+func (r *router) Static(prefix, root string, config ...fiber.Static) fiber.Router {
+	for _, group := range r.groups {
+		group.Static(prefix, root, config...)
+	}
+	return r
+}
+
+func (r *router) Mount(prefix string, app *fiber.App) fiber.Router {
+	for _, group := range r.groups {
+		group.Mount(prefix, app)
+	}
+	return r
+}
+
+func (r *router) Name(name string) fiber.Router {
+	for _, group := range r.groups {
+		group.Name(name)
+	}
+	return r
+}
+
+// join appends path to prefix the way Fiber's groups do.
+func join(prefix, path string) string {
+	if path == "" || path == "/" {
+		return prefix
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return strings.TrimRight(prefix, "/") + path
+}
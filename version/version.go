@@ -0,0 +1,231 @@
+// version/version.go
+// Package version serves the API under versioned prefixes. Every version
+// shares the handlers of the others; a version differs by the mapper that
+// reshapes its JSON responses, and by when it is deprecated and removed.
+package version
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/imadbg01/go-todo/config"
+)
+
+// localsKey is the local holding the version of a request.
+const localsKey = "version"
+
+const (
+	// maxClients bounds the clients counted by a version. Requests from
+	// clients beyond it are counted under otherClients.
+	maxClients   = 100
+	otherClients = "other"
+	// maxClientLength truncates the names clients give themselves.
+	maxClientLength = 64
+)
+
+// Mapper reshapes a decoded JSON body into the shape of a version.
+type Mapper func(body interface{}) interface{}
+
+type Version struct {
+	Name   string
+	Prefix string
+	// Mapper reshapes responses, which are left as the handlers wrote them
+	// when nil.
+	Mapper Mapper
+	// Deprecation is when the version was or will be deprecated, zero when
+	// it is not.
+	Deprecation time.Time
+	// Sunset is when the version stops being served, zero when no date is
+	// set. Requests after it are answered with 410 Gone.
+	Sunset time.Time
+	// Successor is the prefix clients should move to.
+	Successor string
+
+	mu      sync.Mutex
+	metrics Metrics
+}
+
+// Metrics counts the use of a version since the server started, to know
+// when nobody depends on an old version anymore. Clients are counted by the
+// product their User-Agent names, such as go-todo-cli.
+type Metrics struct {
+	Requests      uint64            `json:"requests"`
+	Errors        uint64            `json:"errors"`
+	LastRequestAt *time.Time        `json:"last_request_at"`
+	Clients       map[string]uint64 `json:"clients"`
+}
+
+// Status describes a version and its use.
+type Status struct {
+	Name        string     `json:"name"`
+	Prefix      string     `json:"prefix"`
+	Deprecation *time.Time `json:"deprecation"`
+	Sunset      *time.Time `json:"sunset"`
+	Successor   string     `json:"successor,omitempty"`
+	Metrics
+}
+
+// Date reads a date such as 2027-01-31 or an RFC 3339 time from the setting
+// key. It is the zero time when neither the setting nor fallback is set.
+func Date(key string, fallback string) time.Time {
+	value := config.ConfigOr(key, fallback)
+	if value == "" {
+		return time.Time{}
+	}
+	for _, layout := range []string{"2006-01-02", time.RFC3339} {
+		if date, err := time.Parse(layout, value); err == nil {
+			return date
+		}
+	}
+	log.Printf("version: %s is not a date: %q", key, value)
+	return time.Time{}
+}
+
+// Of returns the version a request was made to, nil when it was made to an
+// unversioned route.
+func Of(c *fiber.Ctx) *Version {
+	v, _ := c.Locals(localsKey).(*Version)
+	return v
+}
+
+// Marshal encodes value as JSON in the shape of the version, for handlers
+// that stream their responses. A nil version encodes it as is.
+func (v *Version) Marshal(value interface{}) ([]byte, error) {
+	data, err := json.Marshal(value)
+	if err != nil || v == nil || v.Mapper == nil {
+		return data, err
+	}
+	return v.reshape(data)
+}
+
+func (v *Version) reshape(data []byte) ([]byte, error) {
+	var body interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&body); err != nil {
+		return nil, err
+	}
+	return json.Marshal(v.Mapper(body))
+}
+
+// handle runs in front of every handler of the version.
+func (v *Version) handle(c *fiber.Ctx) error {
+	c.Locals(localsKey, v)
+	if !v.Deprecation.IsZero() {
+		c.Set("Deprecation", "@"+strconv.FormatInt(v.Deprecation.Unix(), 10))
+		if v.Successor != "" {
+			c.Append(fiber.HeaderLink, "<"+v.Successor+`>; rel="successor-version"`)
+		}
+	}
+	if !v.Sunset.IsZero() {
+		c.Set("Sunset", v.Sunset.UTC().Format(http.TimeFormat))
+		if !time.Now().Before(v.Sunset) {
+			v.record(c, fiber.StatusGone)
+			return c.Status(410).JSON(fiber.Map{
+				"status":  410,
+				"message": "API " + v.Name + " has been removed",
+				"error":   "Sunset at " + v.Sunset.UTC().Format(time.RFC3339),
+			})
+		}
+	}
+
+	err := c.Next()
+	status := c.Response().StatusCode()
+	if err != nil {
+		status = fiber.StatusInternalServerError
+		if e, ok := err.(*fiber.Error); ok {
+			status = e.Code
+		}
+	}
+	v.record(c, status)
+	if err == nil && v.Mapper != nil {
+		v.reshapeResponse(c)
+	}
+	return err
+}
+
+// reshapeResponse maps a JSON response to the shape of the version.
+// Streamed responses are left alone; their handlers use Marshal.
+func (v *Version) reshapeResponse(c *fiber.Ctx) {
+	response := c.Response()
+	if response.IsBodyStream() || len(response.Body()) == 0 ||
+		!strings.HasPrefix(string(response.Header.ContentType()), fiber.MIMEApplicationJSON) {
+		return
+	}
+	data, err := v.reshape(response.Body())
+	if err != nil {
+		log.Printf("version: failed reshaping response for %s: %v", v.Name, err)
+		return
+	}
+	response.SetBody(data)
+}
+
+func (v *Version) record(c *fiber.Ctx, status int) {
+	now := time.Now()
+	client := clientOf(c)
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.metrics.Requests++
+	if status >= 500 {
+		v.metrics.Errors++
+	}
+	v.metrics.LastRequestAt = &now
+	if v.metrics.Clients == nil {
+		v.metrics.Clients = map[string]uint64{}
+	}
+	if _, ok := v.metrics.Clients[client]; !ok && len(v.metrics.Clients) >= maxClients {
+		client = otherClients
+	}
+	v.metrics.Clients[client]++
+}
+
+// clientOf names the client of a request by the first product of its
+// User-Agent, go-todo-cli for "go-todo-cli/1.2 (linux)".
+func clientOf(c *fiber.Ctx) string {
+	client := strings.TrimSpace(c.Get(fiber.HeaderUserAgent))
+	if i := strings.IndexAny(client, "/ "); i >= 0 {
+		client = client[:i]
+	}
+	if client == "" {
+		return "unknown"
+	}
+	if len(client) > maxClientLength {
+		client = client[:maxClientLength]
+	}
+	// The header is only valid during the request, and the name is kept.
+	return utils.CopyString(client)
+}
+
+// Status returns the version and a copy of its metrics.
+func (v *Version) Status() Status {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	status := Status{
+		Name:      v.Name,
+		Prefix:    v.Prefix,
+		Successor: v.Successor,
+		Metrics:   v.metrics,
+	}
+	status.Clients = map[string]uint64{}
+	for client, requests := range v.metrics.Clients {
+		status.Clients[client] = requests
+	}
+	if !v.Deprecation.IsZero() {
+		deprecation := v.Deprecation
+		status.Deprecation = &deprecation
+	}
+	if !v.Sunset.IsZero() {
+		sunset := v.Sunset
+		status.Sunset = &sunset
+	}
+	return status
+}
@@ -0,0 +1,87 @@
+// version/version_test.go
+package version
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestMarshal(t *testing.T) {
+	v2 := &Version{Name: "v2", Mapper: Rename(map[string]string{"ID": "id"})}
+	value := []struct{ ID uint }{{ID: 1}}
+
+	data, err := v2.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `[{"id":1}]` {
+		t.Errorf("v2 marshalled %s", data)
+	}
+	var unversioned *Version
+	if data, _ := unversioned.Marshal(value); string(data) != `[{"ID":1}]` {
+		t.Errorf("unversioned route marshalled %s", data)
+	}
+}
+
+func TestRouterReshapesResponses(t *testing.T) {
+	v1 := &Version{Name: "v1", Prefix: "/api/v1"}
+	v2 := &Version{Name: "v2", Prefix: "/api/v2", Mapper: Rename(map[string]string{"ID": "id"})}
+	app := fiber.New()
+	Router(app, v1, v2).Get("/todo", func(c *fiber.Ctx) error {
+		return c.JSON(fiber.Map{"ID": 1})
+	})
+
+	for prefix, want := range map[string]string{"/api/v1": `{"ID":1}`, "/api/v2": `{"id":1}`} {
+		response, err := app.Test(httptest.NewRequest("GET", prefix+"/todo", nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if body, _ := ioutil.ReadAll(response.Body); string(body) != want {
+			t.Errorf("%s answered %s, want %s", prefix, body, want)
+		}
+	}
+}
+
+func TestMetricsBoundClients(t *testing.T) {
+	v1 := &Version{Name: "v1", Prefix: "/api/v1"}
+	app := fiber.New()
+	Router(app, v1).Get("/todo", func(c *fiber.Ctx) error {
+		return c.SendStatus(204)
+	})
+
+	request := func(agent, user string) {
+		req := httptest.NewRequest("GET", "/api/v1/todo", nil)
+		req.Header.Set("User-Agent", agent)
+		req.Header.Set("X-User", user)
+		if _, err := app.Test(req); err != nil {
+			t.Fatal(err)
+		}
+	}
+	request("go-todo-cli/1.2 (linux)", "alice")
+	request("go-todo-cli/1.3", "bob")
+	for i := 0; i < maxClients+10; i++ {
+		request(fmt.Sprintf("client-%d/1.0", i), "")
+	}
+	request(strings.Repeat("x", 1000), "")
+
+	clients := v1.Status().Clients
+	if len(clients) > maxClients+1 {
+		t.Fatalf("%d clients counted, want at most %d", len(clients), maxClients+1)
+	}
+	if clients["go-todo-cli"] != 2 {
+		t.Errorf("go-todo-cli counted %d times, want 2", clients["go-todo-cli"])
+	}
+	if clients[otherClients] != 12 {
+		t.Errorf("%d requests counted as other clients, want 12", clients[otherClients])
+	}
+	for client := range clients {
+		if client == "alice" || client == "bob" || len(client) > maxClientLength {
+			t.Errorf("counted client %q", client)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+// webhook/address.go
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"github.com/imadbg01/go-todo/config"
+)
+
+// sharedAddressSpace is the carrier-grade NAT range, which net.IP has no
+// predicate for.
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// AllowPrivate tells whether endpoints may be on loopback, link-local or
+// private addresses, from WEBHOOK_ALLOW_PRIVATE. It is off so that whoever
+// can register a webhook cannot make the server call internal services.
+func AllowPrivate() bool {
+	return config.ConfigOr("WEBHOOK_ALLOW_PRIVATE", "false") == "true"
+}
+
+// public reports whether ip is an address webhooks may be sent to.
+func public(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() ||
+		sharedAddressSpace.Contains(ip))
+}
+
+// checkHost refuses hosts that are, or resolve to, addresses that are not
+// public.
+func checkHost(ctx context.Context, host string) error {
+	if ip := net.ParseIP(host); ip != nil {
+		if !public(ip) {
+			return fmt.Errorf("url must not point to the internal address %s", ip)
+		}
+		return nil
+	}
+	addresses, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return fmt.Errorf("url host %q does not resolve", host)
+	}
+	for _, address := range addresses {
+		if !public(address.IP) {
+			return fmt.Errorf("url host %q resolves to the internal address %s", host, address.IP)
+		}
+	}
+	return nil
+}
+
+// newClient returns the client deliveries are sent with. Unless private
+// addresses are allowed, it refuses to connect to them, whatever name or
+// redirect led there, so a host cannot be pointed inside after it was
+// registered.
+func newClient(allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	if !allowPrivate {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !public(ip) {
+				return fmt.Errorf("refusing to connect to the internal address %s", host)
+			}
+			return nil
+		}
+	}
+	return &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 10 * time.Second,
+			MaxIdleConns:        10,
+			IdleConnTimeout:     90 * time.Second,
+		},
+	}
+}
@@ -0,0 +1,67 @@
+// webhook/address_test.go
+package webhook
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPublic(t *testing.T) {
+	for address, want := range map[string]bool{
+		"93.184.216.34":    true,
+		"2606:4700::1111":  true,
+		"127.0.0.1":        false,
+		"::1":              false,
+		"10.1.2.3":         false,
+		"172.16.0.1":       false,
+		"192.168.1.1":      false,
+		"169.254.169.254":  false,
+		"fe80::1":          false,
+		"fd00::1":          false,
+		"100.64.0.1":       false,
+		"0.0.0.0":          false,
+		"::ffff:127.0.0.1": false,
+	} {
+		if got := public(net.ParseIP(address)); got != want {
+			t.Errorf("public(%s) = %v, want %v", address, got, want)
+		}
+	}
+}
+
+func TestValidateRefusesInternalURLs(t *testing.T) {
+	ctx := context.Background()
+	for _, url := range []string{
+		"http://127.0.0.1:8080/hook",
+		"http://[::1]/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://localhost/hook",
+	} {
+		endpoint := Endpoint{URL: url, Events: "*"}
+		if err := validate(ctx, endpoint, false); err == nil {
+			t.Errorf("validate(%s) succeeded", url)
+		}
+		if err := validate(ctx, endpoint, true); err != nil {
+			t.Errorf("validate(%s) with private addresses allowed = %v", url, err)
+		}
+	}
+	if err := validate(ctx, Endpoint{URL: "https://93.184.216.34/hook", Events: "*"}, false); err != nil {
+		t.Errorf("validate of a public address = %v", err)
+	}
+}
+
+func TestClientRefusesInternalAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	if _, err := newClient(false).Get(server.URL); err == nil {
+		t.Error("the delivery client connected to a loopback address")
+	}
+	res, err := newClient(true).Get(server.URL)
+	if err != nil {
+		t.Fatalf("with private addresses allowed: %v", err)
+	}
+	res.Body.Close()
+}
@@ -0,0 +1,289 @@
+// webhook/handlers.go
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/imadbg01/go-todo/auth"
+	"github.com/imadbg01/go-todo/config"
+	"github.com/imadbg01/go-todo/openapi"
+	"github.com/imadbg01/go-todo/outbox"
+	"github.com/imadbg01/go-todo/todo"
+	"github.com/jinzhu/gorm"
+)
+
+var eventTypes = []string{
+	todo.EventCreated,
+	todo.EventUpdated,
+	todo.EventDeleted,
+	todo.EventRestored,
+	todo.EventStatusChanged,
+}
+
+type WebhookHandler struct {
+	repository   *WebhookRepository
+	allowPrivate bool
+}
+
+func (handler *WebhookHandler) GetAll(c *fiber.Ctx) error {
+	endpoints := handler.repository.FindAll(auth.Actor(c))
+	for i := range endpoints {
+		endpoints[i].Secret = ""
+	}
+	return c.JSON(endpoints)
+}
+
+func (handler *WebhookHandler) Get(c *fiber.Ctx) error {
+	endpoint, err := handler.endpoint(c)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status": 404,
+			"error":  err.Error(),
+		})
+	}
+
+	endpoint.Secret = ""
+	return c.JSON(endpoint)
+}
+
+// Create registers an endpoint. Its signing secret is only returned here.
+func (handler *WebhookHandler) Create(c *fiber.Ctx) error {
+	data := new(Endpoint)
+
+	if err := c.BodyParser(data); err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Review your input", "error": err.Error()})
+	}
+
+	if err := validate(c.Context(), *data, handler.allowPrivate); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  400,
+			"message": "Invalid webhook",
+			"error":   err.Error(),
+		})
+	}
+
+	endpoint, err := handler.repository.Create(Endpoint{
+		Owner:  auth.Actor(c),
+		URL:    data.URL,
+		Secret: data.Secret,
+		Events: data.Events,
+	})
+
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  400,
+			"message": "Failed creating webhook",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(201).JSON(endpoint)
+}
+
+func (handler *WebhookHandler) Update(c *fiber.Ctx) error {
+	endpoint, err := handler.endpoint(c)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"message": "Webhook not found",
+		})
+	}
+
+	// Active is left as it is when the update does not mention it.
+	data := new(struct {
+		Endpoint
+		Active *bool `json:"active"`
+	})
+
+	if err := c.BodyParser(data); err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Review your input", "error": err.Error()})
+	}
+
+	if err := validate(c.Context(), data.Endpoint, handler.allowPrivate); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  400,
+			"message": "Invalid webhook",
+			"error":   err.Error(),
+		})
+	}
+
+	endpoint.URL = data.URL
+	endpoint.Events = data.Events
+	if data.Active != nil {
+		if *data.Active && !endpoint.Active {
+			endpoint.Failures = 0
+			endpoint.DisabledAt = nil
+		}
+		endpoint.Active = *data.Active
+	}
+
+	endpoint, err = handler.repository.Save(endpoint)
+
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"message": "Error updating webhook",
+			"error":   err.Error(),
+		})
+	}
+
+	endpoint.Secret = ""
+	return c.JSON(endpoint)
+}
+
+func (handler *WebhookHandler) Delete(c *fiber.Ctx) error {
+	endpoint, err := handler.endpoint(c)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"message": "Webhook not found",
+		})
+	}
+
+	if err := handler.repository.Delete(endpoint); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  400,
+			"message": "Failed deleting webhook",
+			"error":   err.Error(),
+		})
+	}
+	return c.SendStatus(204)
+}
+
+func (handler *WebhookHandler) Deliveries(c *fiber.Ctx) error {
+	endpoint, err := handler.endpoint(c)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"message": "Webhook not found",
+		})
+	}
+
+	return c.JSON(handler.repository.Deliveries(endpoint.ID, 100))
+}
+
+func (handler *WebhookHandler) Redeliver(c *fiber.Ctx) error {
+	endpoint, err := handler.endpoint(c)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"message": "Webhook not found",
+		})
+	}
+
+	id, _ := strconv.Atoi(c.Params("deliveryId"))
+	delivery, err := handler.repository.FindDelivery(endpoint.ID, id)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	delivery, err = handler.repository.Redeliver(delivery)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  400,
+			"message": "Failed queueing delivery",
+			"error":   err.Error(),
+		})
+	}
+	return c.Status(202).JSON(delivery)
+}
+
+func (handler *WebhookHandler) endpoint(c *fiber.Ctx) (Endpoint, error) {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return Endpoint{}, err
+	}
+	return handler.repository.Find(auth.Actor(c), id)
+}
+
+func validate(ctx context.Context, endpoint Endpoint, allowPrivate bool) error {
+	target, err := url.Parse(endpoint.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return fmt.Errorf("url must be an absolute http or https URL")
+	}
+	if !allowPrivate {
+		if err := checkHost(ctx, target.Hostname()); err != nil {
+			return err
+		}
+	}
+	if strings.TrimSpace(endpoint.Events) == "" {
+		return fmt.Errorf("events is required")
+	}
+	for _, event := range strings.Split(endpoint.Events, ",") {
+		if event = strings.TrimSpace(event); event != "*" && !known(event) {
+			return fmt.Errorf("unknown event type %q", event)
+		}
+	}
+	return nil
+}
+
+func known(eventType string) bool {
+	for _, known := range eventTypes {
+		if known == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+func NewWebhookHandler(repository *WebhookRepository) *WebhookHandler {
+	return &WebhookHandler{
+		repository:   repository,
+		allowPrivate: AllowPrivate(),
+	}
+}
+
+// describe documents the routes of the handler in the OpenAPI document.
+func describe(handler *WebhookHandler) {
+	body := struct {
+		URL    string `json:"url"`
+		Secret string `json:"secret"`
+		Events string `json:"events"`
+		Active bool   `json:"active"`
+	}{}
+
+	openapi.Describe(handler.GetAll, openapi.Doc{Summary: "List your webhooks", Result: []Endpoint{}})
+	openapi.Describe(handler.Get, openapi.Doc{Summary: "Get a webhook", Result: Endpoint{}, Errors: []int{404}})
+	openapi.Describe(handler.Create, openapi.Doc{Summary: "Register a webhook", Body: body, Result: Endpoint{}, Status: 201, Errors: []int{400}})
+	openapi.Describe(handler.Update, openapi.Doc{Summary: "Update a webhook", Body: body, Result: Endpoint{}, Errors: []int{400, 404}})
+	openapi.Describe(handler.Delete, openapi.Doc{Summary: "Delete a webhook", Status: 204, Errors: []int{400, 404}})
+	openapi.Describe(handler.Deliveries, openapi.Doc{Summary: "List the latest deliveries of a webhook", Result: []Delivery{}, Errors: []int{404}})
+	openapi.Describe(handler.Redeliver, openapi.Doc{Summary: "Deliver an event again", Result: Delivery{}, Status: 202, Errors: []int{400, 404}})
+}
+
+func Register(router fiber.Router, database *gorm.DB) {
+	database.AutoMigrate(&Endpoint{}, &Delivery{})
+	webhookRepository := NewWebhookRepository(database)
+	webhookHandler := NewWebhookHandler(webhookRepository)
+	describe(webhookHandler)
+
+	outbox.AddSink(outbox.SinkFunc(func(message outbox.Message) error {
+		if !strings.HasPrefix(message.Topic, "todo.") {
+			return nil
+		}
+		var event todo.Event
+		if err := json.Unmarshal([]byte(message.Payload), &event); err != nil {
+			return err
+		}
+		return webhookRepository.Enqueue(fmt.Sprint(message.ID), event)
+	}))
+
+	interval, err := time.ParseDuration(config.ConfigOr("WEBHOOK_POLL_INTERVAL", "5s"))
+	if err != nil {
+		interval = 5 * time.Second
+	}
+	go NewWorker(webhookRepository, DefaultRetryPolicy, interval).Run(context.Background())
+
+	webhookRouter := router.Group("/webhooks")
+	webhookRouter.Get("/", webhookHandler.GetAll)
+	webhookRouter.Get("/:id", webhookHandler.Get)
+	webhookRouter.Post("/", webhookHandler.Create)
+	webhookRouter.Put("/:id", webhookHandler.Update)
+	webhookRouter.Delete("/:id", webhookHandler.Delete)
+	webhookRouter.Get("/:id/deliveries", webhookHandler.Deliveries)
+	webhookRouter.Post("/:id/deliveries/:deliveryId/redeliver", webhookHandler.Redeliver)
+}
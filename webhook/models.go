@@ -0,0 +1,62 @@
+// webhook/models.go
+package webhook
+
+import (
+	"strings"
+	"time"
+)
+
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+type Endpoint struct {
+	ID         uint       `gorm:"primary_key" json:"id"`
+	Owner      string     `gorm:"Not Null;index" json:"owner"`
+	URL        string     `gorm:"Not Null" json:"url"`
+	Secret     string     `gorm:"Not Null" json:"secret,omitempty"`
+	Events     string     `gorm:"Not Null" json:"events"`
+	Active     bool       `json:"active"`
+	Failures   int        `json:"failures"`
+	DisabledAt *time.Time `json:"disabled_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+func (Endpoint) TableName() string {
+	return "webhook_endpoints"
+}
+
+// Subscribed reports whether the endpoint wants events of the given type.
+func (endpoint Endpoint) Subscribed(eventType string) bool {
+	for _, event := range strings.Split(endpoint.Events, ",") {
+		if event = strings.TrimSpace(event); event == eventType || event == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// Delivery is one event queued for one endpoint. The deliveries table is
+// also the retry queue: pending rows are picked up once NextAttemptAt passes.
+type Delivery struct {
+	ID            uint       `gorm:"primary_key" json:"id"`
+	EndpointID    uint       `gorm:"Not Null;index" json:"endpoint_id"`
+	EventID       string     `gorm:"Not Null;index" json:"event_id"`
+	EventType     string     `gorm:"Not Null" json:"event_type"`
+	Payload       string     `gorm:"type:text" json:"payload"`
+	Status        string     `gorm:"Not Null;index" json:"status"`
+	Attempts      int        `json:"attempts"`
+	NextAttemptAt time.Time  `gorm:"index" json:"next_attempt_at"`
+	LastStatus    int        `json:"last_status"`
+	LastError     string     `json:"last_error"`
+	DeliveredAt   *time.Time `json:"delivered_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+func (Delivery) TableName() string {
+	return "webhook_deliveries"
+}
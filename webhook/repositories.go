@@ -0,0 +1,208 @@
+// webhook/repositories.go
+package webhook
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/imadbg01/go-todo/todo"
+	"github.com/jinzhu/gorm"
+)
+
+type WebhookRepository struct {
+	database *gorm.DB
+}
+
+func (repository *WebhookRepository) FindAll(owner string) []Endpoint {
+	endpoints := []Endpoint{}
+	repository.database.Where("owner = ?", owner).Order("id asc").Find(&endpoints)
+	return endpoints
+}
+
+func (repository *WebhookRepository) Find(owner string, id int) (Endpoint, error) {
+	var endpoint Endpoint
+	err := repository.database.Where("owner = ?", owner).First(&endpoint, id).Error
+	if gorm.IsRecordNotFoundError(err) {
+		err = errors.New("Webhook not found")
+	}
+	return endpoint, err
+}
+
+func (repository *WebhookRepository) Create(endpoint Endpoint) (Endpoint, error) {
+	if endpoint.Secret == "" {
+		endpoint.Secret = randomHex(32)
+	}
+	endpoint.Active = true
+	err := repository.database.Create(&endpoint).Error
+	return endpoint, err
+}
+
+func (repository *WebhookRepository) Save(endpoint Endpoint) (Endpoint, error) {
+	err := repository.database.Save(&endpoint).Error
+	return endpoint, err
+}
+
+func (repository *WebhookRepository) Delete(endpoint Endpoint) error {
+	return repository.database.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("endpoint_id = ?", endpoint.ID).Delete(&Delivery{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&endpoint).Error
+	})
+}
+
+func (repository *WebhookRepository) Deliveries(endpointID uint, limit int) []Delivery {
+	deliveries := []Delivery{}
+	repository.database.Where("endpoint_id = ?", endpointID).Order("id desc").Limit(limit).Find(&deliveries)
+	return deliveries
+}
+
+func (repository *WebhookRepository) FindDelivery(endpointID uint, id int) (Delivery, error) {
+	var delivery Delivery
+	err := repository.database.Where("endpoint_id = ?", endpointID).First(&delivery, id).Error
+	if gorm.IsRecordNotFoundError(err) {
+		err = errors.New("Delivery not found")
+	}
+	return delivery, err
+}
+
+// Redeliver queues a delivery to be sent again straight away, with the
+// full number of attempts of the retry policy.
+func (repository *WebhookRepository) Redeliver(delivery Delivery) (Delivery, error) {
+	err := repository.database.Model(&delivery).Updates(map[string]interface{}{
+		"status":          DeliveryPending,
+		"attempts":        0,
+		"next_attempt_at": time.Now(),
+	}).Error
+	return delivery, err
+}
+
+// Enqueue queues a delivery of the event to every active endpoint
+// subscribed to its type. An event whose ID was queued before is skipped,
+// so the outbox can offer it again.
+func (repository *WebhookRepository) Enqueue(id string, event todo.Event) error {
+	var queued int
+	if err := repository.database.Model(&Delivery{}).Where("event_id = ?", id).Count(&queued).Error; err != nil {
+		return err
+	}
+	if queued > 0 {
+		return nil
+	}
+
+	var endpoints []Endpoint
+	if err := repository.database.Where("active = ?", true).Find(&endpoints).Error; err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(map[string]interface{}{
+		"id":         id,
+		"type":       event.Type,
+		"created_at": time.Now().UTC(),
+		"data":       event,
+	})
+	if err != nil {
+		return err
+	}
+
+	return repository.database.Transaction(func(tx *gorm.DB) error {
+		for _, endpoint := range endpoints {
+			if !endpoint.Subscribed(event.Type) {
+				continue
+			}
+			delivery := Delivery{
+				EndpointID:    endpoint.ID,
+				EventID:       id,
+				EventType:     event.Type,
+				Payload:       string(payload),
+				Status:        DeliveryPending,
+				NextAttemptAt: time.Now(),
+			}
+			if err := tx.Create(&delivery).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Claim leases up to limit due deliveries of active endpoints, so that
+// other replicas polling the same queue skip them until the lease ends.
+func (repository *WebhookRepository) Claim(limit int, lease time.Duration) ([]Delivery, error) {
+	var deliveries []Delivery
+	err := repository.database.Transaction(func(tx *gorm.DB) error {
+		query := tx.
+			Where("status = ? AND next_attempt_at <= ?", DeliveryPending, time.Now()).
+			Where("endpoint_id IN (?)", tx.Table("webhook_endpoints").Select("id").Where("active = ?", true).SubQuery()).
+			Order("next_attempt_at asc").
+			Limit(limit)
+		if tx.Dialect().GetName() == "postgres" {
+			query = query.Set("gorm:query_option", "FOR UPDATE SKIP LOCKED")
+		}
+		if err := query.Find(&deliveries).Error; err != nil {
+			return err
+		}
+
+		for _, delivery := range deliveries {
+			err := tx.Model(&Delivery{}).Where("id = ?", delivery.ID).UpdateColumn("next_attempt_at", time.Now().Add(lease)).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return deliveries, err
+}
+
+// Complete records the outcome of a delivery attempt, scheduling a retry or
+// disabling the endpoint as the policy demands.
+func (repository *WebhookRepository) Complete(delivery Delivery, statusCode int, failure error, policy RetryPolicy) error {
+	now := time.Now()
+	delivery.Attempts++
+	delivery.LastStatus = statusCode
+	delivery.LastError = ""
+
+	return repository.database.Transaction(func(tx *gorm.DB) error {
+		var endpoint Endpoint
+		if err := tx.First(&endpoint, delivery.EndpointID).Error; err != nil {
+			return err
+		}
+
+		if failure == nil {
+			delivery.Status = DeliverySucceeded
+			delivery.DeliveredAt = &now
+			endpoint.Failures = 0
+		} else {
+			delivery.LastError = failure.Error()
+			delivery.Status = DeliveryPending
+			delivery.NextAttemptAt = now.Add(policy.Backoff(delivery.Attempts))
+			if delivery.Attempts >= policy.MaxAttempts {
+				delivery.Status = DeliveryFailed
+			}
+			endpoint.Failures++
+			if endpoint.Failures >= policy.DisableAfter && endpoint.Active {
+				endpoint.Active = false
+				endpoint.DisabledAt = &now
+			}
+		}
+
+		if err := tx.Save(&delivery).Error; err != nil {
+			return err
+		}
+		return tx.Save(&endpoint).Error
+	})
+}
+
+func randomHex(n int) string {
+	buf := make([]byte, n)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+func NewWebhookRepository(database *gorm.DB) *WebhookRepository {
+	return &WebhookRepository{
+		database: database,
+	}
+}
@@ -0,0 +1,126 @@
+// webhook/worker.go
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"time"
+)
+
+// RetryPolicy decides how failed deliveries are retried.
+type RetryPolicy struct {
+	BaseDelay    time.Duration
+	MaxDelay     time.Duration
+	MaxAttempts  int
+	DisableAfter int
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	BaseDelay:    30 * time.Second,
+	MaxDelay:     6 * time.Hour,
+	MaxAttempts:  10,
+	DisableAfter: 20,
+}
+
+// Backoff returns the delay before the attempt following the given number
+// of failed attempts, doubling each time up to MaxDelay.
+func (policy RetryPolicy) Backoff(attempts int) time.Duration {
+	delay := policy.BaseDelay
+	for i := 1; i < attempts && delay < policy.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > policy.MaxDelay {
+		delay = policy.MaxDelay
+	}
+	return delay
+}
+
+// Worker sends queued deliveries. Several replicas can run a worker against
+// the same database.
+type Worker struct {
+	repository *WebhookRepository
+	client     *http.Client
+	policy     RetryPolicy
+	interval   time.Duration
+	batch      int
+}
+
+func (worker *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(worker.interval)
+	defer ticker.Stop()
+
+	for {
+		worker.drain()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (worker *Worker) drain() {
+	for {
+		deliveries, err := worker.repository.Claim(worker.batch, 2*worker.client.Timeout)
+		if err != nil {
+			log.Printf("webhook: failed claiming deliveries: %v", err)
+			return
+		}
+		for _, delivery := range deliveries {
+			status, err := worker.send(delivery)
+			if err := worker.repository.Complete(delivery, status, err, worker.policy); err != nil {
+				log.Printf("webhook: failed recording delivery %d: %v", delivery.ID, err)
+			}
+		}
+		if len(deliveries) < worker.batch {
+			return
+		}
+	}
+}
+
+func (worker *Worker) send(delivery Delivery) (int, error) {
+	var endpoint Endpoint
+	if err := worker.repository.database.First(&endpoint, delivery.EndpointID).Error; err != nil {
+		return 0, err
+	}
+
+	payload := []byte(delivery.Payload)
+	req, err := http.NewRequest(http.MethodPost, endpoint.URL, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "go-todo-webhooks")
+	req.Header.Set("X-Webhook-Event", delivery.EventType)
+	req.Header.Set("X-Webhook-Delivery", fmt.Sprint(delivery.ID))
+	req.Header.Set("X-Webhook-Timestamp", fmt.Sprint(now.Unix()))
+	req.Header.Set("X-Webhook-Signature", Sign(endpoint.Secret, now, payload))
+
+	res, err := worker.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	io.Copy(ioutil.Discard, io.LimitReader(res.Body, 64<<10))
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return res.StatusCode, fmt.Errorf("endpoint responded %s", res.Status)
+	}
+	return res.StatusCode, nil
+}
+
+func NewWorker(repository *WebhookRepository, policy RetryPolicy, interval time.Duration) *Worker {
+	return &Worker{
+		repository: repository,
+		client:     newClient(AllowPrivate()),
+		policy:     policy,
+		interval:   interval,
+		batch:      20,
+	}
+}